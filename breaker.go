@@ -0,0 +1,152 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a peer whose circuit breaker is open.
+var ErrCircuitOpen = errors.New("groupcache: peer circuit breaker is open")
+
+// CircuitBreakerOptions are the configurations of a per-peer circuit
+// breaker.
+type CircuitBreakerOptions struct {
+	// Failures is the number of consecutive failed requests that trips
+	// the breaker.
+	// If blank, it defaults to 5.
+	Failures int
+
+	// Window is the period the consecutive failures must occur within.
+	// A failure arriving after Window has elapsed since the first one
+	// starts a new count.
+	// If blank, failures are counted regardless of when they occur.
+	Window time.Duration
+
+	// Cooldown is how long the breaker stays open before letting a
+	// single probe request through to check whether the peer has
+	// recovered.
+	// If blank, it defaults to 1 second.
+	Cooldown time.Duration
+
+	// MaxCooldown is the longest the breaker stays open. Every failed
+	// probe doubles the cooldown up to MaxCooldown, so a peer that
+	// stays down is probed less and less often; a successful probe
+	// resets it to Cooldown.
+	// If blank, the breaker always stays open for Cooldown.
+	MaxCooldown time.Duration
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type circuitBreaker struct {
+	opts CircuitBreakerOptions
+
+	trips *AtomicInt // counts the times the breaker opens, if not nil
+
+	mu           sync.Mutex
+	state        breakerState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	cooldown     time.Duration // of the current opening
+}
+
+func newCircuitBreaker(opts CircuitBreakerOptions) *circuitBreaker {
+	if opts.Failures <= 0 {
+		opts.Failures = 5
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = time.Second
+	}
+	if opts.MaxCooldown < opts.Cooldown {
+		opts.MaxCooldown = opts.Cooldown
+	}
+	return &circuitBreaker{opts: opts, cooldown: opts.Cooldown}
+}
+
+// allow reports whether a request may be sent. Once the cooldown has
+// elapsed on an open breaker exactly one caller is allowed through as
+// the half-open probe.
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case breakerOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return false
+		}
+		cb.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// A probe is already in flight.
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of a request. It is safe
+// to call on a nil breaker. Failures caused by the caller's own context
+// ending are not held against the peer.
+func (cb *circuitBreaker) record(ctx context.Context, err error) {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if err == nil {
+		cb.state = breakerClosed
+		cb.failures = 0
+		cb.cooldown = cb.opts.Cooldown
+		return
+	}
+	if ctx != nil && ctx.Err() != nil {
+		if cb.state == breakerHalfOpen {
+			// Let another caller probe.
+			cb.state = breakerOpen
+		}
+		return
+	}
+
+	now := time.Now()
+	if cb.state == breakerHalfOpen {
+		cb.state = breakerOpen
+		cb.openedAt = now
+		if cb.cooldown *= 2; cb.cooldown > cb.opts.MaxCooldown {
+			cb.cooldown = cb.opts.MaxCooldown
+		}
+		return
+	}
+	if cb.failures == 0 || (cb.opts.Window > 0 && now.Sub(cb.firstFailure) > cb.opts.Window) {
+		cb.failures = 0
+		cb.firstFailure = now
+	}
+	cb.failures++
+	if cb.failures >= cb.opts.Failures {
+		cb.state = breakerOpen
+		cb.openedAt = now
+		cb.failures = 0
+		if cb.trips != nil {
+			cb.trips.Add(1)
+		}
+	}
+}
+
+// open reports whether the breaker currently fails requests fast. It is
+// safe to call on a nil breaker.
+func (cb *circuitBreaker) open() bool {
+	if cb == nil {
+		return false
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state != breakerClosed
+}
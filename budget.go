@@ -0,0 +1,232 @@
+package groupcache
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A MemoryBudget caps the bytes cached by the groups given it as
+// GroupOptions.MemoryBudget, across their main and hot caches, so that
+// groups sized independently cannot together overshoot the memory of
+// the process. The cap is shared between the groups by how much their
+// caches are used: Rebalance, called every interval by Run, gives the
+// groups that served more cache hits since the last call a larger
+// share, and every group at least MinShare of an even share. The
+// cacheBytes a group is created with bounds its share.
+type MemoryBudget struct {
+	total int64
+	opts  MemoryBudgetOptions
+
+	mu     sync.Mutex
+	groups map[*Group]*budgetShare
+
+	// Rebalances counts the calls of Rebalance that changed a share.
+	Rebalances AtomicInt
+}
+
+// MemoryBudgetOptions configures a MemoryBudget.
+type MemoryBudgetOptions struct {
+	// Interval is the time between two calls of Rebalance by Run.
+	// If blank, it defaults to 1 minute.
+	Interval time.Duration
+
+	// MinShare is the fraction of an even share of the budget every
+	// group keeps, however little its cache is used, between 0 and 1.
+	// If blank, it defaults to 0.25.
+	MinShare float64
+}
+
+// budgetShare is the part of a MemoryBudget given to a group.
+type budgetShare struct {
+	bytes  int64
+	weight float64 // cache hits of the group in the last interval
+	hits   int64   // CacheHits of the group at the last Rebalance
+}
+
+// NewMemoryBudget returns a MemoryBudget of totalBytes. opts may be nil.
+func NewMemoryBudget(totalBytes int64, opts *MemoryBudgetOptions) *MemoryBudget {
+	b := &MemoryBudget{total: totalBytes, groups: make(map[*Group]*budgetShare)}
+	if opts != nil {
+		b.opts = *opts
+	}
+	if b.opts.Interval <= 0 {
+		b.opts.Interval = time.Minute
+	}
+	if b.opts.MinShare <= 0 || b.opts.MinShare > 1 {
+		b.opts.MinShare = 0.25
+	}
+	return b
+}
+
+// register adds g to the budget, with the average weight of the groups
+// already in it, and shares the budget again.
+func (b *MemoryBudget) register(g *Group) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	weight := 1.0
+	if len(b.groups) > 0 {
+		var sum float64
+		for _, s := range b.groups {
+			sum += s.weight
+		}
+		weight = sum / float64(len(b.groups))
+	}
+	b.groups[g] = &budgetShare{weight: weight, hits: g.Stats.CacheHits.Get()}
+	b.share()
+}
+
+// unregister removes g from the budget and gives its share to the
+// other groups.
+func (b *MemoryBudget) unregister(g *Group) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.groups[g]; ok {
+		delete(b.groups, g)
+		b.share()
+	}
+}
+
+// Run calls Rebalance every MemoryBudgetOptions.Interval until ctx is
+// done. It blocks, so it is usually run in its own goroutine.
+func (b *MemoryBudget) Run(ctx context.Context) {
+	t := time.NewTicker(b.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		b.Rebalance()
+	}
+}
+
+// Rebalance shares the budget between its groups by the cache hits
+// each served since the last call.
+func (b *MemoryBudget) Rebalance() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var total float64
+	for g, s := range b.groups {
+		hits := g.Stats.CacheHits.Get()
+		s.weight = float64(hits - s.hits)
+		s.hits = hits
+		total += s.weight
+	}
+	if total == 0 {
+		// No traffic to go by: keep the shares.
+		return
+	}
+	if b.share() {
+		b.Rebalances.Add(1)
+	}
+}
+
+// Shares returns the bytes each group of the budget may cache, by the
+// name of the group, as "namespace/name" for groups with a namespace.
+func (b *MemoryBudget) Shares() map[string]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	shares := make(map[string]int64, len(b.groups))
+	for g, s := range b.groups {
+		name := g.name
+		if ns := g.Namespace(); ns != "" {
+			name = ns + "/" + name
+		}
+		shares[name] = s.bytes
+	}
+	return shares
+}
+
+// share computes the share of every group from its weight and applies
+// it, and reports whether a share changed. b.mu must be held.
+func (b *MemoryBudget) share() bool {
+	if len(b.groups) == 0 {
+		return false
+	}
+	type entry struct {
+		g      *Group
+		s      *budgetShare
+		target int64
+	}
+	entries := make([]*entry, 0, len(b.groups))
+	for g, s := range b.groups {
+		entries = append(entries, &entry{g: g, s: s})
+	}
+
+	// Every group gets its floor, then the rest of the budget goes
+	// by weight to the groups below the cacheBytes they were created
+	// with, until it is spent or every group is full.
+	floor := int64(b.opts.MinShare * float64(b.total) / float64(len(entries)))
+	left := b.total
+	for _, e := range entries {
+		e.target = floor
+		if max := e.g.budgetMax; max > 0 && e.target > max {
+			e.target = max
+		}
+		left -= e.target
+	}
+	for left > 0 {
+		var weights float64
+		var open []*entry
+		for _, e := range entries {
+			if max := e.g.budgetMax; max <= 0 || e.target < max {
+				open = append(open, e)
+				weights += e.s.weight + 1
+			}
+		}
+		if len(open) == 0 {
+			break
+		}
+		spent := int64(0)
+		for i, e := range open {
+			add := int64(float64(left) * (e.s.weight + 1) / weights)
+			if i == len(open)-1 {
+				// The bytes lost to rounding.
+				add = left - spent
+			}
+			if max := e.g.budgetMax; max > 0 && e.target+add > max {
+				add = max - e.target
+			}
+			e.target += add
+			spent += add
+		}
+		if spent == 0 {
+			break
+		}
+		left -= spent
+	}
+
+	// Shrink first, so the groups never hold more than the budget
+	// together.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].target-entries[i].s.bytes < entries[j].target-entries[j].s.bytes
+	})
+	var changed bool
+	for _, e := range entries {
+		if e.target == e.s.bytes {
+			continue
+		}
+		e.s.bytes = e.target
+		e.g.setBudget(e.target)
+		changed = true
+	}
+	return changed
+}
+
+// setBudget limits the caches of g to bytes, split between the main and
+// hot caches as by HotCacheRatio, or with the hot cache taking up to
+// an eighth of the main cache.
+func (g *Group) setBudget(bytes int64) {
+	ratio := g.opts.HotCacheRatio
+	if ratio <= 0 || ratio >= 1 {
+		ratio = 1.0 / 9
+	}
+	if g.opts.DisableHotCache {
+		ratio = 0
+	}
+	hot := int64(ratio * float64(bytes))
+	g.SetCacheBytes(bytes-hot, hot)
+}
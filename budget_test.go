@@ -0,0 +1,62 @@
+package groupcache
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	budget := NewMemoryBudget(1000, nil)
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(strings.Repeat("x", 40), time.Time{})
+	})
+	a := newGroupOpts("TestMemoryBudget-a", 0, getter, NoPeers{}, &GroupOptions{MemoryBudget: budget})
+	defer DeregisterGroup("TestMemoryBudget-a")
+	b := newGroupOpts("TestMemoryBudget-b", 0, getter, NoPeers{}, &GroupOptions{MemoryBudget: budget})
+	defer DeregisterGroup("TestMemoryBudget-b")
+
+	limit := func(g *Group) int64 {
+		main, hot := g.CacheBytes()
+		return main + hot
+	}
+	if la, lb := limit(a), limit(b); la != 500 || lb != 500 {
+		t.Fatalf("limits = %d, %d; want an even split of 500", la, lb)
+	}
+
+	// Both groups fill their caches, but only a is read again.
+	var s string
+	for i := 0; i < 50; i++ {
+		a.Get(dummyCtx, strconv.Itoa(i), StringSink(&s))
+		b.Get(dummyCtx, strconv.Itoa(i), StringSink(&s))
+	}
+	for i := 0; i < 100; i++ {
+		a.Get(dummyCtx, "49", StringSink(&s))
+	}
+	budget.Rebalance()
+	if la, lb := limit(a), limit(b); la+lb > 1000 || la < 800 || lb > 150 {
+		t.Errorf("limits after Rebalance = %d, %d; want most for a and about the 125 floor for b", la, lb)
+	}
+	if used := a.CacheStats(MainCache).Bytes + a.CacheStats(HotCache).Bytes + b.CacheStats(MainCache).Bytes + b.CacheStats(HotCache).Bytes; used > 1000 {
+		t.Errorf("groups cache %d bytes; want at most the budget of 1000", used)
+	}
+	if n := budget.Rebalances.Get(); n != 1 {
+		t.Errorf("Rebalances = %d; want 1", n)
+	}
+
+	// The cacheBytes of a group bound its share; deregistering it
+	// gives its share back.
+	c := newGroupOpts("TestMemoryBudget-c", 100, getter, NoPeers{}, &GroupOptions{MemoryBudget: budget})
+	if lc := limit(c); lc != 100 {
+		t.Errorf("limit of c = %d; want its cacheBytes of 100", lc)
+	}
+	if la, lb := limit(a), limit(b); la+lb+100 > 1000 {
+		t.Errorf("limits with c = %d, %d; want at most 900 together", la, lb)
+	}
+	DeregisterGroup("TestMemoryBudget-c")
+	if shares := budget.Shares(); len(shares) != 2 || shares["TestMemoryBudget-a"]+shares["TestMemoryBudget-b"] != 1000 {
+		t.Errorf("Shares after deregistering c = %v; want all of the budget for a and b", shares)
+	}
+}
@@ -0,0 +1,48 @@
+package groupcache
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// Buffers are pooled in power of two size classes from 64 bytes to
+// 1 MiB. Larger buffers are allocated and left to the garbage collector.
+const (
+	minPoolShift = 6
+	maxPoolShift = 20
+)
+
+// A BufferPool recycles the byte slices handed out by
+// PooledByteSliceSink, which cuts the garbage produced by a node
+// serving many small values. The zero value is ready to use; a
+// BufferPool must not be copied after first use.
+type BufferPool struct {
+	classes [maxPoolShift - minPoolShift + 1]sync.Pool
+}
+
+// Get returns a slice of length n, reusing a pooled buffer if one is
+// free.
+func (p *BufferPool) Get(n int) []byte {
+	shift := bits.Len(uint(n - 1))
+	if n <= 0 || shift > maxPoolShift {
+		return make([]byte, n)
+	}
+	if shift < minPoolShift {
+		shift = minPoolShift
+	}
+	if b, ok := p.classes[shift-minPoolShift].Get().(*[]byte); ok {
+		return (*b)[:n]
+	}
+	return make([]byte, n, 1<<shift)
+}
+
+// Put returns b to the pool for reuse by Get. The caller must not use
+// b, or any slice of it, afterwards.
+func (p *BufferPool) Put(b []byte) {
+	shift := bits.Len(uint(cap(b))) - 1
+	if shift < minPoolShift || shift > maxPoolShift {
+		return
+	}
+	b = b[:0]
+	p.classes[shift-minPoolShift].Put(&b)
+}
@@ -0,0 +1,54 @@
+package groupcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBufferPool(t *testing.T) {
+	var p BufferPool
+	for _, tc := range []struct{ n, cap int }{
+		{0, 0},
+		{1, 64},
+		{64, 64},
+		{65, 128},
+		{1 << 20, 1 << 20},
+		{1<<20 + 1, 1<<20 + 1},
+	} {
+		b := p.Get(tc.n)
+		if len(b) != tc.n || cap(b) != tc.cap {
+			t.Errorf("Get(%d) has len %d and cap %d; want %d and %d", tc.n, len(b), cap(b), tc.n, tc.cap)
+		}
+		p.Put(b)
+	}
+	// A slice of another capacity goes to the largest class it fills.
+	p.Put(make([]byte, 100))
+	if b := p.Get(64); cap(b) < 64 {
+		t.Errorf("Get(64) has cap %d", cap(b))
+	}
+}
+
+func TestPooledByteSliceSink(t *testing.T) {
+	const name = "TestPooledByteSliceSink-group"
+	g := newGroup(name, 1<<10, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value-"+key, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(name)
+
+	var pool BufferPool
+	for i := 0; i < 3; i++ {
+		var b []byte
+		if err := g.Get(dummyCtx, "key", PooledByteSliceSink(&b, &pool)); err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "value-key" {
+			t.Fatalf("Get = %q; want %q", b, "value-key")
+		}
+		// Scribbling on a returned buffer must not reach the cache.
+		for j := range b {
+			b[j] = 'x'
+		}
+		pool.Put(b)
+	}
+}
@@ -35,6 +35,17 @@ type ByteView struct {
 	b []byte
 	s string
 	e time.Time
+
+	// gen is the generation of the cache shard the view was added to.
+	gen uint64
+
+	// added is when the view was added to a cache, as Unix
+	// nanoseconds, or zero.
+	added int64
+
+	// cost is what the view counts against cacheBytes in the cache it
+	// was added to, see GroupOptions.Cost.
+	cost int64
 }
 
 // Returns the expire time associated with this view
@@ -42,6 +53,11 @@ func (v ByteView) Expire() time.Time {
 	return v.e
 }
 
+// expired reports whether v has an expiry that has passed.
+func (v ByteView) expired() bool {
+	return !v.e.IsZero() && v.e.Before(time.Now())
+}
+
 // Len returns the view's length.
 func (v ByteView) Len() int {
 	if v.b != nil {
@@ -58,6 +74,17 @@ func (v ByteView) ByteSlice() []byte {
 	return []byte(v.s)
 }
 
+// readOnlyBytes returns the data as a byte slice without copying it
+// unless the view holds a string. Callers must not modify the slice,
+// which may be shared with the cache; it is meant for handing values
+// to encoders that only read them.
+func (v ByteView) readOnlyBytes() []byte {
+	if v.b != nil {
+		return v.b
+	}
+	return []byte(v.s)
+}
+
 // String returns the data as a string, making a copy if necessary.
 func (v ByteView) String() string {
 	if v.b != nil {
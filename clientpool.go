@@ -0,0 +1,47 @@
+package groupcache
+
+import "errors"
+
+// ErrNoPeers is returned by the Gets of a group using a ClientPool
+// while the pool has no peer to send them to.
+var ErrNoPeers = errors.New("groupcache: no peer to load the key from")
+
+// A ClientPool reads the groups of a cluster of GRPCPool peers without
+// joining it, for stateless frontends: it has no address, owns no keys
+// and serves no peer traffic, so every Get of a group given it as
+// GroupOptions.Peers is sent to the owner of the key, or its failover
+// peers. The getter of such a group is never called: Gets fail with
+// ErrNoPeers while the pool has no peers, and with the error of the
+// last peer when none answers. Give the group a cacheBytes of zero to
+// keep no values in the process, or a small one to mirror hot keys.
+//
+// The methods of GRPCPool serving peers are not meant to be used; in
+// particular the pool must not be registered on a grpc.Server.
+type ClientPool struct {
+	*GRPCPool
+}
+
+// NewClientPool creates a ClientPool, whose peers are then given with
+// Set, UpdatePeers or Discover. It is not registered as the default
+// PeerPicker. GRPCPoolOptions.Gossip, which requires an address, is
+// ignored.
+func NewClientPool(opts *GRPCPoolOptions) *ClientPool {
+	var o GRPCPoolOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Gossip != nil {
+		pickLogger(o.Logger).Warn("GRPCPoolOptions.Gossip is ignored by a ClientPool")
+		o.Gossip = nil
+	}
+	return &ClientPool{newGRPCPool("", &o)}
+}
+
+// remoteOnly marks a ClientPool, whose groups never load keys locally.
+func (*ClientPool) remoteOnly() {}
+
+// remoteOnlyPicker is a PeerPicker whose groups only load keys from
+// peers, see ClientPool.
+type remoteOnlyPicker interface {
+	remoteOnly()
+}
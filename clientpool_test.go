@@ -0,0 +1,41 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClientPool(t *testing.T) {
+	addr, stop := startTestPeer(t, &echoPeer{})
+	client := NewClientPool(nil)
+	client.Set(addr)
+	defer client.Set()
+
+	const name = "TestClientPool-group"
+	g := newGroupOpts(name, 0, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		t.Errorf("getter of a ClientPool group called for %q", key)
+		return dest.SetString("local", time.Time{})
+	}), client, nil)
+	defer DeregisterGroup(name)
+
+	var s string
+	for i := 0; i < 2; i++ {
+		if err := g.Get(context.Background(), "key", StringSink(&s)); err != nil || s != "got:key" {
+			t.Fatalf("Get = %q, %v; want got:key", s, err)
+		}
+	}
+	if n := g.CacheStats(MainCache).Items + g.CacheStats(HotCache).Items; n != 0 {
+		t.Errorf("%d items cached by a group of no cache bytes", n)
+	}
+
+	stop()
+	if err := g.Get(context.Background(), "other", StringSink(&s)); err == nil {
+		t.Error("Get from a stopped peer succeeded")
+	}
+	client.Set()
+	if err := g.Get(context.Background(), "other", StringSink(&s)); !errors.Is(err, ErrNoPeers) {
+		t.Errorf("Get without peers = %v; want %v", err, ErrNoPeers)
+	}
+}
@@ -0,0 +1,341 @@
+// Command groupcachectl manages a running cluster of GRPCPool peers
+// with the RPCs of the gcgrpc peer service, so operators need not write
+// one-off programs to change the peers of a node, look at a key or
+// purge a group:
+//
+//	groupcachectl -addr 10.0.0.1:8080 peers
+//	groupcachectl -addr 10.0.0.1:8080 -all set-peers 10.0.0.1:8080 10.0.0.2:8080
+//	groupcachectl -addr 10.0.0.1:8080 get users 42
+//	groupcachectl -addr 10.0.0.1:8080 -all delete-prefix users 4
+//	groupcachectl -addr 10.0.0.1:8080 stats users
+//
+// Commands act on the node given by -addr. With -all, the commands
+// changing peers or removing values act on every peer of that node as
+// well, as each node keeps its own peer list and caches. The peers set
+// or added that the node did not have are sent its new peers as well.
+// Run groupcachectl -h for the list of commands and flags.
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/adistroy/groupcache/v3"
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const usage = `Usage: groupcachectl [flags] <command> [arguments]
+
+Commands:
+  peers                       print the topology version and peers of the node
+  set-peers SPEC...           replace the peers of the node; -empty removes all
+  add-peers SPEC...           add peers to the node
+  remove-peers SPEC...        remove peers from the node
+  get GROUP KEY               get the value of KEY in GROUP through the node
+  delete GROUP KEY            remove KEY from the caches of GROUP
+  delete-prefix GROUP PREFIX  remove the keys of GROUP starting with PREFIX
+  flush GROUP                 remove every value cached by GROUP
+  bump GROUP                  make every value cached by GROUP stale
+  stats [GROUP]               print the statistics of the node as JSON
+
+Flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// options are the flags of groupcachectl.
+type options struct {
+	addr       string
+	namespace  string
+	timeout    time.Duration
+	token      string
+	all        bool
+	empty      bool
+	version    uint64
+	caFile     string
+	certFile   string
+	keyFile    string
+	serverName string
+}
+
+// run runs groupcachectl with args, and returns its exit status.
+func run(args []string, stdout, stderr io.Writer) int {
+	var o options
+	fs := flag.NewFlagSet("groupcachectl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&o.addr, "addr", "localhost:8080", "address of the node")
+	fs.StringVar(&o.namespace, "namespace", "", "namespace of the groups, see GRPCPoolOptions.Namespace")
+	fs.DurationVar(&o.timeout, "timeout", 10*time.Second, "timeout of the command")
+	fs.StringVar(&o.token, "token", os.Getenv("GROUPCACHE_TOKEN"), "peer token, see PeerAuth (default $GROUPCACHE_TOKEN)")
+	fs.BoolVar(&o.all, "all", false, "also run peer and removal commands on every peer of the node")
+	fs.BoolVar(&o.empty, "empty", false, "allow set-peers without SPEC, removing every peer of the node")
+	fs.Uint64Var(&o.version, "version", 0, "topology version of a peer change, see GRPCPool.TopologyVersion")
+	fs.StringVar(&o.caFile, "tls-ca", "", "PEM file of the CAs verifying the nodes; enables TLS")
+	fs.StringVar(&o.certFile, "tls-cert", "", "PEM file of the client certificate, for mutual TLS")
+	fs.StringVar(&o.keyFile, "tls-key", "", "PEM file of the key of -tls-cert")
+	fs.StringVar(&o.serverName, "tls-server-name", "", "name the certificates of the nodes are verified against")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+	if err := command(ctx, &o, fs.Arg(0), fs.Args()[1:], stdout); err != nil {
+		fmt.Fprintf(stderr, "groupcachectl: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fs.Usage()
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// errUsage is returned for a command with wrong arguments.
+var errUsage = errors.New("wrong command or arguments")
+
+// command runs the command name with args.
+func command(ctx context.Context, o *options, name string, args []string, stdout io.Writer) error {
+	nargs := map[string][2]int{ // least and most arguments, -1 for any
+		"peers":         {0, 0},
+		"set-peers":     {1, -1},
+		"add-peers":     {1, -1},
+		"remove-peers":  {1, -1},
+		"get":           {2, 2},
+		"delete":        {2, 2},
+		"delete-prefix": {2, 2},
+		"flush":         {1, 1},
+		"bump":          {1, 1},
+		"stats":         {0, 1},
+	}
+	n, ok := nargs[name]
+	if name == "set-peers" && o.empty {
+		n = [2]int{0, 0}
+	}
+	if !ok || len(args) < n[0] || (n[1] >= 0 && len(args) > n[1]) {
+		return fmt.Errorf("%w: %s %v", errUsage, name, args)
+	}
+
+	c, err := dial(o, o.addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	client := gcgrpc.NewPeerClient(c)
+
+	switch name {
+	case "peers":
+		topology, err := client.GetTopology(ctx, &gcgrpc.GetTopologyRequest{})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "version %d\n", topology.Version)
+		for _, spec := range topology.PeerAddr {
+			fmt.Fprintln(stdout, spec)
+		}
+		return nil
+
+	case "get":
+		resp, err := client.Retrieve(ctx, &gcgrpc.RetrieveRequest{Group: args[0], Namespace: o.namespace, Key: []byte(args[1])})
+		if err != nil {
+			return err
+		}
+		_, err = stdout.Write(resp.Value)
+		return err
+
+	case "stats":
+		req := &gcgrpc.GetStatsRequest{}
+		if len(args) == 1 {
+			req.Group = args[0]
+		}
+		resp, err := client.GetStats(ctx, req)
+		if err != nil {
+			return err
+		}
+		var out bytes.Buffer
+		if err := json.Indent(&out, resp.Report, "", "  "); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		_, err = out.WriteTo(stdout)
+		return err
+	}
+
+	// The other commands act on every peer with -all.
+	var do func(client gcgrpc.PeerClient) error
+	switch name {
+	case "set-peers":
+		do = func(client gcgrpc.PeerClient) error {
+			_, err := client.SetPeers(ctx, &gcgrpc.Peers{PeerAddr: args, Version: o.version})
+			return err
+		}
+	case "add-peers":
+		do = func(client gcgrpc.PeerClient) error {
+			_, err := client.AddPeers(ctx, &gcgrpc.Peers{PeerAddr: args, Version: o.version})
+			return err
+		}
+	case "remove-peers":
+		do = func(client gcgrpc.PeerClient) error {
+			_, err := client.RemovePeers(ctx, &gcgrpc.Peers{PeerAddr: args, Version: o.version})
+			return err
+		}
+	case "delete":
+		do = func(client gcgrpc.PeerClient) error {
+			_, err := client.Delete(ctx, &gcgrpc.DeleteRequest{Group: args[0], Namespace: o.namespace, Key: []byte(args[1])})
+			return err
+		}
+	case "delete-prefix":
+		do = func(client gcgrpc.PeerClient) error {
+			_, err := client.DeletePrefix(ctx, &gcgrpc.DeletePrefixRequest{Group: args[0], Namespace: o.namespace, Prefix: []byte(args[1])})
+			return err
+		}
+	case "flush":
+		do = func(client gcgrpc.PeerClient) error {
+			_, err := client.Flush(ctx, &gcgrpc.FlushRequest{Group: args[0], Namespace: o.namespace})
+			return err
+		}
+	case "bump":
+		do = func(client gcgrpc.PeerClient) error {
+			_, err := client.BumpGeneration(ctx, &gcgrpc.BumpGenerationRequest{Group: args[0], Namespace: o.namespace})
+			return err
+		}
+	}
+	if !o.all {
+		return do(client)
+	}
+	var joining []string
+	join := do
+	switch name {
+	case "set-peers":
+		joining = args
+	case "add-peers":
+		// Peers joining through add-peers get all the peers of the node.
+		joining = args
+		join = func(peer gcgrpc.PeerClient) error {
+			topology, err := client.GetTopology(ctx, &gcgrpc.GetTopologyRequest{})
+			if err != nil {
+				return err
+			}
+			_, err = peer.SetPeers(ctx, &gcgrpc.Peers{PeerAddr: topology.PeerAddr, Version: topology.Version})
+			return err
+		}
+	}
+	return everyPeer(ctx, o, client, do, joining, join)
+}
+
+// everyPeer calls do with the node and then with every other peer the
+// node had before, and join with the peers of joining it did not have,
+// reporting the peers that failed.
+func everyPeer(ctx context.Context, o *options, node gcgrpc.PeerClient, do func(gcgrpc.PeerClient) error, joining []string, join func(gcgrpc.PeerClient) error) error {
+	topology, err := node.GetTopology(ctx, &gcgrpc.GetTopologyRequest{})
+	if err != nil {
+		return err
+	}
+	if err := do(node); err != nil {
+		return fmt.Errorf("%s: %w", o.addr, err)
+	}
+	self, _ := groupcache.ParsePeerSpec(o.addr)
+	done := map[string]bool{self.Address(): true}
+	errs := groupcache.PeersError{}
+	for i, spec := range append(topology.PeerAddr, joining...) {
+		s, err := groupcache.ParsePeerSpec(spec)
+		if err != nil {
+			errs[spec] = err
+			continue
+		}
+		addr := s.Address()
+		if done[addr] {
+			continue
+		}
+		done[addr] = true
+		c, err := dial(o, addr)
+		if err != nil {
+			errs[addr] = err
+			continue
+		}
+		f := do
+		if i >= len(topology.PeerAddr) {
+			f = join
+		}
+		if err := f(gcgrpc.NewPeerClient(c)); err != nil {
+			errs[addr] = err
+		}
+		c.Close()
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// dial connects to the node at addr with the TLS and token options.
+func dial(o *options, addr string) (*grpc.ClientConn, error) {
+	var opts []grpc.DialOption
+	if o.caFile == "" {
+		opts = append(opts, grpc.WithInsecure())
+	} else {
+		cfg, err := tlsConfig(o)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(cfg)))
+	}
+	if o.token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials(o.token)))
+	}
+	return grpc.Dial(addr, opts...)
+}
+
+func tlsConfig(o *options) (*tls.Config, error) {
+	pem, err := ioutil.ReadFile(o.caFile)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate in %s", o.caFile)
+	}
+	cfg := &tls.Config{RootCAs: roots, ServerName: o.serverName}
+	if o.certFile != "" || o.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.certFile, o.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// tokenCredentials sends a PeerAuth token with every RPC.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (tokenCredentials) RequireTransportSecurity() bool { return false }
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adistroy/groupcache/v3"
+	"google.golang.org/grpc"
+)
+
+// startNode serves a GRPCPool with a group named group on a local port.
+func startNode(t *testing.T, loads *groupcache.AtomicInt) (addr string, g *groupcache.Group, stop func()) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr = lis.Addr().String()
+	server := grpc.NewServer()
+	pool := groupcache.NewScopedGRPCPool(addr, server, nil)
+	g = groupcache.NewGroupOpts("group", 1<<20, groupcache.GetterFunc(func(_ context.Context, key string, dest groupcache.Sink) error {
+		loads.Add(1)
+		return dest.SetString("value of "+key, time.Time{})
+	}), &groupcache.GroupOptions{Peers: pool})
+	go server.Serve(lis)
+	return addr, g, func() {
+		server.Stop()
+		groupcache.DeregisterGroup("group")
+	}
+}
+
+func TestGroupcachectl(t *testing.T) {
+	var loads groupcache.AtomicInt
+	addr, g, stop := startNode(t, &loads)
+	defer stop()
+
+	ctl := func(args ...string) string {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		if code := run(append([]string{"-addr", addr}, args...), &stdout, &stderr); code != 0 {
+			t.Fatalf("groupcachectl %s exited with %d: %s", strings.Join(args, " "), code, stderr.String())
+		}
+		return stdout.String()
+	}
+
+	ctl("-version", "2", "set-peers", addr)
+	if got, want := ctl("peers"), "version 2\n"+addr+"\n"; got != want {
+		t.Errorf("peers = %q; want %q", got, want)
+	}
+
+	// With -all, a peer joining is sent the change too.
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := lis.Addr().String()
+	server := grpc.NewServer()
+	groupcache.NewScopedGRPCPool(other, server, nil)
+	go server.Serve(lis)
+	defer server.Stop()
+	ctl("-all", "-version", "3", "add-peers", other)
+	if got, want := ctl("-addr", other, "peers"), "version 3\n"; !strings.HasPrefix(got, want) || !strings.Contains(got, addr) {
+		t.Errorf("peers of the joined peer = %q; want version 3 with %s", got, addr)
+	}
+	ctl("-all", "-version", "4", "set-peers", addr)
+
+	if got := ctl("get", "group", "key"); got != "value of key" {
+		t.Errorf("get = %q; want the value of key", got)
+	}
+	ctl("get", "group", "key")
+	if n := loads.Get(); n != 1 {
+		t.Errorf("getter called %d times; want 1", n)
+	}
+	ctl("-all", "delete", "group", "key")
+	ctl("get", "group", "key")
+	if n := loads.Get(); n != 2 {
+		t.Errorf("getter called %d times after delete; want 2", n)
+	}
+	ctl("flush", "group")
+	if n := g.CacheStats(groupcache.MainCache).Items; n != 0 {
+		t.Errorf("main cache holds %d items after flush; want 0", n)
+	}
+
+	var report groupcache.DebugReport
+	if err := json.Unmarshal([]byte(ctl("stats", "group")), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Groups) != 1 || report.Groups[0].Name != "group" || report.Groups[0].Stats.LocalLoads != 2 {
+		t.Errorf("stats = %+v; want the group with 2 local loads", report)
+	}
+}
+
+func TestGroupcachectlErrors(t *testing.T) {
+	var loads groupcache.AtomicInt
+	addr, _, stop := startNode(t, &loads)
+	defer stop()
+
+	for _, tc := range []struct {
+		args []string
+		code int
+	}{
+		{nil, 2},
+		{[]string{"frobnicate"}, 2},
+		{[]string{"get", "group"}, 2},
+		{[]string{"set-peers"}, 2},
+		{[]string{"-empty", "set-peers", addr}, 2},
+		{[]string{"flush", "nogroup"}, 1},
+		{[]string{"stats", "nogroup"}, 1},
+	} {
+		var stdout, stderr bytes.Buffer
+		if code := run(append([]string{"-addr", addr}, tc.args...), &stdout, &stderr); code != tc.code {
+			t.Errorf("groupcachectl %v exited with %d; want %d", tc.args, code, tc.code)
+		}
+	}
+}
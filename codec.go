@@ -0,0 +1,149 @@
+package groupcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// A Marshaler encodes and decodes values of any type, with the
+// signatures of json.Marshal and json.Unmarshal, which most
+// serialization packages, msgpack and CBOR ones included, follow.
+type Marshaler interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// MarshalerFuncs is a Marshaler calling its functions, such as
+// MarshalerFuncs{msgpack.Marshal, msgpack.Unmarshal}.
+type MarshalerFuncs struct {
+	MarshalFunc   func(v interface{}) ([]byte, error)
+	UnmarshalFunc func(data []byte, v interface{}) error
+}
+
+func (m MarshalerFuncs) Marshal(v interface{}) ([]byte, error) { return m.MarshalFunc(v) }
+
+func (m MarshalerFuncs) Unmarshal(data []byte, v interface{}) error {
+	return m.UnmarshalFunc(data, v)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Marshaler{
+		"json": MarshalerFuncs{json.Marshal, json.Unmarshal},
+		"gob":  MarshalerFuncs{gobMarshal, gobUnmarshal},
+	}
+)
+
+func gobMarshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gobUnmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// RegisterCodec makes m available to NamedCodec under name, so the
+// processes of a cluster can agree on the encoding of a group by name.
+// The "json" and "gob" codecs are registered already. It panics if
+// name is registered twice.
+func RegisterCodec(name string, m Marshaler) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if _, dup := codecs[name]; dup {
+		panic("groupcache: RegisterCodec called twice for " + name)
+	}
+	codecs[name] = m
+}
+
+// NamedCodec returns a Codec for values of type T using the Marshaler
+// registered under name with RegisterCodec. It panics if there is none.
+func NamedCodec[T any](name string) Codec[T] {
+	codecsMu.RLock()
+	m, ok := codecs[name]
+	codecsMu.RUnlock()
+	if !ok {
+		panic("groupcache: no codec registered as " + name)
+	}
+	return marshalerCodec[T]{m}
+}
+
+type marshalerCodec[T any] struct{ m Marshaler }
+
+func (c marshalerCodec[T]) Encode(v T) ([]byte, error)     { return c.m.Marshal(v) }
+func (c marshalerCodec[T]) Decode(data []byte, v *T) error { return c.m.Unmarshal(data, v) }
+
+// SetValue encodes v with codec and sets it as the value of dest, for
+// getters of values that are not protobuf messages.
+func SetValue[T any](dest Sink, codec Codec[T], v T, e time.Time) error {
+	data, err := codec.Encode(v)
+	if err != nil {
+		return &CodecError{Op: "encode", Err: err}
+	}
+	if s, ok := dest.(interface {
+		setBytesOwned(b []byte, e time.Time) error
+	}); ok {
+		return s.setBytesOwned(data, e)
+	}
+	return dest.SetBytes(data, e)
+}
+
+// CodecSink returns a Sink that decodes the value it receives into dst
+// with codec. Decoding errors are returned as a *CodecError.
+func CodecSink[T any](codec Codec[T], dst *T) *ValueSink[T] {
+	if dst == nil {
+		panic("nil dst")
+	}
+	return &ValueSink[T]{codec: codec, dst: dst}
+}
+
+// A ValueSink is the Sink returned by CodecSink.
+type ValueSink[T any] struct {
+	codec Codec[T]
+	dst   *T
+	v     ByteView
+}
+
+var _ Sink = &ValueSink[struct{}]{}
+
+// Len returns the size of the encoded value the sink received, which
+// is what it takes in the cache.
+func (s *ValueSink[T]) Len() int {
+	return s.v.Len()
+}
+
+func (s *ValueSink[T]) view() (ByteView, error) {
+	return s.v, nil
+}
+
+func (s *ValueSink[T]) setView(v ByteView) error {
+	if err := s.codec.Decode(v.ByteSlice(), s.dst); err != nil {
+		return &CodecError{Op: "decode", Err: err}
+	}
+	s.v = v
+	return nil
+}
+
+func (s *ValueSink[T]) SetBytes(b []byte, e time.Time) error {
+	return s.setView(ByteView{b: cloneBytes(b), e: e})
+}
+
+func (s *ValueSink[T]) SetString(v string, e time.Time) error {
+	return s.setView(ByteView{s: v, e: e})
+}
+
+func (s *ValueSink[T]) SetProto(m proto.Message, e time.Time) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return s.setView(ByteView{b: b, e: e})
+}
@@ -0,0 +1,71 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCodecSink(t *testing.T) {
+	const name = "TestCodecSink-group"
+	codec := NamedCodec[typedUser]("json")
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if key == "bad" {
+			return dest.SetString("not json", time.Time{})
+		}
+		return SetValue(dest, codec, typedUser{Name: key, Age: 42}, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(name)
+
+	// Once loaded, and again from the cache.
+	for i := 0; i < 2; i++ {
+		var u typedUser
+		sink := CodecSink(codec, &u)
+		if err := g.Get(dummyCtx, "ann", sink); err != nil {
+			t.Fatal(err)
+		}
+		if u != (typedUser{Name: "ann", Age: 42}) {
+			t.Errorf("Get %d = %+v; want ann, 42", i, u)
+		}
+		if want := len(`{"Name":"ann","Age":42}`); sink.Len() != want {
+			t.Errorf("Len = %d; want %d", sink.Len(), want)
+		}
+	}
+
+	var u typedUser
+	var cerr *CodecError
+	if err := g.Get(dummyCtx, "bad", CodecSink(codec, &u)); !errors.As(err, &cerr) || cerr.Op != "decode" {
+		t.Errorf("Get of a value that is not JSON = %v; want a decode CodecError", err)
+	}
+}
+
+func TestRegisterCodec(t *testing.T) {
+	upper := MarshalerFuncs{
+		MarshalFunc: func(v interface{}) ([]byte, error) {
+			return []byte(strings.ToUpper(v.(string))), nil
+		},
+		UnmarshalFunc: func(data []byte, v interface{}) error {
+			*v.(*string) = strings.ToLower(string(data))
+			return nil
+		},
+	}
+	RegisterCodec("TestRegisterCodec-upper", upper)
+	codec := NamedCodec[string]("TestRegisterCodec-upper")
+	data, err := codec.Encode("value")
+	if err != nil || string(data) != "VALUE" {
+		t.Errorf("Encode = %q, %v; want VALUE", data, err)
+	}
+	var s string
+	if err := codec.Decode(data, &s); err != nil || s != "value" {
+		t.Errorf("Decode = %q, %v; want value", s, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NamedCodec of an unregistered codec did not panic")
+		}
+	}()
+	NamedCodec[string]("TestRegisterCodec-none")
+}
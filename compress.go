@@ -0,0 +1,146 @@
+package groupcache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// A Compressor compresses values sent between peers. Peers only use
+// the compressors both of them registered, negotiated by name on every
+// request, so a compressor may be added to a running cluster one peer
+// at a time.
+//
+// Only gzip is registered by default. Faster codecs such as snappy or
+// zstd can be used by registering a Compressor wrapping them.
+type Compressor interface {
+	// Name identifies the compressor on the wire, e.g. "zstd".
+	Name() string
+
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{"gzip": gzipCompressor{}}
+)
+
+// RegisterCompressor makes c available to the Compression options of
+// the pools under c.Name(), replacing any compressor of that name.
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Name()] = c
+}
+
+func getCompressor(name string) Compressor {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	return compressors[name]
+}
+
+// CompressionOptions enable compression of the values a pool and its
+// peers send each other.
+type CompressionOptions struct {
+	// Accept lists the names of the registered compressors this peer
+	// accepts, in order of preference. Peers asked for a value use the
+	// first of them they also registered.
+	// If empty, it defaults to gzip.
+	Accept []string
+
+	// MinSize is the size below which values are sent uncompressed,
+	// as compressing them saves little.
+	// If blank, it defaults to 1024.
+	MinSize int
+}
+
+const defaultCompressionMinSize = 1024
+
+// acceptHeader returns the value sent to peers to announce the
+// compressors o accepts, or "" if o is nil.
+func (o *CompressionOptions) acceptHeader() string {
+	if o == nil {
+		return ""
+	}
+	if len(o.Accept) == 0 {
+		return "gzip"
+	}
+	return strings.Join(o.Accept, ",")
+}
+
+// compress compresses value with the first compressor of accept, a
+// list sent by a peer, that is registered. It returns the name of the
+// compressor, or "" and value itself if value was not compressed. A
+// nil o never compresses.
+func (o *CompressionOptions) compress(accept string, value []byte) (string, []byte) {
+	if o == nil || accept == "" {
+		return "", value
+	}
+	min := o.MinSize
+	if min <= 0 {
+		min = defaultCompressionMinSize
+	}
+	if len(value) < min {
+		return "", value
+	}
+	for _, name := range strings.Split(accept, ",") {
+		// Drop parameters such as the q-values of HTTP Accept-Encoding.
+		name = strings.SplitN(name, ";", 2)[0]
+		c := getCompressor(strings.TrimSpace(name))
+		if c == nil {
+			continue
+		}
+		data, err := c.Compress(value)
+		if err != nil || len(data) >= len(value) {
+			// Send incompressible values as they are.
+			return "", value
+		}
+		return c.Name(), data
+	}
+	return "", value
+}
+
+// decompress undoes compress for a value received with encoding.
+func decompress(encoding string, data []byte) ([]byte, error) {
+	if encoding == "" {
+		return data, nil
+	}
+	c := getCompressor(encoding)
+	if c == nil {
+		return nil, fmt.Errorf("groupcache: value compressed with unknown compressor %q", encoding)
+	}
+	value, err := c.Decompress(data)
+	if err != nil {
+		return nil, fmt.Errorf("groupcache: decompressing %s value: %w", encoding, err)
+	}
+	return value, nil
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Name() string { return "gzip" }
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
@@ -18,7 +18,9 @@ limitations under the License.
 package consistenthash
 
 import (
+	"fmt"
 	"hash/crc32"
+	"math"
 	"sort"
 	"strconv"
 )
@@ -30,6 +32,8 @@ type Map struct {
 	replicas int
 	keys     []int // Sorted
 	hashMap  map[int]string
+	weights  map[string]int
+	points   map[string]int // virtual nodes of each item
 }
 
 func New(replicas int, fn Hash) *Map {
@@ -37,6 +41,8 @@ func New(replicas int, fn Hash) *Map {
 		replicas: replicas,
 		hash:     fn,
 		hashMap:  make(map[int]string),
+		weights:  make(map[string]int),
+		points:   make(map[string]int),
 	}
 	if m.hash == nil {
 		m.hash = crc32.ChecksumIEEE
@@ -44,6 +50,24 @@ func New(replicas int, fn Hash) *Map {
 	return m
 }
 
+// hashSamples is the number of inputs ValidateHash feeds to a hash.
+const hashSamples = 1000
+
+// ValidateHash samples fn over a fixed set of distinct inputs and
+// returns an error if it produces suspiciously few distinct outputs,
+// which would collapse the ring onto a handful of items. It is meant to
+// catch a broken custom hash function at startup.
+func ValidateHash(fn Hash) error {
+	seen := make(map[uint32]struct{}, hashSamples)
+	for i := 0; i < hashSamples; i++ {
+		seen[fn([]byte("consistenthash-sample-"+strconv.Itoa(i)))] = struct{}{}
+	}
+	if len(seen) < hashSamples/2 {
+		return fmt.Errorf("consistenthash: hash function produced %d distinct values for %d distinct inputs", len(seen), hashSamples)
+	}
+	return nil
+}
+
 // Returns true if there are no items available.
 func (m *Map) IsEmpty() bool {
 	return len(m.keys) == 0
@@ -51,8 +75,21 @@ func (m *Map) IsEmpty() bool {
 
 // Adds some keys to the hash.
 func (m *Map) Add(keys ...string) {
+	m.AddWeighted(1, keys...)
+}
+
+// AddWeighted adds some keys to the hash with weight times as many
+// replicas as Add, so each owns about weight times as much of the ring.
+// Use it for instances of different sizes. A weight below one counts
+// as one.
+func (m *Map) AddWeighted(weight int, keys ...string) {
+	if weight < 1 {
+		weight = 1
+	}
 	for _, key := range keys {
-		for i := 0; i < m.replicas; i++ {
+		m.weights[key] = weight
+		m.points[key] = m.replicas * weight
+		for i := 0; i < m.replicas*weight; i++ {
 			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
 			m.keys = append(m.keys, hash)
 			m.hashMap[hash] = key
@@ -61,6 +98,83 @@ func (m *Map) Add(keys ...string) {
 	sort.Ints(m.keys)
 }
 
+// Weight returns the weight key was added with, or zero if it is not in
+// the hash.
+func (m *Map) Weight(key string) int {
+	return m.weights[key]
+}
+
+// Removes some keys from the hash.
+func (m *Map) Remove(keys ...string) {
+	removed := false
+	for _, key := range keys {
+		points, ok := m.points[key]
+		if !ok {
+			continue
+		}
+		delete(m.weights, key)
+		delete(m.points, key)
+		for i := 0; i < points; i++ {
+			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+			if m.hashMap[hash] == key {
+				delete(m.hashMap, hash)
+				removed = true
+			}
+		}
+	}
+	if removed {
+		m.compact()
+	}
+}
+
+// Replicas returns the number of virtual nodes of key on the ring, or
+// zero if it is not in the hash.
+func (m *Map) Replicas(key string) int {
+	return m.points[key]
+}
+
+// SetReplicas changes the number of virtual nodes of key, which must be
+// in the hash already, to n, keeping its weight. Only the keys of the
+// virtual nodes added or removed change owner. Values below one count
+// as one.
+func (m *Map) SetReplicas(key string, n int) {
+	points, ok := m.points[key]
+	if !ok {
+		return
+	}
+	if n < 1 {
+		n = 1
+	}
+	m.points[key] = n
+	for i := points; i < n; i++ {
+		hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+		m.keys = append(m.keys, hash)
+		m.hashMap[hash] = key
+	}
+	if n > points {
+		sort.Ints(m.keys)
+		return
+	}
+	for i := n; i < points; i++ {
+		hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+		if m.hashMap[hash] == key {
+			delete(m.hashMap, hash)
+		}
+	}
+	m.compact()
+}
+
+// compact drops the hashes no longer in hashMap from keys.
+func (m *Map) compact() {
+	kept := m.keys[:0]
+	for _, hash := range m.keys {
+		if _, ok := m.hashMap[hash]; ok {
+			kept = append(kept, hash)
+		}
+	}
+	m.keys = kept
+}
+
 // Gets the closest item in the hash to the provided key.
 func (m *Map) Get(key string) string {
 	if m.IsEmpty() {
@@ -79,3 +193,180 @@ func (m *Map) Get(key string) string {
 
 	return m.hashMap[m.keys[idx]]
 }
+
+// GetN returns up to n distinct items in the order they follow the
+// provided key on the hash ring. The first item is the one Get returns.
+func (m *Map) GetN(key string, n int) []string {
+	if m.IsEmpty() || n <= 0 {
+		return nil
+	}
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
+
+	var res []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(m.keys) && len(res) < n; i++ {
+		item := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !seen[item] {
+			seen[item] = true
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// GetBounded is like Get but implements consistent hashing with bounded
+// loads: given the current load of every item, such as its number of
+// requests in flight, it returns the first item following key on the
+// ring whose load is below its share of the total load, including the
+// new request, times 1+epsilon. Shares are proportional to the weights
+// of the items, so no item takes more than 1+epsilon times its fair
+// load, while keys stay with their owner as long as it is not
+// overloaded.
+func (m *Map) GetBounded(key string, epsilon float64, load func(item string) int) string {
+	if m.IsEmpty() {
+		return ""
+	}
+	if epsilon < 0 {
+		epsilon = 0
+	}
+	total, weights := 1, 0
+	for item, weight := range m.weights {
+		total += load(item)
+		weights += weight
+	}
+
+	hash := int(m.hash([]byte(key)))
+	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
+
+	seen := make(map[string]bool)
+	for i := 0; i < len(m.keys) && len(seen) < len(m.weights); i++ {
+		item := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if seen[item] {
+			continue
+		}
+		seen[item] = true
+		limit := math.Ceil(float64(total) * (1 + epsilon) * float64(m.weights[item]) / float64(weights))
+		if float64(load(item)) < limit {
+			return item
+		}
+	}
+	// Not reached while the loads are consistent, as the limits add up
+	// to more than the total load.
+	return m.Get(key)
+}
+
+// Point is a virtual node of the ring. It owns the hashes of keys above
+// that of the previous point, and the first point also owns those
+// above the last one.
+type Point struct {
+	Hash uint32
+	Item string
+}
+
+// Points returns the virtual nodes of the ring in the order of their
+// hashes, so the owner of a key can be looked up without the Map.
+func (m *Map) Points() []Point {
+	points := make([]Point, len(m.keys))
+	for i, hash := range m.keys {
+		points[i] = Point{uint32(hash), m.hashMap[hash]}
+	}
+	return points
+}
+
+// Clone returns a copy of m that is not affected by later changes to m.
+func (m *Map) Clone() *Map {
+	c := &Map{
+		hash:     m.hash,
+		replicas: m.replicas,
+		keys:     append([]int(nil), m.keys...),
+		hashMap:  make(map[int]string, len(m.hashMap)),
+		weights:  make(map[string]int, len(m.weights)),
+		points:   make(map[string]int, len(m.points)),
+	}
+	for hash, item := range m.hashMap {
+		c.hashMap[hash] = item
+	}
+	for item, weight := range m.weights {
+		c.weights[item] = weight
+	}
+	for item, points := range m.points {
+		c.points[item] = points
+	}
+	return c
+}
+
+// Range is a range of the hashes of keys, from Start exclusive to End
+// inclusive, that changed owner from the item From to the item To. A
+// range whose End is below its Start wraps around the ring. An empty
+// item means the ring was empty.
+type Range struct {
+	Start, End uint32
+	From, To   string
+}
+
+// Size returns the number of hashes in r.
+func (r Range) Size() uint64 {
+	if r.Start == r.End {
+		return 1 << 32
+	}
+	return uint64(r.End - r.Start)
+}
+
+// Diff returns the ranges of the ring owned by another item in next
+// than in prev, in the order of the ring. Both maps must use the same
+// hash function.
+func Diff(prev, next *Map) []Range {
+	points := append(append([]int(nil), prev.keys...), next.keys...)
+	sort.Ints(points)
+	uniq := points[:0]
+	for i, p := range points {
+		if i == 0 || p != points[i-1] {
+			uniq = append(uniq, p)
+		}
+	}
+
+	var res []Range
+	for i, p := range uniq {
+		// No point of either ring lies inside the segment ending at p,
+		// so each ring gives all of it to the owner of p.
+		start := uniq[len(uniq)-1]
+		if i > 0 {
+			start = uniq[i-1]
+		}
+		from, to := prev.owner(p), next.owner(p)
+		if from == to {
+			continue
+		}
+		if n := len(res); n > 0 && res[n-1].End == uint32(start) && res[n-1].From == from && res[n-1].To == to {
+			res[n-1].End = uint32(p)
+			continue
+		}
+		res = append(res, Range{Start: uint32(start), End: uint32(p), From: from, To: to})
+	}
+	return res
+}
+
+// MovedFraction returns the fraction of the hashes of keys owned by
+// another item in next than in prev, which is about the fraction of the
+// keys that changed owner.
+func MovedFraction(prev, next *Map) float64 {
+	var n uint64
+	for _, r := range Diff(prev, next) {
+		n += r.Size()
+	}
+	return float64(n) / (1 << 32)
+}
+
+// owner returns the item owning hash, or "" if m is empty.
+func (m *Map) owner(hash int) string {
+	if m.IsEmpty() {
+		return ""
+	}
+	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
+	if idx == len(m.keys) {
+		idx = 0
+	}
+	return m.hashMap[m.keys[idx]]
+}
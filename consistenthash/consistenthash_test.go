@@ -18,8 +18,10 @@ package consistenthash
 
 import (
 	"fmt"
+	"hash/crc32"
 	"math/rand"
 	"net"
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
@@ -70,6 +72,87 @@ func TestHashing(t *testing.T) {
 
 }
 
+func TestRemove(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, err := strconv.Atoi(string(key))
+		if err != nil {
+			panic(err)
+		}
+		return uint32(i)
+	})
+
+	// 2, 4, 6, 12, 14, 16, 22, 24, 26
+	hash.Add("6", "4", "2")
+
+	// Leaves 2, 6, 12, 16, 22, 26
+	hash.Remove("4")
+
+	testCases := map[string]string{
+		"3":  "6",
+		"13": "6",
+		"23": "6",
+		"27": "2",
+	}
+	for k, v := range testCases {
+		if hash.Get(k) != v {
+			t.Errorf("Asking for %s, should have yielded %s", k, v)
+		}
+	}
+
+	hash.Remove("6", "2")
+	if !hash.IsEmpty() {
+		t.Errorf("hash should be empty after removing every key")
+	}
+}
+
+func TestGetN(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, err := strconv.Atoi(string(key))
+		if err != nil {
+			panic(err)
+		}
+		return uint32(i)
+	})
+
+	// 2, 4, 6, 12, 14, 16, 22, 24, 26
+	hash.Add("6", "4", "2")
+
+	testCases := []struct {
+		key  string
+		n    int
+		want []string
+	}{
+		{"11", 1, []string{"2"}},
+		{"11", 2, []string{"2", "4"}},
+		{"23", 3, []string{"4", "6", "2"}},
+		{"27", 5, []string{"2", "4", "6"}},
+		{"27", 0, nil},
+	}
+	for _, tc := range testCases {
+		if got := hash.GetN(tc.key, tc.n); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("GetN(%s, %d) = %v, want %v", tc.key, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestValidateHash(t *testing.T) {
+	if err := ValidateHash(crc32.ChecksumIEEE); err != nil {
+		t.Errorf("crc32 rejected: %v", err)
+	}
+	if err := ValidateHash(fnv1.HashBytes32); err != nil {
+		t.Errorf("fnv1 rejected: %v", err)
+	}
+
+	constant := func([]byte) uint32 { return 42 }
+	if err := ValidateHash(constant); err == nil {
+		t.Error("constant hash function was not rejected")
+	}
+	fewBuckets := func(b []byte) uint32 { return crc32.ChecksumIEEE(b) % 8 }
+	if err := ValidateHash(fewBuckets); err == nil {
+		t.Error("hash function with 8 distinct outputs was not rejected")
+	}
+}
+
 func TestConsistency(t *testing.T) {
 	hash1 := New(1, nil)
 	hash2 := New(1, nil)
@@ -152,3 +235,168 @@ func benchmarkGet(b *testing.B, shards int) {
 		hash.Get(buckets[i&(shards-1)])
 	}
 }
+
+func TestWeights(t *testing.T) {
+	hash := New(512, fnv1.HashBytes32)
+	hash.AddWeighted(3, "big")
+	hash.Add("small")
+	if w := hash.Weight("big"); w != 3 {
+		t.Errorf("Weight(big) = %d; want 3", w)
+	}
+
+	owned := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		owned[hash.Get(strconv.Itoa(i))]++
+	}
+	if ratio := float64(owned["big"]) / float64(owned["small"]); ratio < 2 || ratio > 4.5 {
+		t.Errorf("big owns %d keys and small %d; want about 3 times as many", owned["big"], owned["small"])
+	}
+
+	hash.Remove("big")
+	if hash.Weight("big") != 0 || len(hash.keys) != 512 {
+		t.Errorf("Remove left weight %d and %d replicas; want 0 and 512", hash.Weight("big"), len(hash.keys))
+	}
+	if got := hash.Get("1"); got != "small" {
+		t.Errorf("Get after Remove = %q; want small", got)
+	}
+}
+
+func TestSetReplicas(t *testing.T) {
+	hash := New(64, fnv1.HashBytes32)
+	hash.AddWeighted(2, "a")
+	hash.Add("b")
+	owners := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		owners[key] = hash.Get(key)
+	}
+
+	hash.SetReplicas("b", 32)
+	if n, w := hash.Replicas("b"), hash.Weight("b"); n != 32 || w != 1 || len(hash.keys) != 160 {
+		t.Fatalf("after SetReplicas(b, 32): %d replicas of weight %d, %d in all; want 32 of 1, 160", n, w, len(hash.keys))
+	}
+	// Only keys of b move, and only to a.
+	for key, owner := range owners {
+		if got := hash.Get(key); got != owner && (owner != "b" || got != "a") {
+			t.Errorf("key %s moved from %s to %s", key, owner, got)
+		}
+	}
+
+	hash.SetReplicas("b", 64)
+	for key, owner := range owners {
+		if got := hash.Get(key); got != owner {
+			t.Errorf("key %s owned by %s after restoring the replicas of b; want %s", key, got, owner)
+		}
+	}
+	hash.SetReplicas("c", 10)
+	if hash.Replicas("c") != 0 {
+		t.Error("SetReplicas added a missing node")
+	}
+}
+
+func TestPoints(t *testing.T) {
+	m := New(10, nil)
+	m.AddWeighted(2, "a")
+	m.Add("b", "c")
+	points := m.Points()
+	if len(points) != 40 {
+		t.Fatalf("%d points; want 40", len(points))
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i].Hash <= points[i-1].Hash {
+			t.Fatalf("points %d and %d are out of order", i-1, i)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		hash := crc32.ChecksumIEEE([]byte(key))
+		owner := points[0].Item
+		for _, p := range points {
+			if p.Hash >= hash {
+				owner = p.Item
+				break
+			}
+		}
+		if want := m.Get(key); owner != want {
+			t.Errorf("owner of %s from Points = %s; want %s", key, owner, want)
+		}
+	}
+}
+
+func TestGetBounded(t *testing.T) {
+	hash := New(50, nil)
+	hash.Add("a", "b", "c", "d")
+
+	const key = "hot"
+	owner := hash.Get(key)
+	load := map[string]int{}
+	count := func(item string) int { return load[item] }
+	if got := hash.GetBounded(key, 0.25, count); got != owner {
+		t.Errorf("GetBounded without load = %q; want the owner %q", got, owner)
+	}
+
+	// Every request for the same key is placed while the earlier ones
+	// are still in flight.
+	for i := 0; i < 100; i++ {
+		load[hash.GetBounded(key, 0.25, count)]++
+	}
+	for item, n := range load {
+		if limit := (100 + 3) * 1.25 / 4; float64(n) > limit {
+			t.Errorf("%s has load %d; want at most %.0f", item, n, limit)
+		}
+	}
+	if len(load) != 4 {
+		t.Errorf("load spread over %v; want all four items", load)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	prev := New(50, nil)
+	prev.Add("a", "b", "c")
+	next := prev.Clone()
+	next.Add("d")
+	if prev.Weight("d") != 0 {
+		t.Fatal("Clone shares the ring with the original")
+	}
+
+	ranges := Diff(prev, next)
+	inRange := func(hash uint32) (Range, bool) {
+		for _, r := range ranges {
+			if r.Start < r.End && hash > r.Start && hash <= r.End ||
+				r.Start >= r.End && (hash > r.Start || hash <= r.End) {
+				return r, true
+			}
+		}
+		return Range{}, false
+	}
+	moved := 0
+	const keys = 10000
+	for i := 0; i < keys; i++ {
+		key := strconv.Itoa(i)
+		from, to := prev.Get(key), next.Get(key)
+		r, ok := inRange(crc32.ChecksumIEEE([]byte(key)))
+		if ok != (from != to) || ok && (r.From != from || r.To != to) {
+			t.Fatalf("key %s moved from %s to %s; range %+v, %v", key, from, to, r, ok)
+		}
+		if ok {
+			moved++
+			if to != "d" {
+				t.Errorf("key %s moved to %s; want only moves to the new item", key, to)
+			}
+		}
+	}
+
+	frac := MovedFraction(prev, next)
+	if frac < 0.15 || frac > 0.35 {
+		t.Errorf("MovedFraction = %v; want about 1/4", frac)
+	}
+	if got := float64(moved) / keys; got < frac-0.05 || got > frac+0.05 {
+		t.Errorf("%v of the keys moved; MovedFraction = %v", got, frac)
+	}
+	if f := MovedFraction(New(50, nil), next); f != 1 {
+		t.Errorf("MovedFraction from an empty ring = %v; want 1", f)
+	}
+	if r := Diff(next, next); len(r) != 0 {
+		t.Errorf("Diff of a ring with itself = %v", r)
+	}
+}
@@ -0,0 +1,51 @@
+package groupcache
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultDeadLetterSize = 100
+
+// FailedOp describes an operation against a peer that failed.
+type FailedOp struct {
+	Op   string // "remove", "store", "replicate" or "flush"
+	Key  string
+	Peer string // URL of the peer that failed, empty for flushes
+	Err  error
+	Time time.Time
+}
+
+// ring is a bounded ring buffer of the most recent failed operations
+// or evictions.
+type ring[T any] struct {
+	mu   sync.Mutex
+	ops  []T
+	next int  // index the next op is written to
+	full bool // ops has wrapped at least once
+}
+
+func (d *ring[T]) add(op T, size int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ops == nil {
+		d.ops = make([]T, size)
+	}
+	d.ops[d.next] = op
+	d.next = (d.next + 1) % len(d.ops)
+	if d.next == 0 {
+		d.full = true
+	}
+}
+
+// list returns the recorded operations, oldest first.
+func (d *ring[T]) list() []T {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.full {
+		return append([]T(nil), d.ops[:d.next]...)
+	}
+	res := make([]T, 0, len(d.ops))
+	res = append(res, d.ops[d.next:]...)
+	return append(res, d.ops[:d.next]...)
+}
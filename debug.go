@@ -0,0 +1,100 @@
+package groupcache
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// DebugReport is the state of a process as reported by DebugHandler.
+type DebugReport struct {
+	Groups []DebugGroup
+
+	// Peers lists the addresses of the peers returned by GetAll of the
+	// PeerPicker given to DebugHandler, in order.
+	Peers []string
+
+	// PeerStats are the RPC statistics of each peer if the PeerPicker
+	// is a GRPCPool.
+	PeerStats []PeerStats `json:",omitempty"`
+}
+
+// DebugGroup is the state of a group as reported by DebugHandler.
+type DebugGroup struct {
+	Name      string
+	Namespace string `json:",omitempty"`
+	Stats     StatsSnapshot
+
+	MainCache CacheStats
+	HotCache  CacheStats
+
+	// Size limits of the caches, as returned by Group.CacheBytes.
+	MainCacheLimit int64
+	HotCacheLimit  int64
+
+	// RecentEvictions are empty unless GroupOptions.EvictionLogSize is
+	// set.
+	RecentEvictions []Eviction
+}
+
+// DebugHandler returns an http.Handler reporting the state of this
+// process as a JSON DebugReport, so operators can inspect a node
+// without attaching a debugger. The "group" query parameter limits the
+// report to the group of that name. peers may be nil.
+//
+// The report includes the keys of recently evicted entries, so the
+// handler should only be served to trusted clients, for example:
+//
+//	http.Handle("/_groupcache/debug", groupcache.DebugHandler(pool))
+func DebugHandler(peers PeerPicker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("group")
+		report, ok := debugReport(peers, name)
+		if !ok {
+			http.Error(w, "no such group: "+name, http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// debugReport returns the DebugReport of this process, limited to the
+// group name unless it is empty, and false if there is no such group.
+func debugReport(peers PeerPicker, name string) (DebugReport, bool) {
+	var report DebugReport
+	for _, g := range GetGroups() {
+		if name != "" && g.name != name {
+			continue
+		}
+		main, hot := g.CacheBytes()
+		report.Groups = append(report.Groups, DebugGroup{
+			Name:            g.name,
+			Namespace:       g.Namespace(),
+			Stats:           g.Snapshot(),
+			MainCache:       g.CacheStats(MainCache),
+			HotCache:        g.CacheStats(HotCache),
+			MainCacheLimit:  main,
+			HotCacheLimit:   hot,
+			RecentEvictions: g.RecentEvictions(),
+		})
+	}
+	if name != "" && len(report.Groups) == 0 {
+		return report, false
+	}
+	if peers != nil {
+		for _, peer := range peers.GetAll() {
+			report.Peers = append(report.Peers, peer.GetURL())
+		}
+		sort.Strings(report.Peers)
+	}
+	if gp, ok := peers.(*GRPCPool); ok {
+		report.PeerStats = gp.PeerStats()
+	}
+	return report, true
+}
@@ -0,0 +1,67 @@
+package groupcache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	const name = "TestDebugHandler-group"
+	g := newGroupOpts(name, 25, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{}, &GroupOptions{EvictionLogSize: 2})
+	defer DeregisterGroup(name)
+	var s string
+	for _, key := range []string{"key-1", "key-2", "key-3", "key-4"} {
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool := newGRPCPool("self", nil)
+	pool.Set("self", "127.0.0.1:1", "127.0.0.1:2")
+	defer pool.Set()
+	ts := httptest.NewServer(DebugHandler(pool))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "?group=" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var report DebugReport
+	if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Groups) != 1 {
+		t.Fatalf("report has %d groups; want 1", len(report.Groups))
+	}
+	dg := report.Groups[0]
+	if dg.Name != name || dg.Stats.Gets != 4 || dg.MainCache.Items != 2 || dg.MainCacheLimit != 25 || dg.HotCacheLimit != -1 {
+		t.Errorf("group = %+v", dg)
+	}
+	if len(dg.RecentEvictions) != 2 || dg.RecentEvictions[0].Key != "key-1" || dg.RecentEvictions[1].Key != "key-2" ||
+		dg.RecentEvictions[0].Reason != EvictedCapacity || dg.RecentEvictions[0].Size != 10 {
+		t.Errorf("recent evictions = %+v; want key-1 and key-2 evicted for capacity", dg.RecentEvictions)
+	}
+	if strings.Join(report.Peers, ",") != "127.0.0.1:1,127.0.0.1:2,self" {
+		t.Errorf("peers = %v", report.Peers)
+	}
+	if len(report.PeerStats) != 3 || report.PeerStats[0].Peer != "127.0.0.1:1" {
+		t.Errorf("peer stats = %+v", report.PeerStats)
+	}
+
+	res, err = http.Get(ts.URL + "?group=TestDebugHandler-missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("status for a missing group = %d; want %d", res.StatusCode, http.StatusNotFound)
+	}
+}
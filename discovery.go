@@ -0,0 +1,98 @@
+package groupcache
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// A Discovery finds the addresses of the peers in a cluster. The
+// discovery package has implementations based on DNS, Kubernetes
+// endpoints and a static file.
+type Discovery interface {
+	// Watch calls update with the complete list of peer addresses
+	// whenever it may have changed, until ctx is done or discovery
+	// fails. update is never called concurrently.
+	Watch(ctx context.Context, update func(peers []string)) error
+}
+
+type DiscoveryOptions struct {
+	// RetryInterval is how long Discover waits before calling Watch
+	// again after it failed. Defaults to 5 seconds.
+	RetryInterval time.Duration
+
+	// IncludeSelf adds the pool's own address to the discovered peers
+	// if it is missing, for discovery sources that do not list it.
+	IncludeSelf bool
+}
+
+// Discover keeps the pool's peers in sync with d until ctx is done,
+// calling Set each time the discovered set of addresses changes. It
+// blocks, so it is usually run in its own goroutine. An empty list of
+// peers is ignored rather than leaving the pool without peers, since it
+// is more likely a transient discovery failure than an empty cluster.
+func (gp *GRPCPool) Discover(ctx context.Context, d Discovery, opts *DiscoveryOptions) {
+	var o DiscoveryOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.RetryInterval == 0 {
+		o.RetryInterval = 5 * time.Second
+	}
+
+	var current []string
+	update := func(peers []string) {
+		peers = normalizePeers(peers, gp.self, o.IncludeSelf)
+		if len(peers) == 0 {
+			gp.log().Warn("Discovery found no peers, keeping the current ones")
+			return
+		}
+		if equalStrings(peers, current) {
+			return
+		}
+		current = peers
+		gp.Set(peers...)
+	}
+	for {
+		err := d.Watch(ctx, update)
+		if ctx.Err() != nil {
+			return
+		}
+		gp.log().Warn("Peer discovery failed", "retry_in", o.RetryInterval, "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(o.RetryInterval):
+		}
+	}
+}
+
+// normalizePeers returns peers sorted and without duplicates, with self
+// added if includeSelf is set.
+func normalizePeers(peers []string, self string, includeSelf bool) []string {
+	seen := make(map[string]bool, len(peers)+1)
+	res := make([]string, 0, len(peers)+1)
+	for _, peer := range peers {
+		if peer != "" && !seen[peer] {
+			seen[peer] = true
+			res = append(res, peer)
+		}
+	}
+	if includeSelf && len(res) != 0 && !seen[self] {
+		res = append(res, self)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+// Package discovery implements groupcache.Discovery on top of DNS,
+// Kubernetes endpoints and a static file, so a GRPCPool can follow the
+// members of an autoscaled cluster:
+//
+//	d := discovery.NewDNS("groupcache.default.svc.cluster.local", &discovery.DNSOptions{Port: 8080})
+//	go pool.Discover(ctx, d, nil)
+package discovery
+
+import (
+	"context"
+	"time"
+)
+
+// defaultInterval is how often polling implementations look for
+// changes unless configured otherwise.
+const defaultInterval = 30 * time.Second
+
+// poll calls update with the result of resolve right away and then
+// every interval until ctx is done or resolve fails.
+func poll(ctx context.Context, interval time.Duration, resolve func(context.Context) ([]string, error), update func([]string)) error {
+	if interval == 0 {
+		interval = defaultInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		peers, err := resolve(ctx)
+		if err != nil {
+			return err
+		}
+		update(peers)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
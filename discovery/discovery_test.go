@@ -0,0 +1,127 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// collect runs d.Watch in the background and returns a channel of the
+// updates it makes.
+func collect(d interface {
+	Watch(context.Context, func([]string)) error
+}) (<-chan []string, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan []string, 10)
+	go d.Watch(ctx, func(peers []string) {
+		select {
+		case updates <- peers:
+		case <-ctx.Done():
+		}
+	})
+	return updates, cancel
+}
+
+func next(t *testing.T, updates <-chan []string) []string {
+	t.Helper()
+	select {
+	case peers := <-updates:
+		return peers
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an update")
+		return nil
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "peers")
+	if err := ioutil.WriteFile(path, []byte("# peers\n10.0.0.1:8080\n\n 10.0.0.2:8080 \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	updates, cancel := collect(NewFile(path, &FileOptions{Interval: 10 * time.Millisecond}))
+	defer cancel()
+	if got, want := next(t, updates), []string{"10.0.0.1:8080", "10.0.0.2:8080"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("peers = %v; want %v", got, want)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("10.0.0.3:8080\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Updates made before the write was noticed repeat the old peers.
+	want := []string{"10.0.0.3:8080"}
+	got := next(t, updates)
+	for !reflect.DeepEqual(got, want) {
+		got = next(t, updates)
+	}
+}
+
+func TestFileMissing(t *testing.T) {
+	err := NewFile("/nonexistent/peers", nil).Watch(context.Background(), func([]string) {
+		t.Error("update called for a missing file")
+	})
+	if err == nil {
+		t.Fatal("Watch succeeded on a missing file")
+	}
+}
+
+func TestKubernetes(t *testing.T) {
+	const list = `{"metadata":{"resourceVersion":"1"},"subsets":[{
+		"addresses":[{"ip":"10.0.0.1"},{"ip":"10.0.0.2"}],
+		"ports":[{"name":"http","port":80},{"name":"grpc","port":8080}]}]}`
+	const event = `{"type":"MODIFIED","object":{"metadata":{"resourceVersion":"2"},"subsets":[{
+		"addresses":[{"ip":"10.0.0.3"}],"ports":[{"name":"grpc","port":8080}]}]}}`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/v1/namespaces/ns/endpoints/svc":
+			fmt.Fprint(w, list)
+		case r.URL.Path == "/api/v1/namespaces/ns/endpoints" && r.URL.Query().Get("watch") == "1":
+			if r.URL.Query().Get("resourceVersion") != "1" {
+				t.Errorf("watch from version %q; want 1", r.URL.Query().Get("resourceVersion"))
+			}
+			fmt.Fprintln(w, event)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	updates, cancel := collect(NewKubernetes("ns", "svc", &KubernetesOptions{
+		PortName:  "grpc",
+		APIServer: srv.URL,
+		Token:     "token",
+		Client:    srv.Client(),
+	}))
+	defer cancel()
+	if got, want := next(t, updates), []string{"10.0.0.1:8080", "10.0.0.2:8080"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("listed peers = %v; want %v", got, want)
+	}
+	if got, want := next(t, updates), []string{"10.0.0.3:8080"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("watched peers = %v; want %v", got, want)
+	}
+}
+
+func TestDNSRequiresPort(t *testing.T) {
+	if err := NewDNS("localhost", nil).Watch(context.Background(), func([]string) {}); err == nil {
+		t.Fatal("Watch succeeded without a port")
+	}
+}
@@ -0,0 +1,77 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DNSOptions struct {
+	// Port is appended to the addresses of A and AAAA records. It is
+	// required unless SRV is set.
+	Port int
+
+	// SRV looks up SRV records instead of A and AAAA records, taking
+	// the port of each peer from its record.
+	SRV bool
+
+	// Interval is how often the name is resolved again. Defaults to 30
+	// seconds.
+	Interval time.Duration
+
+	// Resolver resolves the name. Defaults to net.DefaultResolver.
+	Resolver *net.Resolver
+}
+
+// DNS discovers peers by periodically resolving a DNS name, such as the
+// name of a Kubernetes headless service.
+type DNS struct {
+	name string
+	opts DNSOptions
+}
+
+// NewDNS returns a DNS discovering the peers listed under name.
+func NewDNS(name string, opts *DNSOptions) *DNS {
+	d := &DNS{name: name}
+	if opts != nil {
+		d.opts = *opts
+	}
+	if d.opts.Resolver == nil {
+		d.opts.Resolver = net.DefaultResolver
+	}
+	return d
+}
+
+func (d *DNS) Watch(ctx context.Context, update func(peers []string)) error {
+	return poll(ctx, d.opts.Interval, d.resolve, update)
+}
+
+func (d *DNS) resolve(ctx context.Context) ([]string, error) {
+	if d.opts.SRV {
+		_, srvs, err := d.opts.Resolver.LookupSRV(ctx, "", "", d.name)
+		if err != nil {
+			return nil, fmt.Errorf("discovery: looking up SRV records of %s: %w", d.name, err)
+		}
+		peers := make([]string, len(srvs))
+		for i, srv := range srvs {
+			peers[i] = net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port)))
+		}
+		return peers, nil
+	}
+
+	if d.opts.Port == 0 {
+		return nil, fmt.Errorf("discovery: DNSOptions.Port is required to resolve %s", d.name)
+	}
+	addrs, err := d.opts.Resolver.LookupHost(ctx, d.name)
+	if err != nil {
+		return nil, fmt.Errorf("discovery: looking up %s: %w", d.name, err)
+	}
+	peers := make([]string, len(addrs))
+	for i, addr := range addrs {
+		peers[i] = net.JoinHostPort(addr, strconv.Itoa(d.opts.Port))
+	}
+	return peers, nil
+}
@@ -0,0 +1,55 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+type FileOptions struct {
+	// Interval is how often the file is read again. Defaults to 30
+	// seconds.
+	Interval time.Duration
+}
+
+// File discovers peers from a file listing one address per line, which
+// is read again periodically so it can be edited or replaced while the
+// process runs. Blank lines and lines starting with # are ignored.
+type File struct {
+	path string
+	opts FileOptions
+}
+
+// NewFile returns a File reading the peers from path.
+func NewFile(path string, opts *FileOptions) *File {
+	f := &File{path: path}
+	if opts != nil {
+		f.opts = *opts
+	}
+	return f
+}
+
+func (f *File) Watch(ctx context.Context, update func(peers []string)) error {
+	return poll(ctx, f.opts.Interval, f.read, update)
+}
+
+func (f *File) read(context.Context) ([]string, error) {
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("discovery: reading peers: %w", err)
+	}
+	var peers []string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		peers = append(peers, line)
+	}
+	return peers, s.Err()
+}
@@ -0,0 +1,204 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+
+type KubernetesOptions struct {
+	// Port selects the port appended to the endpoint addresses. If
+	// zero, the port named PortName is used, or the first port of each
+	// subset if PortName is empty.
+	Port     int
+	PortName string
+
+	// APIServer is the URL of the Kubernetes API server. Defaults to
+	// the in-cluster address from KUBERNETES_SERVICE_HOST and
+	// KUBERNETES_SERVICE_PORT.
+	APIServer string
+
+	// Token authenticates the requests to the API server. Defaults to
+	// the token of the pod's service account.
+	Token string
+
+	// Client sends the requests to the API server. Defaults to a client
+	// trusting the CA certificate of the pod's service account.
+	Client *http.Client
+}
+
+// Kubernetes discovers peers by watching the endpoints of a Kubernetes
+// service. Only ready addresses are reported. The service account
+// needs permission to get and watch endpoints in the namespace.
+type Kubernetes struct {
+	namespace, service string
+	opts               KubernetesOptions
+}
+
+// NewKubernetes returns a Kubernetes watching the endpoints of service
+// in namespace.
+func NewKubernetes(namespace, service string, opts *KubernetesOptions) *Kubernetes {
+	k := &Kubernetes{namespace: namespace, service: service}
+	if opts != nil {
+		k.opts = *opts
+	}
+	return k
+}
+
+func (k *Kubernetes) Watch(ctx context.Context, update func(peers []string)) error {
+	if err := k.init(); err != nil {
+		return err
+	}
+	base := fmt.Sprintf("%s/api/v1/namespaces/%s/endpoints", strings.TrimSuffix(k.opts.APIServer, "/"),
+		url.PathEscape(k.namespace))
+	for {
+		var ep endpoints
+		if err := k.get(ctx, base+"/"+url.PathEscape(k.service), func(r io.Reader) error {
+			return json.NewDecoder(r).Decode(&ep)
+		}); err != nil {
+			return err
+		}
+		update(k.peers(&ep))
+
+		// Watch from the listed version until the API server ends the
+		// watch, then list again.
+		q := url.Values{
+			"watch":           {"1"},
+			"fieldSelector":   {"metadata.name=" + k.service},
+			"resourceVersion": {ep.Metadata.ResourceVersion},
+		}
+		if err := k.get(ctx, base+"?"+q.Encode(), func(r io.Reader) error {
+			dec := json.NewDecoder(r)
+			for {
+				var ev struct {
+					Type   string    `json:"type"`
+					Object endpoints `json:"object"`
+				}
+				if err := dec.Decode(&ev); err == io.EOF {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				switch ev.Type {
+				case "ADDED", "MODIFIED":
+					update(k.peers(&ev.Object))
+				case "DELETED":
+					update(nil)
+				case "ERROR":
+					// Usually 410 Gone, the listed version is too old.
+					return nil
+				}
+			}
+		}); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// init fills in the in-cluster defaults.
+func (k *Kubernetes) init() error {
+	if k.opts.APIServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return fmt.Errorf("discovery: not running in Kubernetes and KubernetesOptions.APIServer is not set")
+		}
+		k.opts.APIServer = "https://" + net.JoinHostPort(host, port)
+	}
+	if k.opts.Token == "" {
+		b, err := ioutil.ReadFile(serviceAccountDir + "token")
+		if err != nil {
+			return fmt.Errorf("discovery: reading service account token: %w", err)
+		}
+		k.opts.Token = strings.TrimSpace(string(b))
+	}
+	if k.opts.Client == nil {
+		ca, err := ioutil.ReadFile(serviceAccountDir + "ca.crt")
+		if err != nil {
+			return fmt.Errorf("discovery: reading service account CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca)
+		k.opts.Client = &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}}
+	}
+	return nil
+}
+
+// get sends a GET request for url to the API server and passes the
+// response body to read.
+func (k *Kubernetes) get(ctx context.Context, url string, read func(io.Reader) error) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+k.opts.Token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := k.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("discovery: getting endpoints of %s/%s: %w", k.namespace, k.service, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("discovery: getting endpoints of %s/%s: %s", k.namespace, k.service, resp.Status)
+	}
+	if err := read(resp.Body); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("discovery: reading endpoints of %s/%s: %w", k.namespace, k.service, err)
+	}
+	return nil
+}
+
+// peers returns the ready addresses of ep.
+func (k *Kubernetes) peers(ep *endpoints) []string {
+	var peers []string
+	for _, subset := range ep.Subsets {
+		port := k.opts.Port
+		for _, p := range subset.Ports {
+			if port != 0 {
+				break
+			}
+			if k.opts.PortName == "" || p.Name == k.opts.PortName {
+				port = p.Port
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		for _, addr := range subset.Addresses {
+			peers = append(peers, net.JoinHostPort(addr.IP, strconv.Itoa(port)))
+		}
+	}
+	return peers
+}
+
+// endpoints is the part of a Kubernetes Endpoints object used here.
+type endpoints struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Subsets []struct {
+		Addresses []struct {
+			IP string `json:"ip"`
+		} `json:"addresses"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
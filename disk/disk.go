@@ -0,0 +1,149 @@
+// Package disk implements a groupcache.TierTwo keeping values in local
+// files, so a restarted peer serves the keys it owns without loading
+// them from the origin again.
+//
+//	store, err := disk.New("/var/cache/groupcache", nil)
+//	g := groupcache.NewGroupOpts("thumbnails", 64<<20, getter,
+//		&groupcache.GroupOptions{TierTwo: store})
+//
+// Embedded databases such as BoltDB or badger can be used instead by
+// implementing groupcache.TierTwo around them; they are not bundled to
+// keep groupcache free of their dependencies.
+package disk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/adistroy/groupcache/v3"
+)
+
+type Options struct {
+	// Perm is the permission of the files and directories created.
+	// If blank, it defaults to 0700 for directories and 0600 for files.
+	Perm os.FileMode
+}
+
+// Store is a groupcache.TierTwo and groupcache.TierTwoClearer keeping
+// each value in its own file, under a directory per group. Writes
+// replace the file atomically, so a crash never leaves a torn value.
+// Expired values are removed when they are read.
+type Store struct {
+	dir  string
+	perm os.FileMode
+}
+
+// New returns a Store keeping its files under dir, which is created if
+// it does not exist.
+func New(dir string, opts *Options) (*Store, error) {
+	s := &Store{dir: dir, perm: 0700}
+	if opts != nil && opts.Perm != 0 {
+		s.perm = opts.Perm
+	}
+	if err := os.MkdirAll(dir, s.perm); err != nil {
+		return nil, fmt.Errorf("disk: creating %s: %w", dir, err)
+	}
+	return s, nil
+}
+
+// headerSize is the size of the expiry and key length preceding the key
+// and the value in a file.
+const headerSize = 8 + 4
+
+func (s *Store) groupDir(group string) string {
+	return filepath.Join(s.dir, hex.EncodeToString([]byte(group)))
+}
+
+func (s *Store) path(group, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.groupDir(group), hex.EncodeToString(sum[:]))
+}
+
+// Get implements groupcache.TierTwo.
+func (s *Store) Get(ctx context.Context, group, key string) ([]byte, time.Time, error) {
+	path := s.path(group, key)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, time.Time{}, groupcache.ErrNotFound
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("disk: reading %s: %w", path, err)
+	}
+	if len(data) < headerSize {
+		return nil, time.Time{}, fmt.Errorf("disk: %s is truncated", path)
+	}
+	var expire time.Time
+	if ns := int64(binary.BigEndian.Uint64(data)); ns != 0 {
+		expire = time.Unix(0, ns)
+	}
+	n := int(binary.BigEndian.Uint32(data[8:]))
+	if len(data)-headerSize < n {
+		return nil, time.Time{}, fmt.Errorf("disk: %s is truncated", path)
+	}
+	if string(data[headerSize:headerSize+n]) != key {
+		// Another key with the same hash.
+		return nil, time.Time{}, groupcache.ErrNotFound
+	}
+	if !expire.IsZero() && expire.Before(time.Now()) {
+		os.Remove(path)
+		return nil, time.Time{}, groupcache.ErrNotFound
+	}
+	return data[headerSize+n:], expire, nil
+}
+
+// Set implements groupcache.TierTwo.
+func (s *Store) Set(ctx context.Context, group, key string, value []byte, expire time.Time) error {
+	dir := s.groupDir(group)
+	if err := os.MkdirAll(dir, s.perm); err != nil {
+		return fmt.Errorf("disk: creating %s: %w", dir, err)
+	}
+	data := make([]byte, headerSize, headerSize+len(key)+len(value))
+	if !expire.IsZero() {
+		binary.BigEndian.PutUint64(data, uint64(expire.UnixNano()))
+	}
+	binary.BigEndian.PutUint32(data[8:], uint32(len(key)))
+	data = append(append(data, key...), value...)
+
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return fmt.Errorf("disk: %w", err)
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), s.perm&^0111)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path(group, key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("disk: writing %s: %w", key, err)
+	}
+	return nil
+}
+
+// Remove implements groupcache.TierTwo.
+func (s *Store) Remove(ctx context.Context, group, key string) error {
+	if err := os.Remove(s.path(group, key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("disk: %w", err)
+	}
+	return nil
+}
+
+// Clear implements groupcache.TierTwoClearer.
+func (s *Store) Clear(ctx context.Context, group string) error {
+	if err := os.RemoveAll(s.groupDir(group)); err != nil {
+		return fmt.Errorf("disk: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package disk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adistroy/groupcache/v3"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get(ctx, "g", "k"); !errors.Is(err, groupcache.ErrNotFound) {
+		t.Fatalf("Get of a missing key = %v; want ErrNotFound", err)
+	}
+
+	expire := time.Now().Add(time.Hour).Round(0)
+	if err := s.Set(ctx, "g", "k", []byte("v"), expire); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(ctx, "g", "never", []byte("forever"), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if v, e, err := s.Get(ctx, "g", "k"); err != nil || string(v) != "v" || !e.Equal(expire) {
+		t.Errorf("Get = %q, %v, %v; want v, %v", v, e, err, expire)
+	}
+	if v, e, err := s.Get(ctx, "g", "never"); err != nil || string(v) != "forever" || !e.IsZero() {
+		t.Errorf("Get = %q, %v, %v; want forever without expiry", v, e, err)
+	}
+	if _, _, err := s.Get(ctx, "other", "k"); !errors.Is(err, groupcache.ErrNotFound) {
+		t.Errorf("Get from another group = %v; want ErrNotFound", err)
+	}
+
+	if err := s.Set(ctx, "g", "old", []byte("v"), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get(ctx, "g", "old"); !errors.Is(err, groupcache.ErrNotFound) {
+		t.Errorf("Get of an expired key = %v; want ErrNotFound", err)
+	}
+
+	if err := s.Remove(ctx, "g", "k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get(ctx, "g", "k"); !errors.Is(err, groupcache.ErrNotFound) {
+		t.Errorf("Get after Remove = %v; want ErrNotFound", err)
+	}
+	if err := s.Remove(ctx, "g", "k"); err != nil {
+		t.Errorf("Remove of a missing key = %v", err)
+	}
+
+	if err := s.Clear(ctx, "g"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get(ctx, "g", "never"); !errors.Is(err, groupcache.ErrNotFound) {
+		t.Errorf("Get after Clear = %v; want ErrNotFound", err)
+	}
+}
+
+// TestRestart checks that a group backed by a Store serves values
+// loaded before a restart without calling its getter.
+func TestRestart(t *testing.T) {
+	const name = "disk-TestRestart-group"
+	dir := t.TempDir()
+	var loads int
+	getter := groupcache.GetterFunc(func(_ context.Context, key string, dest groupcache.Sink) error {
+		loads++
+		return dest.SetString("value of "+key, time.Time{})
+	})
+	for i := 0; i < 2; i++ {
+		s, err := New(dir, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := groupcache.NewGroupOpts(name, 1<<20, getter, &groupcache.GroupOptions{
+			TierTwo: s,
+			Peers:   groupcache.NoPeers{},
+		})
+		var v string
+		if err := g.Get(context.Background(), "k", groupcache.StringSink(&v)); err != nil || v != "value of k" {
+			t.Errorf("Get = %q, %v", v, err)
+		}
+		groupcache.DeregisterGroup(name)
+	}
+	if loads != 1 {
+		t.Errorf("getter called %d times across a restart; want 1", loads)
+	}
+}
@@ -0,0 +1,168 @@
+package groupcache
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+	"golang.org/x/net/context"
+)
+
+// DrainOptions are the configurations of GRPCPool.Drain.
+type DrainOptions struct {
+	// MaxKeys is the number of entries of each group's main cache that
+	// are handed off, the ones the cache policy values most first.
+	// If blank, it defaults to 10000.
+	MaxKeys int
+}
+
+const defaultDrainKeys = 10000
+
+// Drain hands the entries of the main caches of this pool's groups to
+// the peers that own their keys once this peer is gone, with the
+// TransferKeys RPC, so they are not reloaded from the origin after a
+// scale-down or during a rolling deploy. Call it before this peer is
+// removed from the other pools, and stop serving once it returns.
+// Only caches whose policy is a policy.Ranger, which the built-in
+// policies are, are drained. Peers that could not be sent their
+// entries are reported in the returned PeersError.
+func (gp *GRPCPool) Drain(ctx context.Context, opts *DrainOptions) error {
+	maxKeys := defaultDrainKeys
+	if opts != nil && opts.MaxKeys > 0 {
+		maxKeys = opts.MaxKeys
+	}
+
+	mu.RLock()
+	var drained []*Group
+	for _, g := range groups {
+		drained = append(drained, g)
+	}
+	mu.RUnlock()
+
+	byPeer := make(map[*grpcGetter][]*gcgrpc.TransferEntry)
+	for _, g := range drained {
+		g.peersOnce.Do(g.initPeers)
+		if g.peers != PeerPicker(gp) {
+			continue
+		}
+		entries := g.mainCache.hottest(maxKeys)
+		gp.mu.Lock()
+		for _, e := range entries {
+			getter := gp.successor(e.key)
+			if getter == nil {
+				continue
+			}
+			entry := &gcgrpc.TransferEntry{Group: g.name, Namespace: g.Namespace(), Key: []byte(e.key), Value: e.value.ByteSlice()}
+			if !e.value.Expire().IsZero() {
+				entry.Expire = e.value.Expire().UnixNano()
+			}
+			byPeer[getter] = append(byPeer[getter], entry)
+		}
+		gp.mu.Unlock()
+	}
+
+	var (
+		wg   sync.WaitGroup
+		emu  sync.Mutex
+		errs = PeersError{}
+	)
+	for getter, entries := range byPeer {
+		wg.Add(1)
+		go func(getter *grpcGetter, entries []*gcgrpc.TransferEntry) {
+			defer wg.Done()
+			if err := getter.transferKeys(ctx, entries); err != nil {
+				emu.Lock()
+				errs[getter.address] = err
+				emu.Unlock()
+				return
+			}
+			gp.Stats.TransferredKeys.Add(int64(len(entries)))
+		}(getter, entries)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// successor returns the peer owning key once this peer is gone, or nil
+// if there is none. gp.mu must be held.
+func (gp *GRPCPool) successor(key string) *grpcGetter {
+	for _, peer := range gp.peers.GetN(key, 2) {
+		if peer != gp.self {
+			return gp.grpcGetters[peer]
+		}
+	}
+	return nil
+}
+
+// TransferKeys receives the entries a departing peer hands off with
+// Drain. Entries of keys this peer owns are kept in the main cache,
+// others in the hot cache, unless a value for the key is already
+// cached.
+func (gp *GRPCPool) TransferKeys(stream gcgrpc.Peer_TransferKeysServer) error {
+	release, err := gp.acquire(stream.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&gcgrpc.Ack{})
+		}
+		if err != nil {
+			return err
+		}
+		group := GetGroupInNamespace(entry.Namespace, entry.Group)
+		if group == nil {
+			continue
+		}
+		key := string(entry.Key)
+		var expire time.Time
+		if entry.Expire != 0 {
+			expire = time.Unix(0, entry.Expire)
+		}
+		gp.mu.Lock()
+		owner := gp.peers.Get(key)
+		owned := owner == "" || owner == gp.self
+		if owned {
+			gp.keepTransferred(group, key)
+		}
+		gp.mu.Unlock()
+		cache := &group.hotCache
+		if owned {
+			cache = &group.mainCache
+		}
+		if group.localFill(key, ByteView{b: entry.Value, e: expire}, cache) {
+			gp.Stats.ReceivedKeys.Add(1)
+		}
+	}
+}
+
+func (g *grpcGetter) transferKeys(ctx context.Context, entries []*gcgrpc.TransferEntry) error {
+	conn, err := g.begin()
+	if err != nil {
+		return fmt.Errorf("Failed to TRANSFER keys: %v", err)
+	}
+	defer g.end(conn)
+	stream, err := gcgrpc.NewPeerClient(conn).TransferKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to TRANSFER keys: %w", errFromStatus(err))
+	}
+	for _, entry := range entries {
+		if err := stream.Send(entry); err != nil {
+			// The cause is returned by CloseAndRecv.
+			break
+		}
+	}
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return fmt.Errorf("Failed to TRANSFER keys: %w", errFromStatus(err))
+	}
+	return nil
+}
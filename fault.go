@@ -0,0 +1,65 @@
+package groupcache
+
+import (
+	"context"
+	"time"
+)
+
+// A FaultInjector injects faults into the loads of a group, so the
+// resilience of an application to slow or failing peers and getters
+// can be tested, as in a game day, without a proxy between processes.
+// See GroupOptions.Faults.
+type FaultInjector interface {
+	// Fault returns the fault to inject into the load of key of group
+	// from peer, as returned by its GetURL, or from the getter of this
+	// process if peer is empty. The zero Fault injects nothing.
+	Fault(ctx context.Context, group, key, peer string) Fault
+}
+
+// FaultFunc implements FaultInjector with a function.
+type FaultFunc func(ctx context.Context, group, key, peer string) Fault
+
+func (f FaultFunc) Fault(ctx context.Context, group, key, peer string) Fault {
+	return f(ctx, group, key, peer)
+}
+
+// A Fault is injected into a load.
+type Fault struct {
+	// Delay is waited before the load, as if the peer or getter were
+	// slow. A load whose context is done meanwhile fails with its
+	// error.
+	Delay time.Duration
+
+	// Err fails the load, after Delay, without sending it to the peer
+	// or calling the getter. Whether it is retried or cached as
+	// negative depends on the error, as for real ones.
+	Err error
+
+	// Truncate cuts the value returned by a peer to half its length, as
+	// if its response were corrupted. It does not apply to the getter.
+	Truncate bool
+}
+
+// injectFault waits for the fault GroupOptions.Faults returns for the
+// load of key from peer, and returns it with the error to fail the load
+// with.
+func (g *Group) injectFault(ctx context.Context, key, peer string) (Fault, error) {
+	if g.opts.Faults == nil {
+		return Fault{}, nil
+	}
+	f := g.opts.Faults.Fault(ctx, g.name, key, peer)
+	if f.Delay <= 0 && f.Err == nil && !f.Truncate {
+		return f, nil
+	}
+	g.Stats.FaultsInjected.Add(1)
+	if f.Delay > 0 {
+		t := time.NewTimer(f.Delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return f, ctx.Err()
+		}
+	}
+	return f, f.Err
+}
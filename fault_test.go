@@ -0,0 +1,67 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaults(t *testing.T) {
+	const name = "TestFaults-group"
+	errInjected := errors.New("injected")
+	faults := FaultFunc(func(_ context.Context, group, key, peer string) Fault {
+		switch {
+		case group != name:
+			t.Errorf("fault asked for group %q", group)
+		case strings.HasPrefix(key, "slow"):
+			return Fault{Delay: 20 * time.Millisecond}
+		case key == "cut" && peer != "":
+			return Fault{Truncate: true}
+		case key == "down" && peer != "":
+			return Fault{Err: errInjected}
+		case key == "fail" && peer == "":
+			return Fault{Err: errInjected}
+		}
+		return Fault{}
+	})
+	peer := &fakePeer{}
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local", time.Time{})
+	}), fakePeers{peer}, &GroupOptions{Faults: faults})
+	defer DeregisterGroup(name)
+
+	var s string
+	start := time.Now()
+	if err := g.Get(dummyCtx, "slow", StringSink(&s)); err != nil || s != "got:slow" {
+		t.Errorf("Get(slow) = %q, %v; want got:slow", s, err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("Get(slow) took %v; want the 20ms delay", d)
+	}
+	if err := g.Get(dummyCtx, "cut", StringSink(&s)); err != nil || s != "got" {
+		t.Errorf("Get(cut) = %q, %v; want got:cut truncated to got", s, err)
+	}
+	hits := peer.hits
+	if err := g.Get(dummyCtx, "down", StringSink(&s)); err != nil || s != "local" {
+		t.Errorf("Get(down) = %q, %v; want the local fallback", s, err)
+	}
+	if peer.hits != hits {
+		t.Error("a failed fault still sent the load to the peer")
+	}
+
+	g.peers = NoPeers{}
+	if err := g.Get(dummyCtx, "fail", StringSink(&s)); !errors.Is(err, errInjected) {
+		t.Errorf("Get(fail) = %v; want %v", err, errInjected)
+	}
+	if n := g.Snapshot().FaultsInjected; n != 4 {
+		t.Errorf("FaultsInjected = %d; want 4", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := g.Get(ctx, "slow-deadline", StringSink(&s)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get(slow-deadline) past its deadline = %v; want %v", err, context.DeadlineExceeded)
+	}
+}
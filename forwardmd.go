@@ -0,0 +1,62 @@
+package groupcache
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// forwardedMetadata returns keys in lower case, as gRPC carries
+// metadata keys.
+func forwardedMetadata(keys []string) []string {
+	if len(keys) == 0 {
+		return nil
+	}
+	lower := make([]string, len(keys))
+	for i, key := range keys {
+		lower[i] = strings.ToLower(key)
+	}
+	return lower
+}
+
+// forwardMetadata adds the keys of the incoming metadata of ctx to its
+// outgoing metadata, unless they are already set there, so a Get made
+// while serving an RPC passes them on to the peer it is sent to.
+func forwardMetadata(ctx context.Context, keys []string) context.Context {
+	in, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(keys) == 0 {
+		return ctx
+	}
+	out, _ := metadata.FromOutgoingContext(ctx)
+	var kv []string
+	for _, key := range keys {
+		if len(out.Get(key)) != 0 {
+			continue
+		}
+		for _, v := range in.Get(key) {
+			kv = append(kv, key, v)
+		}
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// getterContext returns the ctx an inbound Get of a peer is loaded
+// with: one whose incoming metadata only holds the keys of
+// GRPCPoolOptions.ForwardMetadata, if it is set.
+func (gp *GRPCPool) getterContext(ctx context.Context) context.Context {
+	if gp.opts.ForwardMetadata == nil {
+		return ctx
+	}
+	in, _ := metadata.FromIncomingContext(ctx)
+	md := metadata.MD{}
+	for _, key := range gp.opts.ForwardMetadata {
+		if v := in.Get(key); len(v) != 0 {
+			md[key] = v
+		}
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
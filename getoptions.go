@@ -0,0 +1,160 @@
+package groupcache
+
+import (
+	"context"
+	"time"
+)
+
+// A GetOption changes how GetWithOptions serves a single Get.
+type GetOption func(*getOptions)
+
+type getOptions struct {
+	forceRefresh bool
+	noStore      bool
+	minFresh     time.Duration // zero unless hasMinFresh
+	hasMinFresh  bool
+	result       *GetResult
+}
+
+// ForceRefresh skips the caches, the TierTwo and the negative caches
+// and loads the key again, replacing the cached value. A hot copy is
+// dropped even if the HotCachePolicy does not admit the new value.
+// Concurrent Gets may still share a load that started before.
+func ForceRefresh() GetOption {
+	return func(o *getOptions) { o.forceRefresh = true }
+}
+
+// NoStore loads a key that is not cached without caching its value, for
+// reads such as scans that should not evict values that are read
+// often. A value that is cached is still returned.
+func NoStore() GetOption {
+	return func(o *getOptions) { o.noStore = true }
+}
+
+// MinFreshness treats a cached value that expires within d as missing,
+// so it is loaded again. Values that never expire are always fresh
+// enough; expired values served with StaleWhileRevalidate never are.
+func MinFreshness(d time.Duration) GetOption {
+	return func(o *getOptions) { o.minFresh, o.hasMinFresh = d, true }
+}
+
+// WithResult makes GetWithOptions describe in r where the value was
+// found, for metrics and freshness decisions of the caller that do not
+// have to be derived from Stats. r is left zero if the Get fails.
+func WithResult(r *GetResult) GetOption {
+	return func(o *getOptions) { o.result = r }
+}
+
+// A GetSource is where a Get found its value.
+type GetSource int
+
+const (
+	SourceMainCache GetSource = iota + 1 // the main cache of this process
+	SourceHotCache                       // the hot cache of this process
+	SourcePeer                           // the peer owning the key, or a failover peer
+	SourceTierTwo                        // the TierTwo of the group
+	SourceGetter                         // the getter of this process
+)
+
+func (s GetSource) String() string {
+	switch s {
+	case SourceMainCache:
+		return "main_cache"
+	case SourceHotCache:
+		return "hot_cache"
+	case SourcePeer:
+		return "peer"
+	case SourceTierTwo:
+		return "tier_two"
+	case SourceGetter:
+		return "getter"
+	}
+	return "unknown"
+}
+
+// GetResult describes how a Get was served, see WithResult.
+type GetResult struct {
+	Source GetSource
+
+	// Age is how long ago the value was cached on this process, zero
+	// if it was not cached before this Get. Values fetched from a peer
+	// may be older than that on the peer.
+	Age time.Duration
+}
+
+// cacheResult returns the GetResult of value found in the cache of
+// source.
+func cacheResult(source GetSource, value ByteView) GetResult {
+	r := GetResult{Source: source}
+	if value.added != 0 {
+		r.Age = time.Since(time.Unix(0, value.added))
+	}
+	return r
+}
+
+type getSourceKey struct{}
+
+// setGetSource records the source fetch found a value in, for the
+// GetResult of the load with ctx.
+func setGetSource(ctx context.Context, source GetSource) {
+	if ctx == nil {
+		return
+	}
+	if p, ok := ctx.Value(getSourceKey{}).(*GetSource); ok {
+		*p = source
+	}
+}
+
+// fresh reports whether a cached value may be returned under o.
+func (o getOptions) fresh(value ByteView) bool {
+	if !o.hasMinFresh || value.Expire().IsZero() {
+		return true
+	}
+	return time.Until(value.Expire()) >= o.minFresh
+}
+
+// GetWithOptions is Get with options for this call only, such as
+// ForceRefresh, so callers with different consistency requirements can
+// share a group. Through a GRPCPool the options also apply on the peer
+// owning the key; HTTPPool sends Gets without them.
+func (g *Group) GetWithOptions(ctx context.Context, key string, dest Sink, opts ...GetOption) error {
+	return g.getWithOptions(ctx, g.encodeKey(key), dest, opts...)
+}
+
+// getWithOptions is GetWithOptions of a key encoded already, as get.
+func (g *Group) getWithOptions(ctx context.Context, key string, dest Sink, opts ...GetOption) error {
+	var o getOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o != (getOptions{}) {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx = context.WithValue(ctx, getOptionsKey{}, getOptionsValue{group: g.name, key: key, opts: o})
+	}
+	return g.get(ctx, key, dest)
+}
+
+type getOptionsKey struct{}
+
+// getOptionsValue carries the options of a GetWithOptions call through
+// ctx. They only apply to the Get of that key, not to other Gets a
+// getter may make with the same ctx.
+type getOptionsValue struct {
+	group, key string
+	opts       getOptions
+}
+
+// getOptionsFor returns the options given to GetWithOptions for the key
+// of group.
+func getOptionsFor(ctx context.Context, group, key string) getOptions {
+	if ctx == nil {
+		return getOptions{}
+	}
+	v, ok := ctx.Value(getOptionsKey{}).(getOptionsValue)
+	if !ok || v.group != group || v.key != key {
+		return getOptions{}
+	}
+	return v.opts
+}
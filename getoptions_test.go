@@ -0,0 +1,163 @@
+package groupcache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+)
+
+func TestGetWithOptions(t *testing.T) {
+	const name = "TestGetWithOptions-group"
+	var loads AtomicInt
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads.Add(1)
+		return dest.SetString(key+":"+strconv.FormatInt(loads.Get(), 10), time.Now().Add(time.Hour))
+	}), NoPeers{})
+	defer DeregisterGroup(name)
+
+	get := func(key string, opts ...GetOption) string {
+		t.Helper()
+		var s string
+		if err := g.GetWithOptions(dummyCtx, key, StringSink(&s), opts...); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	get("key")
+	if got := get("key", ForceRefresh()); got != "key:2" {
+		t.Errorf("ForceRefresh = %q; want key:2", got)
+	}
+	if got := get("key"); got != "key:2" {
+		t.Errorf("Get after ForceRefresh = %q; want the refreshed key:2", got)
+	}
+
+	if got := get("key", MinFreshness(30*time.Minute)); got != "key:2" {
+		t.Errorf("MinFreshness(30m) = %q; want the cached key:2", got)
+	}
+	if got := get("key", MinFreshness(2*time.Hour)); got != "key:3" {
+		t.Errorf("MinFreshness(2h) = %q; want key:3", got)
+	}
+
+	items := g.CacheStats(MainCache).Items
+	if got := get("scan", NoStore()); got != "scan:4" {
+		t.Errorf("NoStore = %q; want scan:4", got)
+	}
+	if n := g.CacheStats(MainCache).Items; n != items {
+		t.Errorf("NoStore cached its value: %d items; want %d", n, items)
+	}
+	if got := get("scan", NoStore()); got != "scan:5" {
+		t.Errorf("second NoStore = %q; want scan:5", got)
+	}
+	if got := get("key", NoStore()); got != "key:3" {
+		t.Errorf("NoStore of a cached key = %q; want the cached key:3", got)
+	}
+}
+
+func TestForceRefreshHotCache(t *testing.T) {
+	const name = "TestForceRefreshHotCache-group"
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local", time.Time{})
+	}), fakePeers([]ProtoGetter{&fakePeer{}}), &GroupOptions{HotCachePolicy: NeverAdmission()})
+	defer DeregisterGroup(name)
+
+	// A refreshed value the hot cache does not admit drops the old copy.
+	g.localSet("key", ByteView{s: "stale"}, &g.hotCache)
+	var s string
+	if err := g.GetWithOptions(context.Background(), "key", StringSink(&s), ForceRefresh()); err != nil || s != "got:key" {
+		t.Fatalf("ForceRefresh = %q, %v; want got:key", s, err)
+	}
+	if v, ok := g.hotCache.get("key"); ok {
+		t.Errorf("hot cache holds %q after ForceRefresh; want nothing", v)
+	}
+}
+
+// optionsPeer is an in-process gcgrpc.PeerServer recording the last
+// RetrieveRequest it received.
+type optionsPeer struct {
+	gcgrpc.UnimplementedPeerServer
+	mu  sync.Mutex
+	req *gcgrpc.RetrieveRequest
+}
+
+func (p *optionsPeer) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.req = req
+	return &gcgrpc.RetrieveResponse{Value: []byte("got:" + string(req.Key))}, nil
+}
+
+func TestGRPCPoolGetOptions(t *testing.T) {
+	peer := &optionsPeer{}
+	addr, stop := startTestPeer(t, peer)
+	defer stop()
+	pool := newGRPCPool("client", nil)
+	pool.Set(addr)
+	defer pool.Set()
+
+	const name = "TestGRPCPoolGetOptions-group"
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		t.Error("local getter called for a key of the peer")
+		return dest.SetString("local", time.Time{})
+	}), pool)
+	defer DeregisterGroup(name)
+
+	var s string
+	if err := g.GetWithOptions(context.Background(), "key", StringSink(&s), ForceRefresh(), MinFreshness(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	peer.mu.Lock()
+	req := peer.req
+	peer.mu.Unlock()
+	if !req.ForceRefresh || req.NoStore || !req.HasMinFreshness || req.MinFreshness != int64(time.Minute) {
+		t.Errorf("request = %+v; want ForceRefresh and MinFreshness of a minute", req)
+	}
+
+	if err := g.Get(context.Background(), "other", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	peer.mu.Lock()
+	req = peer.req
+	peer.mu.Unlock()
+	if req.ForceRefresh || req.NoStore || req.HasMinFreshness {
+		t.Errorf("request of a plain Get = %+v; want no options", req)
+	}
+}
+
+func TestGetWithResult(t *testing.T) {
+	const name = "TestGetWithResult-group"
+	peer := &fakePeer{}
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local", time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(name)
+
+	get := func(key string) GetResult {
+		t.Helper()
+		var r GetResult
+		var s string
+		if err := g.GetWithOptions(dummyCtx, key, StringSink(&s), WithResult(&r)); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	if r := get("key"); r.Source != SourceGetter || r.Age != 0 {
+		t.Errorf("first Get = %+v; want a load by the getter", r)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if r := get("key"); r.Source != SourceMainCache || r.Age < 10*time.Millisecond {
+		t.Errorf("second Get = %+v; want a main cache hit at least 10ms old", r)
+	}
+
+	g.peers = fakePeers{peer}
+	if r := get("remote"); r.Source != SourcePeer {
+		t.Errorf("Get of a peer's key = %v; want %v", r.Source, SourcePeer)
+	}
+	if r := get("remote"); r.Source != SourceHotCache {
+		t.Errorf("second Get of a peer's key = %v; want %v", r.Source, SourceHotCache)
+	}
+}
@@ -0,0 +1,376 @@
+package groupcache
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GossipOptions are the configurations of the membership gossip of a
+// GRPCPool.
+type GossipOptions struct {
+	// Interval is the time between two gossip rounds, in each of which
+	// the pool exchanges its membership with Fanout random peers.
+	// Changes are also gossiped as soon as they are made.
+	// If blank, it defaults to 1 second.
+	Interval time.Duration
+
+	// Fanout is the number of peers gossiped with in a round.
+	// If blank, it defaults to 3.
+	Fanout int
+
+	// TombstoneTTL is how long a removed peer is remembered, so stale
+	// gossip from a peer that did not hear of the removal yet cannot
+	// add it back. It should be much longer than it takes a change to
+	// reach every peer.
+	// If blank, it defaults to 1 hour.
+	TombstoneTTL time.Duration
+}
+
+// member is the state of a peer in the membership of a pool.
+type member struct {
+	weight      int
+	zone        string
+	incarnation uint64
+	left        bool
+	leftAt      time.Time // when left was set, to expire the tombstone
+}
+
+func (m *member) spec(addr string) string {
+	return peerSpecString(addr, m.weight, m.zone, 0)
+}
+
+// gossipState is the membership of a pool with Gossip.
+type gossipState struct {
+	self string
+	log  func() Logger
+
+	// mu is held while the ring is changed to the members, so the
+	// changes apply in order.
+	mu      sync.Mutex
+	members map[string]*member
+	leaving bool // self was removed locally and must not refute it
+
+	notify chan struct{} // signals RunGossip to gossip a change
+}
+
+func newGossipState(self, zone string, log func() Logger) *gossipState {
+	return &gossipState{
+		self:    self,
+		log:     log,
+		members: map[string]*member{self: {weight: 1, zone: zone}},
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// updateMembers changes the membership with fn, which reports whether
+// it changed, then changes the ring to the present members and has
+// RunGossip gossip the change.
+func (gp *GRPCPool) updateMembers(fn func(s *gossipState) bool) {
+	s := gp.gossip
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !fn(s) {
+		return
+	}
+	gp.applyPeers(s.present())
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// add marks the peers of specs as present, with a higher incarnation
+// if they were removed or their weight or zone changed. s.mu must be
+// held.
+func (s *gossipState) add(specs []string) bool {
+	var changed bool
+	for _, spec := range specs {
+		peer, weight, zone, err := parsePeer(spec)
+		if err != nil {
+			s.log().Warn("Ignoring peer", "peer", spec, "err", err)
+			continue
+		}
+		m, ok := s.members[peer]
+		switch {
+		case !ok:
+			s.members[peer] = &member{weight: weight, zone: zone}
+		case m.left || m.weight != weight || m.zone != zone:
+			m.incarnation++
+			m.weight, m.zone, m.left = weight, zone, false
+		default:
+			continue
+		}
+		if peer == s.self {
+			s.leaving = false
+		}
+		changed = true
+	}
+	return changed
+}
+
+// remove marks the peers of specs as removed. Removing self makes the
+// pool leave rather than refute its removal. s.mu must be held.
+func (s *gossipState) remove(specs []string) bool {
+	var changed bool
+	for _, spec := range specs {
+		peer := peerAddr(spec)
+		m, ok := s.members[peer]
+		switch {
+		case !ok:
+			// Remembered so gossip that did not see the removal yet
+			// does not add the peer back.
+			s.members[peer] = &member{weight: 1, left: true, leftAt: time.Now()}
+		case !m.left:
+			m.left, m.leftAt = true, time.Now()
+		default:
+			continue
+		}
+		if peer == s.self {
+			s.leaving = true
+		}
+		changed = true
+	}
+	return changed
+}
+
+// set makes the peers of specs the only present members, except that
+// self is only removed explicitly. s.mu must be held.
+func (s *gossipState) set(specs []string) bool {
+	want := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		want[peerAddr(spec)] = true
+	}
+	var remove []string
+	for peer, m := range s.members {
+		if !m.left && !want[peer] && peer != s.self {
+			remove = append(remove, peer)
+		}
+	}
+	added := s.add(specs)
+	return s.remove(remove) || added
+}
+
+// merge applies the members gossiped by another peer: the state with
+// the higher incarnation wins, and on a tie a removal wins. Self
+// refutes a removal it did not make with a higher incarnation, so a
+// running peer stays in the membership however it was removed
+// elsewhere. s.mu must be held.
+func (s *gossipState) merge(remote []*gcgrpc.Member) bool {
+	var changed bool
+	for _, r := range remote {
+		if r.Addr == "" {
+			continue
+		}
+		weight := int(r.Weight)
+		if weight < 1 {
+			weight = 1
+		}
+		m, ok := s.members[r.Addr]
+		if r.Addr == s.self {
+			switch {
+			case s.leaving:
+				// Gossip the removal with the higher incarnation.
+				if r.Incarnation > m.incarnation {
+					m.incarnation = r.Incarnation
+					changed = true
+				}
+			case r.Incarnation > m.incarnation || (r.Incarnation == m.incarnation && r.Left):
+				if r.Left {
+					m.incarnation = r.Incarnation + 1
+				} else {
+					m.incarnation, m.weight, m.zone = r.Incarnation, weight, r.Zone
+				}
+				changed = true
+			}
+			continue
+		}
+		if ok && (r.Incarnation < m.incarnation || (r.Incarnation == m.incarnation && (m.left || !r.Left))) {
+			continue
+		}
+		if !ok {
+			m = &member{}
+			s.members[r.Addr] = m
+		}
+		if r.Left && !m.left {
+			m.leftAt = time.Now()
+		}
+		m.weight, m.zone, m.incarnation, m.left = weight, r.Zone, r.Incarnation, r.Left
+		changed = true
+	}
+	return changed
+}
+
+// present returns the specs of the members that were not removed.
+// s.mu must be held.
+func (s *gossipState) present() []string {
+	var res []string
+	for peer, m := range s.members {
+		if !m.left {
+			res = append(res, m.spec(peer))
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// snapshot returns every member, to gossip to another peer. s.mu must
+// be held.
+func (s *gossipState) snapshot() []*gcgrpc.Member {
+	res := make([]*gcgrpc.Member, 0, len(s.members))
+	for peer, m := range s.members {
+		res = append(res, &gcgrpc.Member{Addr: peer, Weight: int32(m.weight), Zone: m.zone, Incarnation: m.incarnation, Left: m.left})
+	}
+	return res
+}
+
+// expire forgets the members removed before cutoff. s.mu must be held.
+func (s *gossipState) expire(cutoff time.Time) {
+	for peer, m := range s.members {
+		if m.left && peer != s.self && m.leftAt.Before(cutoff) {
+			delete(s.members, peer)
+		}
+	}
+}
+
+// Gossip merges the membership of the calling peer and answers with
+// the membership of gp, so both end up with the newer state of every
+// member.
+func (gp *GRPCPool) Gossip(ctx context.Context, req *gcgrpc.GossipMessage) (*gcgrpc.GossipMessage, error) {
+	if gp.gossip == nil {
+		return nil, status.Error(codes.Unimplemented, "groupcache: gossip is not enabled")
+	}
+	var res *gcgrpc.GossipMessage
+	gp.updateMembers(func(s *gossipState) bool {
+		changed := s.merge(req.Members)
+		res = &gcgrpc.GossipMessage{Members: s.snapshot()}
+		if changed {
+			gp.Stats.GossipUpdates.Add(1)
+		}
+		return changed
+	})
+	return res, nil
+}
+
+// RunGossip gossips the membership of the pool until ctx is done. It
+// first gossips with seeds, the addresses of a few peers of the
+// cluster, and again in every round in which it knows of no other
+// peer, so a new pool only needs to be told about one running peer to
+// join. It blocks, so it is usually run in its own goroutine. Nothing
+// is gossiped unless GRPCPoolOptions.Gossip is set.
+func (gp *GRPCPool) RunGossip(ctx context.Context, seeds ...string) {
+	if gp.gossip == nil {
+		gp.log().Warn("RunGossip called without GRPCPoolOptions.Gossip")
+		return
+	}
+	opts := *gp.opts.Gossip
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.Fanout <= 0 {
+		opts.Fanout = 3
+	}
+	if opts.TombstoneTTL <= 0 {
+		opts.TombstoneTTL = time.Hour
+	}
+
+	// Adds self to the ring.
+	gp.updateMembers(func(*gossipState) bool { return true })
+
+	t := time.NewTicker(opts.Interval)
+	defer t.Stop()
+	for {
+		gp.gossipRound(ctx, seeds, opts)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-gp.gossip.notify:
+		}
+	}
+}
+
+// gossipRound exchanges the membership with up to opts.Fanout random
+// present peers, or with seeds if there are none.
+func (gp *GRPCPool) gossipRound(ctx context.Context, seeds []string, opts GossipOptions) {
+	s := gp.gossip
+	s.mu.Lock()
+	s.expire(time.Now().Add(-opts.TombstoneTTL))
+	var targets []string
+	for peer, m := range s.members {
+		if !m.left && peer != s.self {
+			targets = append(targets, peer)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(targets) == 0 {
+		for _, seed := range seeds {
+			if seed = peerAddr(seed); seed != gp.self {
+				targets = append(targets, seed)
+			}
+		}
+	}
+	rand.Shuffle(len(targets), func(i, j int) { targets[i], targets[j] = targets[j], targets[i] })
+	if len(targets) > opts.Fanout {
+		targets = targets[:opts.Fanout]
+	}
+
+	for _, peer := range targets {
+		ctx, cancel := context.WithTimeout(ctx, opts.Interval)
+		err := gp.gossipWith(ctx, peer)
+		cancel()
+		if err != nil {
+			gp.log().Debug("Failed to gossip", "peer", peer, "err", err)
+		}
+	}
+}
+
+// gossipWith exchanges the membership with peer, connecting to it for
+// this exchange only if it is not a peer of the ring, as seeds are.
+func (gp *GRPCPool) gossipWith(ctx context.Context, peer string) error {
+	gp.mu.Lock()
+	getter, ok := gp.grpcGetters[peer]
+	gp.mu.Unlock()
+	if !ok {
+		var err error
+		if getter, err = newGRPCGetter(peer, &gp.opts, &gp.Stats); err != nil {
+			return err
+		}
+		defer getter.close()
+	}
+
+	s := gp.gossip
+	s.mu.Lock()
+	req := &gcgrpc.GossipMessage{Members: s.snapshot()}
+	s.mu.Unlock()
+
+	gp.Stats.GossipRounds.Add(1)
+	res, err := getter.gossip(ctx, req)
+	if err != nil {
+		return err
+	}
+	gp.updateMembers(func(s *gossipState) bool {
+		if !s.merge(res.Members) {
+			return false
+		}
+		gp.Stats.GossipUpdates.Add(1)
+		return true
+	})
+	return nil
+}
+
+func (g *grpcGetter) gossip(ctx context.Context, req *gcgrpc.GossipMessage) (*gcgrpc.GossipMessage, error) {
+	conn, err := g.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer g.end(conn)
+	return gcgrpc.NewPeerClient(conn).Gossip(ctx, req)
+}
@@ -0,0 +1,145 @@
+package groupcache
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+	"google.golang.org/grpc"
+)
+
+// startGossipPool serves a pool with Gossip whose self is the address
+// it listens on.
+func startGossipPool(t *testing.T) (*GRPCPool, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := newGRPCPool(lis.Addr().String(), &GRPCPoolOptions{Gossip: &GossipOptions{Interval: 10 * time.Millisecond}})
+	server := grpc.NewServer()
+	gcgrpc.RegisterPeerServer(server, pool)
+	go server.Serve(lis)
+	return pool, func() {
+		server.Stop()
+		pool.applyPeers(nil)
+	}
+}
+
+func peerURLs(gp *GRPCPool) string {
+	var urls []string
+	for _, p := range gp.GetAll() {
+		urls = append(urls, p.GetURL())
+	}
+	sort.Strings(urls)
+	return strings.Join(urls, ",")
+}
+
+func waitForPeers(t *testing.T, gp *GRPCPool, want ...string) {
+	t.Helper()
+	sort.Strings(want)
+	for deadline := time.Now().Add(5 * time.Second); peerURLs(gp) != strings.Join(want, ","); {
+		if time.Now().After(deadline) {
+			t.Fatalf("peers of %s = %s; want %s", gp.self, peerURLs(gp), strings.Join(want, ","))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGossip(t *testing.T) {
+	a, stopA := startGossipPool(t)
+	defer stopA()
+	b, stopB := startGossipPool(t)
+	defer stopB()
+	c, stopC := startGossipPool(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.RunGossip(ctx)
+	go b.RunGossip(ctx, a.self)
+	ctxC, cancelC := context.WithCancel(ctx)
+	go c.RunGossip(ctxC, a.self)
+
+	// Both joined through a only, and learn of each other from it.
+	for _, gp := range []*GRPCPool{a, b, c} {
+		waitForPeers(t, gp, a.self, b.self, c.self)
+	}
+
+	// A weight changed on one pool reaches the others.
+	b.AddPeers(context.Background(), &gcgrpc.Peers{PeerAddr: []string{a.self + "?weight=2"}})
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		weight := c.weights[a.self]
+		c.mu.Unlock()
+		if weight == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("weight of %s on c = %d; want 2", a.self, weight)
+		}
+	}
+
+	// A running peer refutes its removal.
+	a.RemovePeers(context.Background(), &gcgrpc.Peers{PeerAddr: []string{b.self}})
+	waitForPeers(t, a, a.self, b.self, c.self)
+
+	// A stopped peer removed on one pool is removed from all of them.
+	cancelC()
+	stopC()
+	b.RemovePeers(context.Background(), &gcgrpc.Peers{PeerAddr: []string{c.self}})
+	waitForPeers(t, a, a.self, b.self)
+	waitForPeers(t, b, a.self, b.self)
+	if a.Stats.GossipRounds.Get() == 0 || a.Stats.GossipUpdates.Get() == 0 {
+		t.Errorf("GossipRounds = %d, GossipUpdates = %d; want both above zero", a.Stats.GossipRounds.Get(), a.Stats.GossipUpdates.Get())
+	}
+}
+
+func TestGossipMerge(t *testing.T) {
+	s := newGossipState("self", "", func() Logger { return nopLogger{} })
+	s.add([]string{"peer"})
+
+	// A removal wins over a present peer of the same incarnation, but
+	// not over a later one.
+	if !s.merge([]*gcgrpc.Member{{Addr: "peer", Left: true}}) || !s.members["peer"].left {
+		t.Error("removal of the same incarnation was not applied")
+	}
+	if s.merge([]*gcgrpc.Member{{Addr: "peer"}}) {
+		t.Error("presence of the same incarnation overrode the removal")
+	}
+	if !s.merge([]*gcgrpc.Member{{Addr: "peer", Incarnation: 1, Weight: 3}}) || s.members["peer"].left || s.members["peer"].weight != 3 {
+		t.Errorf("later incarnation was not applied: %+v", s.members["peer"])
+	}
+	if s.merge([]*gcgrpc.Member{{Addr: "peer", Left: true}}) {
+		t.Error("older incarnation was applied")
+	}
+	if !s.merge([]*gcgrpc.Member{{Addr: "peer", Incarnation: 2, Weight: 3, Zone: "z1"}}) || s.members["peer"].spec("peer") != "peer?weight=3&zone=z1" {
+		t.Errorf("zone was not applied: %+v", s.members["peer"])
+	}
+
+	// Self refutes a removal with a higher incarnation, unless it left.
+	if !s.merge([]*gcgrpc.Member{{Addr: "self", Incarnation: 4, Left: true}}) || s.members["self"].left || s.members["self"].incarnation != 5 {
+		t.Errorf("self = %+v; want present with incarnation 5", s.members["self"])
+	}
+	s.remove([]string{"self"})
+	s.merge([]*gcgrpc.Member{{Addr: "self", Incarnation: 5}})
+	if !s.members["self"].left {
+		t.Error("self refuted its own removal")
+	}
+
+	// A tombstone is forgotten after the TTL.
+	s.expire(time.Now().Add(time.Second))
+	if _, ok := s.members["peer"]; !ok {
+		t.Error("present peer expired")
+	}
+	s.remove([]string{"peer"})
+	s.expire(time.Now().Add(time.Second))
+	if _, ok := s.members["peer"]; ok {
+		t.Error("tombstone did not expire")
+	}
+	if got := strings.Join(s.present(), ","); got != "" {
+		t.Errorf("present = %q; want none", got)
+	}
+}
@@ -27,23 +27,24 @@ package groupcache
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unsafe"
 
 	pb "github.com/adistroy/groupcache/v3/groupcachepb"
-	"github.com/adistroy/groupcache/v3/lru"
+	"github.com/adistroy/groupcache/v3/policy"
 	"github.com/adistroy/groupcache/v3/singleflight"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var logger *logrus.Entry
-
-func SetLogger(log *logrus.Entry) {
-	logger = log
-}
-
 // A Getter loads data for a key.
 type Getter interface {
 	// Get returns the value identified by key, populating dest.
@@ -71,14 +72,54 @@ var (
 )
 
 // GetGroup returns the named group previously created with NewGroup, or
-// nil if there's no such group.
+// nil if there's no such group. Groups created with a
+// GroupOptions.Namespace are found with GetGroupInNamespace instead.
 func GetGroup(name string) *Group {
+	return GetGroupInNamespace("", name)
+}
+
+// GetGroupInNamespace returns the group of name created with
+// GroupOptions.Namespace set to namespace, or nil if there's no such
+// group.
+func GetGroupInNamespace(namespace, name string) *Group {
 	mu.RLock()
-	g := groups[name]
+	g := groups[groupKey(namespace, name)]
 	mu.RUnlock()
 	return g
 }
 
+// groupKey is the key of a group in groups. The names of groups without
+// a namespace are their keys.
+func groupKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "\x00" + name
+}
+
+// qualifiedName returns the key of g in groups.
+func (g *Group) qualifiedName() string {
+	return groupKey(g.opts.Namespace, g.name)
+}
+
+// GetGroups returns all the registered groups, sorted by namespace and
+// name.
+func GetGroups() []*Group {
+	mu.RLock()
+	res := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		res = append(res, g)
+	}
+	mu.RUnlock()
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].opts.Namespace != res[j].opts.Namespace {
+			return res[i].opts.Namespace < res[j].opts.Namespace
+		}
+		return res[i].name < res[j].name
+	})
+	return res
+}
+
 // NewGroup creates a coordinated group-aware Getter from a Getter.
 //
 // The returned Getter tries (but does not guarantee) to run only one
@@ -87,41 +128,604 @@ func GetGroup(name string) *Group {
 // other processes receive copies of the answer once the original Get
 // completes.
 //
-// The group name must be unique for each getter.
+// The group name must be unique for each getter. Creating a group with
+// the name of a registered group panics; the name may be used again once
+// the group is removed with DeregisterGroup. NewGroupE and
+// GetOrCreateGroup return an error instead.
 func NewGroup(name string, cacheBytes int64, getter Getter) *Group {
 	return newGroup(name, cacheBytes, getter, nil)
 }
 
+// ErrGroupExists is returned when creating a group with the name of a
+// registered group.
+var ErrGroupExists = errors.New("groupcache: group already exists")
+
+// NewGroupE is NewGroup returning an error wrapping ErrGroupExists if a
+// group of name is registered, and an error if getter is nil, rather
+// than panicking.
+func NewGroupE(name string, cacheBytes int64, getter Getter) (*Group, error) {
+	return newGroupOptsE(name, cacheBytes, getter, nil, nil)
+}
+
+// NewGroupOptsE is NewGroupOpts returning an error like NewGroupE.
+func NewGroupOptsE(name string, cacheBytes int64, getter Getter, o *GroupOptions) (*Group, error) {
+	var peers PeerPicker
+	if o != nil {
+		peers = o.Peers
+	}
+	return newGroupOptsE(name, cacheBytes, getter, peers, o)
+}
+
+// GetOrCreateGroup returns the group of name, creating it like NewGroup
+// if it is not registered, so the packages of a library may each create
+// the groups they share on first use. A registered group must have been
+// created with the same cacheBytes and getter, compared by value or, for
+// functions such as a GetterFunc, as the same function value, so each
+// caller must pass the getter it shares rather than a new closure;
+// otherwise an error wrapping ErrGroupExists is returned.
+func GetOrCreateGroup(name string, cacheBytes int64, getter Getter) (*Group, error) {
+	if getter == nil {
+		return nil, errors.New("groupcache: nil Getter")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if g, ok := groups[name]; ok {
+		if g.createBytes != cacheBytes {
+			return nil, fmt.Errorf("%w: %s was created with %d cache bytes, not %d", ErrGroupExists, name, g.createBytes, cacheBytes)
+		}
+		if !sameGetter(g.getter, getter) {
+			return nil, fmt.Errorf("%w: %s was created with another getter", ErrGroupExists, name)
+		}
+		return g, nil
+	}
+	return createGroupLocked(name, cacheBytes, getter, nil, nil), nil
+}
+
+// sameGetter reports whether a and b are the same getter. Functions
+// are the same only if they are the same function value: two closures
+// made by one factory, which may capture different backends, are not.
+// Values that cannot be compared, such as structs holding functions,
+// are never the same.
+func sameGetter(a, b Getter) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	if va.Kind() == reflect.Func {
+		return funcValue(va) == funcValue(vb)
+	}
+	if !va.Type().Comparable() {
+		return false
+	}
+	defer func() {
+		// A comparable type may still hold an incomparable value in
+		// an interface field.
+		recover()
+	}()
+	return a == b
+}
+
+// funcValue returns the pointer to the closure of the function v, which
+// unlike v.Pointer, the pointer to its code, tells closures apart.
+func funcValue(v reflect.Value) unsafe.Pointer {
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return *(*unsafe.Pointer)(p.UnsafePointer())
+}
+
+// GroupOptions are the configurations of a Group.
+type GroupOptions struct {
+	// MaxValueFraction is the largest fraction of cacheBytes a single
+	// entry (key plus value) may occupy and still be cached. Larger
+	// values are returned to the caller but never stored, which stops
+	// two budget sized keys from evicting each other (and everything
+	// else) on every Get.
+	// If zero, every value is cached regardless of its size.
+	MaxValueFraction float64
+
+	// MaxValueBytes is the largest size in bytes of an entry (key plus
+	// value) that is cached, so a few giant blobs cannot evict thousands
+	// of small hot entries. Larger values are returned but never stored,
+	// as with MaxValueFraction.
+	// If zero, entries are only limited by MaxValueFraction.
+	MaxValueBytes int64
+
+	// BypassOversizedTTL is how long a key whose value was too large to
+	// cache is loaded straight from the getter of this process rather
+	// than from its owner, so the value is not copied between peers on
+	// every Get when no peer will cache it anyway. The key is loaded
+	// normally again once its value fits.
+	// If zero, oversized keys are loaded like any other.
+	BypassOversizedTTL time.Duration
+
+	// DeadLetterSize is the number of failed peer operations kept for
+	// inspection by FailedOperations.
+	// If blank, it defaults to 100. If negative, nothing is kept.
+	DeadLetterSize int
+
+	// EvictionLogSize is the number of entries that recently left the
+	// main or hot cache kept for inspection by RecentEvictions.
+	// If zero, none are kept.
+	EvictionLogSize int
+
+	// HotCachePolicy decides which values fetched from peers are
+	// mirrored in the hot cache.
+	// If nil, every value fetched from a peer is added to the hot cache.
+	HotCachePolicy HotCachePolicy
+
+	// DisableHotCache keeps the hot cache of the group empty, for
+	// groups where copies of values owned by other peers waste more
+	// memory than they save requests. Values fetched from peers, Set
+	// with hotCache, or replicated to this peer are then not kept, and
+	// HotCacheRatio is ignored.
+	DisableHotCache bool
+
+	// RefreshWindow enables refresh-ahead: a Get that hits a cached
+	// value expiring within RefreshWindow returns it immediately and
+	// reloads the key in the background, so popular keys are replaced
+	// before they expire instead of making a caller wait for the load.
+	// At most one refresh per key runs at a time. Refreshes run with a
+	// background context that is canceled by DeregisterGroup.
+	// If zero, values are only reloaded once they have expired.
+	RefreshWindow time.Duration
+
+	// StaleWhileRevalidate is how long after its expiry a cached value
+	// is still served while the key is reloaded in the background, as
+	// for RefreshWindow. Only Gets of values that expired longer ago
+	// wait for a load. Values received from peers are accepted within
+	// the same grace period.
+	// If zero, expired values are never served.
+	StaleWhileRevalidate time.Duration
+
+	// Peers is the PeerPicker of the group, for example a pool created
+	// with NewScopedGRPCPool.
+	// If nil, the PeerPicker registered with RegisterPeerPicker or
+	// RegisterPerGroupPeerPicker is used.
+	Peers PeerPicker
+
+	// DefaultTTL is how long values loaded by the getter without an
+	// expiry are cached. Getters choose the expiry of each key with the
+	// time passed to the Sink, which DefaultTTL does not override.
+	// If zero, such values never expire.
+	DefaultTTL time.Duration
+
+	// SweepInterval is how often expired values are removed from the
+	// caches in the background. Expired values are never returned by
+	// Get, but without sweeping they only leave the cache once evicted
+	// by newer values.
+	// If zero, expired values are not swept.
+	SweepInterval time.Duration
+
+	// OnPeerError decides what Get does when the owner of a key, and
+	// the failover peers of a FailoverPicker, failed to return it.
+	// If blank, it defaults to LoadLocally.
+	OnPeerError PeerErrorPolicy
+
+	// NegativeTTL is how long a key the getter reported as ErrNotFound
+	// is remembered, so that Gets for it fail with ErrNotFound without
+	// calling the getter again. Set, Remove and Flush forget the key
+	// early. Keep it short: a value created in the backing store is
+	// not seen until the key is forgotten.
+	// If zero, missing keys are not remembered.
+	NegativeTTL time.Duration
+
+	// NegativeCacheSize is the number of missing keys remembered for
+	// NegativeTTL. The least recently used are forgotten first.
+	// If blank, it defaults to 1000.
+	NegativeCacheSize int
+
+	// GetterRetry retries the getter with backoff when it fails with
+	// an error for which IsTemporary is true, so a short outage of the
+	// backing store does not fail every Get of the keys being loaded.
+	// Concurrent Gets of a key share the retries of a single load, so
+	// the backing store is not asked more often than without retries.
+	// Other errors are returned at once.
+	// If nil, the getter is called once.
+	GetterRetry *RetryOptions
+
+	// PermanentErrorTTL is how long the error of a key the getter
+	// failed with a Permanent error is remembered, so that Gets for it
+	// fail with that error without calling the getter again. Set,
+	// Remove and Flush forget the key early, and NegativeCacheSize
+	// bounds the number of keys remembered.
+	// If zero, permanent errors are not remembered.
+	PermanentErrorTTL time.Duration
+
+	// ErrorCooldown is how long the error of a failed load of a key is
+	// returned to the Gets of that key on this process without loading
+	// it again, whatever the error, so a failing backend is not hit by
+	// every Get. Shorter than PermanentErrorTTL, it suits errors that
+	// will go away. ErrNotFound, which NegativeTTL covers, context
+	// errors and ErrTooManyLoads are not remembered. Set, Remove,
+	// Flush and ForceRefresh end the cooldown early.
+	// If zero, the next Get after a failed load loads the key again.
+	ErrorCooldown time.Duration
+
+	// ErrorCooldownJitter adds up to this fraction of ErrorCooldown, at
+	// random, to the cooldown of each error, so the processes of a
+	// cluster that saw the same failure do not load the key again all
+	// at once.
+	// If blank, it defaults to 0.2.
+	ErrorCooldownJitter float64
+
+	// RemoveTombstoneTTL is how long a key removed with Remove is
+	// remembered on every peer the removal reached. Within it Gets on
+	// any of them refuse copies of the key in the hot cache, and values
+	// fetched from peers are not admitted to it, so a copy the owner
+	// served just before the removal cannot be read after it: the key
+	// is fetched from its owner instead. NegativeCacheSize bounds the
+	// number of keys remembered.
+	// If zero, hot cache copies are served right after a Remove.
+	RemoveTombstoneTTL time.Duration
+
+	// MaxConcurrentLoads is the number of getter calls of the group
+	// that run at once, across all keys, so a burst of Gets for cold
+	// keys cannot open thousands of connections to the backing store.
+	// Loads arriving while all slots are taken wait for one, until
+	// their context is done or LoadQueueTimeout passes, when they fail
+	// with ErrTooManyLoads.
+	// If zero, concurrent loads are only deduplicated by key.
+	MaxConcurrentLoads int
+
+	// LoadQueueTimeout is how long a load waits for a
+	// MaxConcurrentLoads slot.
+	// If zero, loads wait until their context is done. If negative,
+	// loads fail as soon as all slots are taken.
+	LoadQueueTimeout time.Duration
+
+	// CachePolicy decides which entries the main and hot caches evict
+	// when they are full, for example policy.TinyLFU so that scans of
+	// keys read once do not evict hot keys.
+	// If nil, the least recently used entries are evicted.
+	CachePolicy policy.New
+
+	// Cost returns what an entry counts against cacheBytes, such as the
+	// size of its value once decoded or a weight for the CPU it takes
+	// to load again, so the caches evict by what holding entries costs
+	// rather than by their raw length. It is called once as each entry
+	// is cached, and must be cheap; negative costs count as zero.
+	// MaxValueBytes and MaxValueFraction still limit the length of
+	// entries.
+	// If nil, an entry costs the length of its key and value.
+	Cost func(key string, value ByteView) int64
+
+	// EntryOverhead is added to the cost of every entry for the memory
+	// the caches spend holding it besides its key and value, so that
+	// cacheBytes also bounds the memory of many small entries.
+	// DefaultEntryOverhead estimates it for the built-in policies.
+	// If zero, only the cost of entries counts against cacheBytes.
+	EntryOverhead int64
+
+	// Logger receives the log messages of the group.
+	// If nil, the Logger set with SetLogger is used.
+	Logger Logger
+
+	// TierTwo is a second, typically persistent, cache consulted after
+	// the main and hot caches miss and before the getter is called,
+	// such as a disk.Store. Values the getter loads and values Set on
+	// this peer as their owner are written to it.
+	// If nil, misses go straight to the getter.
+	TierTwo TierTwo
+
+	// TierTwoTimeout bounds each call of the TierTwo, so a slow second
+	// tier holds up a load, Set or Remove by at most this long. The
+	// caches are never locked during a call.
+	// If zero, calls of the TierTwo time out after a second.
+	TierTwoTimeout time.Duration
+
+	// WriteBack turns on write-back mode: Set stores the value in the
+	// cache as usual, then queues it to be persisted asynchronously,
+	// in batches, by WriteBack.Flusher, so the cache absorbs bursts of
+	// writes for backing stores that tolerate values being persisted
+	// a little late. Values are queued on the process that called Set
+	// and are lost if it dies before they are flushed; a Get of a key
+	// whose value was evicted before its flush loads the older value
+	// with the getter. Call FlushWriteBack or DeregisterGroupContext
+	// before the process exits.
+	// If nil, Set only stores the value in the cache.
+	WriteBack *WriteBackOptions
+
+	// Namespace separates groups of the same name, such as those of
+	// different tenants or environments served by the same processes.
+	// Peers look the group up in the namespace of the GRPCPool the
+	// request came from, so the group must use a pool whose
+	// GRPCPoolOptions.Namespace is the same. HTTPPool only serves
+	// groups without a namespace.
+	// If blank, the group has no namespace and is found by GetGroup.
+	Namespace string
+
+	// CacheShards is the number of shards the main and hot caches are
+	// each split into by key hash, each with its own lock, to reduce
+	// lock contention on machines with many cores. Each shard runs its
+	// own CachePolicy, and eviction takes from the largest shard, so
+	// eviction order is only approximately that of a single cache.
+	// If zero or one, the caches are not sharded.
+	CacheShards int
+
+	// HotCacheRatio is the share of cacheBytes reserved for the hot
+	// cache, between 0 and 1; the main cache gets the rest. Each cache
+	// then only evicts its own entries.
+	// If zero, the caches share cacheBytes, and the hot cache is
+	// evicted from first whenever it holds more than an eighth of the
+	// bytes of the main cache.
+	HotCacheRatio float64
+
+	// MemoryBudget caps the bytes cached by this group together with
+	// the other groups given the same budget, which shares it between
+	// them by their cache hits. The cacheBytes of the group then only
+	// bounds its share; zero leaves it unbounded. The main and hot
+	// caches only evict their own entries, split by HotCacheRatio or,
+	// if it is zero, with an eighth of the main cache for the hot one.
+	// If nil, the group caches up to cacheBytes on its own.
+	MemoryBudget *MemoryBudget
+
+	// Profile starts profiling the group when it is created, as
+	// SetProfiling does.
+	// If nil, the group is not profiled until SetProfiling is called.
+	Profile *ProfileOptions
+
+	// Faults is consulted before every load of the group from a peer
+	// or its getter and may delay or fail it, or truncate the value a
+	// peer returned, to test how the application copes. Peers serving
+	// the group apply their own Faults to the loads they make.
+	// If nil, no faults are injected.
+	Faults FaultInjector
+
+	// KeyEncoder, such as one returned by NewKeyCipher, encodes keys
+	// when they are given to the group, so keys bearing personal data
+	// such as emails or tokens are only stored, sent to peers, logged,
+	// traced, profiled and given to hooks encoded. Only the getter and
+	// the WriteBack Flusher receive them decoded, and GetMulti returns
+	// the keys it was given. Every peer must use the same KeyEncoder.
+	// The owner of a key may only have received its encoding from a
+	// peer, so the encoding must be reversible: one-way encoders such
+	// as hashes cannot be used. RemovePrefix fails, as prefixes of keys
+	// are not prefixes of their encodings.
+	// If nil, keys are used as they are.
+	KeyEncoder KeyEncoder
+
+	// Revalidate periodically checks the values cached by the group
+	// against their origin with its Validator, reloading or removing
+	// those that changed, so data changed without a Set or Remove
+	// through the cache is eventually consistent without a short TTL.
+	// Each process validates the values it caches, hot copies included.
+	// If nil, values are cached until they expire, are evicted or are
+	// removed.
+	Revalidate *RevalidateOptions
+}
+
+// PeerErrorPolicy is what a Group does when the peers it asked for a
+// key failed to return it.
+type PeerErrorPolicy int
+
+const (
+	// LoadLocally loads the key with the group's getter, as if this
+	// process owned it.
+	LoadLocally PeerErrorPolicy = iota
+
+	// ReturnPeerError returns the error of the last peer to the caller
+	// without calling the getter, for getters that must only run on
+	// the owner of a key.
+	ReturnPeerError
+)
+
+// NewGroupOpts creates a new group like NewGroup with the given options.
+func NewGroupOpts(name string, cacheBytes int64, getter Getter, o *GroupOptions) *Group {
+	var peers PeerPicker
+	if o != nil {
+		peers = o.Peers
+	}
+	return newGroupOpts(name, cacheBytes, getter, peers, o)
+}
+
 // DeregisterGroup removes group from group pool
 func DeregisterGroup(name string) {
 	mu.Lock()
+	g := groups[name]
 	delete(groups, name)
 	mu.Unlock()
+	if g != nil {
+		g.cancelBackground()
+		if b := g.opts.MemoryBudget; b != nil {
+			b.unregister(g)
+		}
+	}
+}
+
+// DeregisterGroupContext removes the group of name like DeregisterGroup,
+// so a new group of that name may be created right away, then waits for
+// the loads in flight in the removed group to finish and empties its
+// main and hot cache, so long-lived servers can tear down groups, such
+// as those of a tenant, without keeping their memory until the last
+// reference is dropped. Its TierTwo is left as is, for a group created
+// again with the name. Gets the removed group receives meanwhile are
+// still served.
+// If ctx is done first the caches are emptied anyway and ctx.Err() is
+// returned. Removing a group that does not exist is not an error.
+func DeregisterGroupContext(ctx context.Context, name string) error {
+	return DeregisterGroupInNamespace(ctx, "", name)
+}
+
+// DeregisterGroupInNamespace is DeregisterGroupContext for the group of
+// name created with GroupOptions.Namespace set to namespace.
+func DeregisterGroupInNamespace(ctx context.Context, namespace, name string) error {
+	key := groupKey(namespace, name)
+	mu.Lock()
+	g := groups[key]
+	delete(groups, key)
+	mu.Unlock()
+	if g == nil {
+		return nil
+	}
+	g.cancelBackground()
+	if b := g.opts.MemoryBudget; b != nil {
+		b.unregister(g)
+	}
+	err := g.drain(ctx)
+	if ferr := g.FlushWriteBack(ctx); err == nil {
+		err = ferr
+	}
+	// The TierTwo outlives the group, like the data source it caches.
+	g.emptyCaches(false)
+	return err
+}
+
+// GroupExists reports whether a group of name is registered.
+func GroupExists(name string) bool {
+	return GetGroup(name) != nil
+}
+
+// drain waits until no fetch is in flight or ctx is done.
+func (g *Group) drain(ctx context.Context) error {
+	g.fetchesMu.Lock()
+	if g.fetches == 0 {
+		g.fetchesMu.Unlock()
+		return nil
+	}
+	if g.idle == nil {
+		g.idle = make(chan struct{})
+	}
+	idle := g.idle
+	g.fetchesMu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (g *Group) beginFetch() {
+	g.fetchesMu.Lock()
+	g.fetches++
+	g.fetchesMu.Unlock()
+}
+
+func (g *Group) endFetch() {
+	g.fetchesMu.Lock()
+	defer g.fetchesMu.Unlock()
+	if g.fetches--; g.fetches == 0 && g.idle != nil {
+		close(g.idle)
+		g.idle = nil
+	}
 }
 
 // If peers is nil, the peerPicker is called via a sync.Once to initialize it.
 func newGroup(name string, cacheBytes int64, getter Getter, peers PeerPicker) *Group {
+	return newGroupOpts(name, cacheBytes, getter, peers, nil)
+}
+
+func newGroupOpts(name string, cacheBytes int64, getter Getter, peers PeerPicker, o *GroupOptions) *Group {
 	if getter == nil {
 		panic("nil Getter")
 	}
+	g, err := newGroupOptsE(name, cacheBytes, getter, peers, o)
+	if err != nil {
+		panic("duplicate registration of group " + name)
+	}
+	return g
+}
+
+func newGroupOptsE(name string, cacheBytes int64, getter Getter, peers PeerPicker, o *GroupOptions) (*Group, error) {
+	if getter == nil {
+		return nil, errors.New("groupcache: nil Getter")
+	}
+	var namespace string
+	if o != nil {
+		namespace = o.Namespace
+	}
 	mu.Lock()
-	defer mu.Unlock()
+	if _, dup := groups[groupKey(namespace, name)]; dup {
+		mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrGroupExists, name)
+	}
+	g := createGroupLocked(name, cacheBytes, getter, peers, o)
+	mu.Unlock()
+	if b := g.opts.MemoryBudget; b != nil {
+		// Outside of mu: shrinking the other groups runs their
+		// eviction hooks.
+		b.register(g)
+	}
+	return g, nil
+}
+
+// createGroupLocked creates and registers a group whose name is not
+// registered. mu must be held.
+func createGroupLocked(name string, cacheBytes int64, getter Getter, peers PeerPicker, o *GroupOptions) *Group {
 	initPeerServerOnce.Do(callInitPeerServer)
-	if _, dup := groups[name]; dup {
-		panic("duplicate registration of group " + name)
+	var namespace string
+	if o != nil {
+		namespace = o.Namespace
 	}
 	g := &Group{
-		name:        name,
-		getter:      getter,
-		peers:       peers,
-		cacheBytes:  cacheBytes,
-		loadGroup:   &singleflight.Group{},
-		removeGroup: &singleflight.Group{},
+		name:          name,
+		getter:        getter,
+		peers:         peers,
+		cacheBytes:    cacheBytes,
+		createBytes:   cacheBytes,
+		hotCacheBytes: -1,
+		loadGroup:     &singleflight.Group{},
+		removeGroup:   &singleflight.Group{},
+	}
+	g.background, g.cancelBackground = context.WithCancel(context.Background())
+	if o != nil {
+		g.opts = *o
+	}
+	if g.opts.ErrorCooldown > 0 {
+		jitter := g.opts.ErrorCooldownJitter
+		if jitter == 0 {
+			jitter = 0.2
+		}
+		g.loadGroup = &singleflight.Group{
+			ErrorCooldown:  g.opts.ErrorCooldown,
+			CooldownJitter: jitter,
+			RememberError:  cooldownError,
+		}
+	}
+	g.misses = newNegativeCache(g.opts.NegativeTTL, g.opts.NegativeCacheSize)
+	g.oversized = newNegativeCache(g.opts.BypassOversizedTTL, 0)
+	g.failures = newNegativeCache(g.opts.PermanentErrorTTL, g.opts.NegativeCacheSize)
+	g.tombstones = newNegativeCache(g.opts.RemoveTombstoneTTL, g.opts.NegativeCacheSize)
+	if g.opts.GetterRetry != nil {
+		g.getterRetry = newRetryPolicy(*g.opts.GetterRetry, IsTemporary)
+	}
+	if g.opts.MaxConcurrentLoads > 0 {
+		g.loadSem = make(chan struct{}, g.opts.MaxConcurrentLoads)
+	}
+	if r := g.opts.HotCacheRatio; r > 0 && r < 1 && !g.opts.DisableHotCache {
+		g.hotCacheBytes = int64(r * float64(cacheBytes))
+	}
+	g.mainCache.init(g.opts.CacheShards, g.opts.CachePolicy, g.opts.StaleWhileRevalidate, g.entryCost)
+	g.hotCache.init(g.opts.CacheShards, g.opts.CachePolicy, g.opts.StaleWhileRevalidate, g.entryCost)
+	if g.opts.DeadLetterSize == 0 {
+		g.opts.DeadLetterSize = defaultDeadLetterSize
+	}
+	if g.opts.EvictionLogSize > 0 {
+		g.updateEvictHooks()
+	}
+	if g.opts.SweepInterval > 0 {
+		go g.sweep(g.opts.SweepInterval)
+	}
+	if g.opts.WriteBack != nil {
+		g.writeBack = newWriteBack(*g.opts.WriteBack)
+		go g.runWriteBack()
+	}
+	if g.opts.Revalidate != nil && g.opts.Revalidate.Validator != nil {
+		go g.runRevalidate()
+	}
+	if g.opts.Profile != nil {
+		g.SetProfiling(g.opts.Profile)
+	}
+	if g.opts.MemoryBudget != nil {
+		// Nothing is cached until the budget gives the group a share.
+		g.budgetMax = cacheBytes
+		g.SetCacheBytes(0, 0)
 	}
 	if fn := newGroupHook; fn != nil {
 		fn(g)
 	}
-	groups[name] = g
+	groups[groupKey(namespace, name)] = g
 	return g
 }
 
@@ -155,11 +759,19 @@ func callInitPeerServer() {
 // A Group is a cache namespace and associated data loaded spread over
 // a group of 1 or more machines.
 type Group struct {
-	name       string
-	getter     Getter
-	peersOnce  sync.Once
-	peers      PeerPicker
-	cacheBytes int64 // limit for sum of mainCache and hotCache size
+	// cacheBytes is the limit for the sum of mainCache and hotCache
+	// size, and hotCacheBytes the part of it reserved for hotCache, or
+	// -1 if the caches share cacheBytes. They are accessed atomically
+	// and kept as the first fields so they are 8-byte aligned on
+	// 32-bit platforms.
+	cacheBytes    int64
+	hotCacheBytes int64
+
+	name      string
+	getter    Getter
+	peersOnce sync.Once
+	peers     PeerPicker
+	opts      GroupOptions
 
 	// mainCache is a cache of the keys for which this process
 	// (amongst its peers) is authoritative. That is, this cache
@@ -186,8 +798,73 @@ type Group struct {
 	// remotely once regardless of the number of concurrent callers.
 	removeGroup flightGroup
 
+	fetchesMu sync.Mutex    // guards fetches and idle
+	fetches   int           // fetches in flight, for DeregisterGroupContext
+	idle      chan struct{} // closed when fetches drops to zero
+
+	// failedOps records peer operations that failed.
+	failedOps ring[FailedOp]
+
+	// evictions records the entries that recently left the caches.
+	evictions ring[Eviction]
+
+	// misses remembers keys the getter could not find, nil unless
+	// NegativeTTL is set.
+	misses *negativeCache
+
+	// oversized remembers keys whose values were too large to cache,
+	// nil unless BypassOversizedTTL is set.
+	oversized *negativeCache
+
+	// failures remembers the errors of keys the getter failed with a
+	// Permanent error, nil unless PermanentErrorTTL is set.
+	failures *negativeCache
+
+	// tombstones remembers keys removed recently, nil unless
+	// RemoveTombstoneTTL is set.
+	tombstones *negativeCache
+
+	// loadSem holds a token for each getter call in progress, nil
+	// unless MaxConcurrentLoads is set.
+	loadSem chan struct{}
+
+	// getterRetry retries temporary getter errors, nil unless
+	// GetterRetry is set.
+	getterRetry *retryPolicy
+
+	// writeBack queues values Set to be flushed, nil unless WriteBack
+	// is set.
+	writeBack *writeBack
+
+	// budgetMax bounds the share of the MemoryBudget of the group, if
+	// positive.
+	budgetMax int64
+
+	// createBytes is the cacheBytes the group was created with, for
+	// GetOrCreateGroup.
+	createBytes int64
+
+	// profile holds the *profiler of the group, nil unless profiling.
+	profile atomic.Value
+
+	hooksMu   sync.RWMutex // guards onLoad, onEvict and onRemoved
+	onLoad    func(key string, value ByteView, local bool)
+	onEvict   func(key string, value ByteView)
+	onRemoved func(key string, value ByteView, reason EvictReason)
+
+	// background is the context of refreshes, canceled when the group
+	// is deregistered.
+	background       context.Context
+	cancelBackground context.CancelFunc
+
+	refreshMu  sync.Mutex // guards refreshing
+	refreshing map[string]struct{}
+
 	_ int32 // force Stats to be 8-byte aligned on 32-bit platforms
 
+	// generation counts the calls of localBumpGeneration.
+	generation AtomicInt
+
 	// Stats are statistics on the group.
 	Stats Stats
 }
@@ -197,7 +874,17 @@ type Group struct {
 // implementation.
 type flightGroup interface {
 	Do(key string, fn func() (interface{}, error)) (interface{}, error)
+	DoContext(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error)
 	Lock(fn func())
+	Forget(key string)
+	ForgetAll()
+}
+
+// cooldownError reports whether the error of a load is remembered for
+// GroupOptions.ErrorCooldown.
+func cooldownError(err error) bool {
+	return !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrTooManyLoads) &&
+		!errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
 }
 
 // Stats are per-group statistics.
@@ -212,6 +899,31 @@ type Stats struct {
 	LocalLoads               AtomicInt // total good local loads
 	LocalLoadErrs            AtomicInt // total bad local loads
 	ServerRequests           AtomicInt // gets that came over the network from peers
+	NegativeHits             AtomicInt // gets answered by the negative cache
+	StaleHits                AtomicInt // cache hits served past their expiry
+	TierTwoHits              AtomicInt // loads answered by the TierTwo
+	OversizedBypasses        AtomicInt // oversized keys loaded locally instead of from their owner
+	HedgedLoads              AtomicInt // peer loads also sent to a failover peer because the first was slow
+	HedgeWins                AtomicInt // hedged peer loads the failover peer answered first
+	GetterRetries            AtomicInt // getter calls retried after a temporary error
+	PermanentErrorHits       AtomicInt // gets answered with a remembered permanent error
+	TombstoneHits            AtomicInt // hot cache copies refused for a recently removed key
+	LoadsQueued              AtomicInt // getter calls that waited for a MaxConcurrentLoads slot
+	LoadsRejected            AtomicInt // getter calls that failed with ErrTooManyLoads
+	WriteBackFlushed         AtomicInt // values persisted by the WriteBack Flusher
+	WriteBackDropped         AtomicInt // values dropped after the WriteBack Flusher failed
+	FaultsInjected           AtomicInt // faults injected into loads by GroupOptions.Faults
+	Revalidations            AtomicInt // cached values checked by the Revalidate Validator
+	RevalidationChanges      AtomicInt // cached values the Revalidate Validator found stale
+	UnleasedLoads            AtomicInt // values loaded as owner but not cached for lack of a lease
+
+	LocalLoadLatency Histogram // durations of the loads by the getter
+	PeerLoadLatency  Histogram // durations of the loads from peers, failed or not
+	ServerLatency    Histogram // durations of the Gets served to peers
+}
+
+func (g *Group) log() Logger {
+	return pickLogger(g.opts.Logger)
 }
 
 // Name returns the name of the group.
@@ -219,44 +931,140 @@ func (g *Group) Name() string {
 	return g.name
 }
 
+// Namespace returns the namespace of the group, as in GroupOptions.
+func (g *Group) Namespace() string {
+	return g.opts.Namespace
+}
+
 func (g *Group) initPeers() {
 	if g.peers == nil {
 		g.peers = getPeers(g.name)
 	}
 }
 
+// Get loads the value of key into dest, from the caches, the peer
+// owning key or the getter. A key may hold arbitrary bytes, such as a
+// binary UUID or hash converted with string(id[:]); it is sent to peers
+// as it is, without an encoding such as hex or base64, unless the group
+// has a KeyEncoder.
 func (g *Group) Get(ctx context.Context, key string, dest Sink) error {
+	return g.get(ctx, g.encodeKey(key), dest)
+}
+
+// get is Get of a key encoded already, as those sent by peers are.
+func (g *Group) get(ctx context.Context, key string, dest Sink) (err error) {
 	g.peersOnce.Do(g.initPeers)
 	g.Stats.Gets.Add(1)
 	if dest == nil {
 		return errors.New("groupcache: nil dest Sink")
 	}
-	value, cacheHit := g.lookupCache(key)
+	if p := g.profiler(); p != nil {
+		p.record(key)
+	}
+	ctx, span := startSpan(ctx, "groupcache.Get", g.name, key)
+	defer func() { endSpan(span, err) }()
+	o := getOptionsFor(ctx, g.name, key)
+	value, source, cacheHit := g.lookupCacheSource(key)
+	if cacheHit && (o.forceRefresh || !o.fresh(value)) {
+		cacheHit = false
+	}
+	span.SetAttributes(attribute.Bool("groupcache.cache_hit", cacheHit))
 
 	if cacheHit {
 		g.Stats.CacheHits.Add(1)
+		if value.expired() {
+			g.Stats.StaleHits.Add(1)
+		}
+		g.maybeRefresh(key, value)
+		if o.result != nil {
+			*o.result = cacheResult(source, value)
+		}
 		return setSinkView(dest, value)
 	}
+	if !o.forceRefresh && g.misses.has(key) {
+		g.Stats.NegativeHits.Add(1)
+		return ErrNotFound
+	}
+	if err, ok := g.failures.err(key); ok && !o.forceRefresh {
+		g.Stats.PermanentErrorHits.Add(1)
+		return err
+	}
 
-	// Optimization to avoid double unmarshalling or copying: keep
-	// track of whether the dest was already populated. One caller
-	// (if local) will set this; the losers will not. The common
-	// case will likely be one caller.
-	destPopulated := false
-	value, destPopulated, err := g.load(ctx, key, dest)
+	// The load may outlive this call if ctx is done first, so it never
+	// writes to dest directly.
+	value, result, err := g.loadResult(ctx, key)
 	if err != nil {
 		return err
 	}
-	if destPopulated {
-		return nil
+	if o.result != nil {
+		*o.result = result
 	}
 	return setSinkView(dest, value)
 }
 
-// Remove clears the key from our cache then forwards the remove
-// request to all peers.
+// Set stores value for key, making it the value returned by Get until it
+// expires or is removed or evicted, without calling the getter. Use it
+// to push a freshly computed value, for example after writing it to a
+// database. The value is written to the key's owner, which must be a
+// PeerSetter if it is a remote peer; if hotCache is true it is also
+// stored in the local hot cache. Copies of a previous value in the hot
+// caches of other peers are not updated. The caller retains ownership
+// of value. In write-back mode the stored value is also queued to be
+// flushed, see GroupOptions.WriteBack.
+func (g *Group) Set(ctx context.Context, key string, value []byte, expire time.Time, hotCache bool) error {
+	g.peersOnce.Do(g.initPeers)
+	key = g.encodeKey(key)
+	if g.writeBack.full(key) {
+		return ErrWriteBackFull
+	}
+	view := ByteView{b: cloneBytes(value), e: expire}
+
+	owner, ok := g.peers.PickPeer(key)
+	if !ok {
+		g.localSet(key, view, &g.mainCache)
+		g.replicate(key, view)
+		g.writeBack.add(key, view)
+		return nil
+	}
+	setter, ok := owner.(PeerSetter)
+	if !ok {
+		return fmt.Errorf("groupcache: peer [%s] does not support Set", owner.GetURL())
+	}
+	if err := setter.Set(ctx, g.name, key, view); err != nil {
+		g.recordFailure("store", key, owner, err)
+		return err
+	}
+	if hotCache {
+		g.localSet(key, view, &g.hotCache)
+	} else {
+		g.localRemove(key)
+	}
+	g.writeBack.add(key, view)
+	return nil
+}
+
+// GetReader is like Get but returns the value as an io.ReadSeeker
+// reading the cached bytes directly, so large values are not copied.
+// The value is still loaded, or received from its owner, in full
+// before the reader is returned; GetReader saves a copy, not memory.
+func (g *Group) GetReader(ctx context.Context, key string) (io.ReadSeeker, error) {
+	var value ByteView
+	if err := g.Get(ctx, key, ByteViewSink(&value)); err != nil {
+		return nil, err
+	}
+	return value.Reader(), nil
+}
+
+// Remove clears the key from its owner first, then from our cache and
+// finally forwards the remove request to all other peers returned by
+// GetAll, so no stale copies linger in their hot caches. Peers that
+// fail to remove the key are reported in the returned PeersError. If
+// the owner fails, the key is left cached and no other peer is asked.
+// A copy fetched from the owner just before the removal may still be
+// admitted to a hot cache afterwards unless RemoveTombstoneTTL is set.
 func (g *Group) Remove(ctx context.Context, key string) error {
 	g.peersOnce.Do(g.initPeers)
+	key = g.encodeKey(key)
 
 	_, err := g.removeGroup.Do(key, func() (interface{}, error) {
 
@@ -264,13 +1072,14 @@ func (g *Group) Remove(ctx context.Context, key string) error {
 		owner, ok := g.peers.PickPeer(key)
 		if ok {
 			if err := g.removeFromPeer(ctx, owner, key); err != nil {
-				return nil, err
+				return nil, PeersError{owner.GetURL(): err}
 			}
 		}
 		// Remove from our cache next
 		g.localRemove(key)
 		wg := sync.WaitGroup{}
-		errs := make(chan error)
+		var mu sync.Mutex
+		errs := make(PeersError)
 
 		// Asynchronously clear the key from all hot and main caches of peers
 		for _, peer := range g.peers.GetAll() {
@@ -281,31 +1090,191 @@ func (g *Group) Remove(ctx context.Context, key string) error {
 
 			wg.Add(1)
 			go func(peer ProtoGetter) {
-				errs <- g.removeFromPeer(ctx, peer, key)
-				wg.Done()
+				defer wg.Done()
+				if err := g.removeFromPeer(ctx, peer, key); err != nil {
+					mu.Lock()
+					errs[peer.GetURL()] = err
+					mu.Unlock()
+				}
 			}(peer)
 		}
-		go func() {
-			wg.Wait()
-			close(errs)
-		}()
+		wg.Wait()
 
-		// TODO(thrawn01): Should we report all errors? Reporting context
-		//  cancelled error for each peer doesn't make much sense.
-		var err error
-		for e := range errs {
-			err = e
+		if len(errs) != 0 {
+			return nil, errs
 		}
-
-		return nil, err
+		return nil, nil
 	})
 	return err
 }
 
+// RemovePrefix removes every key starting with prefix, such as
+// "user:123:" for all the keys of a user, from this process and from
+// every peer, so related keys can be invalidated without tracking each
+// of them. Each peer must be a PeerPrefixRemover. Keys are found by
+// visiting the entries of the caches, whose policy must be a
+// policy.Ranger, as the built-in policies are. The TierTwo is only
+// cleared of the keys found cached. Peers that could not remove the
+// keys are reported in the returned PeersError. Values loaded while
+// RemovePrefix runs may survive it.
+func (g *Group) RemovePrefix(ctx context.Context, prefix string) error {
+	if g.opts.KeyEncoder != nil {
+		return fmt.Errorf("groupcache: RemovePrefix of %s, whose keys are encoded", g.name)
+	}
+	g.peersOnce.Do(g.initPeers)
+	g.localRemovePrefix(prefix)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = PeersError{}
+	)
+	for _, peer := range g.peers.GetAll() {
+		wg.Add(1)
+		go func(peer ProtoGetter) {
+			defer wg.Done()
+			var err error
+			if remover, ok := peer.(PeerPrefixRemover); ok {
+				err = remover.RemovePrefix(ctx, g.name, prefix)
+			} else {
+				err = fmt.Errorf("groupcache: peer [%s] does not support RemovePrefix", peer.GetURL())
+			}
+			if err != nil {
+				g.recordFailure("remove_prefix", prefix, peer, err)
+				mu.Lock()
+				errs[peer.GetURL()] = err
+				mu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// Clear empties the group on this process and on every peer, for when
+// the cached values are all wrong, for example after a change to the
+// format of the values, and removing them by key is not feasible. Each
+// peer must be a PeerFlusher. Peers that could not be emptied are
+// reported in the returned PeersError. Values loaded while Clear runs
+// may survive it.
+func (g *Group) Clear(ctx context.Context) error {
+	g.peersOnce.Do(g.initPeers)
+	g.localFlush()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = PeersError{}
+	)
+	for _, peer := range g.peers.GetAll() {
+		wg.Add(1)
+		go func(peer ProtoGetter) {
+			defer wg.Done()
+			var err error
+			if flusher, ok := peer.(PeerFlusher); ok {
+				err = flusher.Flush(ctx, g.name)
+			} else {
+				err = fmt.Errorf("groupcache: peer [%s] does not support Clear", peer.GetURL())
+			}
+			if err != nil {
+				g.recordFailure("flush", "", peer, err)
+				mu.Lock()
+				errs[peer.GetURL()] = err
+				mu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// BumpGeneration invalidates every cached value of the group on this
+// process and on every peer, like Clear, but without visiting the
+// entries: values cached before the bump are treated as misses and are
+// evicted as the caches fill up again. Each peer must be a
+// PeerGenerationBumper. Peers that could not be reached are reported in
+// the returned PeersError.
+func (g *Group) BumpGeneration(ctx context.Context) error {
+	g.peersOnce.Do(g.initPeers)
+	g.localBumpGeneration()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = PeersError{}
+	)
+	for _, peer := range g.peers.GetAll() {
+		wg.Add(1)
+		go func(peer ProtoGetter) {
+			defer wg.Done()
+			var err error
+			if bumper, ok := peer.(PeerGenerationBumper); ok {
+				err = bumper.BumpGeneration(ctx, g.name)
+			} else {
+				err = fmt.Errorf("groupcache: peer [%s] does not support BumpGeneration", peer.GetURL())
+			}
+			if err != nil {
+				g.recordFailure("bump", "", peer, err)
+				mu.Lock()
+				errs[peer.GetURL()] = err
+				mu.Unlock()
+			}
+		}(peer)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// Generation returns the number of times the group was invalidated by
+// BumpGeneration on this process, including by its peers.
+func (g *Group) Generation() int64 {
+	return g.generation.Get()
+}
+
 // load loads key either by invoking the getter locally or by sending it to another machine.
-func (g *Group) load(ctx context.Context, key string, dest Sink) (value ByteView, destPopulated bool, err error) {
+//
+// Concurrent loads of a key share a single fetch, which is canceled
+// only once the contexts of all of them are done. A load whose ctx is
+// done returns ctx.Err() right away without affecting the others.
+func (g *Group) load(ctx context.Context, key string) (ByteView, error) {
+	value, _, err := g.loadResult(ctx, key)
+	return value, err
+}
+
+// loadedValue is the result of a call of loadGroup.
+type loadedValue struct {
+	value  ByteView
+	result GetResult
+}
+
+// loadResult is load, also returning how the value was found.
+func (g *Group) loadResult(ctx context.Context, key string) (value ByteView, result GetResult, err error) {
 	g.Stats.Loads.Add(1)
-	viewi, err := g.loadGroup.Do(key, func() (interface{}, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	o := getOptionsFor(ctx, g.name, key)
+	if o.forceRefresh {
+		g.loadGroup.Forget(key)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		// The shared load runs without the deadline of ctx, which a
+		// DeadlinePicker still needs.
+		ctx = context.WithValue(ctx, callerDeadlineKey{}, deadline)
+	}
+	viewi, err := g.loadGroup.DoContext(ctx, key, func(ctx context.Context) (interface{}, error) {
 		// Check the cache again because singleflight can only dedup calls
 		// that overlap concurrently.  It's possible for 2 concurrent
 		// requests to miss the cache, resulting in 2 load() calls.  An
@@ -327,127 +1296,698 @@ func (g *Group) load(ctx context.Context, key string, dest Sink) (value ByteView
 		// 1: fn()
 		// 2: loadGroup.Do("key", fn)
 		// 2: fn()
-		if value, cacheHit := g.lookupCache(key); cacheHit {
+		if value, source, cacheHit := g.lookupCacheSource(key); cacheHit && !o.forceRefresh && o.fresh(value) {
 			g.Stats.CacheHits.Add(1)
-			return value, nil
+			return loadedValue{value, cacheResult(source, value)}, nil
 		}
-		g.Stats.LoadsDeduped.Add(1)
-		var value ByteView
-		var err error
-		if peer, ok := g.peers.PickPeer(key); ok {
-
-			// metrics duration start
-			start := time.Now()
-
-			// get value from peers
-			value, err = g.getFromPeer(ctx, peer, key)
-
-			// metrics duration compute
-			duration := int64(time.Since(start)) / int64(time.Millisecond)
-
-			// metrics only store the slowest duration
-			if g.Stats.GetFromPeersLatencyLower.Get() < duration {
-				g.Stats.GetFromPeersLatencyLower.Store(duration)
-			}
-
-			if err == nil {
-				g.Stats.PeerLoads.Add(1)
-				return value, nil
-			}
-
-			if logger != nil {
-				logger.WithFields(logrus.Fields{
-					"err":      err,
-					"key":      key,
-					"category": "groupcache",
-				}).Errorf("error retrieving key from peer '%s'", peer.GetURL())
-			}
-
-			g.Stats.PeerErrors.Add(1)
-			if ctx != nil && ctx.Err() != nil {
-				// Return here without attempting to get locally
-				// since the context is no longer valid
-				return nil, err
-			}
-			// TODO(bradfitz): log the peer's error? keep
-			// log of the past few for /groupcachez?  It's
-			// probably boring (normal task movement), so not
-			// worth logging I imagine.
+		if !o.forceRefresh && g.misses.has(key) {
+			g.Stats.NegativeHits.Add(1)
+			return nil, ErrNotFound
 		}
-
-		value, err = g.getLocally(ctx, key, dest)
+		if err, ok := g.failures.err(key); ok && !o.forceRefresh {
+			g.Stats.PermanentErrorHits.Add(1)
+			return nil, err
+		}
+		g.Stats.LoadsDeduped.Add(1)
+		ctx, span := startSpan(ctx, "groupcache.load", g.name, key)
+		loaded, err := g.fetchResult(ctx, key)
+		endSpan(span, err)
 		if err != nil {
-			g.Stats.LocalLoadErrs.Add(1)
+			if errors.Is(err, ErrNotFound) {
+				g.misses.add(key)
+			} else if IsPermanent(err) {
+				g.failures.addErr(key, err)
+			}
 			return nil, err
 		}
-		g.Stats.LocalLoads.Add(1)
-		destPopulated = true // only one caller of load gets this return value
-		g.populateCache(key, value, &g.mainCache)
-		return value, nil
+		return loaded, nil
 	})
 	if err == nil {
-		value = viewi.(ByteView)
+		loaded := viewi.(loadedValue)
+		value, result = loaded.value, loaded.result
 	}
 	return
 }
 
-func (g *Group) getLocally(ctx context.Context, key string, dest Sink) (ByteView, error) {
-	err := g.getter.Get(ctx, key, dest)
-	if err != nil {
-		return ByteView{}, err
-	}
-	return dest.view()
+// fetchResult is fetch, returning the value with how it was found.
+func (g *Group) fetchResult(ctx context.Context, key string) (loadedValue, error) {
+	var source GetSource
+	value, err := g.fetch(context.WithValue(ctx, getSourceKey{}, &source), key)
+	return loadedValue{value, GetResult{Source: source}}, err
 }
 
-func (g *Group) getFromPeer(ctx context.Context, peer ProtoGetter, key string) (ByteView, error) {
-	req := &pb.GetRequest{
-		Group: &g.name,
-		Key:   &key,
+// fetch loads key from its owner, the failover peers or finally the
+// getter, and caches the value, without consulting the cache first.
+// It must be called from within loadGroup.
+func (g *Group) fetch(ctx context.Context, key string) (value ByteView, err error) {
+	g.beginFetch()
+	defer g.endFetch()
+	peer, ok := g.pickPeerForGet(ctx, key)
+	_, remoteOnly := g.peers.(remoteOnlyPicker)
+	if !ok && remoteOnly {
+		return ByteView{}, ErrNoPeers
 	}
-	res := &pb.GetResponse{}
-	err := peer.Get(ctx, req, res)
-	if err != nil {
-		return ByteView{}, err
+	if ok && isFallbackLoad(ctx) {
+		// A peer that failed to reach the owner made us its fallback.
+		ok = false
 	}
+	if ok && !remoteOnly && g.oversized.has(key) {
+		return g.loadOversized(ctx, key)
+	}
+	if ok {
+		value, err = g.loadFromPeerHedged(ctx, peer, key)
+		if err == nil || errors.Is(err, ErrNotFound) {
+			setGetSource(ctx, SourcePeer)
+			return value, err
+		}
+		if ctx != nil && ctx.Err() != nil {
+			// Return here without attempting to get locally
+			// since the context is no longer valid
+			return value, err
+		}
 
-	var expire time.Time
-	if res.Expire != nil && *res.Expire != 0 {
-		expire = time.Unix(*res.Expire/int64(time.Second), *res.Expire%int64(time.Second))
-		if time.Now().After(expire) {
-			return ByteView{}, errors.New("peer returned expired value")
+		// Try the peers that follow the owner on the ring before
+		// falling back to a local load.
+		if fp, ok := g.peers.(FailoverPicker); ok {
+			hops, ok := failoverHopsFromContext(ctx)
+			if !ok {
+				hops = fp.FailoverHops()
+			}
+			for _, peer := range fp.PickFailover(key, hops) {
+				value, err = g.loadFromPeer(ctx, peer, key)
+				if err == nil || errors.Is(err, ErrNotFound) {
+					setGetSource(ctx, SourcePeer)
+					return value, err
+				}
+				if ctx != nil && ctx.Err() != nil {
+					return value, err
+				}
+			}
+		}
+		// TODO(bradfitz): log the peer's error? keep
+		// log of the past few for /groupcachez?  It's
+		// probably boring (normal task movement), so not
+		// worth logging I imagine.
+		if g.opts.OnPeerError == ReturnPeerError || remoteOnly {
+			return value, err
 		}
 	}
 
-	value := ByteView{b: res.Value, e: expire}
+	o := getOptionsFor(ctx, g.name, key)
+	// Without the lease of key, the TierTwo may hold the copy this
+	// peer stored when it last owned key.
+	if !o.forceRefresh && g.holdsLease(key) {
+		if value, hit := g.getTierTwo(ctx, key); hit && o.fresh(value) {
+			if !o.noStore {
+				g.populateCache(key, value, &g.mainCache)
+			}
+			setGetSource(ctx, SourceTierTwo)
+			return value, nil
+		}
+	}
 
-	// Always populate the hot cache
-	g.populateCache(key, value, &g.hotCache)
+	var dest ByteView
+	value, err = g.getLocally(ctx, key, ByteViewSink(&dest))
+	if err != nil {
+		g.Stats.LocalLoadErrs.Add(1)
+		return value, err
+	}
+	g.Stats.LocalLoads.Add(1)
+	setGetSource(ctx, SourceGetter)
+	if !o.noStore && !g.holdsLease(key) {
+		g.Stats.UnleasedLoads.Add(1)
+		o.noStore = true
+	}
+	if !o.noStore {
+		g.populateCache(key, value, &g.mainCache)
+		g.setTierTwo(key, value)
+	}
+	g.fireOnLoad(key, value, true)
+	if !ok && !o.noStore {
+		// Only the owner replicates, not a peer that fell back to a
+		// local load or took the load off an overloaded owner.
+		if _, remote := g.peers.PickPeer(key); !remote {
+			g.replicate(key, value)
+		}
+	}
 	return value, nil
 }
 
-func (g *Group) removeFromPeer(ctx context.Context, peer ProtoGetter, key string) error {
-	req := &pb.GetRequest{
-		Group: &g.name,
+// holdsLease reports whether the values loaded for key may be cached,
+// as the group's PeerPicker is not a LeasePicker or leases key to the
+// current peer.
+func (g *Group) holdsLease(key string) bool {
+	lp, ok := g.peers.(LeasePicker)
+	return !ok || lp.HoldsLease(key)
+}
+
+// loadOversized loads key, which belongs to a peer but recently had a
+// value too large to cache, from the getter without caching it.
+func (g *Group) loadOversized(ctx context.Context, key string) (ByteView, error) {
+	g.Stats.OversizedBypasses.Add(1)
+	var dest ByteView
+	value, err := g.getLocally(ctx, key, ByteViewSink(&dest))
+	if err != nil {
+		g.Stats.LocalLoadErrs.Add(1)
+		return value, err
+	}
+	g.Stats.LocalLoads.Add(1)
+	setGetSource(ctx, SourceGetter)
+	if !g.tooLargeToCache(key, value) {
+		// The next Get asks the owner again, which caches the value.
+		g.oversized.remove(key)
+	}
+	g.fireOnLoad(key, value, true)
+	return value, nil
+}
+
+// callerDeadlineKey is the context key of the deadline of the Get that
+// started a load.
+type callerDeadlineKey struct{}
+
+// pickPeerForGet returns the peer to load key from, which is its owner
+// unless the PeerPicker is a DeadlinePicker or LoadPicker.
+func (g *Group) pickPeerForGet(ctx context.Context, key string) (ProtoGetter, bool) {
+	if dp, ok := g.peers.(DeadlinePicker); ok {
+		if ctx != nil {
+			if deadline, ok := ctx.Value(callerDeadlineKey{}).(time.Time); ok {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, deadline)
+				defer cancel()
+			}
+		}
+		return dp.PickPeerContext(ctx, key)
+	}
+	if lp, ok := g.peers.(LoadPicker); ok {
+		return lp.PickPeerForLoad(key)
+	}
+	return g.peers.PickPeer(key)
+}
+
+// replicate mirrors value onto the replica peers of key in the
+// background, if the PeerPicker is a Replicator.
+func (g *Group) replicate(key string, value ByteView) {
+	r, ok := g.peers.(Replicator)
+	if !ok {
+		return
+	}
+	go func() {
+		err := r.Replicate(g.background, g.name, key, value)
+		if err == nil || g.opts.DeadLetterSize < 0 {
+			return
+		}
+		var perr PeersError
+		if !errors.As(err, &perr) {
+			perr = PeersError{"": err}
+		}
+		for peer, err := range perr {
+			g.failedOps.add(FailedOp{Op: "replicate", Key: key, Peer: peer, Err: err, Time: time.Now()}, g.opts.DeadLetterSize)
+		}
+	}()
+}
+
+// maybeRefresh starts a background reload of key if its cached value
+// expires within RefreshWindow, or is stale, and no refresh of key is
+// running yet.
+func (g *Group) maybeRefresh(key string, value ByteView) {
+	window := g.opts.RefreshWindow
+	if window <= 0 && g.opts.StaleWhileRevalidate <= 0 {
+		return
+	}
+	if value.Expire().IsZero() || time.Until(value.Expire()) > window {
+		return
+	}
+
+	g.refreshMu.Lock()
+	if _, running := g.refreshing[key]; running || g.background.Err() != nil {
+		g.refreshMu.Unlock()
+		return
+	}
+	if g.refreshing == nil {
+		g.refreshing = make(map[string]struct{})
+	}
+	g.refreshing[key] = struct{}{}
+	g.refreshMu.Unlock()
+
+	go func() {
+		defer func() {
+			g.refreshMu.Lock()
+			delete(g.refreshing, key)
+			g.refreshMu.Unlock()
+		}()
+		g.loadGroup.DoContext(g.background, key, func(ctx context.Context) (interface{}, error) {
+			return g.fetchResult(ctx, key)
+		})
+	}()
+}
+
+// OnEvict registers fn to be called each time an entry leaves the main
+// or hot cache. fn is called without any cache lock held. Passing nil
+// removes a previously registered hook.
+func (g *Group) OnEvict(fn func(key string, value ByteView)) {
+	g.hooksMu.Lock()
+	g.onEvict = fn
+	g.hooksMu.Unlock()
+	g.updateEvictHooks()
+}
+
+// EvictReason tells why an entry left a cache.
+type EvictReason int
+
+const (
+	// EvictedCapacity entries were evicted by the cache policy to keep
+	// the caches within their size limits.
+	EvictedCapacity EvictReason = iota + 1
+
+	// EvictedExpired entries were dropped because their expiry passed.
+	EvictedExpired
+
+	// EvictedRemoved entries were removed explicitly, for example by
+	// Group.Remove, Group.Set or a flush.
+	EvictedRemoved
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictedCapacity:
+		return "capacity"
+	case EvictedExpired:
+		return "expired"
+	case EvictedRemoved:
+		return "removed"
+	}
+	return "EvictReason(" + strconv.Itoa(int(r)) + ")"
+}
+
+// MarshalText encodes r as its String, such as "capacity".
+func (r EvictReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText decodes a reason encoded by MarshalText.
+func (r *EvictReason) UnmarshalText(text []byte) error {
+	for _, reason := range []EvictReason{EvictedCapacity, EvictedExpired, EvictedRemoved} {
+		if string(text) == reason.String() {
+			*r = reason
+			return nil
+		}
+	}
+	return fmt.Errorf("groupcache: unknown evict reason %q", text)
+}
+
+// Eviction describes an entry that left the main or hot cache.
+type Eviction struct {
+	Key    string
+	Size   int64 // as counted against cacheBytes
+	Reason EvictReason
+	Time   time.Time
+}
+
+// RecentEvictions returns up to GroupOptions.EvictionLogSize entries
+// that most recently left the caches of the group, oldest first.
+func (g *Group) RecentEvictions() []Eviction {
+	return g.evictions.list()
+}
+
+// OnRemoved is like OnEvict but also tells fn why the entry left the
+// cache. Both hooks may be registered at once. Reloading a cached
+// value in place, as refreshes do, calls neither.
+func (g *Group) OnRemoved(fn func(key string, value ByteView, reason EvictReason)) {
+	g.hooksMu.Lock()
+	g.onRemoved = fn
+	g.hooksMu.Unlock()
+	g.updateEvictHooks()
+}
+
+// updateEvictHooks makes the caches report evicted entries only while
+// a hook wants them.
+func (g *Group) updateEvictHooks() {
+	g.hooksMu.RLock()
+	enabled := g.onEvict != nil || g.onRemoved != nil || g.opts.EvictionLogSize > 0
+	g.hooksMu.RUnlock()
+	fn := g.fireOnEvicted
+	if !enabled {
+		fn = nil
+	}
+	g.mainCache.setOnEvicted(fn)
+	g.hotCache.setOnEvicted(fn)
+}
+
+func (g *Group) fireOnEvicted(key string, value ByteView, reason EvictReason) {
+	if n := g.opts.EvictionLogSize; n > 0 {
+		g.evictions.add(Eviction{
+			Key:    key,
+			Size:   value.cost,
+			Reason: reason,
+			Time:   time.Now(),
+		}, n)
+	}
+	g.hooksMu.RLock()
+	onEvict, onRemoved := g.onEvict, g.onRemoved
+	g.hooksMu.RUnlock()
+	if onEvict != nil {
+		onEvict(key, value)
+	}
+	if onRemoved != nil {
+		onRemoved(key, value, reason)
+	}
+}
+
+// OnLoad registers fn to be called after a value has been successfully
+// loaded, either locally from the Getter (local is true) or from the
+// owning peer (local is false). Concurrent callers deduplicated by
+// singleflight only trigger a single call. Passing nil removes a
+// previously registered hook.
+func (g *Group) OnLoad(fn func(key string, value ByteView, local bool)) {
+	g.hooksMu.Lock()
+	g.onLoad = fn
+	g.hooksMu.Unlock()
+}
+
+func (g *Group) fireOnLoad(key string, value ByteView, local bool) {
+	g.hooksMu.RLock()
+	fn := g.onLoad
+	g.hooksMu.RUnlock()
+	if fn != nil {
+		fn(key, value, local)
+	}
+}
+
+// loadFromPeer fetches key from peer, recording the outcome in the
+// group's stats.
+func (g *Group) loadFromPeer(ctx context.Context, peer ProtoGetter, key string) (ByteView, error) {
+	// metrics duration start
+	start := time.Now()
+
+	// get value from peers
+	ctx, span := startSpan(ctx, "groupcache.loadFromPeer", g.name, key,
+		trace.WithAttributes(attribute.String("groupcache.peer", peer.GetURL())))
+	value, err := g.getFromPeer(ctx, peer, key)
+	endSpan(span, err)
+	return g.peerLoaded(start, peer, key, value, err)
+}
+
+// loadFromPeerHedged is loadFromPeer, but also asks the first failover
+// peer of key if peer has not answered within the delay of a
+// HedgePicker, and returns the first value either returns.
+func (g *Group) loadFromPeerHedged(ctx context.Context, peer ProtoGetter, key string) (ByteView, error) {
+	hp, ok := g.peers.(HedgePicker)
+	if !ok {
+		return g.loadFromPeer(ctx, peer, key)
+	}
+	delay := hp.HedgeDelay(peer)
+	if delay <= 0 {
+		return g.loadFromPeer(ctx, peer, key)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	start := time.Now()
+	ctx, span := startSpan(ctx, "groupcache.loadFromPeer", g.name, key,
+		trace.WithAttributes(attribute.String("groupcache.peer", peer.GetURL())))
+	// The slower RPC is canceled once the other answers.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		peer  ProtoGetter
+		value ByteView
+		err   error
+	}
+	results := make(chan result, 2)
+	get := func(peer ProtoGetter) {
+		value, err := g.getFromPeer(ctx, peer, key)
+		results <- result{peer, value, err}
+	}
+	go get(peer)
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	var r result
+	select {
+	case r = <-results:
+	case <-t.C:
+		hedge, ok := hp.PickHedge(key)
+		if !ok {
+			r = <-results
+			break
+		}
+		g.Stats.HedgedLoads.Add(1)
+		span.SetAttributes(attribute.String("groupcache.hedge_peer", hedge.GetURL()))
+		go get(hedge)
+		r = <-results
+		if r.err != nil && !errors.Is(r.err, ErrNotFound) {
+			// Give the other a chance before failing.
+			if other := <-results; other.err == nil || errors.Is(other.err, ErrNotFound) {
+				r = other
+			}
+		}
+		if r.peer != peer {
+			g.Stats.HedgeWins.Add(1)
+		}
+	}
+	endSpan(span, r.err)
+	return g.peerLoaded(start, r.peer, key, r.value, r.err)
+}
+
+// peerLoaded records the outcome of a load from peer that started at
+// start and returns it.
+func (g *Group) peerLoaded(start time.Time, peer ProtoGetter, key string, value ByteView, err error) (ByteView, error) {
+	// metrics duration compute
+	g.Stats.PeerLoadLatency.Observe(time.Since(start))
+	duration := int64(time.Since(start)) / int64(time.Millisecond)
+
+	// metrics only store the slowest duration
+	if g.Stats.GetFromPeersLatencyLower.Get() < duration {
+		g.Stats.GetFromPeersLatencyLower.Store(duration)
+	}
+
+	if err == nil {
+		g.Stats.PeerLoads.Add(1)
+		g.fireOnLoad(key, value, false)
+		return value, nil
+	}
+	if errors.Is(err, ErrNotFound) {
+		// The peer is fine, the key has no value.
+		return ByteView{}, err
+	}
+
+	g.log().Error("error retrieving key from peer", "group", g.name, "key", key, "peer", peer.GetURL(), "err", err)
+
+	g.Stats.PeerErrors.Add(1)
+	return ByteView{}, err
+}
+
+func (g *Group) getLocally(ctx context.Context, key string, dest Sink) (ByteView, error) {
+	ctx, span := startSpan(ctx, "groupcache.getLocally", g.name, key)
+	start := time.Now()
+	var attempts int
+	err := g.getterRetry.do(ctx, func(ctx context.Context) error {
+		if attempts++; attempts > 1 {
+			g.Stats.GetterRetries.Add(1)
+		}
+		release, err := g.acquireLoad(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		if _, err := g.injectFault(ctx, key, ""); err != nil {
+			return err
+		}
+		key, err := g.decodeKey(key)
+		if err != nil {
+			return err
+		}
+		return g.getter.Get(ctx, key, dest)
+	})
+	g.Stats.LocalLoadLatency.Observe(time.Since(start))
+	endSpan(span, err)
+	if err != nil {
+		return ByteView{}, err
+	}
+	value, err := dest.view()
+	if err == nil && value.e.IsZero() && g.opts.DefaultTTL > 0 {
+		value.e = time.Now().Add(g.opts.DefaultTTL)
+	}
+	return value, err
+}
+
+// ErrTooManyLoads is returned by Get when the load of a key waited for
+// a GroupOptions.MaxConcurrentLoads slot for LoadQueueTimeout.
+var ErrTooManyLoads = errors.New("groupcache: too many concurrent loads")
+
+// acquireLoad takes a MaxConcurrentLoads slot for a getter call. The
+// returned func releases it.
+func (g *Group) acquireLoad(ctx context.Context) (func(), error) {
+	if g.loadSem == nil {
+		return func() {}, nil
+	}
+	release := func() { <-g.loadSem }
+	select {
+	case g.loadSem <- struct{}{}:
+		return release, nil
+	default:
+	}
+	if g.opts.LoadQueueTimeout < 0 {
+		g.Stats.LoadsRejected.Add(1)
+		return nil, ErrTooManyLoads
+	}
+	g.Stats.LoadsQueued.Add(1)
+	var timeout <-chan time.Time
+	if g.opts.LoadQueueTimeout > 0 {
+		t := time.NewTimer(g.opts.LoadQueueTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	select {
+	case g.loadSem <- struct{}{}:
+		return release, nil
+	case <-timeout:
+		g.Stats.LoadsRejected.Add(1)
+		return nil, ErrTooManyLoads
+	case <-done:
+		return nil, ctx.Err()
+	}
+}
+
+// sweep removes expired values from the caches every interval until
+// the group is deregistered.
+func (g *Group) sweep(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-g.background.Done():
+			return
+		case now := <-t.C:
+			g.mainCache.removeExpired(now)
+			g.hotCache.removeExpired(now)
+		}
+	}
+}
+
+func (g *Group) getFromPeer(ctx context.Context, peer ProtoGetter, key string) (ByteView, error) {
+	req := &pb.GetRequest{
+		Group: &g.name,
 		Key:   &key,
 	}
-	return peer.Remove(ctx, req)
+	res := &pb.GetResponse{}
+	fault, err := g.injectFault(ctx, key, peer.GetURL())
+	if err != nil {
+		return ByteView{}, err
+	}
+	if err := peer.Get(ctx, req, res); err != nil {
+		return ByteView{}, err
+	}
+	if fault.Truncate {
+		res.Value = res.Value[:len(res.Value)/2]
+	}
+
+	var expire time.Time
+	if res.Expire != nil && *res.Expire != 0 {
+		expire = time.Unix(*res.Expire/int64(time.Second), *res.Expire%int64(time.Second))
+		if time.Since(expire) > g.opts.StaleWhileRevalidate {
+			return ByteView{}, errors.New("peer returned expired value")
+		}
+	}
+
+	value := ByteView{b: res.Value, e: expire}
+
+	o := getOptionsFor(ctx, g.name, key)
+	if o.forceRefresh {
+		// The hot copy is stale, whether or not the refreshed value
+		// replaces it.
+		g.hotCache.remove(key)
+	}
+	if !o.noStore {
+		g.admitToHotCache(key, value)
+	}
+	return value, nil
+}
+
+// admitToHotCache mirrors a value fetched from a peer in the hot cache
+// if the group's HotCachePolicy allows it.
+func (g *Group) admitToHotCache(key string, value ByteView) {
+	if g.maxBytes() > 0 && g.tooLargeToCache(key, value) {
+		g.oversized.add(key)
+		return
+	}
+	if g.tombstones.has(key) {
+		return
+	}
+	if g.opts.HotCachePolicy == nil || g.opts.HotCachePolicy.Admit(key) {
+		g.populateCache(key, value, &g.hotCache)
+	}
+}
+
+func (g *Group) removeFromPeer(ctx context.Context, peer ProtoGetter, key string) error {
+	req := &pb.GetRequest{
+		Group: &g.name,
+		Key:   &key,
+	}
+	err := peer.Remove(ctx, req)
+	if err != nil {
+		g.recordFailure("remove", key, peer, err)
+	}
+	return err
+}
+
+func (g *Group) recordFailure(op, key string, peer ProtoGetter, err error) {
+	if g.opts.DeadLetterSize < 0 {
+		return
+	}
+	g.failedOps.add(FailedOp{
+		Op:   op,
+		Key:  key,
+		Peer: peer.GetURL(),
+		Err:  err,
+		Time: time.Now(),
+	}, g.opts.DeadLetterSize)
+}
+
+// FailedOperations returns the most recent peer operations that failed,
+// oldest first, so they can be inspected or retried by an operator. At
+// most GroupOptions.DeadLetterSize operations are kept.
+func (g *Group) FailedOperations() []FailedOp {
+	return g.failedOps.list()
 }
 
 func (g *Group) lookupCache(key string) (value ByteView, ok bool) {
-	if g.cacheBytes <= 0 {
+	value, _, ok = g.lookupCacheSource(key)
+	return
+}
+
+// lookupCacheSource is lookupCache, also returning the cache the value
+// was found in.
+func (g *Group) lookupCacheSource(key string) (value ByteView, source GetSource, ok bool) {
+	if g.maxBytes() <= 0 {
 		return
 	}
 	value, ok = g.mainCache.get(key)
 	if ok {
-		return
+		return value, SourceMainCache, true
 	}
 	value, ok = g.hotCache.get(key)
-	return
+	if ok && g.tombstones.has(key) {
+		// Possibly older than the removal.
+		g.Stats.TombstoneHits.Add(1)
+		g.hotCache.remove(key)
+		return ByteView{}, 0, false
+	}
+	return value, SourceHotCache, ok
 }
 
 func (g *Group) localRemove(key string) {
+	defer g.loadGroup.Forget(key)
+	// Removed from the TierTwo first, so a load after the removal from
+	// the caches cannot read the value back.
+	g.removeTierTwo(key)
 	// Clear key from our local cache
-	if g.cacheBytes <= 0 {
+	if g.maxBytes() <= 0 {
 		return
 	}
 
@@ -455,20 +1995,161 @@ func (g *Group) localRemove(key string) {
 	g.loadGroup.Lock(func() {
 		g.hotCache.remove(key)
 		g.mainCache.remove(key)
+		g.misses.remove(key)
+		g.oversized.remove(key)
+		g.failures.remove(key)
+		g.tombstones.add(key)
 	})
 }
 
+// localRemovePrefix removes the keys starting with prefix as
+// localRemove does.
+func (g *Group) localRemovePrefix(prefix string) {
+	defer g.loadGroup.ForgetAll()
+	if g.maxBytes() <= 0 {
+		return
+	}
+
+	// Removed from the TierTwo first, as by localRemove.
+	for _, key := range append(g.mainCache.keysWithPrefix(prefix), g.hotCache.keysWithPrefix(prefix)...) {
+		g.removeTierTwo(key)
+	}
+	// Ensure no requests are in flight
+	g.loadGroup.Lock(func() {
+		keys := append(g.mainCache.keysWithPrefix(prefix), g.hotCache.keysWithPrefix(prefix)...)
+		for _, key := range keys {
+			g.hotCache.remove(key)
+			g.mainCache.remove(key)
+			g.tombstones.add(key)
+		}
+		g.misses.removePrefix(prefix)
+		g.oversized.removePrefix(prefix)
+		g.failures.removePrefix(prefix)
+	})
+}
+
+// localSet replaces any cached value for key with value in cache. Values
+// for the main cache are also written to the TierTwo.
+func (g *Group) localSet(key string, value ByteView, cache *cache) {
+	defer g.loadGroup.Forget(key)
+	if cache == &g.mainCache {
+		g.setTierTwo(key, value)
+	}
+	if g.maxBytes() <= 0 {
+		return
+	}
+
+	// Ensure no requests are in flight
+	g.loadGroup.Lock(func() {
+		g.hotCache.remove(key)
+		g.mainCache.remove(key)
+		g.misses.remove(key)
+		g.oversized.remove(key)
+		g.failures.remove(key)
+		g.tombstones.remove(key)
+		g.populateCache(key, value, cache)
+	})
+}
+
+// localFill caches value for key in cache unless a value for key is
+// already cached, which may be more recent, and reports whether value
+// was cached.
+func (g *Group) localFill(key string, value ByteView, cache *cache) bool {
+	if g.maxBytes() <= 0 || value.expired() {
+		return false
+	}
+
+	var filled bool
+	g.loadGroup.Lock(func() {
+		if _, ok := g.lookupCache(key); ok {
+			return
+		}
+		g.misses.remove(key)
+		g.failures.remove(key)
+		g.populateCache(key, value, cache)
+		filled = true
+	})
+	return filled
+}
+
+// localBumpGeneration invalidates every entry of the main and hot cache
+// in constant time and forgets the keys of the negative cache. The
+// TierTwo, whose entries are not versioned, is cleared as by localFlush.
+func (g *Group) localBumpGeneration() {
+	g.clearTierTwo()
+	// Ensure no requests are in flight
+	g.loadGroup.Lock(func() {
+		g.hotCache.bump()
+		g.mainCache.bump()
+		g.misses.clear()
+		g.oversized.clear()
+		g.failures.clear()
+		g.generation.Add(1)
+	})
+	g.loadGroup.ForgetAll()
+}
+
+// localFlush empties both the main and hot cache, and the TierTwo if it
+// is a TierTwoClearer.
+func (g *Group) localFlush() {
+	g.emptyCaches(true)
+}
+
+// emptyCaches empties both the main and hot cache, and the TierTwo too
+// if tierTwo is set.
+func (g *Group) emptyCaches(tierTwo bool) {
+	if tierTwo {
+		g.clearTierTwo()
+	}
+	// Ensure no requests are in flight
+	g.loadGroup.Lock(func() {
+		g.hotCache.clear()
+		g.mainCache.clear()
+		g.misses.clear()
+		g.oversized.clear()
+		g.failures.clear()
+	})
+	g.loadGroup.ForgetAll()
+}
+
 func (g *Group) populateCache(key string, value ByteView, cache *cache) {
-	if g.cacheBytes <= 0 {
+	if g.maxBytes() <= 0 {
+		return
+	}
+	if g.tooLargeToCache(key, value) {
+		g.oversized.add(key)
+		return
+	}
+	if cache == &g.hotCache && g.opts.DisableHotCache {
 		return
 	}
+	value.added = time.Now().UnixNano()
 	cache.add(key, value)
+	g.evict()
+}
 
-	// Evict items from cache(s) if necessary.
+// evict removes items from the cache(s) until they fit within the
+// current cacheBytes limit.
+func (g *Group) evict() {
 	for {
 		mainBytes := g.mainCache.bytes()
 		hotBytes := g.hotCache.bytes()
-		if mainBytes+hotBytes <= g.cacheBytes {
+		limit := g.maxBytes()
+		if limit < 0 {
+			limit = 0
+		}
+		if hotLimit := atomic.LoadInt64(&g.hotCacheBytes); hotLimit >= 0 {
+			switch {
+			case hotBytes > hotLimit:
+				g.hotCache.removeOldest()
+			case mainBytes > limit-hotLimit:
+				g.mainCache.removeOldest()
+			default:
+				return
+			}
+			continue
+		}
+		if mainBytes+hotBytes <= limit {
 			return
 		}
 
@@ -483,6 +2164,75 @@ func (g *Group) populateCache(key string, value ByteView, cache *cache) {
 	}
 }
 
+// DefaultEntryOverhead is an estimate of the memory the caches spend
+// holding an entry besides its key and value, on 64-bit platforms: its
+// map slot, policy list element and entry, and boxed ByteView. See
+// GroupOptions.EntryOverhead.
+const DefaultEntryOverhead = 256
+
+// entryCost returns what the entry of key counts against cacheBytes.
+// Negative costs count as zero.
+func (g *Group) entryCost(key string, value ByteView) int64 {
+	if g.opts.Cost == nil {
+		return entrySize(key, value) + g.opts.EntryOverhead
+	}
+	cost := g.opts.Cost(key, value)
+	if cost < 0 {
+		cost = 0
+	}
+	return cost + g.opts.EntryOverhead
+}
+
+// entrySize returns the length of the key and value of an entry.
+func entrySize(key string, value ByteView) int64 {
+	return int64(len(key)) + int64(value.Len())
+}
+
+// tooLargeToCache reports whether the size of the entry exceeds the
+// configured MaxValueBytes or MaxValueFraction of the cache budget,
+// whatever its Cost.
+func (g *Group) tooLargeToCache(key string, value ByteView) bool {
+	size := entrySize(key, value)
+	if g.opts.MaxValueBytes > 0 && size > g.opts.MaxValueBytes {
+		return true
+	}
+	if g.opts.MaxValueFraction <= 0 {
+		return false
+	}
+	return float64(size) > g.opts.MaxValueFraction*float64(g.maxBytes())
+}
+
+func (g *Group) maxBytes() int64 {
+	return atomic.LoadInt64(&g.cacheBytes)
+}
+
+// SetCacheBytes changes the size limits of the main and hot caches,
+// which from then on only evict their own entries, as with
+// GroupOptions.HotCacheRatio. A cache holding more than its new limit
+// evicts entries immediately until it fits.
+func (g *Group) SetCacheBytes(main, hot int64) {
+	if main < 0 {
+		main = 0
+	}
+	if hot < 0 {
+		hot = 0
+	}
+	atomic.StoreInt64(&g.hotCacheBytes, hot)
+	atomic.StoreInt64(&g.cacheBytes, main+hot)
+	g.evict()
+}
+
+// CacheBytes returns the size limits of the main and hot caches. If
+// the caches share a single limit, hot is -1 and main is the limit.
+func (g *Group) CacheBytes() (main, hot int64) {
+	hot = atomic.LoadInt64(&g.hotCacheBytes)
+	main = g.maxBytes()
+	if hot > 0 {
+		main -= hot
+	}
+	return main, hot
+}
+
 // CacheType represents a type of cache.
 type CacheType int
 
@@ -509,18 +2259,470 @@ func (g *Group) CacheStats(which CacheType) CacheStats {
 	}
 }
 
-// cache is a wrapper around an *lru.Cache that adds synchronization,
-// makes values always be ByteView, and counts the size of all keys and
-// values.
+// KeyInfo describes a cached entry of a group, as listed by Keys.
+type KeyInfo struct {
+	Key    string
+	Size   int64     // as counted against cacheBytes, see GroupOptions.Cost
+	Expire time.Time // zero if the value never expires
+	Cache  CacheType
+}
+
+// Keys lists the unexpired entries of the main and hot caches, for
+// debugging and admin tooling. It does not count as an access of the
+// entries. Each shard of a cache is listed at a single instant, but
+// entries may be added or evicted between shards. Caches whose policy
+// is not a policy.Ranger list no entries.
+func (g *Group) Keys() []KeyInfo {
+	var res []KeyInfo
+	for _, c := range []struct {
+		cache *cache
+		which CacheType
+	}{{&g.mainCache, MainCache}, {&g.hotCache, HotCache}} {
+		c.cache.rangeEntries(func(key string, value ByteView) {
+			res = append(res, KeyInfo{
+				Key:    key,
+				Size:   value.cost,
+				Expire: value.Expire(),
+				Cache:  c.which,
+			})
+		})
+	}
+	return res
+}
+
+// Peek returns the cached value of key, if any, without loading it and
+// without counting an access, so the eviction order and the hit rate
+// of the group are unchanged. Caches whose policy is not a
+// policy.Peeker never have a value to peek at.
+func (g *Group) Peek(key string) (ByteView, bool) {
+	key = g.encodeKey(key)
+	if value, ok := g.mainCache.peek(key); ok {
+		return value, true
+	}
+	return g.hotCache.peek(key)
+}
+
+// Contains reports whether the main or hot cache holds an unexpired
+// value for key, as Peek does.
+func (g *Group) Contains(key string) bool {
+	_, ok := g.Peek(key)
+	return ok
+}
+
+// StatsSnapshot is a point-in-time copy of a group's Stats together
+// with the sizes of its main and hot caches.
+type StatsSnapshot struct {
+	Gets                     int64
+	CacheHits                int64
+	GetFromPeersLatencyLower int64
+	PeerLoads                int64
+	PeerErrors               int64
+	Loads                    int64
+	LoadsDeduped             int64
+	LocalLoads               int64
+	LocalLoadErrs            int64
+	ServerRequests           int64
+	NegativeHits             int64
+	StaleHits                int64
+	TierTwoHits              int64
+	OversizedBypasses        int64
+	HedgedLoads              int64
+	HedgeWins                int64
+	GetterRetries            int64
+	PermanentErrorHits       int64
+	TombstoneHits            int64
+	LoadsQueued              int64
+	LoadsRejected            int64
+	WriteBackFlushed         int64
+	WriteBackDropped         int64
+	FaultsInjected           int64
+	Revalidations            int64
+	RevalidationChanges      int64
+	UnleasedLoads            int64
+
+	MainCacheBytes int64
+	MainCacheItems int64
+	HotCacheBytes  int64
+	HotCacheItems  int64
+
+	LocalLoadLatency HistogramSnapshot
+	PeerLoadLatency  HistogramSnapshot
+	ServerLatency    HistogramSnapshot
+}
+
+// Sub returns the counts of s since prev, an earlier snapshot of the
+// same group, to compute rates, hit ratios or latency quantiles over
+// the window between them. GetFromPeersLatencyLower and the cache sizes
+// are current values and are kept as they are in s.
+func (s StatsSnapshot) Sub(prev StatsSnapshot) StatsSnapshot {
+	s.Gets -= prev.Gets
+	s.CacheHits -= prev.CacheHits
+	s.PeerLoads -= prev.PeerLoads
+	s.PeerErrors -= prev.PeerErrors
+	s.Loads -= prev.Loads
+	s.LoadsDeduped -= prev.LoadsDeduped
+	s.LocalLoads -= prev.LocalLoads
+	s.LocalLoadErrs -= prev.LocalLoadErrs
+	s.ServerRequests -= prev.ServerRequests
+	s.NegativeHits -= prev.NegativeHits
+	s.StaleHits -= prev.StaleHits
+	s.TierTwoHits -= prev.TierTwoHits
+	s.OversizedBypasses -= prev.OversizedBypasses
+	s.HedgedLoads -= prev.HedgedLoads
+	s.HedgeWins -= prev.HedgeWins
+	s.GetterRetries -= prev.GetterRetries
+	s.PermanentErrorHits -= prev.PermanentErrorHits
+	s.TombstoneHits -= prev.TombstoneHits
+	s.LoadsQueued -= prev.LoadsQueued
+	s.LoadsRejected -= prev.LoadsRejected
+	s.WriteBackFlushed -= prev.WriteBackFlushed
+	s.WriteBackDropped -= prev.WriteBackDropped
+	s.FaultsInjected -= prev.FaultsInjected
+	s.Revalidations -= prev.Revalidations
+	s.RevalidationChanges -= prev.RevalidationChanges
+	s.UnleasedLoads -= prev.UnleasedLoads
+	s.LocalLoadLatency = s.LocalLoadLatency.Sub(prev.LocalLoadLatency)
+	s.PeerLoadLatency = s.PeerLoadLatency.Sub(prev.PeerLoadLatency)
+	s.ServerLatency = s.ServerLatency.Sub(prev.ServerLatency)
+	return s
+}
+
+// Add returns the statistics of s and other together, such as those of
+// several groups. GetFromPeersLatencyLower is the larger of both.
+func (s StatsSnapshot) Add(other StatsSnapshot) StatsSnapshot {
+	s.Gets += other.Gets
+	s.CacheHits += other.CacheHits
+	if other.GetFromPeersLatencyLower > s.GetFromPeersLatencyLower {
+		s.GetFromPeersLatencyLower = other.GetFromPeersLatencyLower
+	}
+	s.PeerLoads += other.PeerLoads
+	s.PeerErrors += other.PeerErrors
+	s.Loads += other.Loads
+	s.LoadsDeduped += other.LoadsDeduped
+	s.LocalLoads += other.LocalLoads
+	s.LocalLoadErrs += other.LocalLoadErrs
+	s.ServerRequests += other.ServerRequests
+	s.NegativeHits += other.NegativeHits
+	s.StaleHits += other.StaleHits
+	s.TierTwoHits += other.TierTwoHits
+	s.OversizedBypasses += other.OversizedBypasses
+	s.HedgedLoads += other.HedgedLoads
+	s.HedgeWins += other.HedgeWins
+	s.GetterRetries += other.GetterRetries
+	s.PermanentErrorHits += other.PermanentErrorHits
+	s.TombstoneHits += other.TombstoneHits
+	s.LoadsQueued += other.LoadsQueued
+	s.LoadsRejected += other.LoadsRejected
+	s.WriteBackFlushed += other.WriteBackFlushed
+	s.WriteBackDropped += other.WriteBackDropped
+	s.FaultsInjected += other.FaultsInjected
+	s.Revalidations += other.Revalidations
+	s.RevalidationChanges += other.RevalidationChanges
+	s.UnleasedLoads += other.UnleasedLoads
+	s.MainCacheBytes += other.MainCacheBytes
+	s.MainCacheItems += other.MainCacheItems
+	s.HotCacheBytes += other.HotCacheBytes
+	s.HotCacheItems += other.HotCacheItems
+	s.LocalLoadLatency = s.LocalLoadLatency.Add(other.LocalLoadLatency)
+	s.PeerLoadLatency = s.PeerLoadLatency.Add(other.PeerLoadLatency)
+	s.ServerLatency = s.ServerLatency.Add(other.ServerLatency)
+	return s
+}
+
+// NamespaceStats returns the statistics of the groups of namespace
+// added together, as of their Snapshots.
+func NamespaceStats(namespace string) StatsSnapshot {
+	var s StatsSnapshot
+	for _, g := range GetGroups() {
+		if g.Namespace() == namespace {
+			s = s.Add(g.Snapshot())
+		}
+	}
+	return s
+}
+
+// HitRatio returns the share of Gets served from the caches, or zero if
+// there were none.
+func (s StatsSnapshot) HitRatio() float64 {
+	if s.Gets == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(s.Gets)
+}
+
+// Snapshot returns a coherent copy of the group's statistics.
+//
+// Counters are read in the reverse order in which Get increments them,
+// so derived invariants such as CacheHits <= Gets always hold for the
+// returned value. The cache sizes are read while holding both cache
+// locks so they describe the same instant.
+func (g *Group) Snapshot() StatsSnapshot {
+	var s StatsSnapshot
+	s.ServerLatency = g.Stats.ServerLatency.Snapshot()
+	s.PeerLoadLatency = g.Stats.PeerLoadLatency.Snapshot()
+	s.LocalLoadLatency = g.Stats.LocalLoadLatency.Snapshot()
+	s.ServerRequests = g.Stats.ServerRequests.Get()
+	s.PermanentErrorHits = g.Stats.PermanentErrorHits.Get()
+	s.TombstoneHits = g.Stats.TombstoneHits.Get()
+	s.LoadsQueued = g.Stats.LoadsQueued.Get()
+	s.LoadsRejected = g.Stats.LoadsRejected.Get()
+	s.WriteBackFlushed = g.Stats.WriteBackFlushed.Get()
+	s.WriteBackDropped = g.Stats.WriteBackDropped.Get()
+	s.FaultsInjected = g.Stats.FaultsInjected.Get()
+	s.Revalidations = g.Stats.Revalidations.Get()
+	s.RevalidationChanges = g.Stats.RevalidationChanges.Get()
+	s.UnleasedLoads = g.Stats.UnleasedLoads.Get()
+	s.GetterRetries = g.Stats.GetterRetries.Get()
+	s.OversizedBypasses = g.Stats.OversizedBypasses.Get()
+	s.HedgedLoads = g.Stats.HedgedLoads.Get()
+	s.HedgeWins = g.Stats.HedgeWins.Get()
+	s.TierTwoHits = g.Stats.TierTwoHits.Get()
+	s.StaleHits = g.Stats.StaleHits.Get()
+	s.NegativeHits = g.Stats.NegativeHits.Get()
+	s.LocalLoadErrs = g.Stats.LocalLoadErrs.Get()
+	s.LocalLoads = g.Stats.LocalLoads.Get()
+	s.PeerErrors = g.Stats.PeerErrors.Get()
+	s.PeerLoads = g.Stats.PeerLoads.Get()
+	s.GetFromPeersLatencyLower = g.Stats.GetFromPeersLatencyLower.Get()
+	s.LoadsDeduped = g.Stats.LoadsDeduped.Get()
+	s.CacheHits = g.Stats.CacheHits.Get()
+	s.Loads = g.Stats.Loads.Get()
+	s.Gets = g.Stats.Gets.Get()
+
+	g.mainCache.rlock()
+	g.hotCache.rlock()
+	s.MainCacheBytes = g.mainCache.bytesLocked()
+	s.MainCacheItems = g.mainCache.itemsLocked()
+	s.HotCacheBytes = g.hotCache.bytesLocked()
+	s.HotCacheItems = g.hotCache.itemsLocked()
+	g.hotCache.runlock()
+	g.mainCache.runlock()
+	return s
+}
+
+// cache holds the entries of a main or hot cache in shards, each
+// holding the keys that hash to it, so that concurrent accesses to
+// different keys rarely contend for the same lock.
 type cache struct {
+	shards []cacheShard
+}
+
+func (c *cache) init(shards int, newPolicy policy.New, grace time.Duration, cost func(key string, value ByteView) int64) {
+	if shards < 1 {
+		shards = 1
+	}
+	c.shards = make([]cacheShard, shards)
+	for i := range c.shards {
+		c.shards[i].newPolicy = newPolicy
+		c.shards[i].grace = grace
+		c.shards[i].cost = cost
+	}
+}
+
+func (c *cache) shard(key string) *cacheShard {
+	if len(c.shards) == 1 {
+		return &c.shards[0]
+	}
+	// FNV-1a, inlined to avoid allocating a hash.Hash per call.
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &c.shards[h%uint32(len(c.shards))]
+}
+
+func (c *cache) stats() CacheStats {
+	var s CacheStats
+	for i := range c.shards {
+		ss := c.shards[i].stats()
+		s.Bytes += ss.Bytes
+		s.Items += ss.Items
+		s.Gets += ss.Gets
+		s.Hits += ss.Hits
+		s.Evictions += ss.Evictions
+	}
+	return s
+}
+
+func (c *cache) add(key string, value ByteView) {
+	c.shard(key).add(key, value)
+}
+
+func (c *cache) get(key string) (value ByteView, ok bool) {
+	return c.shard(key).get(key)
+}
+
+func (c *cache) remove(key string) {
+	c.shard(key).remove(key)
+}
+
+// removeOldest evicts the oldest entry of the largest shard, which
+// keeps the shards about evenly filled.
+func (c *cache) removeOldest() {
+	victim := &c.shards[0]
+	max := victim.bytes()
+	for i := 1; i < len(c.shards); i++ {
+		if b := c.shards[i].bytes(); b > max {
+			victim, max = &c.shards[i], b
+		}
+	}
+	victim.removeOldest()
+}
+
+func (c *cache) removeExpired(now time.Time) {
+	for i := range c.shards {
+		c.shards[i].removeExpired(now)
+	}
+}
+
+// bump makes every entry of c stale without iterating them.
+func (c *cache) bump() {
+	for i := range c.shards {
+		c.shards[i].bump()
+	}
+}
+
+func (c *cache) clear() {
+	for i := range c.shards {
+		c.shards[i].clear()
+	}
+}
+
+func (c *cache) setOnEvicted(fn func(key string, value ByteView, reason EvictReason)) {
+	for i := range c.shards {
+		c.shards[i].setOnEvicted(fn)
+	}
+}
+
+func (c *cache) bytes() int64 {
+	var n int64
+	for i := range c.shards {
+		n += c.shards[i].bytes()
+	}
+	return n
+}
+
+func (c *cache) items() int64 {
+	var n int64
+	for i := range c.shards {
+		n += c.shards[i].items()
+	}
+	return n
+}
+
+// hottest returns up to n unexpired entries of the cache, those its
+// policy values most first, taking turns between the shards. Caches
+// whose policy is not a policy.Ranger return none.
+func (c *cache) hottest(n int) []cacheEntry {
+	lists := make([][]cacheEntry, len(c.shards))
+	for i := range c.shards {
+		lists[i] = c.shards[i].hottest(n)
+	}
+	var res []cacheEntry
+	for i := 0; len(res) < n; i++ {
+		var more bool
+		for _, l := range lists {
+			if i < len(l) && len(res) < n {
+				res = append(res, l[i])
+				more = true
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return res
+}
+
+// keysWithPrefix returns the keys of the cache starting with prefix,
+// expired ones included. Caches whose policy is not a policy.Ranger
+// return none.
+func (c *cache) keysWithPrefix(prefix string) []string {
+	var keys []string
+	for i := range c.shards {
+		keys = c.shards[i].keysWithPrefix(prefix, keys)
+	}
+	return keys
+}
+
+// peek returns the unexpired value of key without recording an access.
+func (c *cache) peek(key string) (ByteView, bool) {
+	return c.shard(key).peek(key)
+}
+
+// rangeEntries calls fn for every unexpired entry, one shard at a time.
+// fn must not call back into the cache.
+func (c *cache) rangeEntries(fn func(key string, value ByteView)) {
+	for i := range c.shards {
+		c.shards[i].rangeEntries(fn)
+	}
+}
+
+// rlock read locks every shard, so bytesLocked and itemsLocked
+// describe a single instant.
+func (c *cache) rlock() {
+	for i := range c.shards {
+		c.shards[i].mu.RLock()
+	}
+}
+
+func (c *cache) runlock() {
+	for i := range c.shards {
+		c.shards[i].mu.RUnlock()
+	}
+}
+
+func (c *cache) bytesLocked() int64 {
+	var n int64
+	for i := range c.shards {
+		n += c.shards[i].nbytes
+	}
+	return n
+}
+
+func (c *cache) itemsLocked() int64 {
+	var n int64
+	for i := range c.shards {
+		n += c.shards[i].itemsLocked()
+	}
+	return n
+}
+
+// cacheShard is a wrapper around a policy.Cache that adds
+// synchronization, makes values always be ByteView, and counts the
+// cost of all entries.
+type cacheShard struct {
 	mu         sync.RWMutex
-	nbytes     int64 // of all keys and values
-	lru        *lru.Cache
+	nbytes     int64         // cost of all entries
+	newPolicy  policy.New    // creates entries; nil means policy.LRU
+	grace      time.Duration // how long entries are kept past their expiry
+	entries    policy.Cache  // created on first add
 	nhit, nget int64
-	nevict     int64 // number of evictions
+	nevict     int64       // number of evictions
+	replacing  bool        // add is removing the entry it replaces
+	reason     EvictReason // of the entries the current operation evicts
+	gen        uint64      // entries of older generations are stale
+
+	onEvicted func(key string, value ByteView, reason EvictReason)
+	evicted   []evictedEntry // pending onEvicted calls; guarded by mu
+
+	// cost returns what an entry counts in nbytes; nil means entrySize.
+	cost func(key string, value ByteView) int64
 }
 
-func (c *cache) stats() CacheStats {
+type cacheEntry struct {
+	key   string
+	value ByteView
+}
+
+type evictedEntry struct {
+	key    string
+	value  ByteView
+	reason EvictReason
+}
+
+func (c *cacheShard) stats() CacheStats {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return CacheStats{
@@ -532,71 +2734,213 @@ func (c *cache) stats() CacheStats {
 	}
 }
 
-func (c *cache) add(key string, value ByteView) {
+func (c *cacheShard) add(key string, value ByteView) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru == nil {
-		c.lru = &lru.Cache{
-			OnEvicted: func(key lru.Key, value interface{}) {
-				val := value.(ByteView)
-				c.nbytes -= int64(len(key.(string))) + int64(val.Len())
-				c.nevict++
-			},
+	defer c.unlock()
+	if c.entries == nil {
+		newPolicy := c.newPolicy
+		if newPolicy == nil {
+			newPolicy = policy.LRU()
 		}
+		c.entries = newPolicy(func(key string, value interface{}) {
+			val := value.(ByteView)
+			c.nbytes -= val.cost
+			if c.replacing {
+				return
+			}
+			c.nevict++
+			if c.onEvicted != nil {
+				c.evicted = append(c.evicted, evictedEntry{key, val, c.reason})
+			}
+		})
+	}
+	// Replace rather than update an existing entry, such as one being
+	// refreshed, so both its size and expiry are accounted for. The old
+	// value is not evicted, so it is neither counted nor reported.
+	c.replacing = true
+	c.entries.Remove(key)
+	c.replacing = false
+	value.gen = c.gen
+	if c.cost != nil {
+		value.cost = c.cost(key, value)
+	} else {
+		value.cost = entrySize(key, value)
+	}
+	c.nbytes += value.cost
+	c.reason = EvictedCapacity // if the policy declines value
+	expire := value.Expire()
+	if !expire.IsZero() {
+		expire = expire.Add(c.grace)
 	}
-	c.lru.Add(key, value, value.Expire())
-	c.nbytes += int64(len(key)) + int64(value.Len())
+	c.entries.Add(key, value, expire)
 }
 
-func (c *cache) get(key string) (value ByteView, ok bool) {
+func (c *cacheShard) get(key string) (value ByteView, ok bool) {
 	c.mu.Lock()
-	defer c.mu.Unlock()
+	defer c.unlock()
 	c.nget++
-	if c.lru == nil {
+	if c.entries == nil {
 		return
 	}
-	vi, ok := c.lru.Get(key)
+	c.reason = EvictedExpired
+	vi, ok := c.entries.Get(key)
 	if !ok {
 		return
 	}
+	if vi.(ByteView).gen != c.gen {
+		c.reason = EvictedRemoved
+		c.entries.Remove(key)
+		return ByteView{}, false
+	}
 	c.nhit++
 	return vi.(ByteView), true
 }
 
-func (c *cache) remove(key string) {
+// bump makes every entry of c stale. Stale entries are misses, which
+// Get removes, and are otherwise left for the policy to evict.
+func (c *cacheShard) bump() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.lru == nil {
+	c.gen++
+}
+
+func (c *cacheShard) remove(key string) {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.entries == nil {
 		return
 	}
-	c.lru.Remove(key)
+	c.reason = EvictedRemoved
+	c.entries.Remove(key)
 }
 
-func (c *cache) removeOldest() {
+func (c *cacheShard) removeOldest() {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.lru != nil {
-		c.lru.RemoveOldest()
+	defer c.unlock()
+	if c.entries != nil {
+		c.reason = EvictedCapacity
+		c.entries.RemoveOldest()
 	}
 }
 
-func (c *cache) bytes() int64 {
+func (c *cacheShard) removeExpired(now time.Time) {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.entries != nil {
+		c.reason = EvictedExpired
+		c.entries.RemoveExpired(now)
+	}
+}
+
+func (c *cacheShard) clear() {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.entries != nil {
+		c.reason = EvictedRemoved
+		c.entries.Clear()
+	}
+}
+
+// unlock releases c.mu and then hands any entries evicted while it was
+// held to the onEvicted hook, so the hook may safely call back into
+// the group.
+func (c *cacheShard) unlock() {
+	evicted, fn := c.evicted, c.onEvicted
+	c.evicted = nil
+	c.mu.Unlock()
+	for _, e := range evicted {
+		fn(e.key, e.value, e.reason)
+	}
+}
+
+func (c *cacheShard) hottest(n int) []cacheEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r, ok := c.entries.(policy.Ranger)
+	if !ok || n <= 0 {
+		return nil
+	}
+	var res []cacheEntry
+	r.Range(func(key string, value interface{}) bool {
+		if val := value.(ByteView); !val.expired() && val.gen == c.gen {
+			res = append(res, cacheEntry{key, val})
+		}
+		return len(res) < n
+	})
+	return res
+}
+
+func (c *cacheShard) peek(key string) (ByteView, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	p, ok := c.entries.(policy.Peeker)
+	if !ok {
+		return ByteView{}, false
+	}
+	vi, ok := p.Peek(key)
+	if !ok || vi.(ByteView).expired() || vi.(ByteView).gen != c.gen {
+		return ByteView{}, false
+	}
+	return vi.(ByteView), true
+}
+
+// keysWithPrefix appends the keys of c starting with prefix to keys.
+func (c *cacheShard) keysWithPrefix(prefix string, keys []string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r, ok := c.entries.(policy.Ranger)
+	if !ok {
+		return keys
+	}
+	r.Range(func(key string, _ interface{}) bool {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+	return keys
+}
+
+// rangeEntries calls fn for the unexpired entries of c with its read
+// lock held, so fn must not call back into the cache.
+func (c *cacheShard) rangeEntries(fn func(key string, value ByteView)) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r, ok := c.entries.(policy.Ranger)
+	if !ok {
+		return
+	}
+	r.Range(func(key string, value interface{}) bool {
+		if val := value.(ByteView); !val.expired() && val.gen == c.gen {
+			fn(key, val)
+		}
+		return true
+	})
+}
+
+func (c *cacheShard) setOnEvicted(fn func(key string, value ByteView, reason EvictReason)) {
+	c.mu.Lock()
+	c.onEvicted = fn
+	c.mu.Unlock()
+}
+
+func (c *cacheShard) bytes() int64 {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.nbytes
 }
 
-func (c *cache) items() int64 {
+func (c *cacheShard) items() int64 {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	return c.itemsLocked()
 }
 
-func (c *cache) itemsLocked() int64 {
-	if c.lru == nil {
+func (c *cacheShard) itemsLocked() int64 {
+	if c.entries == nil {
 		return 0
 	}
-	return int64(c.lru.Len())
+	return int64(c.entries.Len())
 }
 
 // An AtomicInt is an int64 to be accessed atomically.
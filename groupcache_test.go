@@ -23,8 +23,13 @@ import (
 	"errors"
 	"fmt"
 	"hash/crc32"
+	"io/ioutil"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 	"unsafe"
@@ -32,6 +37,7 @@ import (
 	"github.com/golang/protobuf/proto"
 
 	pb "github.com/adistroy/groupcache/v3/groupcachepb"
+	"github.com/adistroy/groupcache/v3/policy"
 	"github.com/adistroy/groupcache/v3/testpb"
 )
 
@@ -226,7 +232,7 @@ func TestCacheEviction(t *testing.T) {
 	}
 
 	g := stringGroup.(*Group)
-	evict0 := g.mainCache.nevict
+	evict0 := g.mainCache.stats().Evictions
 
 	// Trash the cache with other keys.
 	var bytesFlooded int64
@@ -237,7 +243,7 @@ func TestCacheEviction(t *testing.T) {
 		stringGroup.Get(dummyCtx, key, StringSink(&res))
 		bytesFlooded += int64(len(key) + len(res))
 	}
-	evicts := g.mainCache.nevict - evict0
+	evicts := g.mainCache.stats().Evictions - evict0
 	if evicts <= 0 {
 		t.Errorf("evicts = %v; want more than 0", evicts)
 	}
@@ -252,6 +258,7 @@ func TestCacheEviction(t *testing.T) {
 type fakePeer struct {
 	hits int
 	fail bool
+	url  string // "fakePeer" if empty
 }
 
 func (p *fakePeer) Get(_ context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
@@ -272,6 +279,9 @@ func (p *fakePeer) Remove(_ context.Context, in *pb.GetRequest) error {
 }
 
 func (p *fakePeer) GetURL() string {
+	if p.url != "" {
+		return p.url
+	}
 	return "fakePeer"
 }
 
@@ -333,8 +343,8 @@ func TestPeers(t *testing.T) {
 	resetCacheSize := func(maxBytes int64) {
 		g := testGroup
 		g.cacheBytes = maxBytes
-		g.mainCache = cache{}
-		g.hotCache = cache{}
+		g.mainCache.clear()
+		g.hotCache.clear()
 	}
 
 	// Base case; peers all up, with no problems.
@@ -421,10 +431,22 @@ func (g *orderedFlightGroup) Do(key string, fn func() (interface{}, error)) (int
 	return g.orig.Do(key, fn)
 }
 
+func (g *orderedFlightGroup) DoContext(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	<-g.stage1
+	<-g.stage2
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.orig.DoContext(ctx, key, fn)
+}
+
 func (g *orderedFlightGroup) Lock(fn func()) {
 	fn()
 }
 
+func (g *orderedFlightGroup) Forget(key string) {}
+
+func (g *orderedFlightGroup) ForgetAll() {}
+
 // TestNoDedup tests invariants on the cache size when singleflight is
 // unable to dedup calls.
 func TestNoDedup(t *testing.T) {
@@ -440,7 +462,7 @@ func TestNoDedup(t *testing.T) {
 		orig:   g.loadGroup,
 	}
 	// Replace loadGroup with our wrapper so we can control when
-	// loadGroup.Do is entered for each concurrent request.
+	// loadGroup.DoContext is entered for each concurrent request.
 	g.loadGroup = orderedGroup
 
 	// Issue two idential requests concurrently.  Since the cache is
@@ -482,8 +504,8 @@ func TestNoDedup(t *testing.T) {
 	// upon entry, we would increment nbytes twice but the entry would
 	// only be in the cache once.
 	const wantBytes = int64(len(testkey) + len(testval))
-	if g.mainCache.nbytes != wantBytes {
-		t.Errorf("cache has %d bytes, want %d", g.mainCache.nbytes, wantBytes)
+	if g.mainCache.bytes() != wantBytes {
+		t.Errorf("cache has %d bytes, want %d", g.mainCache.bytes(), wantBytes)
 	}
 }
 
@@ -527,3 +549,1917 @@ func TestContextDeadlineOnPeer(t *testing.T) {
 		}
 	}
 }
+
+func TestSnapshotInvariants(t *testing.T) {
+	g := newGroup("TestSnapshotInvariants-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("got:"+key, time.Time{})
+	}), NoPeers{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				var s string
+				g.Get(dummyCtx, fmt.Sprintf("key-%d", j%50), StringSink(&s))
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		s := g.Snapshot()
+		if s.CacheHits > s.Gets {
+			t.Fatalf("snapshot CacheHits = %d > Gets = %d", s.CacheHits, s.Gets)
+		}
+		if s.LocalLoads > s.Loads {
+			t.Fatalf("snapshot LocalLoads = %d > Loads = %d", s.LocalLoads, s.Loads)
+		}
+	}
+
+	s := g.Snapshot()
+	if s.MainCacheItems != 50 {
+		t.Errorf("MainCacheItems = %d; want 50", s.MainCacheItems)
+	}
+	if want := g.mainCache.bytes(); s.MainCacheBytes != want {
+		t.Errorf("MainCacheBytes = %d; want %d", s.MainCacheBytes, want)
+	}
+}
+
+func TestMaxValueFraction(t *testing.T) {
+	var fills int
+	g := newGroupOpts("TestMaxValueFraction-group", 100, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		fills++
+		if key == "big" {
+			return dest.SetString(strings.Repeat("x", 60), time.Time{})
+		}
+		return dest.SetString("small", time.Time{})
+	}), NoPeers{}, &GroupOptions{MaxValueFraction: 0.5})
+
+	for _, key := range []string{"big", "small"} {
+		fills = 0
+		for i := 0; i < 2; i++ {
+			var s string
+			if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+				t.Fatal(err)
+			}
+			if key == "big" && len(s) != 60 {
+				t.Errorf("Get(%q) returned %d bytes; want 60", key, len(s))
+			}
+		}
+		want := 1
+		if key == "big" {
+			want = 2
+		}
+		if fills != want {
+			t.Errorf("key %q: got %d fills; want %d", key, fills, want)
+		}
+	}
+	if items := g.mainCache.items(); items != 1 {
+		t.Errorf("mainCache has %d items; want 1", items)
+	}
+}
+
+// flushPeer is a fakePeer that is a PeerFlusher.
+type flushPeer struct {
+	fakePeer
+	flushed []string
+}
+
+func (p *flushPeer) Flush(_ context.Context, group string) error {
+	p.flushed = append(p.flushed, group)
+	return nil
+}
+
+func TestClear(t *testing.T) {
+	const name = "TestClear-group"
+	g := newGroup(name, 1<<10, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(name)
+	var s string
+	if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+
+	flusher, plain := &flushPeer{fakePeer: fakePeer{url: "flusher"}}, &fakePeer{url: "plain"}
+	g.peers = fakePeers{flusher, plain}
+	err := g.Clear(dummyCtx)
+	perr, ok := err.(PeersError)
+	if !ok || len(perr) != 1 || perr["plain"] == nil {
+		t.Errorf("Clear returned %v; want an error for the plain peer only", err)
+	}
+	if len(flusher.flushed) != 1 || flusher.flushed[0] != name {
+		t.Errorf("peer flushed %v; want [%s]", flusher.flushed, name)
+	}
+	if items := g.mainCache.items(); items != 0 {
+		t.Errorf("main cache has %d items after Clear; want 0", items)
+	}
+}
+
+// bumpPeer is a fakePeer that is a PeerGenerationBumper.
+type bumpPeer struct {
+	fakePeer
+	bumped []string
+}
+
+func (p *bumpPeer) BumpGeneration(_ context.Context, group string) error {
+	p.bumped = append(p.bumped, group)
+	return nil
+}
+
+func TestBumpGeneration(t *testing.T) {
+	const name = "TestBumpGeneration-group"
+	var fills int
+	g := newGroup(name, 1<<10, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		fills++
+		return dest.SetString(fmt.Sprintf("value-%d", fills), time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(name)
+	get := func() string {
+		var s string
+		if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	get()
+	g.localSet("hot", ByteView{s: "hot"}, &g.hotCache)
+	if err := g.BumpGeneration(dummyCtx); err != nil {
+		t.Fatal(err)
+	}
+	if g.Generation() != 1 {
+		t.Errorf("Generation = %d; want 1", g.Generation())
+	}
+	if keys := g.Keys(); len(keys) != 0 {
+		t.Errorf("Keys after BumpGeneration = %v; want none", keys)
+	}
+	if g.Contains("hot") {
+		t.Error("hot cache entry survived BumpGeneration")
+	}
+	if s := get(); s != "value-2" {
+		t.Errorf("Get after BumpGeneration = %q; want a reloaded value", s)
+	}
+	if s := get(); s != "value-2" || fills != 2 {
+		t.Errorf("Get = %q after %d fills; want the value cached in the new generation", s, fills)
+	}
+
+	bumper, plain := &bumpPeer{fakePeer: fakePeer{url: "bumper"}}, &fakePeer{url: "plain"}
+	g.peers = fakePeers{bumper, plain}
+	err := g.BumpGeneration(dummyCtx)
+	if perr, ok := err.(PeersError); !ok || len(perr) != 1 || perr["plain"] == nil {
+		t.Errorf("BumpGeneration returned %v; want an error for the plain peer only", err)
+	}
+	if len(bumper.bumped) != 1 || bumper.bumped[0] != name {
+		t.Errorf("peer bumped %v; want [%s]", bumper.bumped, name)
+	}
+}
+
+func TestMaxValueBytes(t *testing.T) {
+	var fills int
+	g := newGroupOpts("TestMaxValueBytes-group", 1000, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		fills++
+		return dest.SetString(strings.Repeat("x", 20), time.Time{})
+	}), NoPeers{}, &GroupOptions{MaxValueBytes: 20})
+	defer DeregisterGroup("TestMaxValueBytes-group")
+
+	for i := 0; i < 2; i++ {
+		var s string
+		if err := g.Get(dummyCtx, "big", StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		if len(s) != 20 {
+			t.Errorf("Get returned %d bytes; want 20", len(s))
+		}
+	}
+	if fills != 2 {
+		t.Errorf("got %d fills; want 2", fills)
+	}
+	if items := g.mainCache.items(); items != 0 {
+		t.Errorf("mainCache has %d items; want 0", items)
+	}
+}
+
+func TestBypassOversized(t *testing.T) {
+	var fills int
+	value := strings.Repeat("x", 20)
+	peer := &fakePeer{}
+	g := newGroupOpts("TestBypassOversized-group", 1000, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		fills++
+		return dest.SetString(value, time.Time{})
+	}), fakePeers{peer}, &GroupOptions{MaxValueBytes: 8, BypassOversizedTTL: time.Minute})
+	defer DeregisterGroup("TestBypassOversized-group")
+
+	get := func() string {
+		var s string
+		if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	// The owner returns "got:key", too large to cache, so the key is
+	// loaded by the local getter from then on.
+	if s := get(); s != "got:key" || peer.hits != 1 {
+		t.Fatalf("first Get = %q with %d peer hits; want the value of the owner", s, peer.hits)
+	}
+	for i := 0; i < 2; i++ {
+		if s := get(); s != value {
+			t.Errorf("Get = %q; want the value of the getter", s)
+		}
+	}
+	if peer.hits != 1 || fills != 2 || g.Stats.OversizedBypasses.Get() != 2 {
+		t.Errorf("got %d peer hits, %d fills and %d bypasses; want 1, 2 and 2", peer.hits, fills, g.Stats.OversizedBypasses.Get())
+	}
+
+	// Once the value fits, the owner is asked again.
+	value = "small"
+	get()
+	get()
+	if peer.hits != 2 || fills != 3 {
+		t.Errorf("got %d peer hits and %d fills; want 2 and 3", peer.hits, fills)
+	}
+}
+
+func TestSetCacheBytes(t *testing.T) {
+	g := newGroup("TestSetCacheBytes-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+
+	// Each entry is len("key-N") + len("value") = 10 bytes.
+	const entrySize = 10
+	for i := 0; i < 10; i++ {
+		var s string
+		if err := g.Get(dummyCtx, fmt.Sprintf("key-%d", i), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if items := g.mainCache.items(); items != 10 {
+		t.Fatalf("mainCache has %d items; want 10", items)
+	}
+
+	g.SetCacheBytes(4*entrySize, 0)
+	stats := g.CacheStats(MainCache)
+	if stats.Items != 4 {
+		t.Errorf("after SetCacheBytes mainCache has %d items; want 4", stats.Items)
+	}
+	if stats.Evictions != 6 {
+		t.Errorf("after SetCacheBytes got %d evictions; want 6", stats.Evictions)
+	}
+
+	for i := 10; i < 20; i++ {
+		var s string
+		if err := g.Get(dummyCtx, fmt.Sprintf("key-%d", i), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		if bytes := g.mainCache.bytes() + g.hotCache.bytes(); bytes > 4*entrySize {
+			t.Fatalf("cache holds %d bytes; want at most %d", bytes, 4*entrySize)
+		}
+	}
+}
+
+func TestHotCacheBytes(t *testing.T) {
+	const name = "TestHotCacheBytes-group"
+	g := newGroupOpts(name, 100, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{}, &GroupOptions{HotCacheRatio: 0.2})
+	defer DeregisterGroup(name)
+	if main, hot := g.CacheBytes(); main != 80 || hot != 20 {
+		t.Errorf("CacheBytes = %d, %d; want 80, 20", main, hot)
+	}
+
+	// Each entry is len("key-N") + len("value") = 10 bytes.
+	g.SetCacheBytes(40, 20)
+	for i := 0; i < 4; i++ {
+		g.localSet(fmt.Sprintf("key-%d", i), ByteView{s: "value"}, &g.mainCache)
+	}
+	for i := 4; i < 7; i++ {
+		g.localSet(fmt.Sprintf("key-%d", i), ByteView{s: "value"}, &g.hotCache)
+	}
+	// The hot cache only evicts its own entries, although the main
+	// cache is far from an eighth of it.
+	if main, hot := g.mainCache.items(), g.hotCache.items(); main != 4 || hot != 2 {
+		t.Errorf("main and hot caches hold %d and %d items; want 4 and 2", main, hot)
+	}
+
+	g.SetCacheBytes(20, 20)
+	if main, hot := g.mainCache.items(), g.hotCache.items(); main != 2 || hot != 2 {
+		t.Errorf("after shrinking the main cache they hold %d and %d items; want 2 and 2", main, hot)
+	}
+}
+
+func TestOnEvictHook(t *testing.T) {
+	g := newGroup("TestOnEvictHook-group", 20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+
+	var evicted []string
+	g.OnEvict(func(key string, value ByteView) {
+		// Calling back into the group must not deadlock.
+		g.CacheStats(MainCache)
+		evicted = append(evicted, key)
+	})
+
+	// Each entry is 10 bytes so only two fit.
+	for _, key := range []string{"key-1", "key-2", "key-3"} {
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"key-1"}; !reflect.DeepEqual(evicted, want) {
+		t.Errorf("evicted = %v; want %v", evicted, want)
+	}
+}
+
+func TestOnRemovedHook(t *testing.T) {
+	g := newGroup("TestOnRemovedHook-group", 20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if key == "short" {
+			return dest.SetString("value", time.Now().Add(time.Millisecond))
+		}
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup("TestOnRemovedHook-group")
+
+	var removed []string
+	g.OnRemoved(func(key string, value ByteView, reason EvictReason) {
+		removed = append(removed, key+":"+reason.String())
+	})
+	var evicted int
+	g.OnEvict(func(string, ByteView) { evicted++ })
+
+	get := func(key string) {
+		t.Helper()
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Each entry is 10 bytes so only two fit.
+	get("key-1")
+	get("key-2")
+	get("key-3")
+	if err := g.Remove(dummyCtx, "key-2"); err != nil {
+		t.Fatal(err)
+	}
+	get("short")
+	time.Sleep(5 * time.Millisecond)
+	get("short")
+
+	want := []string{"key-1:capacity", "key-2:removed", "short:expired"}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed = %v; want %v", removed, want)
+	}
+	if evicted != len(removed) {
+		t.Errorf("OnEvict was called %d times; want %d", evicted, len(removed))
+	}
+}
+
+func TestOnLoadHook(t *testing.T) {
+	peer := &fakePeer{}
+	g := newGroup("TestOnLoadHook-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("got:"+key, time.Time{})
+	}), fakePeers([]ProtoGetter{peer}))
+
+	type load struct {
+		key, value string
+		local      bool
+	}
+	var loads []load
+	g.OnLoad(func(key string, value ByteView, local bool) {
+		loads = append(loads, load{key, value.String(), local})
+	})
+
+	var s string
+	if err := g.Get(dummyCtx, "peer-key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	want := []load{{"peer-key", "got:peer-key", false}}
+	if !reflect.DeepEqual(loads, want) {
+		t.Errorf("loads = %v; want %v", loads, want)
+	}
+
+	// Cache hits are not loads.
+	if err := g.Get(dummyCtx, "peer-key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if len(loads) != 1 {
+		t.Errorf("got %d loads after cache hit; want 1", len(loads))
+	}
+}
+
+// failoverPeers always nominates owner and fails over to the peers in
+// failover.
+type failoverPeers struct {
+	owner    ProtoGetter
+	failover []ProtoGetter
+	hops     int
+}
+
+func (p *failoverPeers) PickPeer(key string) (ProtoGetter, bool) { return p.owner, true }
+func (p *failoverPeers) GetAll() []ProtoGetter                   { return append([]ProtoGetter{p.owner}, p.failover...) }
+func (p *failoverPeers) FailoverHops() int                       { return p.hops }
+
+func (p *failoverPeers) PickFailover(key string, hops int) []ProtoGetter {
+	if hops > len(p.failover) {
+		hops = len(p.failover)
+	}
+	return p.failover[:hops]
+}
+
+func TestFailoverHopsOverride(t *testing.T) {
+	owner := &fakePeer{fail: true}
+	next := &fakePeer{}
+	localHits := 0
+	g := newGroup("TestFailoverHopsOverride-group", 0, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		localHits++
+		return dest.SetString("local:"+key, time.Time{})
+	}), &failoverPeers{owner: owner, failover: []ProtoGetter{next}, hops: 1})
+
+	var s string
+	if err := g.Get(context.Background(), "key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if s != "got:key" || next.hits != 1 || localHits != 0 {
+		t.Errorf("pool default: got %q, next hits = %d, local hits = %d; want %q from next peer", s, next.hits, localHits, "got:key")
+	}
+
+	ctx := WithFailoverHops(context.Background(), 0)
+	if err := g.Get(ctx, "key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if s != "local:key" || next.hits != 1 || localHits != 1 {
+		t.Errorf("zero hops: got %q, next hits = %d, local hits = %d; want %q from local getter", s, next.hits, localHits, "local:key")
+	}
+	if owner.hits != 2 {
+		t.Errorf("owner hits = %d; want 2", owner.hits)
+	}
+}
+
+func TestFailedOperations(t *testing.T) {
+	failing := &fakePeer{fail: true}
+	g := newGroupOpts("TestFailedOperations-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), fakePeers([]ProtoGetter{failing}), &GroupOptions{DeadLetterSize: 2})
+
+	for _, key := range []string{"key-1", "key-2", "key-3"} {
+		if err := g.Remove(dummyCtx, key); err == nil {
+			t.Fatalf("Remove(%q) succeeded against a failing peer", key)
+		}
+	}
+
+	ops := g.FailedOperations()
+	if len(ops) != 2 {
+		t.Fatalf("got %d failed operations; want 2", len(ops))
+	}
+	for i, key := range []string{"key-2", "key-3"} {
+		op := ops[i]
+		if op.Op != "remove" || op.Key != key || op.Peer != "fakePeer" || op.Err == nil || op.Time.IsZero() {
+			t.Errorf("ops[%d] = %+v; want failed remove of %q on fakePeer", i, op, key)
+		}
+	}
+}
+
+func TestFrequencyAdmission(t *testing.T) {
+	peer := &fakePeer{}
+	g := newGroupOpts("TestFrequencyAdmission-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local", time.Time{})
+	}), fakePeers([]ProtoGetter{peer}), &GroupOptions{HotCachePolicy: FrequencyAdmission(3, time.Minute)})
+
+	get := func(key string) {
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get("one-hit")
+	if _, ok := g.hotCache.get("one-hit"); ok {
+		t.Error("one-hit key was promoted to the hot cache")
+	}
+
+	for i := 0; i < 3; i++ {
+		get("three-hit")
+	}
+	if _, ok := g.hotCache.get("three-hit"); !ok {
+		t.Error("three-hit key was not promoted to the hot cache")
+	}
+
+	peer.hits = 0
+	get("three-hit")
+	if peer.hits != 0 {
+		t.Errorf("promoted key was fetched from the peer %d times; want 0", peer.hits)
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	const name = "TestIntrospection-group"
+	var loads int
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads++
+		return dest.SetString("value of "+key, time.Time{})
+	}), NoPeers{}, &GroupOptions{CacheShards: 4})
+	defer DeregisterGroup(name)
+
+	if _, ok := g.Peek("key"); ok || g.Contains("key") || loads != 0 {
+		t.Fatalf("Peek or Contains of an uncached key found it or loaded it %d times", loads)
+	}
+	var s string
+	for _, key := range []string{"key", "other"} {
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expire := time.Now().Add(time.Hour)
+	g.localSet("hot", ByteView{s: "hot value", e: expire}, &g.hotCache)
+
+	gets := g.CacheStats(MainCache).Gets
+	if v, ok := g.Peek("key"); !ok || v.String() != "value of key" {
+		t.Errorf("Peek(key) = %q, %v; want the cached value", v.String(), ok)
+	}
+	if !g.Contains("hot") || g.Contains("missing") {
+		t.Error("Contains did not report the hot key or reported a missing one")
+	}
+	if n := g.CacheStats(MainCache).Gets; n != gets {
+		t.Errorf("Peek and Contains counted %d cache gets", n-gets)
+	}
+
+	keys := g.Keys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	want := []KeyInfo{
+		{Key: "hot", Size: 12, Expire: expire, Cache: HotCache},
+		{Key: "key", Size: 15, Cache: MainCache},
+		{Key: "other", Size: 19, Cache: MainCache},
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("Keys = %+v; want %+v", keys, want)
+	}
+	if loads != 2 {
+		t.Errorf("getter called %d times; want 2", loads)
+	}
+}
+
+func TestHotCachePolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   GroupOptions
+		gets   int
+		cached bool
+	}{
+		{"always", GroupOptions{HotCachePolicy: AlwaysAdmission()}, 1, true},
+		{"never", GroupOptions{HotCachePolicy: NeverAdmission()}, 5, false},
+		{"sketch below k", GroupOptions{HotCachePolicy: SketchAdmission(3, 1024)}, 2, false},
+		{"sketch at k", GroupOptions{HotCachePolicy: SketchAdmission(3, 1024)}, 3, true},
+		{"sketch k of 0", GroupOptions{HotCachePolicy: SketchAdmission(0, 1024)}, 1, true},
+		{"sketch negative k", GroupOptions{HotCachePolicy: SketchAdmission(-1, 1024)}, 1, true},
+		{"disabled", GroupOptions{HotCachePolicy: AlwaysAdmission(), DisableHotCache: true, HotCacheRatio: 0.5}, 5, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "TestHotCachePolicies-" + tt.name
+			opts := tt.opts
+			g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+				return dest.SetString("local", time.Time{})
+			}), fakePeers([]ProtoGetter{&fakePeer{}}), &opts)
+			defer DeregisterGroup(name)
+
+			for i := 0; i < tt.gets; i++ {
+				var s string
+				if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, ok := g.hotCache.get("key"); ok != tt.cached {
+				t.Errorf("key in hot cache after %d gets = %v; want %v", tt.gets, ok, tt.cached)
+			}
+		})
+	}
+}
+
+func TestSketchAdmissionMinK(t *testing.T) {
+	for _, k := range []int{-1, 0} {
+		if got := SketchAdmission(k, 1024).(*sketchAdmission).k; got != 1 {
+			t.Errorf("SketchAdmission(%d) has k %d; want 1", k, got)
+		}
+	}
+}
+
+func TestDisableHotCache(t *testing.T) {
+	const name = "TestDisableHotCache-group"
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local", time.Time{})
+	}), NoPeers{}, &GroupOptions{DisableHotCache: true, HotCacheRatio: 0.5})
+	defer DeregisterGroup(name)
+
+	if main, hot := g.CacheBytes(); main != cacheSize || hot != -1 {
+		t.Errorf("CacheBytes = %d, %d; want %d, -1", main, hot, cacheSize)
+	}
+	// Set with hotCache and replication take this path.
+	g.localSet("key", ByteView{b: []byte("value")}, &g.hotCache)
+	if _, ok := g.hotCache.get("key"); ok {
+		t.Error("a value was added to a disabled hot cache")
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	var calls int32
+	unblock := make(chan bool)
+	g := newGroupOpts("TestRefreshAhead-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return dest.SetString("v1", time.Now().Add(30*time.Second))
+		}
+		<-unblock
+		return dest.SetString("v2", time.Now().Add(time.Hour))
+	}), NoPeers{}, &GroupOptions{RefreshWindow: time.Minute})
+	defer DeregisterGroup("TestRefreshAhead-group")
+	var evictions int32
+	g.OnEvict(func(string, ByteView) { atomic.AddInt32(&evictions, 1) })
+
+	get := func() string {
+		t.Helper()
+		var s string
+		if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if got := get(); got != "v1" {
+		t.Fatalf("first Get = %q; want v1", got)
+	}
+
+	// v1 expires within the window: it is still served, without waiting
+	// for the refresh blocked in the getter.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := get(); got != "v1" {
+				t.Errorf("Get during refresh = %q; want v1", got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	close(unblock)
+	refreshing := func() bool {
+		g.refreshMu.Lock()
+		defer g.refreshMu.Unlock()
+		return len(g.refreshing) != 0
+	}
+	for deadline := time.Now().Add(5 * time.Second); refreshing(); {
+		if time.Now().After(deadline) {
+			t.Fatal("refresh did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if got := get(); got != "v2" {
+		t.Errorf("Get after refresh = %q; want v2", got)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("getter called %d times; want 2", got)
+	}
+	if got, want := g.CacheStats(MainCache).Bytes, int64(len("key")+len("v2")); got != want {
+		t.Errorf("main cache holds %d bytes after refresh; want %d", got, want)
+	}
+	// Replacing v1 is not an eviction.
+	if n, stat := atomic.LoadInt32(&evictions), g.CacheStats(MainCache).Evictions; n != 0 || stat != 0 {
+		t.Errorf("refresh fired OnEvict %d times and counted %d evictions; want 0", n, stat)
+	}
+}
+
+func TestCost(t *testing.T) {
+	const name = "TestCost-group"
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	})
+	var calls AtomicInt
+	g := newGroupOpts(name, 250, getter, NoPeers{}, &GroupOptions{
+		Cost: func(key string, value ByteView) int64 {
+			calls.Add(1)
+			return 100
+		},
+	})
+	defer DeregisterGroup(name)
+
+	var s string
+	for _, key := range []string{"a", "b", "c"} {
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := g.CacheStats(MainCache); st.Items != 2 || st.Bytes != 200 {
+		t.Errorf("main cache holds %d items of %d bytes; want 2 of 200", st.Items, st.Bytes)
+	}
+	if n := calls.Get(); n != 3 {
+		t.Errorf("Cost called %d times for 3 entries; want 3", n)
+	}
+	for _, k := range g.Keys() {
+		if k.Size != 100 {
+			t.Errorf("Keys: %s has size %d; want its cost of 100", k.Key, k.Size)
+		}
+	}
+
+	const overheadName = "TestCost-overhead-group"
+	g = newGroupOpts(overheadName, cacheSize, getter, NoPeers{}, &GroupOptions{EntryOverhead: DefaultEntryOverhead})
+	defer DeregisterGroup(overheadName)
+	if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.CacheStats(MainCache).Bytes, int64(len("key")+len("value")+DefaultEntryOverhead); got != want {
+		t.Errorf("main cache holds %d bytes; want %d with the entry overhead", got, want)
+	}
+	g.Remove(dummyCtx, "key")
+	if got := g.CacheStats(MainCache).Bytes; got != 0 {
+		t.Errorf("main cache holds %d bytes after Remove; want 0", got)
+	}
+
+	// Negative costs count as zero, and the size limits apply to the
+	// length of entries rather than their cost.
+	const limitsName = "TestCost-limits-group"
+	g = newGroupOpts(limitsName, cacheSize, getter, NoPeers{}, &GroupOptions{
+		Cost: func(key string, value ByteView) int64 {
+			if key == "negative" {
+				return -1000
+			}
+			return 1000
+		},
+		MaxValueBytes: 100,
+	})
+	defer DeregisterGroup(limitsName)
+	for _, key := range []string{"negative", "costly"} {
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := g.CacheStats(MainCache); st.Items != 2 || st.Bytes != 1000 {
+		t.Errorf("main cache holds %d items of %d bytes; want 2 of 1000", st.Items, st.Bytes)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	var calls int32
+	unblock := make(chan bool)
+	g := newGroupOpts("TestStaleWhileRevalidate-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return dest.SetString("v1", time.Now().Add(10*time.Millisecond))
+		}
+		<-unblock
+		return dest.SetString("v2", time.Now().Add(10*time.Millisecond))
+	}), NoPeers{}, &GroupOptions{StaleWhileRevalidate: 100 * time.Millisecond})
+	defer DeregisterGroup("TestStaleWhileRevalidate-group")
+
+	get := func() string {
+		t.Helper()
+		var s string
+		if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	get()
+	time.Sleep(20 * time.Millisecond)
+	// v1 expired within the grace period: it is served without waiting
+	// for the reload blocked in the getter.
+	if got := get(); got != "v1" {
+		t.Errorf("Get of a stale value = %q; want v1", got)
+	}
+	if n := g.Snapshot().StaleHits; n != 1 {
+		t.Errorf("StaleHits = %d; want 1", n)
+	}
+	close(unblock)
+	for deadline := time.Now().Add(5 * time.Second); get() != "v2"; {
+		if time.Now().After(deadline) {
+			t.Fatal("stale value was not refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Past the grace period the value is gone and Get waits for a load.
+	time.Sleep(150 * time.Millisecond)
+	before := atomic.LoadInt32(&calls)
+	if got := get(); got != "v2" || atomic.LoadInt32(&calls) != before+1 {
+		t.Errorf("Get past the grace period = %q after %d loads; want v2 after one load", got, atomic.LoadInt32(&calls)-before)
+	}
+}
+
+func TestGetMultiSinks(t *testing.T) {
+	g := newGroup("TestGetMultiSinks-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if key == "bad" {
+			return errors.New("bad key")
+		}
+		return dest.SetString("got:"+key, time.Time{})
+	}), NoPeers{})
+
+	got := make(map[string]*string)
+	err := g.GetMultiSinks(dummyCtx, []string{"a", "b", "bad", "a"}, func(key string) Sink {
+		s := new(string)
+		got[key] = s
+		return StringSink(s)
+	})
+
+	var kerr KeysError
+	if !errors.As(err, &kerr) || len(kerr) != 1 || kerr["bad"] == nil {
+		t.Fatalf("GetMultiSinks error = %v; want a KeysError for bad only", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("created %d sinks; want 2", len(got))
+	}
+	for _, key := range []string{"a", "b"} {
+		if s := got[key]; s == nil || *s != "got:"+key {
+			t.Errorf("sink of %q not filled with %q", key, "got:"+key)
+		}
+	}
+}
+
+func TestGetReader(t *testing.T) {
+	g := newGroup("TestGetReader-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("got:"+key, time.Time{})
+	}), NoPeers{})
+	r, err := g.GetReader(dummyCtx, "key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "got:key" {
+		t.Errorf("GetReader read %q; want %q", b, "got:key")
+	}
+}
+
+func TestDefaultTTLAndSweep(t *testing.T) {
+	g := newGroupOpts("TestDefaultTTLAndSweep-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if key == "own-expiry" {
+			return dest.SetString("v", time.Now().Add(time.Hour))
+		}
+		return dest.SetString("v", time.Time{})
+	}), NoPeers{}, &GroupOptions{DefaultTTL: 20 * time.Millisecond, SweepInterval: 10 * time.Millisecond})
+	defer DeregisterGroup("TestDefaultTTLAndSweep-group")
+
+	for _, key := range []string{"default", "own-expiry"} {
+		var v ByteView
+		if err := g.Get(dummyCtx, key, ByteViewSink(&v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The sweeper drops the value using DefaultTTL without any Get.
+	deadline := time.Now().Add(5 * time.Second)
+	for g.CacheStats(MainCache).Items != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("main cache has %d items; want 1 after sweeping", g.CacheStats(MainCache).Items)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, ok := g.mainCache.get("own-expiry"); !ok {
+		t.Error("value with its own expiry was swept")
+	}
+}
+
+func TestRemoveFansOut(t *testing.T) {
+	peers := fakePeers{&fakePeer{}, &fakePeer{}, &fakePeer{}}
+	g := newGroup("TestRemoveFansOut-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return errors.New("local getter called")
+	}), peers)
+
+	const key = "key"
+	var s string
+	if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.hotCache.get(key); !ok {
+		t.Fatal("value fetched from the owner is not in the hot cache")
+	}
+	before := make([]int, len(peers))
+	for i, p := range peers {
+		before[i] = p.(*fakePeer).hits
+	}
+
+	if err := g.Remove(dummyCtx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.hotCache.get(key); ok {
+		t.Error("Remove left the value in the local hot cache")
+	}
+	// The owner and every other peer are each asked to remove the key
+	// exactly once.
+	for i, p := range peers {
+		if got := p.(*fakePeer).hits - before[i]; got != 1 {
+			t.Errorf("peer %d received %d removes; want 1", i, got)
+		}
+	}
+}
+
+func TestRemovePeersError(t *testing.T) {
+	owner := &fakePeer{url: "owner"}
+	peers := fakePeers{owner, &fakePeer{url: "ok"}, &fakePeer{url: "bad-1", fail: true}, &fakePeer{url: "bad-2", fail: true}}
+	g := newGroup("TestRemovePeersError-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return errors.New("local getter called")
+	}), ownerPicker{owner, peers})
+
+	err := g.Remove(dummyCtx, "key")
+	var perr PeersError
+	if !errors.As(err, &perr) {
+		t.Fatalf("Remove error = %v; want a PeersError", err)
+	}
+	if len(perr) != 2 || perr["bad-1"] == nil || perr["bad-2"] == nil {
+		t.Errorf("Remove reported %v; want errors of bad-1 and bad-2", perr)
+	}
+
+	owner.fail = true
+	err = g.Remove(dummyCtx, "key")
+	if !errors.As(err, &perr) || len(perr) != 1 || perr["owner"] == nil {
+		t.Errorf("Remove with a failing owner error = %v; want a PeersError of the owner", err)
+	}
+}
+
+// ownerPicker picks owner for every key.
+type ownerPicker struct {
+	owner ProtoGetter
+	all   fakePeers
+}
+
+func (p ownerPicker) PickPeer(string) (ProtoGetter, bool) { return p.owner, true }
+func (p ownerPicker) GetAll() []ProtoGetter               { return p.all }
+
+// settingPeer is a fakePeer that also implements PeerSetter.
+type settingPeer struct {
+	fakePeer
+	set map[string]ByteView
+}
+
+func (p *settingPeer) Set(_ context.Context, group, key string, value ByteView) error {
+	if p.fail {
+		return errors.New("simulated error from peer")
+	}
+	p.set[key] = value
+	return nil
+}
+
+func TestSet(t *testing.T) {
+	var loads int
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads++
+		return dest.SetString("loaded", time.Time{})
+	})
+
+	// This process owns the key.
+	local := newGroup("TestSet-local", cacheSize, getter, NoPeers{})
+	expire := time.Now().Add(time.Hour)
+	if err := local.Set(dummyCtx, "key", []byte("set"), expire, false); err != nil {
+		t.Fatal(err)
+	}
+	var v ByteView
+	if err := local.Get(dummyCtx, "key", ByteViewSink(&v)); err != nil {
+		t.Fatal(err)
+	}
+	if v.String() != "set" || !v.Expire().Equal(expire) || loads != 0 {
+		t.Errorf("Get after Set = %q expiring %v with %d loads; want %q expiring %v without loads", v, v.Expire(), loads, "set", expire)
+	}
+
+	// A remote peer owns the key.
+	owner := &settingPeer{set: make(map[string]ByteView)}
+	remote := newGroup("TestSet-remote", cacheSize, getter, fakePeers{owner})
+	if err := remote.Set(dummyCtx, "key", []byte("set"), time.Time{}, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := owner.set["key"]; got.String() != "set" {
+		t.Errorf("owner was sent %q; want %q", got, "set")
+	}
+	if got, ok := remote.hotCache.get("key"); !ok || got.String() != "set" {
+		t.Errorf("hot cache holds %q, %v; want %q", got, ok, "set")
+	}
+
+	// Peers that cannot store values are reported.
+	unsupported := newGroup("TestSet-unsupported", cacheSize, getter, fakePeers{&fakePeer{}})
+	if err := unsupported.Set(dummyCtx, "key", []byte("set"), time.Time{}, false); err == nil {
+		t.Error("Set on a peer without PeerSetter succeeded")
+	}
+
+	// Values the owner rejects are recorded as failed operations.
+	failing := &settingPeer{fakePeer: fakePeer{fail: true}, set: make(map[string]ByteView)}
+	rejected := newGroupOpts("TestSet-rejected", cacheSize, getter, fakePeers{failing}, &GroupOptions{DeadLetterSize: 1})
+	if err := rejected.Set(dummyCtx, "key", []byte("set"), time.Time{}, false); err == nil {
+		t.Error("Set rejected by the owner succeeded")
+	}
+	if ops := rejected.FailedOperations(); len(ops) != 1 || ops[0].Op != "store" || ops[0].Key != "key" || ops[0].Err == nil {
+		t.Errorf("FailedOperations after a rejected Set = %+v; want a failed store of key", ops)
+	}
+}
+
+func TestOnPeerError(t *testing.T) {
+	for _, policy := range []PeerErrorPolicy{LoadLocally, ReturnPeerError} {
+		name := fmt.Sprintf("TestOnPeerError-group-%d", policy)
+		var localLoads int
+		g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			localLoads++
+			return dest.SetString("local", time.Time{})
+		}), fakePeers{&fakePeer{fail: true}}, &GroupOptions{OnPeerError: policy})
+
+		var s string
+		err := g.Get(dummyCtx, "key", StringSink(&s))
+		switch policy {
+		case LoadLocally:
+			if err != nil || s != "local" || localLoads != 1 {
+				t.Errorf("LoadLocally: Get = %q, %v with %d local loads; want local value", s, err, localLoads)
+			}
+		case ReturnPeerError:
+			if err == nil || localLoads != 0 {
+				t.Errorf("ReturnPeerError: Get error = %v with %d local loads; want the peer's error", err, localLoads)
+			}
+		}
+		DeregisterGroup(name)
+	}
+}
+
+func TestLoadContext(t *testing.T) {
+	release := make(chan struct{})
+	var loads, aborted AtomicInt
+	g := newGroup("TestLoadContext-group", cacheSize, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		loads.Add(1)
+		select {
+		case <-release:
+			return dest.SetString("value", time.Time{})
+		case <-ctx.Done():
+			aborted.Add(1)
+			return ctx.Err()
+		}
+	}), NoPeers{})
+	defer DeregisterGroup("TestLoadContext-group")
+
+	// A caller that gives up returns at its deadline without failing
+	// the load another caller is waiting for.
+	res := make(chan string, 1)
+	go func() {
+		var s string
+		if err := g.Get(context.Background(), "shared", StringSink(&s)); err != nil {
+			s = err.Error()
+		}
+		res <- s
+	}()
+	for loads.Get() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var s string
+	if err := g.Get(ctx, "shared", StringSink(&s)); err != context.DeadlineExceeded {
+		t.Errorf("Get with deadline = %v; want context.DeadlineExceeded", err)
+	}
+	close(release)
+	if s := <-res; s != "value" {
+		t.Errorf("waiting caller got %q; want value", s)
+	}
+	if n := loads.Get(); n != 1 {
+		t.Errorf("getter called %d times; want 1", n)
+	}
+
+	// The getter is canceled once its only caller gives up.
+	release = make(chan struct{})
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := g.Get(ctx, "alone", StringSink(&s)); err != context.DeadlineExceeded {
+		t.Errorf("Get with deadline = %v; want context.DeadlineExceeded", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); aborted.Get() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("getter was not canceled")
+		}
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	const name = "TestNegativeCache-group"
+	var loads AtomicInt
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads.Add(1)
+		return fmt.Errorf("looking up %s: %w", key, ErrNotFound)
+	}), NoPeers{}, &GroupOptions{NegativeTTL: 50 * time.Millisecond})
+	defer DeregisterGroup(name)
+
+	var s string
+	for i := 0; i < 3; i++ {
+		if err := g.Get(dummyCtx, "missing", StringSink(&s)); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get error = %v; want ErrNotFound", err)
+		}
+	}
+	if n := loads.Get(); n != 1 {
+		t.Errorf("getter called %d times within NegativeTTL; want 1", n)
+	}
+	if n, s := g.Stats.NegativeHits.Get(), g.Snapshot().NegativeHits; n != 2 || s != 2 {
+		t.Errorf("NegativeHits = %d, %d in the snapshot; want 2", n, s)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	g.Get(dummyCtx, "missing", StringSink(&s))
+	if n := loads.Get(); n != 2 {
+		t.Errorf("getter called %d times after NegativeTTL; want 2", n)
+	}
+
+	if err := g.Set(dummyCtx, "missing", []byte("found"), time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Get(dummyCtx, "missing", StringSink(&s)); err != nil || s != "found" {
+		t.Errorf("Get after Set = %q, %v; want found", s, err)
+	}
+}
+
+func TestGetterRetry(t *testing.T) {
+	const name = "TestGetterRetry-group"
+	var loads AtomicInt
+	errDown := errors.New("backend down")
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads.Add(1)
+		switch {
+		case key == "bad":
+			return errors.New("bad key")
+		case key == "down" || loads.Get() < 3:
+			return Temporary(errDown)
+		}
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{}, &GroupOptions{GetterRetry: &RetryOptions{Attempts: 3, Backoff: time.Millisecond}})
+	defer DeregisterGroup(name)
+
+	var s string
+	if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil || s != "value" {
+		t.Fatalf("Get = %q, %v; want value after two retries", s, err)
+	}
+	if n := g.Snapshot().GetterRetries; n != 2 {
+		t.Errorf("GetterRetries = %d; want 2", n)
+	}
+
+	// Errors that are not temporary are returned at once.
+	loads.Store(0)
+	if err := g.Get(dummyCtx, "bad", StringSink(&s)); err == nil {
+		t.Error("Get of a bad key succeeded")
+	}
+	if n := loads.Get(); n != 1 {
+		t.Errorf("getter called %d times for a permanent error; want 1", n)
+	}
+
+	// The attempts are bounded.
+	loads.Store(0)
+	if err := g.Get(dummyCtx, "down", StringSink(&s)); !errors.Is(err, errDown) || !IsTemporary(err) {
+		t.Errorf("Get error = %v; want the temporary errDown", err)
+	}
+	if n := loads.Get(); n != 3 {
+		t.Errorf("getter called %d times; want 3", n)
+	}
+}
+
+func TestPermanentErrorTTL(t *testing.T) {
+	const name = "TestPermanentErrorTTL-group"
+	var loads AtomicInt
+	errGone := errors.New("gone")
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads.Add(1)
+		return Permanent(fmt.Errorf("loading %s: %w", key, errGone))
+	}), NoPeers{}, &GroupOptions{PermanentErrorTTL: time.Minute})
+	defer DeregisterGroup(name)
+
+	var s string
+	for i := 0; i < 3; i++ {
+		if err := g.Get(dummyCtx, "key", StringSink(&s)); !errors.Is(err, errGone) || !IsPermanent(err) {
+			t.Fatalf("Get error = %v; want the permanent errGone", err)
+		}
+	}
+	if n := loads.Get(); n != 1 {
+		t.Errorf("getter called %d times within PermanentErrorTTL; want 1", n)
+	}
+	if n := g.Snapshot().PermanentErrorHits; n != 2 {
+		t.Errorf("PermanentErrorHits = %d; want 2", n)
+	}
+
+	if err := g.Remove(dummyCtx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	g.Get(dummyCtx, "key", StringSink(&s))
+	if n := loads.Get(); n != 2 {
+		t.Errorf("getter called %d times after Remove; want 2", n)
+	}
+}
+
+func TestErrorCooldown(t *testing.T) {
+	const name = "TestErrorCooldown-group"
+	var loads AtomicInt
+	errDown := errors.New("backend down")
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads.Add(1)
+		if key == "missing" {
+			return ErrNotFound
+		}
+		return errDown
+	}), NoPeers{}, &GroupOptions{ErrorCooldown: time.Minute})
+	defer DeregisterGroup(name)
+
+	var s string
+	for i := 0; i < 3; i++ {
+		if err := g.Get(dummyCtx, "key", StringSink(&s)); !errors.Is(err, errDown) {
+			t.Fatalf("Get error = %v; want %v", err, errDown)
+		}
+	}
+	if n := loads.Get(); n != 1 {
+		t.Errorf("getter called %d times within ErrorCooldown; want 1", n)
+	}
+
+	g.GetWithOptions(dummyCtx, "key", StringSink(&s), ForceRefresh())
+	if n := loads.Get(); n != 2 {
+		t.Errorf("getter called %d times after ForceRefresh; want 2", n)
+	}
+	g.Remove(dummyCtx, "key")
+	g.Get(dummyCtx, "key", StringSink(&s))
+	if n := loads.Get(); n != 3 {
+		t.Errorf("getter called %d times after Remove; want 3", n)
+	}
+
+	// Misses are left to the negative cache.
+	g.Get(dummyCtx, "missing", StringSink(&s))
+	g.Get(dummyCtx, "missing", StringSink(&s))
+	if n := loads.Get(); n != 5 {
+		t.Errorf("getter called %d times for two Gets of a missing key; want 5 in all", n)
+	}
+}
+
+func TestNotFoundFromPeerIsFinal(t *testing.T) {
+	const name = "TestNotFoundFromPeerIsFinal-group"
+	peer := &notFoundPeer{}
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		t.Error("local getter called for a key its owner could not find")
+		return dest.SetString("local", time.Time{})
+	}), fakePeers{peer}, &GroupOptions{NegativeTTL: time.Minute})
+	defer DeregisterGroup(name)
+
+	var s string
+	for i := 0; i < 2; i++ {
+		if err := g.Get(dummyCtx, "missing", StringSink(&s)); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get error = %v; want ErrNotFound", err)
+		}
+	}
+	if peer.hits != 1 {
+		t.Errorf("owner asked %d times; want 1", peer.hits)
+	}
+	if n := g.Stats.PeerErrors.Get(); n != 0 {
+		t.Errorf("PeerErrors = %d; want 0", n)
+	}
+}
+
+// notFoundPeer answers every Get with ErrNotFound.
+type notFoundPeer struct {
+	fakePeer
+}
+
+func (p *notFoundPeer) Get(_ context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	p.hits++
+	return fmt.Errorf("Failed to GET [%s]: %w", in, ErrNotFound)
+}
+
+func TestCachePolicy(t *testing.T) {
+	const name = "TestCachePolicy-group"
+	var loads AtomicInt
+	g := newGroupOpts(name, 1000, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads.Add(1)
+		return dest.SetString(strings.Repeat("x", 90), time.Time{})
+	}), NoPeers{}, &GroupOptions{CachePolicy: policy.SLRU(nil)})
+	defer DeregisterGroup(name)
+
+	var s string
+	for i := 0; i < 3; i++ {
+		g.Get(dummyCtx, "hot", StringSink(&s))
+	}
+	for i := 0; i < 100; i++ {
+		g.Get(dummyCtx, "scan"+strconv.Itoa(i), StringSink(&s))
+	}
+	before := loads.Get()
+	g.Get(dummyCtx, "hot", StringSink(&s))
+	if loads.Get() != before {
+		t.Error("hot key was evicted by a scan")
+	}
+	if b := g.mainCache.bytes(); b > 1000 {
+		t.Errorf("main cache holds %d bytes; want at most 1000", b)
+	}
+}
+
+func TestCacheShards(t *testing.T) {
+	const name = "TestCacheShards-group"
+	g := newGroupOpts(name, 10000, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(strings.Repeat("x", 90), time.Time{})
+	}), NoPeers{}, &GroupOptions{CacheShards: 8})
+	defer DeregisterGroup(name)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				var s string
+				if err := g.Get(dummyCtx, strconv.Itoa(w*100+i), StringSink(&s)); err != nil {
+					t.Error(err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if len(g.mainCache.shards) != 8 {
+		t.Fatalf("main cache has %d shards; want 8", len(g.mainCache.shards))
+	}
+	var used int
+	for i := range g.mainCache.shards {
+		if g.mainCache.shards[i].items() > 0 {
+			used++
+		}
+	}
+	if used != 8 {
+		t.Errorf("keys were spread over %d of 8 shards", used)
+	}
+
+	stats, snap := g.CacheStats(MainCache), g.Snapshot()
+	if stats.Bytes > 10000 || stats.Bytes != snap.MainCacheBytes || stats.Items != snap.MainCacheItems {
+		t.Errorf("CacheStats = %+v, snapshot holds %d bytes in %d items; want matching totals within 10000 bytes", stats, snap.MainCacheBytes, snap.MainCacheItems)
+	}
+	if stats.Evictions == 0 || stats.Items+stats.Evictions != 800 {
+		t.Errorf("%d items cached and %d evicted; want 800 in total with evictions", stats.Items, stats.Evictions)
+	}
+}
+
+// mapTier is a TierTwo and TierTwoClearer keeping values in a map.
+type mapTier struct {
+	mu     sync.Mutex
+	values map[string]ByteView
+}
+
+func (m *mapTier) Get(_ context.Context, group, key string) ([]byte, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.values[group+"/"+key]
+	if !ok {
+		return nil, time.Time{}, ErrNotFound
+	}
+	return v.ByteSlice(), v.Expire(), nil
+}
+
+func (m *mapTier) Set(_ context.Context, group, key string, value []byte, expire time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[group+"/"+key] = ByteView{b: value, e: expire}
+	return nil
+}
+
+func (m *mapTier) Remove(_ context.Context, group, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, group+"/"+key)
+	return nil
+}
+
+func (m *mapTier) Clear(_ context.Context, group string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k := range m.values {
+		if strings.HasPrefix(k, group+"/") {
+			delete(m.values, k)
+		}
+	}
+	return nil
+}
+
+func TestTierTwo(t *testing.T) {
+	const name = "TestTierTwo-group"
+	tier := &mapTier{values: map[string]ByteView{}}
+	var loads AtomicInt
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads.Add(1)
+		return dest.SetString("value of "+key, time.Time{})
+	}), NoPeers{}, &GroupOptions{TierTwo: tier})
+	defer DeregisterGroup(name)
+
+	var s string
+	if err := g.Get(dummyCtx, "a", StringSink(&s)); err != nil || s != "value of a" {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if v, _, err := tier.Get(dummyCtx, name, "a"); err != nil || string(v) != "value of a" {
+		t.Errorf("tier holds %q, %v after a load; want the loaded value", v, err)
+	}
+
+	// Values dropped from memory are served by the tier.
+	g.mainCache.clear()
+	if err := g.Get(dummyCtx, "a", StringSink(&s)); err != nil || s != "value of a" {
+		t.Errorf("Get from the tier = %q, %v", s, err)
+	}
+	if n, h := loads.Get(), g.Snapshot().TierTwoHits; n != 1 || h != 1 {
+		t.Errorf("getter called %d times with %d tier hits; want 1, 1", n, h)
+	}
+
+	// Expired values in the tier are not served.
+	tier.Set(dummyCtx, name, "old", []byte("stale"), time.Now().Add(-time.Second))
+	if err := g.Get(dummyCtx, "old", StringSink(&s)); err != nil || s != "value of old" {
+		t.Errorf("Get of an expired tier value = %q, %v; want the getter's", s, err)
+	}
+
+	if err := g.Set(dummyCtx, "b", []byte("set"), time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, err := tier.Get(dummyCtx, name, "b"); err != nil || string(v) != "set" {
+		t.Errorf("tier holds %q, %v after Set; want set", v, err)
+	}
+	if err := g.Remove(dummyCtx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tier.Get(dummyCtx, name, "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("tier Get after Remove = %v; want ErrNotFound", err)
+	}
+
+	g.localFlush()
+	if _, _, err := tier.Get(dummyCtx, name, "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("tier Get after a flush = %v; want ErrNotFound", err)
+	}
+
+	// A group of the same name in a namespace has its own values.
+	other := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("other value of "+key, time.Time{})
+	}), NoPeers{}, &GroupOptions{TierTwo: tier, Namespace: "ns"})
+	defer DeregisterGroupInNamespace(context.Background(), "ns", name)
+	if err := other.Get(dummyCtx, "a", StringSink(&s)); err != nil || s != "other value of a" {
+		t.Fatalf("Get in the namespace = %q, %v", s, err)
+	}
+	if err := g.Get(dummyCtx, "a", StringSink(&s)); err != nil || s != "value of a" {
+		t.Errorf("Get outside the namespace = %q, %v; want the group's own value", s, err)
+	}
+	if v, _, err := tier.Get(dummyCtx, "ns\x00"+name, "a"); err != nil || string(v) != "other value of a" {
+		t.Errorf("tier holds %q, %v for the namespace; want its value", v, err)
+	}
+}
+
+// stuckTier is a mapTier whose Remove blocks until its context is done.
+type stuckTier struct {
+	mapTier
+	removing chan struct{}
+}
+
+func (m *stuckTier) Remove(ctx context.Context, group, key string) error {
+	m.removing <- struct{}{}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestTierTwoTimeout(t *testing.T) {
+	const name = "TestTierTwoTimeout-group"
+	tier := &stuckTier{mapTier: mapTier{values: map[string]ByteView{}}, removing: make(chan struct{}, 1)}
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value of "+key, time.Time{})
+	}), NoPeers{}, &GroupOptions{TierTwo: tier, TierTwoTimeout: 50 * time.Millisecond, Logger: &recordingLogger{}})
+	defer DeregisterGroup(name)
+
+	removed := make(chan error)
+	start := time.Now()
+	go func() { removed <- g.Remove(context.Background(), "a") }()
+	<-tier.removing
+
+	// Loads are not held up by a stuck TierTwo call.
+	var s string
+	if err := g.Get(context.Background(), "b", StringSink(&s)); err != nil || s != "value of b" {
+		t.Errorf("Get during a stuck TierTwo Remove = %q, %v", s, err)
+	}
+	select {
+	case <-removed:
+		if d := time.Since(start); d < 50*time.Millisecond {
+			t.Errorf("Remove returned after %v; want the TierTwoTimeout", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Remove did not return after the TierTwoTimeout")
+	}
+}
+
+// recordingLogger is a Logger keeping the messages it is sent.
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+	fields   []map[string]interface{}
+}
+
+func (l *recordingLogger) record(msg string, keyvals []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fields := make(map[string]interface{})
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fields[keyvals[i].(string)] = keyvals[i+1]
+	}
+	l.messages = append(l.messages, msg)
+	l.fields = append(l.fields, fields)
+}
+
+func (l *recordingLogger) Debug(msg string, keyvals ...interface{}) { l.record(msg, keyvals) }
+func (l *recordingLogger) Info(msg string, keyvals ...interface{})  { l.record(msg, keyvals) }
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{})  { l.record(msg, keyvals) }
+func (l *recordingLogger) Error(msg string, keyvals ...interface{}) { l.record(msg, keyvals) }
+
+func TestGroupLogger(t *testing.T) {
+	const name = "TestGroupLogger-group"
+	log := &recordingLogger{}
+	peer := &fakePeer{fail: true}
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), fakePeers{peer}, &GroupOptions{Logger: log})
+	defer DeregisterGroup(name)
+
+	var s string
+	if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.messages) != 1 {
+		t.Fatalf("logged %v; want one peer error", log.messages)
+	}
+	f := log.fields[0]
+	if f["group"] != name || f["key"] != "key" || f["peer"] != "fakePeer" || f["err"] == nil {
+		t.Errorf("peer error logged with fields %v", f)
+	}
+}
+
+func TestDeregisterGroupContext(t *testing.T) {
+	const name = "TestDeregisterGroupContext-group"
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if key == "slow" {
+			started <- struct{}{}
+			<-release
+		}
+		return dest.SetString("value", time.Time{})
+	})
+	g := newGroup(name, cacheSize, getter, NoPeers{})
+	var s string
+	if err := g.Get(dummyCtx, "fast", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		var s string
+		done <- g.Get(context.Background(), "slow", StringSink(&s))
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := DeregisterGroupContext(ctx, name); err != context.DeadlineExceeded {
+		t.Errorf("DeregisterGroupContext with a load in flight = %v; want %v", err, context.DeadlineExceeded)
+	}
+	if GroupExists(name) {
+		t.Error("group exists after DeregisterGroupContext")
+	}
+
+	// The name can be reused while the old group drains.
+	g2 := newGroup(name, cacheSize, getter, NoPeers{})
+	if !GroupExists(name) || GetGroup(name) != g2 {
+		t.Error("new group of the same name is not registered")
+	}
+	go func() {
+		var s string
+		g2.Get(context.Background(), "slow", StringSink(&s))
+	}()
+	<-started
+	drained := make(chan error)
+	go func() { drained <- DeregisterGroupContext(context.Background(), name) }()
+	select {
+	case err := <-drained:
+		t.Fatalf("DeregisterGroupContext returned %v with a load in flight", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	if err := <-drained; err != nil {
+		t.Errorf("DeregisterGroupContext = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Get in flight during DeregisterGroupContext = %v", err)
+	}
+	if n := g2.CacheStats(MainCache).Items; n != 0 {
+		t.Errorf("deregistered group caches %d items; want 0", n)
+	}
+	if err := DeregisterGroupContext(context.Background(), name); err != nil {
+		t.Errorf("DeregisterGroupContext of a missing group = %v", err)
+	}
+
+	// The TierTwo is not cleared.
+	tier := &mapTier{values: map[string]ByteView{}}
+	g3 := newGroupOpts(name, cacheSize, getter, NoPeers{}, &GroupOptions{TierTwo: tier})
+	if err := g3.Get(context.Background(), "fast", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeregisterGroupContext(context.Background(), name); err != nil {
+		t.Errorf("DeregisterGroupContext = %v", err)
+	}
+	if _, _, err := tier.Get(context.Background(), name, "fast"); err != nil {
+		t.Errorf("TierTwo Get after DeregisterGroupContext = %v; want the value kept", err)
+	}
+}
+
+func TestRemoveTombstoneTTL(t *testing.T) {
+	const name = "TestRemoveTombstoneTTL-group"
+	peer := &fakePeer{}
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		t.Error("local getter called for a key of the peer")
+		return dest.SetString("local", time.Time{})
+	}), fakePeers{peer}, &GroupOptions{RemoveTombstoneTTL: 50 * time.Millisecond})
+	defer DeregisterGroup(name)
+
+	get := func() {
+		t.Helper()
+		var s string
+		if err := g.Get(dummyCtx, "key", StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get()
+	get()
+	if peer.hits != 1 {
+		t.Fatalf("peer hits = %d; want 1 with the value in the hot cache", peer.hits)
+	}
+
+	// A copy fetched before the removal reaches the hot cache after it.
+	if err := g.Remove(dummyCtx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	g.populateCache("key", ByteView{s: "stale"}, &g.hotCache)
+	peer.hits = 0
+	get()
+	get()
+	if peer.hits != 2 || g.Stats.TombstoneHits.Get() != 1 {
+		t.Errorf("peer hits = %d, TombstoneHits = %d after Remove; want 2 and 1", peer.hits, g.Stats.TombstoneHits.Get())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	peer.hits = 0
+	get()
+	get()
+	if peer.hits != 1 {
+		t.Errorf("peer hits = %d after the tombstone expired; want 1", peer.hits)
+	}
+}
+
+// prefixRemovePeer is a fakePeer that is a PeerPrefixRemover.
+type prefixRemovePeer struct {
+	fakePeer
+	removed []string
+}
+
+func (p *prefixRemovePeer) RemovePrefix(_ context.Context, group, prefix string) error {
+	p.removed = append(p.removed, group+"/"+prefix)
+	return nil
+}
+
+func TestRemovePrefix(t *testing.T) {
+	const name = "TestRemovePrefix-group"
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if key == "user:1:missing" {
+			return ErrNotFound
+		}
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{}, &GroupOptions{NegativeTTL: time.Hour})
+	defer DeregisterGroup(name)
+	var s string
+	for _, key := range []string{"user:1:profile", "user:1:settings", "user:12:profile", "user:2:profile", "user:1:missing"} {
+		g.Get(dummyCtx, key, StringSink(&s))
+	}
+	g.localSet("user:1:hot", ByteView{s: "hot"}, &g.hotCache)
+
+	remover, plain := &prefixRemovePeer{fakePeer: fakePeer{url: "remover"}}, &fakePeer{url: "plain"}
+	g.peers = fakePeers{remover, plain}
+	err := g.RemovePrefix(dummyCtx, "user:1:")
+	if perr, ok := err.(PeersError); !ok || len(perr) != 1 || perr["plain"] == nil {
+		t.Errorf("RemovePrefix returned %v; want an error for the plain peer only", err)
+	}
+	if len(remover.removed) != 1 || remover.removed[0] != name+"/user:1:" {
+		t.Errorf("peer removed %v; want [%s/user:1:]", remover.removed, name)
+	}
+	for key, want := range map[string]bool{
+		"user:1:profile":  false,
+		"user:1:settings": false,
+		"user:1:hot":      false,
+		"user:12:profile": true,
+		"user:2:profile":  true,
+	} {
+		if _, ok := g.Peek(key); ok != want {
+			t.Errorf("%s cached = %v; want %v", key, ok, want)
+		}
+	}
+	if g.misses.has("user:1:missing") {
+		t.Error("missing key of the prefix still remembered")
+	}
+}
+
+// hedgePicker sends every key to owner, hedged to next after delay.
+type hedgePicker struct {
+	owner, next ProtoGetter
+	delay       time.Duration
+}
+
+func (p hedgePicker) PickPeer(string) (ProtoGetter, bool)       { return p.owner, true }
+func (p hedgePicker) GetAll() []ProtoGetter                     { return []ProtoGetter{p.owner, p.next} }
+func (p hedgePicker) PickFailover(string, int) []ProtoGetter    { return []ProtoGetter{p.next} }
+func (p hedgePicker) FailoverHops() int                         { return 1 }
+func (p hedgePicker) HedgeDelay(peer ProtoGetter) time.Duration { return p.delay }
+func (p hedgePicker) PickHedge(string) (ProtoGetter, bool)      { return p.next, true }
+
+// pausedPeer is a fakePeer answering Gets after a delay, or when the
+// Get is canceled.
+type pausedPeer struct {
+	fakePeer
+	delay    time.Duration
+	canceled chan struct{}
+}
+
+func (p *pausedPeer) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	select {
+	case <-time.After(p.delay):
+		return p.fakePeer.Get(ctx, in, out)
+	case <-ctx.Done():
+		close(p.canceled)
+		return ctx.Err()
+	}
+}
+
+func TestHedgedLoad(t *testing.T) {
+	const name = "TestHedgedLoad-group"
+	slow := &pausedPeer{fakePeer: fakePeer{url: "slow"}, delay: time.Minute, canceled: make(chan struct{})}
+	fast := &fakePeer{url: "fast"}
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		t.Error("local getter called for a key of a peer")
+		return dest.SetString("local", time.Time{})
+	}), hedgePicker{owner: slow, next: fast, delay: 10 * time.Millisecond})
+	defer DeregisterGroup(name)
+
+	var s string
+	if err := g.Get(context.Background(), "key", StringSink(&s)); err != nil || s != "got:key" {
+		t.Fatalf("Get = %q, %v; want got:key", s, err)
+	}
+	if g.Stats.HedgedLoads.Get() != 1 || g.Stats.HedgeWins.Get() != 1 || fast.hits != 1 {
+		t.Errorf("HedgedLoads = %d, HedgeWins = %d, hedge peer hits = %d; want 1 each",
+			g.Stats.HedgedLoads.Get(), g.Stats.HedgeWins.Get(), fast.hits)
+	}
+	select {
+	case <-slow.canceled:
+	case <-time.After(5 * time.Second):
+		t.Error("Get to the slow peer was not canceled")
+	}
+
+	// A peer answering in time is not hedged.
+	slow.delay = 0
+	if err := g.Get(context.Background(), "other", StringSink(&s)); err != nil || s != "got:other" {
+		t.Fatalf("Get = %q, %v; want got:other", s, err)
+	}
+	if g.Stats.HedgedLoads.Get() != 1 || fast.hits != 1 {
+		t.Errorf("HedgedLoads = %d, hedge peer hits = %d after a fast answer; want 1", g.Stats.HedgedLoads.Get(), fast.hits)
+	}
+}
+
+func TestMaxConcurrentLoads(t *testing.T) {
+	const name = "TestMaxConcurrentLoads-group"
+	var running, most int32
+	started := make(chan struct{}, 10)
+	unblock := make(chan struct{})
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for m := atomic.LoadInt32(&most); n > m && !atomic.CompareAndSwapInt32(&most, m, n); m = atomic.LoadInt32(&most) {
+		}
+		started <- struct{}{}
+		<-unblock
+		return dest.SetString("value", time.Time{})
+	})
+	g := newGroupOpts(name, cacheSize, getter, NoPeers{}, &GroupOptions{MaxConcurrentLoads: 2, LoadQueueTimeout: 20 * time.Millisecond})
+	defer DeregisterGroup(name)
+	// Without LoadQueueTimeout loads wait for a slot as long as needed.
+	q := newGroupOpts(name+"-queue", cacheSize, getter, NoPeers{}, &GroupOptions{MaxConcurrentLoads: 1})
+	defer DeregisterGroup(name + "-queue")
+
+	errs := make(chan error, 3)
+	for i := 0; i < 2; i++ {
+		go func(i int) {
+			var s string
+			errs <- g.Get(dummyCtx, strconv.Itoa(i), StringSink(&s))
+		}(i)
+		<-started
+	}
+	// Both slots are taken: the next load waits, then gives up.
+	var s string
+	if err := g.Get(dummyCtx, "late", StringSink(&s)); err != ErrTooManyLoads {
+		t.Errorf("Get with all slots taken = %v; want %v", err, ErrTooManyLoads)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := g.Get(ctx, "canceled", StringSink(&s)); err != context.Canceled {
+		t.Errorf("Get with a canceled ctx = %v; want %v", err, context.Canceled)
+	}
+
+	// A queued load runs once a slot frees up.
+	go func() {
+		var s string
+		errs <- q.Get(context.Background(), "first", StringSink(&s))
+	}()
+	<-started
+	queued := make(chan error, 1)
+	go func() {
+		var s string
+		queued <- q.Get(context.Background(), "queued", StringSink(&s))
+	}()
+	for q.Stats.LoadsQueued.Get() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(unblock)
+	for i := 0; i < 3; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if err := <-queued; err != nil {
+		t.Errorf("queued Get = %v", err)
+	}
+	if g.Stats.LoadsRejected.Get() != 1 || q.Stats.LoadsRejected.Get() != 0 {
+		t.Errorf("%d and %d loads rejected; want 1 and 0", g.Stats.LoadsRejected.Get(), q.Stats.LoadsRejected.Get())
+	}
+	if most != 3 {
+		t.Errorf("%d loads ran at once; want 3, 2 of the group and 1 of the queue group", most)
+	}
+}
+
+// funcHolder is a Getter of a comparable type whose values need not be.
+type funcHolder struct{ f interface{} }
+
+func (funcHolder) Get(_ context.Context, key string, dest Sink) error {
+	return dest.SetString("value", time.Time{})
+}
+
+func TestGetOrCreateGroup(t *testing.T) {
+	const name = "TestGetOrCreateGroup-group"
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	})
+	g, err := GetOrCreateGroup(name, cacheSize, getter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DeregisterGroup(name)
+	if g2, err := GetOrCreateGroup(name, cacheSize, getter); err != nil || g2 != g {
+		t.Errorf("second GetOrCreateGroup = %p, %v; want the group created first", g2, err)
+	}
+	if _, err := GetOrCreateGroup(name, cacheSize/2, getter); !errors.Is(err, ErrGroupExists) {
+		t.Errorf("GetOrCreateGroup with another size = %v; want %v", err, ErrGroupExists)
+	}
+	other := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("other", time.Time{})
+	})
+	if _, err := GetOrCreateGroup(name, cacheSize, other); !errors.Is(err, ErrGroupExists) {
+		t.Errorf("GetOrCreateGroup with another getter = %v; want %v", err, ErrGroupExists)
+	}
+	if _, err := NewGroupE(name, cacheSize, getter); !errors.Is(err, ErrGroupExists) {
+		t.Errorf("NewGroupE of a registered name = %v; want %v", err, ErrGroupExists)
+	}
+
+	// Closures of one factory may capture different backends.
+	backend := func(db string) Getter {
+		return GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			return dest.SetString(db, time.Time{})
+		})
+	}
+	const factoryName = "TestGetOrCreateGroup-factory"
+	if _, err := GetOrCreateGroup(factoryName, cacheSize, backend("db-A")); err != nil {
+		t.Fatal(err)
+	}
+	defer DeregisterGroup(factoryName)
+	if _, err := GetOrCreateGroup(factoryName, cacheSize, backend("db-B")); !errors.Is(err, ErrGroupExists) {
+		t.Errorf("GetOrCreateGroup with a closure of another backend = %v; want %v", err, ErrGroupExists)
+	}
+
+	// A comparable getter type may hold a function in an interface.
+	const structName = "TestGetOrCreateGroup-struct"
+	if _, err := GetOrCreateGroup(structName, cacheSize, funcHolder{f: func() {}}); err != nil {
+		t.Fatal(err)
+	}
+	defer DeregisterGroup(structName)
+	if _, err := GetOrCreateGroup(structName, cacheSize, funcHolder{f: func() {}}); !errors.Is(err, ErrGroupExists) {
+		t.Errorf("GetOrCreateGroup with an incomparable getter = %v; want %v", err, ErrGroupExists)
+	}
+	if g1, err := GetOrCreateGroup(structName+"-n", cacheSize, funcHolder{f: 1}); err != nil {
+		t.Fatal(err)
+	} else if g2, err := GetOrCreateGroup(structName+"-n", cacheSize, funcHolder{f: 1}); err != nil || g2 != g1 {
+		t.Errorf("GetOrCreateGroup with an equal getter = %p, %v; want the group created first", g2, err)
+	}
+	DeregisterGroup(structName + "-n")
+	if _, err := NewGroupE("TestGetOrCreateGroup-nil", cacheSize, nil); err == nil {
+		t.Error("NewGroupE with a nil getter succeeded")
+	}
+}
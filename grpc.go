@@ -16,28 +16,700 @@
 package groupcache
 
 import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/adistroy/groupcache/v3/consistenthash"
 	"github.com/adistroy/groupcache/v3/gcgrpc"
 	pb "github.com/adistroy/groupcache/v3/groupcachepb"
-	log "github.com/sirupsen/logrus"
+	"github.com/adistroy/groupcache/v3/lru"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
+var (
+	// ErrGroupNotFound is returned when a peer has no group registered
+	// under the requested name. Peers answer with codes.NotFound.
+	ErrGroupNotFound = errors.New("groupcache: group not found")
+
+	// ErrKeyLoadFailed is returned when a peer found the group but failed
+	// to load the requested key. Peers answer with codes.Internal.
+	ErrKeyLoadFailed = errors.New("groupcache: key load failed")
+)
+
+// NotOwnerError is returned when an owner-sensitive request was sent to
+// a peer that does not own the key. Owner is the address of the peer
+// the request should be sent to instead.
+type NotOwnerError struct {
+	Owner string
+}
+
+func (e *NotOwnerError) Error() string {
+	return fmt.Sprintf("groupcache: key is owned by peer [%s]", e.Owner)
+}
+
 type GRPCPool struct {
 	self        string
 	opts        GRPCPoolOptions
 	mu          sync.Mutex
 	peers       *consistenthash.Map
 	grpcGetters map[string]*grpcGetter
+	lookups     *lru.Cache          // key -> owner; nil unless LookupCacheSize is set
+	serverSlots *serverSlots        // nil unless MaxServerConcurrency is set
+	limiter     *rateLimiter        // nil unless ServerRateLimit is set
+	groupIDs    groupTable          // IDs handed out by ResolveGroup
+	ejected     map[string]bool     // peers taken off the ring by health checks
+	weights     map[string]int      // weights of the peers given in their specs
+	zones       map[string]string   // zones of the peers given in their specs, if any
+	replicas    map[string]int      // virtual nodes of the peers given in their specs, if any
+	picks       map[string]int64    // requests routed to each owner, nil unless RingTuning is set
+	reported    *consistenthash.Map // ring last reported to OnTopologyChange
+	topology    topologyNotifier
+	gossip      *gossipState            // nil unless Gossip is set
+	leaseRing   *consistenthash.Map     // ring as of the last OwnershipLease change
+	leases      []ownershipLease        // changes within the last OwnershipLease
+	transferred map[transferredKey]bool // received by TransferKeys since the last lease change
+
+	versionMu sync.Mutex // held while a versioned peer change is applied
+	version   uint64     // topology version of the last versioned change
+
+	// Stats are statistics on the pool's peer connections.
+	Stats GRPCPoolStats
 }
 
 type GRPCPoolOptions struct {
 	Replicas        int
 	HashFn          consistenthash.Hash
 	PeerDialOptions []grpc.DialOption
+
+	// IdleTimeout closes the connection to a peer once no RPC has been
+	// made to it for this long. The connection is dialed again on the
+	// next request to that peer.
+	// If zero, connections are kept open until the peer is removed.
+	IdleTimeout time.Duration
+
+	// ConnsPerPeer is the largest number of connections kept to each
+	// peer. Each RPC is sent on the connection with the fewest RPCs in
+	// flight, so a chatty pair of peers is not limited to one TCP
+	// connection, where a slow or large response delays every other
+	// stream. Connections are dialed as needed, see MaxStreamsPerConn.
+	// If blank, it defaults to 1.
+	ConnsPerPeer int
+
+	// MaxStreamsPerConn is the number of RPCs in flight on every
+	// connection to a peer before another one is dialed, up to
+	// ConnsPerPeer. Match it to the MaxConcurrentStreams server option
+	// of the peers so RPCs do not queue for a stream on a busy
+	// connection while another has room.
+	// If zero, all ConnsPerPeer connections are dialed as soon as they
+	// are used.
+	MaxStreamsPerConn int
+
+	// Keepalive optionally sends HTTP/2 pings on idle connections to
+	// peers, so connections broken without a TCP reset are detected
+	// before the next request times out on them. The grpc.Server of the
+	// pool must allow pings that often, with grpc.KeepaliveEnforcementPolicy,
+	// or it closes the connection; ServerOptions and Serve do.
+	// If nil, gRPC's defaults are used, which send no pings.
+	Keepalive *keepalive.ClientParameters
+
+	// ServerKeepalive optionally makes the server of the pool, through
+	// ServerOptions and Serve, ping idle connections from peers and
+	// close connections idle or open for longer than it allows.
+	// If nil, gRPC's defaults are used, which ping after two hours
+	// without activity and never close a connection for its age.
+	ServerKeepalive *keepalive.ServerParameters
+
+	// MaxRecvMsgSize and MaxSendMsgSize are the largest messages, in
+	// bytes, the pool receives from and sends to peers, as a client
+	// and, through ServerOptions and Serve, as a server. Raise both on
+	// every peer together to cache values above gRPC's limit without
+	// StreamValues.
+	// If zero, gRPC's defaults are used: 4MB received, unlimited sent.
+	MaxRecvMsgSize, MaxSendMsgSize int
+
+	// RPCTimeout bounds every RPC sent to peers, including the whole of
+	// a RetrieveStream, whose context has no earlier deadline, so a
+	// caller passing context.Background() does not wait forever on a
+	// stuck peer. The TransferKeys stream of Drain, which lasts as long
+	// as the keys take to send, is only bounded by the context of
+	// Drain. Retry.Timeout further bounds each attempt.
+	// If zero, RPCs are only bounded by their context.
+	RPCTimeout time.Duration
+
+	// CircuitBreaker optionally stops sending Retrieve requests to a
+	// peer that keeps failing them. While the breaker is open Get
+	// fails fast with ErrCircuitOpen, which makes the group try the
+	// failover peers, see FailoverHops, and then load the key locally,
+	// rather than wait for the peer to time out on every Get. Open
+	// breakers are listed by PeerStats.
+	// If nil, requests are always sent.
+	CircuitBreaker *CircuitBreakerOptions
+
+	// Retry optionally sends a Retrieve request that failed with a
+	// transient error, such as codes.Unavailable or an attempt timing
+	// out, to the peer again before Get gives up on it. The group then
+	// tries the failover peers, see FailoverHops, and finally handles
+	// the error as set by GroupOptions.OnPeerError.
+	// If nil, requests are sent once.
+	Retry *RetryOptions
+
+	// HealthCheck optionally pings every peer periodically and ejects
+	// peers that stop answering from the hash ring, so their keys are
+	// owned by the remaining peers until they recover and are added
+	// back. Health checks count as use of a connection, so IdleTimeout
+	// never closes the connection to a health checked peer.
+	// If nil, peers stay on the ring until they are removed.
+	HealthCheck *HealthCheckOptions
+
+	// FailoverHops is the number of peers following a key's owner on the
+	// hash ring that are tried, in order, when the owner fails before
+	// the key is loaded locally. It can be overridden per call with
+	// WithFailoverHops.
+	// If zero, keys are loaded locally as soon as the owner fails.
+	FailoverHops int
+
+	// CoalesceFailover makes the failover peers of a key its fallback
+	// owners: the first of them that answers loads the key with its
+	// getter rather than asking the owner again, deduplicating the
+	// loads of every peer that failed to reach the owner. While an
+	// owner is down, each of its keys is then loaded from the origin
+	// once for the cluster instead of once per peer. A peer reaching
+	// neither the owner nor a failover peer, as during a partition,
+	// handles the error as set by GroupOptions.OnPeerError.
+	// If FailoverHops is zero, it is raised to 1.
+	CoalesceFailover bool
+
+	// Hedge sends a Get that the peer it was sent to has not answered
+	// in time to the first failover peer of the key as well, and uses
+	// whichever answers first, cutting the tail latency of Gets while
+	// a peer pauses, at the cost of more RPCs. It is most useful with
+	// a ReplicationFactor above one, so the failover peer holds a
+	// replica. The failover peer loads the key as its fallback owner
+	// if it has no copy, rather than forward it to the slow owner.
+	// If nil, Gets wait for the peer they were sent to.
+	Hedge *HedgeOptions
+
+	// RingTuning counts the requests the pool routes to the owner of
+	// each key, so RunRingTuning can adjust the virtual nodes of the
+	// peers to even out their shares.
+	// If nil, every peer has Replicas virtual nodes times its weight.
+	RingTuning *RingTuningOptions
+
+	// OwnershipLease is how long this peer waits, after a change of the
+	// peers gives it keys, before caching the values it loads for them:
+	// meanwhile it loads them on every Get without caching them, while
+	// their previous owner may still serve its copies to the peers yet
+	// to learn of the change, so the two do not cache conflicting
+	// versions, and does not read them from a TierTwo either. The main
+	// cache values of the keys a change moves to or from this peer are
+	// also removed, except those handed to it by a draining peer, so a
+	// peer never serves a copy from when it last owned a key. The first
+	// peers given to the pool start no lease. Set it to about the time a
+	// change takes to reach every peer.
+	// If zero, keys are cached as soon as they are owned.
+	OwnershipLease time.Duration
+
+	// ContextDialer optionally specifies how connections to peers are
+	// made; it is passed to grpc.WithContextDialer in addition to
+	// PeerDialOptions. Peer addresses are still used as given for
+	// hashing. This allows in-memory transports such as bufconn.
+	// If nil, peers are dialed over TCP.
+	ContextDialer func(context.Context, string) (net.Conn, error)
+
+	// Resolvers resolve the peer addresses of their scheme, such as
+	// "consul:///cache", in addition to the resolvers registered with
+	// gRPC, which include dns and unix. They are passed to
+	// grpc.WithResolvers in addition to PeerDialOptions.
+	Resolvers []resolver.Builder
+
+	// UnaryClientInterceptors are chained, in order, on every peer
+	// connection in addition to PeerDialOptions. Use them for auth,
+	// tracing or metrics on outgoing peer RPCs.
+	//
+	// Server side interceptors must be installed on the *grpc.Server
+	// passed to NewGRPCPoolOptions when it is created, for example with
+	// grpc.NewServer(grpc.ChainUnaryInterceptor(auth, tracing)).
+	UnaryClientInterceptors []grpc.UnaryClientInterceptor
+
+	// MaxBatchKeys is the largest number of keys sent to a peer in a
+	// single RetrieveMulti request by Group.GetMulti. Larger key sets
+	// are split into several batches. The default keeps a batch of
+	// values of up to about 16KB each well below gRPC's default 4MB
+	// message size limit.
+	// If blank, it defaults to 100.
+	MaxBatchKeys int
+
+	// BatchParallelism is the number of RetrieveMulti batches that may
+	// be in flight to a single peer at once.
+	// If blank, it defaults to 4.
+	BatchParallelism int
+
+	// ServerBatchParallelism is the number of keys of a single inbound
+	// RetrieveMulti request this peer loads at once, so a large batch
+	// cannot start an unbounded number of loads.
+	// If blank, it defaults to 16.
+	ServerBatchParallelism int
+
+	// LookupCacheSize is the number of key to owner lookups PickPeer
+	// remembers, saving a walk of the hash ring for hot keys.
+	//
+	// The lookup cache is flushed whenever the pool's peers change, so
+	// cached lookups always agree with the pool's own hash ring. It does
+	// not make peers agree with each other: every pool routes keys with
+	// its own ring, which changes only when its own peer list is
+	// updated, with Set, UpdatePeers or the peer list RPCs. Changes are
+	// also announced to the other peers with an asynchronous, best
+	// effort InvalidateLookups RPC, which flushes their lookup caches
+	// but leaves their peer lists alone.
+	// If zero, lookups are not cached.
+	LookupCacheSize int
+
+	// MaxServerConcurrency is the number of inbound Retrieve,
+	// RetrieveMulti and Delete requests this peer serves at once.
+	// Requests arriving while all slots are taken wait in the queue set
+	// by MaxServerQueue, or are rejected with codes.ResourceExhausted
+	// once it is full; the calling peer then loads the key itself.
+	// Waiting requests are served in order of Priority, and an
+	// interactive request arriving while the queue is full takes the
+	// place of the bulk request queued last, see WithPriority.
+	// If zero, inbound requests are not limited.
+	MaxServerConcurrency int
+
+	// MaxServerQueue is the number of inbound requests that wait for a
+	// MaxServerConcurrency slot, until their deadline, when all slots
+	// are taken. Requests arriving while the queue is full are
+	// rejected.
+	// If zero, requests are rejected as soon as all slots are taken.
+	// It is ignored unless MaxServerConcurrency is set.
+	MaxServerQueue int
+
+	// ServerRateLimit is the number of inbound requests per second,
+	// on average, this peer serves, counting the same requests as
+	// MaxServerConcurrency. Requests above the rate are rejected with
+	// codes.ResourceExhausted.
+	// If zero, the rate is not limited.
+	ServerRateLimit float64
+
+	// ServerRateBurst is the number of requests allowed at once above
+	// ServerRateLimit.
+	// If blank, it defaults to ServerRateLimit, rounded up.
+	ServerRateBurst int
+
+	// InternGroupNames makes Retrieve requests identify the group by a
+	// short numeric ID instead of its name. The ID is negotiated once
+	// per peer and group with a ResolveGroup RPC, see gcgrpc.proto for
+	// the handshake. This saves bytes on every request for high-QPS
+	// traffic of small values, at the cost of one extra round trip the
+	// first time a group is fetched from a peer.
+	// Peers that do not support ResolveGroup are sent group names.
+	InternGroupNames bool
+
+	// ReplicationFactor is the number of peers holding each value
+	// loaded by its owner, or Set on it: the owner plus the
+	// ReplicationFactor-1 peers that follow it on the hash ring, which
+	// are sent the value with a Store RPC and keep it in their hot
+	// cache. Gets fail over to the
+	// replicas, in ring order, when the owner does not answer, so
+	// FailoverHops is raised to at least ReplicationFactor-1.
+	// Group.Remove already clears a key from every peer, replicas
+	// included.
+	// If zero or one, values are only held by their owner.
+	ReplicationFactor int
+
+	// Zone is the zone of this process, such as its availability zone,
+	// which must be the zone given to it in the peer specs of every
+	// pool, as in "10.0.0.1:8080?zone=us-east-1a". While peers have
+	// zones, the replicas of a key are picked in ring order from the
+	// zones holding none yet, so a ReplicationFactor of the number of
+	// zones puts a copy in each. With Zone set, Gets of keys owned in
+	// another zone are sent to their replica in Zone, which serves
+	// them from its hot cache, so values cross zones once rather than
+	// on every Get, cutting the cost of cross-zone traffic.
+	// If blank, Gets are sent to the owners of keys.
+	Zone string
+
+	// TLS optionally encrypts, and with client certificates mutually
+	// authenticates, the connections to peers. The grpc.Server of the
+	// pool must be created with TLS.ServerOption() to match.
+	// If nil, peers are dialed with PeerDialOptions, which default to
+	// an insecure connection.
+	TLS *PeerTLS
+
+	// Auth optionally sends a shared token with every RPC to peers. The
+	// grpc.Server of the pool must be created with Auth.ServerOptions()
+	// to require it from other peers.
+	// If nil, RPCs carry no token.
+	Auth *PeerAuth
+
+	// StreamValues makes Get fetch values from peers with the
+	// RetrieveStream RPC, which sends them in chunks, instead of
+	// Retrieve. This allows values larger than the maximum gRPC message
+	// size, 4MB by default, to be transferred. It does not bound memory
+	// use: the owner loads and the caller receives the whole value
+	// before it is cached, so values must still fit in memory on both
+	// sides.
+	// Peers that do not support RetrieveStream are sent Retrieve.
+	StreamValues bool
+
+	// LoadBound enables consistent hashing with bounded loads for the
+	// Gets sent to peers: a key whose owner already has more than
+	// 1+LoadBound times its share of this pool's requests in flight,
+	// by weight, is fetched from the next peer on the ring that has
+	// not, which loads it as its fallback owner rather than forward it
+	// to the owner. This spreads a burst on a few hot keys at the cost of
+	// caching them on more peers. Loads are counted per pool, and the
+	// current peer never counts as loaded. Set, Store and the owner
+	// checks of other RPCs still use the plain ring.
+	// If zero, Gets always go to the owner.
+	LoadBound float64
+
+	// DeadlineAware makes a Get load a key locally rather than send it
+	// to a peer whose average Retrieve latency is longer than the time
+	// left until the deadline of the Get's context, so slow peers, such
+	// as those in another region, do not make latency sensitive callers
+	// miss their deadlines. The average is only updated by the Gets
+	// sent to the peer, so callers with looser or no deadlines keep it
+	// current, and a Get is sent to a skipped peer anyway once every
+	// DeadlineProbeInterval. Gets without a deadline always go to the
+	// peer.
+	// If false, Gets go to the peer however slow it is.
+	DeadlineAware bool
+
+	// DeadlineProbeInterval is how long DeadlineAware skips a peer
+	// whose latency was not measured meanwhile before sending it a Get
+	// anyway, so a peer that became fast again is used again.
+	// If blank, it defaults to 10 seconds.
+	DeadlineProbeInterval time.Duration
+
+	// Logger receives the log messages of the pool.
+	// If nil, the Logger set with SetLogger is used.
+	Logger Logger
+
+	// OnTopologyChange is called after the hash ring of the pool
+	// changed, by Set, the peer list RPCs or health checks, with the
+	// ranges of keys that changed owner. Use it to correlate deploys
+	// with drops of the hit rate or to warm the keys that moved to the
+	// current peer. It is called in order from a goroutine of its own.
+	// If nil, changes are not reported.
+	OnTopologyChange func(TopologyChange)
+
+	// Namespace is sent with the requests of the pool, so its peers
+	// serve them with the groups created with the same
+	// GroupOptions.Namespace. Several logical clusters, such as
+	// tenants or environments, can share processes without their group
+	// names colliding: give each a scoped pool with its own namespace
+	// and peers, whose requests may all be served by one pool of the
+	// process, since the namespace of a request is that of its sender.
+	// If blank, requests are for the groups without a namespace.
+	Namespace string
+
+	// Gossip makes the pool keep a membership of peers that it
+	// gossips with the other peers, so a peer added to or removed from
+	// one pool with the peer list RPCs, Set or UpdatePeers reaches all
+	// of them. Call RunGossip to gossip.
+	// If nil, the peer list of each pool is only changed locally.
+	Gossip *GossipOptions
+
+	// Compression compresses values sent to and received from peers
+	// with Retrieve and RetrieveStream, negotiated per request with
+	// the groupcache-accept-encoding metadata. Values are compressed
+	// only if the asking peer accepts a compressor this peer has.
+	// If nil, values are neither compressed nor asked for compressed.
+	Compression *CompressionOptions
+
+	// ForwardMetadata lists the gRPC metadata keys, such as a tenant
+	// id, an auth principal or tracing headers, that reach the getter
+	// of the peer owning a key, so origin loads can be authorized and
+	// attributed to the original caller. Getters read them with
+	// metadata.FromIncomingContext. Gets send the listed keys of the
+	// incoming metadata of their ctx, when made while serving an RPC,
+	// along with its outgoing metadata, which is always sent. On the
+	// owner the getter's ctx carries only the listed keys of the
+	// incoming metadata. Keys are case insensitive.
+	//
+	// Loads and cached values are still shared by key alone: the Gets
+	// of a key made while it loads receive the value loaded with the
+	// metadata of the first, and the cached value is then served to
+	// every caller whatever its metadata. Metadata can thus attribute
+	// loads, but not restrict values to a principal; include the tenant
+	// or principal in the key, or use a group per tenant, for that.
+	// If nil, the getter's ctx carries all the incoming metadata of the
+	// Retrieve RPC, and Gets send only their outgoing metadata.
+	ForwardMetadata []string
+}
+
+// HedgeOptions configures GRPCPoolOptions.Hedge.
+type HedgeOptions struct {
+	// Delay is how long Gets wait for the peer they were sent to
+	// before they are hedged.
+	// If blank, it is twice the average latency of the Retrieve RPCs
+	// to the peer, see PeerStats.Latency, a rough estimate of its tail
+	// latency, and Gets are not hedged until the average is known.
+	Delay time.Duration
+}
+
+const (
+	defaultMaxBatchKeys     = 100
+	defaultBatchParallelism = 4
+
+	defaultServerBatchParallelism = 16
+
+	defaultDeadlineProbeInterval = 10 * time.Second
+)
+
+// dialOptions returns the options used to dial peer.
+func (o *GRPCPoolOptions) dialOptions(peer string) []grpc.DialOption {
+	opts := append([]grpc.DialOption(nil), o.PeerDialOptions...)
+	if o.TLS != nil {
+		opts = append(opts, o.TLS.dialOption(peer))
+	}
+	if o.Auth != nil {
+		opts = append(opts, o.Auth.dialOption())
+	}
+	if o.ContextDialer != nil {
+		opts = append(opts, grpc.WithContextDialer(o.ContextDialer))
+	}
+	if len(o.Resolvers) != 0 {
+		opts = append(opts, grpc.WithResolvers(o.Resolvers...))
+	}
+	if len(o.UnaryClientInterceptors) != 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(o.UnaryClientInterceptors...))
+	}
+	if o.Keepalive != nil {
+		opts = append(opts, grpc.WithKeepaliveParams(*o.Keepalive))
+	}
+	var callOpts []grpc.CallOption
+	if o.MaxRecvMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(o.MaxRecvMsgSize))
+	}
+	if o.MaxSendMsgSize > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(o.MaxSendMsgSize))
+	}
+	if len(callOpts) != 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	if o.RPCTimeout > 0 {
+		opts = append(opts,
+			grpc.WithChainUnaryInterceptor(unaryTimeout(o.RPCTimeout)),
+			grpc.WithChainStreamInterceptor(streamTimeout(o.RPCTimeout)))
+	}
+	return opts
+}
+
+// ServerOptions returns the options to pass to grpc.NewServer so the
+// server of a pool created with o matches it: those of TLS and Auth,
+// the message size limits, ServerKeepalive and a keepalive enforcement
+// policy allowing the pings of Keepalive. Serve uses them.
+func (o *GRPCPoolOptions) ServerOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if o == nil {
+		return opts
+	}
+	if o.TLS != nil {
+		opts = append(opts, o.TLS.ServerOption())
+	}
+	if o.Auth != nil {
+		opts = append(opts, o.Auth.ServerOptions()...)
+	}
+	if o.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(o.MaxRecvMsgSize))
+	}
+	if o.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(o.MaxSendMsgSize))
+	}
+	if o.Keepalive != nil {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             o.Keepalive.Time,
+			PermitWithoutStream: o.Keepalive.PermitWithoutStream,
+		}))
+	}
+	if o.ServerKeepalive != nil {
+		opts = append(opts, grpc.KeepaliveParams(*o.ServerKeepalive))
+	}
+	return opts
+}
+
+// withRPCTimeout bounds ctx by d unless its deadline is earlier.
+func withRPCTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= d {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// unaryTimeout is the interceptor of GRPCPoolOptions.RPCTimeout for
+// unary RPCs.
+func unaryTimeout(d time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, cancel := withRPCTimeout(ctx, d)
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// streamTimeout is the interceptor of GRPCPoolOptions.RPCTimeout for
+// streaming RPCs. Client streams, such as TransferKeys, are not bounded.
+func streamTimeout(d time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if desc.ClientStreams {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		ctx, cancel := withRPCTimeout(ctx, d)
+		s, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &timeoutStream{ClientStream: s, cancel: cancel, single: !desc.ServerStreams}, nil
+	}
+}
+
+// timeoutStream releases the timer of its RPCTimeout once the stream
+// ends: after an error, or after the only response if single.
+type timeoutStream struct {
+	grpc.ClientStream
+	cancel context.CancelFunc
+	single bool
+}
+
+func (s *timeoutStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || s.single {
+		s.cancel()
+	}
+	return err
+}
+
+// GRPCPoolStats are per-pool statistics.
+type GRPCPoolStats struct {
+	IdleCloses AtomicInt // closes of the connections to a peer after IdleTimeout
+	Redials    AtomicInt // connections dialed again after an idle close
+
+	LookupInvalidations AtomicInt // lookup cache flushes, local or broadcast
+	ServerRejections    AtomicInt // inbound requests rejected by MaxServerConcurrency or ServerRateLimit
+	ServerQueued        AtomicInt // inbound requests that waited in the MaxServerQueue queue
+	ServerDisplaced     AtomicInt // bulk requests rejected from a full queue for an interactive one
+	PeerEjections       AtomicInt // peers ejected from the ring by health checks
+	PeerRestorations    AtomicInt // ejected peers added back to the ring
+	TransferredKeys     AtomicInt // entries handed off to other peers by Drain
+	ReceivedKeys        AtomicInt // entries handed off by other peers and kept
+	DeadlineSkips       AtomicInt // Gets loaded locally because of DeadlineAware
+	GossipRounds        AtomicInt // Gossip RPCs sent to peers
+	GossipUpdates       AtomicInt // membership changes learned from gossip
+	StaleTopologies     AtomicInt // peer list RPCs ignored for an old topology version
+	RingTunings         AtomicInt // adjustments of virtual nodes by RunRingTuning
+	ZonePicks           AtomicInt // Gets sent to a replica in Zone rather than to the owner
+	CircuitTrips        AtomicInt // times the CircuitBreaker of a peer opened
+	LeaseInvalidations  AtomicInt // cached values removed as their key changed owner, see OwnershipLease
+
+	// RPC statistics on the connections to peers. They are not
+	// collected if PeerDialOptions installs its own grpc.StatsHandler.
+	RPCs          AtomicInt // RPCs sent to peers
+	RPCErrors     AtomicInt // RPCs sent to peers that failed
+	RPCNanos      AtomicInt // total duration of RPCs sent to peers
+	BytesSent     AtomicInt // message bytes sent to peers, as on the wire
+	BytesReceived AtomicInt // message bytes received from peers, as on the wire
+}
+
+// statsHandler is a grpc stats.Handler updating the RPC statistics of
+// a pool and of the peer it is connected to.
+type statsHandler struct {
+	stats *GRPCPoolStats
+	peer  *grpcGetter
+}
+
+func (h statsHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h statsHandler) HandleRPC(_ context.Context, s stats.RPCStats) {
+	switch s := s.(type) {
+	case *stats.OutPayload:
+		h.stats.BytesSent.Add(int64(s.WireLength))
+	case *stats.InPayload:
+		h.stats.BytesReceived.Add(int64(s.WireLength))
+	case *stats.End:
+		h.stats.RPCs.Add(1)
+		h.peer.rpcs.Add(1)
+		h.stats.RPCNanos.Add(int64(s.EndTime.Sub(s.BeginTime)))
+		if s.Error != nil {
+			h.stats.RPCErrors.Add(1)
+			h.peer.rpcErrors.Add(1)
+		}
+	}
+}
+
+func (h statsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h statsHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+// PeerStats are statistics on the RPCs a pool sent to one of its peers.
+type PeerStats struct {
+	Peer      string
+	RPCs      int64 // RPCs sent to the peer
+	RPCErrors int64 // RPCs sent to the peer that failed
+	Inflight  int   // requests to the peer in progress
+	Ejected   bool  // taken off the ring by health checks
+
+	// CircuitOpen is set while the CircuitBreaker of the peer is open
+	// or probing it, failing Gets to it fast.
+	CircuitOpen bool
+
+	// Latency is the moving average of the Retrieve RPCs to the peer, as
+	// used by DeadlineAware.
+	Latency time.Duration
+}
+
+// PeerStats returns the statistics of every peer of the pool, ordered
+// by address. They cover the connections of the
+// current peer set only: a peer that is removed and added again starts
+// from zero.
+func (gp *GRPCPool) PeerStats() []PeerStats {
+	gp.mu.Lock()
+	getters := make([]*grpcGetter, 0, len(gp.grpcGetters))
+	for _, getter := range gp.grpcGetters {
+		getters = append(getters, getter)
+	}
+	ejected := make(map[string]bool, len(gp.ejected))
+	for peer := range gp.ejected {
+		ejected[peer] = true
+	}
+	gp.mu.Unlock()
+
+	res := make([]PeerStats, len(getters))
+	for i, getter := range getters {
+		res[i] = PeerStats{
+			Peer:      getter.address,
+			RPCs:      getter.rpcs.Get(),
+			RPCErrors: getter.rpcErrors.Get(),
+			Inflight:  getter.load(),
+			Ejected:   ejected[getter.address],
+			Latency:   getter.latency.get(),
+
+			CircuitOpen: getter.breaker.open(),
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Peer < res[j].Peer })
+	return res
 }
 
 func NewGRPCPool(self string, server *grpc.Server) *GRPCPool {
@@ -46,6 +718,11 @@ func NewGRPCPool(self string, server *grpc.Server) *GRPCPool {
 
 var grpcPoolCreated bool
 
+// NewGRPCPoolOptions creates a pool, registers it on server and
+// registers it as the PeerPicker of every group that is not given its
+// own GroupOptions.Peers. It must be called only once per process; use
+// NewScopedGRPCPool to run several pools. If server is nil the pool is
+// not registered, for a pool served with ListenAndServe.
 func NewGRPCPoolOptions(self string, server *grpc.Server, opts *GRPCPoolOptions) *GRPCPool {
 	if grpcPoolCreated {
 		panic("NewGRPCPool must be called only once")
@@ -53,9 +730,45 @@ func NewGRPCPoolOptions(self string, server *grpc.Server, opts *GRPCPoolOptions)
 
 	grpcPoolCreated = true
 
+	pool := newGRPCPool(self, opts)
+	RegisterPeerPicker(func() PeerPicker { return pool })
+	if server != nil {
+		gcgrpc.RegisterPeerServer(server, pool)
+	}
+	return pool
+}
+
+// NewScopedGRPCPool creates a pool and registers it on server without
+// making it the default PeerPicker. It is only used by the groups given
+// it as GroupOptions.Peers, so it may be called any number of times to
+// run independent clusters in one process, each on its own server.
+// Group names are global within a namespace: groups served by
+// different pools must be named differently unless the pools have
+// different GRPCPoolOptions.Namespace. If server is nil the pool is
+// not registered, for a pool of a namespace whose requests are served
+// by another pool of the process.
+func NewScopedGRPCPool(self string, server *grpc.Server, opts *GRPCPoolOptions) *GRPCPool {
+	pool := newGRPCPool(self, opts)
+	if server != nil {
+		gcgrpc.RegisterPeerServer(server, pool)
+	}
+	return pool
+}
+
+// newGRPCPool creates a pool without registering it as the PeerPicker
+// or as a gRPC service.
+func newGRPCPool(self string, opts *GRPCPoolOptions) *GRPCPool {
+	if s, err := ParsePeerSpec(self); err == nil {
+		// Compare against the peer addresses as Set writes them.
+		self = s.Address()
+	}
 	pool := &GRPCPool{
 		self:        self,
 		grpcGetters: make(map[string]*grpcGetter),
+		ejected:     make(map[string]bool),
+		weights:     make(map[string]int),
+		zones:       make(map[string]string),
+		replicas:    make(map[string]int),
 	}
 
 	if opts != nil {
@@ -66,33 +779,106 @@ func NewGRPCPoolOptions(self string, server *grpc.Server, opts *GRPCPoolOptions)
 		pool.opts.Replicas = defaultReplicas
 	}
 
-	if pool.opts.PeerDialOptions == nil {
+	if pool.opts.PeerDialOptions == nil && pool.opts.TLS == nil {
 		pool.opts.PeerDialOptions = []grpc.DialOption{grpc.WithInsecure()}
 	}
 
+	pool.opts.ForwardMetadata = forwardedMetadata(pool.opts.ForwardMetadata)
+
+	if pool.opts.MaxBatchKeys <= 0 {
+		pool.opts.MaxBatchKeys = defaultMaxBatchKeys
+	}
+
+	if pool.opts.BatchParallelism <= 0 {
+		pool.opts.BatchParallelism = defaultBatchParallelism
+	}
+
+	if pool.opts.ServerBatchParallelism <= 0 {
+		pool.opts.ServerBatchParallelism = defaultServerBatchParallelism
+	}
+
+	if pool.opts.HashFn != nil {
+		if err := consistenthash.ValidateHash(pool.opts.HashFn); err != nil {
+			pool.log().Warn("GRPCPoolOptions.HashFn looks degenerate", "err", err)
+		}
+	}
+
+	if pool.opts.CoalesceFailover && pool.opts.FailoverHops < 1 {
+		pool.opts.FailoverHops = 1
+	}
+	if pool.opts.ReplicationFactor > 1 && pool.opts.FailoverHops < pool.opts.ReplicationFactor-1 {
+		pool.opts.FailoverHops = pool.opts.ReplicationFactor - 1
+	}
+
+	if pool.opts.MaxServerConcurrency > 0 {
+		pool.serverSlots = newServerSlots(pool.opts.MaxServerConcurrency, pool.opts.MaxServerQueue, &pool.Stats)
+	}
+	if pool.opts.ServerRateLimit > 0 {
+		pool.limiter = newRateLimiter(pool.opts.ServerRateLimit, pool.opts.ServerRateBurst)
+	}
+
+	if pool.opts.LookupCacheSize > 0 {
+		pool.lookups = lru.New(pool.opts.LookupCacheSize)
+	}
+
 	pool.peers = consistenthash.New(pool.opts.Replicas, pool.opts.HashFn)
-	RegisterPeerPicker(func() PeerPicker { return pool })
-	gcgrpc.RegisterPeerServer(server, pool)
+	pool.reported = pool.peers.Clone()
+	pool.leaseRing = pool.peers.Clone()
+	if pool.opts.RingTuning != nil {
+		pool.picks = make(map[string]int64)
+	}
+	pool.topology.fn = pool.opts.OnTopologyChange
+	if pool.opts.Gossip != nil {
+		pool.gossip = newGossipState(self, pool.opts.Zone, pool.log)
+	}
 	return pool
 }
 
+// Set replaces the pool's peers. Each peer is given by its address,
+// optionally followed by a weight as in "10.0.0.1:8080?weight=3", which
+// makes it own about three times as many keys as a peer of weight one.
+// Addresses may also be IPv6 literals, DNS names, unix sockets or
+// targets of custom resolvers, see PeerSpec.
+// With Gossip it changes the membership as UpdatePeers does, so the
+// connections to peers that stay are kept.
 func (gp *GRPCPool) Set(peers ...string) {
+	if gp.gossip != nil {
+		gp.UpdatePeers(peers)
+		return
+	}
 	gp.mu.Lock()
 	defer gp.mu.Unlock()
 	gp.peers = consistenthash.New(gp.opts.Replicas, gp.opts.HashFn)
+	gp.weights = make(map[string]int, len(peers))
+	gp.zones = make(map[string]string)
+	gp.replicas = make(map[string]int)
 	tempGetters := make(map[string]*grpcGetter, len(peers))
-	for _, peer := range peers {
+	for _, spec := range peers {
+		s, err := ParsePeerSpec(spec)
+		if err != nil {
+			gp.log().Warn("Ignoring peer", "peer", spec, "err", err)
+			continue
+		}
+		peer := s.Address()
 		if getter, exists := gp.grpcGetters[peer]; exists == true {
 			tempGetters[peer] = getter
-			gp.peers.Add(peer)
+			gp.weights[peer] = s.Weight
+			gp.setZone(peer, s.Zone)
+			gp.setReplicas(peer, s.Replicas)
+			if !gp.ejected[peer] {
+				gp.addToRing(peer)
+			}
 			delete(gp.grpcGetters, peer)
 		} else {
-			getter, err := newGRPCGetter(peer, gp.opts.PeerDialOptions...)
+			getter, err := gp.newGetter(peer)
 			if err != nil {
-				log.WithError(err).Warnf("Failed to open connection to [%s]", peer)
+				gp.log().Warn("Failed to open connection", "peer", peer, "err", err)
 			} else {
 				tempGetters[peer] = getter
-				gp.peers.Add(peer)
+				gp.weights[peer] = s.Weight
+				gp.setZone(peer, s.Zone)
+				gp.setReplicas(peer, s.Replicas)
+				gp.addToRing(peer)
 			}
 		}
 	}
@@ -100,9 +886,84 @@ func (gp *GRPCPool) Set(peers ...string) {
 	for p, g := range gp.grpcGetters {
 		g.close()
 		delete(gp.grpcGetters, p)
+		delete(gp.ejected, p)
 	}
 
 	gp.grpcGetters = tempGetters
+	gp.peersChanged()
+}
+
+// peerAddr returns the address of a peer spec, ignoring its weight.
+func peerAddr(spec string) string {
+	if i := strings.IndexByte(spec, '?'); i >= 0 {
+		return spec[:i]
+	}
+	return spec
+}
+
+func (gp *GRPCPool) log() Logger {
+	return pickLogger(gp.opts.Logger)
+}
+
+// setZone records the zone of peer and reports whether it changed.
+// gp.mu must be held.
+func (gp *GRPCPool) setZone(peer, zone string) bool {
+	if gp.zones[peer] == zone {
+		return false
+	}
+	if zone == "" {
+		delete(gp.zones, peer)
+	} else {
+		gp.zones[peer] = zone
+	}
+	return true
+}
+
+// setReplicas records the virtual nodes given to peer in its spec, zero
+// for the default, and reports whether they changed. gp.mu must be
+// held.
+func (gp *GRPCPool) setReplicas(peer string, replicas int) bool {
+	if gp.replicas[peer] == replicas {
+		return false
+	}
+	if replicas == 0 {
+		delete(gp.replicas, peer)
+	} else {
+		gp.replicas[peer] = replicas
+	}
+	return true
+}
+
+// addToRing adds peer to the hash ring with the weight and virtual
+// nodes of its spec. gp.mu must be held.
+func (gp *GRPCPool) addToRing(peer string) {
+	gp.peers.AddWeighted(gp.weights[peer], peer)
+	if n, ok := gp.replicas[peer]; ok {
+		gp.peers.SetReplicas(peer, n)
+	}
+}
+
+// parsePeer splits a peer spec, see PeerSpec, into the address, weight
+// and zone of the peer.
+func parsePeer(spec string) (string, int, string, error) {
+	s, err := ParsePeerSpec(spec)
+	if err != nil {
+		return "", 0, "", err
+	}
+	return s.Address(), s.Weight, s.Zone, nil
+}
+
+// newGetter connects to peer and starts its health checks. gp.mu must
+// be held.
+func (gp *GRPCPool) newGetter(peer string) (*grpcGetter, error) {
+	getter, err := newGRPCGetter(peer, &gp.opts, &gp.Stats)
+	if err != nil {
+		return nil, err
+	}
+	if gp.opts.HealthCheck != nil && peer != gp.self {
+		go gp.checkHealth(getter)
+	}
+	return getter, nil
 }
 
 // GetAll returns all the peers in the pool
@@ -127,106 +988,1570 @@ func (gp *GRPCPool) PickPeer(key string) (ProtoGetter, bool) {
 		return nil, false
 	}
 
-	if peer := gp.peers.Get(key); peer != gp.self {
+	peer := gp.lookup(key)
+	gp.countPick(peer)
+	peer = gp.zonePeer(key, peer)
+	if peer != gp.self {
 		return gp.grpcGetters[peer], true
 	}
 	return nil, false
 }
 
-func (gp *GRPCPool) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
-	group := GetGroup(req.Group)
-	if group == nil {
-		//log.Warnf("Unable to find group [%s]", req.Group)
-		return nil, fmt.Errorf("Unable to find group [%s]", req.Group)
+// zonePeer returns the replica of key in Zone to send Gets to instead
+// of owner, or owner. A replica asks the owner itself, as it already
+// looked in its hot cache. gp.mu must be held.
+func (gp *GRPCPool) zonePeer(key, owner string) string {
+	if gp.opts.Zone == "" || gp.opts.ReplicationFactor <= 1 || owner == gp.self || gp.zones[owner] == gp.opts.Zone {
+		return owner
 	}
-	group.Stats.ServerRequests.Add(1)
-	var value []byte
-	err := group.Get(ctx, req.Key, AllocatingByteSliceSink(&value))
-	if err != nil {
-		//log.WithError(err).Warnf("Failed to retrieve [%s]", req)
-		return nil, fmt.Errorf("Failed to retrieve [%s]: %v", req, err)
+	replicas := gp.replicaPeers(key, gp.opts.ReplicationFactor)
+	for _, peer := range replicas {
+		if peer == gp.self {
+			return owner
+		}
 	}
-	return &gcgrpc.RetrieveResponse{Value: value}, nil
-}
-
-func (gp *GRPCPool) Delete(ctx context.Context, req *gcgrpc.DeleteRequest) (*gcgrpc.Ack, error) {
-	group := GetGroup(req.Group)
-	if group == nil {
-		//log.Warnf("Unable to find group [%s]", req.Group)
-		return nil, fmt.Errorf("Unable to find group [%s]", req.Group)
+	for _, peer := range replicas[1:] {
+		if _, ok := gp.grpcGetters[peer]; ok && gp.zones[peer] == gp.opts.Zone {
+			gp.Stats.ZonePicks.Add(1)
+			return peer
+		}
 	}
-	group.Stats.ServerRequests.Add(1)
-	group.localRemove(req.Key)
-	return &gcgrpc.Ack{}, nil
+	return owner
 }
 
-func (gp *GRPCPool) AddPeers(ctx context.Context, peers *gcgrpc.Peers) (*gcgrpc.Ack, error) {
-	gp.mu.Lock()
-	defer gp.mu.Unlock()
-	for _, peer := range peers.PeerAddr {
-		if _, exists := gp.grpcGetters[peer]; exists != true {
-			getter, err := newGRPCGetter(peer, gp.opts.PeerDialOptions...)
-			if err != nil {
-				log.WithError(err).Warnf("Failed to open connection to [%s]", peer)
-			} else {
-				log.Infof("Adding peer [%s]", peer)
-				gp.grpcGetters[peer] = getter
-				gp.peers.Add(peer)
-			}
+// replicaPeers returns the owner of key followed by the peers holding
+// its replicas, n in all unless there are fewer peers. While peers
+// have zones, the peers of zones holding no copy yet come first, in
+// ring order. gp.mu must be held.
+func (gp *GRPCPool) replicaPeers(key string, n int) []string {
+	if len(gp.zones) == 0 {
+		return gp.peers.GetN(key, n)
+	}
+	all := gp.peers.GetN(key, len(gp.weights))
+	if len(all) <= n {
+		return all
+	}
+	res := make([]string, 0, n)
+	var rest []string
+	zones := make(map[string]bool)
+	for _, peer := range all {
+		if len(res) == n {
+			break
+		}
+		if zone := gp.zones[peer]; !zones[zone] {
+			zones[zone] = true
+			res = append(res, peer)
+		} else {
+			rest = append(rest, peer)
 		}
 	}
-	return &gcgrpc.Ack{}, nil
+	for i := 0; len(res) < n; i++ {
+		res = append(res, rest[i])
+	}
+	return res
 }
 
-func (gp *GRPCPool) RemovePeers(ctx context.Context, peers *gcgrpc.Peers) (*gcgrpc.Ack, error) {
+// PickPeerForLoad implements LoadPicker. Unless LoadBound is set it
+// returns the owner of key, like PickPeer.
+func (gp *GRPCPool) PickPeerForLoad(key string) (ProtoGetter, bool) {
+	if gp.opts.LoadBound <= 0 {
+		return gp.PickPeer(key)
+	}
 	gp.mu.Lock()
 	defer gp.mu.Unlock()
-	for _, peer := range peers.PeerAddr {
-		if p, exists := gp.grpcGetters[peer]; exists == true {
-			log.Infof("Removing peer [%s]", peer)
-			p.close()
-			delete(gp.grpcGetters, peer)
-		}
+
+	if gp.peers.IsEmpty() {
+		return nil, false
 	}
-	return &gcgrpc.Ack{}, nil
-}
 
-func (gp *GRPCPool) SetPeers(ctx context.Context, peers *gcgrpc.Peers) (*gcgrpc.Ack, error) {
-	gp.Set(peers.PeerAddr...)
-	return &gcgrpc.Ack{}, nil
+	peer := gp.peers.GetBounded(key, gp.opts.LoadBound, func(peer string) int {
+		if getter, ok := gp.grpcGetters[peer]; ok && peer != gp.self {
+			return getter.load()
+		}
+		return 0
+	})
+	gp.countPick(peer)
+	if peer == gp.self {
+		return nil, false
+	}
+	if peer != gp.peers.Get(key) {
+		// Another peer would forward the Get back to the owner.
+		return fallbackGetter{gp.grpcGetters[peer]}, true
+	}
+	return gp.grpcGetters[peer], true
 }
 
-type grpcGetter struct {
-	address string
-	conn    *grpc.ClientConn
+// PickPeerContext implements DeadlinePicker: it returns the peer of
+// PickPeerForLoad unless DeadlineAware is set and the peer is not
+// expected to answer before the deadline of ctx.
+func (gp *GRPCPool) PickPeerContext(ctx context.Context, key string) (ProtoGetter, bool) {
+	peer, ok := gp.PickPeerForLoad(key)
+	if !ok || !gp.opts.DeadlineAware || ctx == nil {
+		return peer, ok
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return peer, true
+	}
+	getter, isGRPC := grpcGetterOf(peer)
+	if !isGRPC || getter.latency.get() <= time.Until(deadline) {
+		return peer, true
+	}
+	every := gp.opts.DeadlineProbeInterval
+	if every <= 0 {
+		every = defaultDeadlineProbeInterval
+	}
+	if getter.latency.probe(every) {
+		return peer, true
+	}
+	gp.Stats.DeadlineSkips.Add(1)
+	return nil, false
 }
 
-func newGRPCGetter(address string, dialOpts ...grpc.DialOption) (*grpcGetter, error) {
-	conn, err := grpc.Dial(address, dialOpts...)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to connect to [%s]: %v", address, err)
+// lookup returns the owner of key, consulting the lookup cache first.
+// gp.mu must be held.
+func (gp *GRPCPool) lookup(key string) string {
+	if gp.lookups == nil {
+		return gp.peers.Get(key)
 	}
-	return &grpcGetter{address: address, conn: conn}, nil
+	if peer, ok := gp.lookups.Get(key); ok {
+		return peer.(string)
+	}
+	peer := gp.peers.Get(key)
+	gp.lookups.Add(key, peer, time.Time{})
+	return peer
 }
 
-func (g *grpcGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
-	client := gcgrpc.NewPeerClient(g.conn)
-	resp, err := client.Retrieve(ctx, &gcgrpc.RetrieveRequest{Group: *in.Group, Key: *in.Key})
-	if err != nil {
-		return fmt.Errorf("Failed to GET [%s]: %v", in, err)
+// peersChanged reports the change to OnTopologyChange, flushes the
+// lookup cache and asks every other peer to flush theirs. gp.mu must be
+// held.
+func (gp *GRPCPool) peersChanged() {
+	gp.topologyChanged()
+	gp.changeLeases()
+	if gp.lookups == nil {
+		return
 	}
+	gp.lookups.Clear()
+	gp.Stats.LookupInvalidations.Add(1)
 
-	out.Value = resp.Value
-	return nil
+	var getters []*grpcGetter
+	for peer, getter := range gp.grpcGetters {
+		if peer != gp.self {
+			getters = append(getters, getter)
+		}
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), invalidateTimeout)
+		defer cancel()
+		for _, getter := range getters {
+			if err := getter.invalidateLookups(ctx); err != nil {
+				gp.log().Debug("Failed to invalidate lookups", "peer", getter.address, "err", err)
+			}
+		}
+	}()
 }
 
-func (g *grpcGetter) Remove(ctx context.Context, in *pb.GetRequest) error {
-	client := gcgrpc.NewPeerClient(g.conn)
-	_, err := client.Delete(ctx, &gcgrpc.DeleteRequest{Group: *in.Group, Key: *in.Key})
-	if err != nil {
-		return fmt.Errorf("Failed to REMOVE [%s]: %v", in, err)
+// invalidateTimeout bounds the lookup invalidation broadcast.
+const invalidateTimeout = 5 * time.Second
+
+// PickFailover implements FailoverPicker.
+func (gp *GRPCPool) PickFailover(key string, hops int) []ProtoGetter {
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+
+	var res []ProtoGetter
+	owners := gp.replicaPeers(key, hops+1)
+	for i := 1; i < len(owners); i++ {
+		if owners[i] == gp.self {
+			break
+		}
+		getter, ok := gp.grpcGetters[owners[i]]
+		if !ok {
+			continue
+		}
+		if gp.opts.CoalesceFailover {
+			res = append(res, fallbackGetter{getter})
+		} else {
+			res = append(res, getter)
+		}
+	}
+	return res
+}
+
+// fallbackGetter asks a peer other than the owner to load keys itself
+// as their fallback owner rather than forward them to the owner, see
+// GRPCPoolOptions.CoalesceFailover and LoadBound. It is not a
+// MultiGetter, as RetrieveMulti has no fallback, so the keys of a
+// GetMulti sent to it are sent one by one.
+type fallbackGetter struct {
+	getter *grpcGetter
+}
+
+func (g fallbackGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	return g.getter.get(ctx, in, out, true)
+}
+
+func (g fallbackGetter) Remove(ctx context.Context, in *pb.GetRequest) error {
+	return g.getter.Remove(ctx, in)
+}
+
+func (g fallbackGetter) GetURL() string {
+	return g.getter.GetURL()
+}
+
+// grpcGetterOf returns the grpcGetter of peer, which may be wrapped.
+func grpcGetterOf(peer ProtoGetter) (*grpcGetter, bool) {
+	switch p := peer.(type) {
+	case *grpcGetter:
+		return p, true
+	case fallbackGetter:
+		return p.getter, true
+	}
+	return nil, false
+}
+
+// HedgeDelay implements HedgePicker.
+func (gp *GRPCPool) HedgeDelay(peer ProtoGetter) time.Duration {
+	if gp.opts.Hedge == nil {
+		return 0
+	}
+	if gp.opts.Hedge.Delay > 0 {
+		return gp.opts.Hedge.Delay
+	}
+	if getter, ok := grpcGetterOf(peer); ok {
+		return 2 * getter.latency.get()
+	}
+	return 0
+}
+
+// PickHedge implements HedgePicker: the hedged Get goes to the first
+// failover peer of key, as its fallback owner so it does not add to
+// the load of the slow owner.
+func (gp *GRPCPool) PickHedge(key string) (ProtoGetter, bool) {
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+	owners := gp.replicaPeers(key, 2)
+	if len(owners) < 2 || owners[1] == gp.self {
+		return nil, false
+	}
+	getter, ok := gp.grpcGetters[owners[1]]
+	if !ok {
+		return nil, false
+	}
+	return fallbackGetter{getter}, true
+}
+
+// FailoverHops implements FailoverPicker.
+func (gp *GRPCPool) FailoverHops() int {
+	return gp.opts.FailoverHops
+}
+
+// acquire takes a MaxServerConcurrency slot for an inbound request of
+// the priority of ctx. The returned func releases it and must be
+// deferred, so the slot is freed even if the handler panics.
+func (gp *GRPCPool) acquire(ctx context.Context) (func(), error) {
+	if gp.limiter != nil && !gp.limiter.allow(time.Now()) {
+		gp.Stats.ServerRejections.Add(1)
+		return nil, status.Errorf(codes.ResourceExhausted, "Too many requests (limit %g/s)", gp.opts.ServerRateLimit)
+	}
+	if gp.serverSlots == nil {
+		return func() {}, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	p, _ := priorityFromContext(ctx)
+	switch err := gp.serverSlots.acquire(ctx, p); {
+	case err == errNoSlot:
+		gp.Stats.ServerRejections.Add(1)
+		return nil, status.Errorf(codes.ResourceExhausted, "Too many concurrent requests (limit %d)", gp.opts.MaxServerConcurrency)
+	case err != nil:
+		return nil, status.FromContextError(err).Err()
+	}
+	return gp.serverSlots.release, nil
+}
+
+func (gp *GRPCPool) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	ctx = incomingPriority(extractTrace(ctx))
+	release, err := gp.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	group, err := gp.retrieveGroup(req)
+	if err != nil {
+		return nil, err
+	}
+	group.Stats.ServerRequests.Add(1)
+	if req.Fallback {
+		ctx = withFallbackLoad(ctx)
+	}
+	var value ByteView
+	start := time.Now()
+	err = group.getWithOptions(gp.getterContext(ctx), string(req.Key), ByteViewSink(&value), retrieveOptions(req)...)
+	group.Stats.ServerLatency.Observe(time.Since(start))
+	if err != nil {
+		return nil, retrieveError(req, err)
+	}
+	encoding, data := gp.opts.Compression.compress(acceptEncoding(ctx), value.readOnlyBytes())
+	return &gcgrpc.RetrieveResponse{Value: data, Expire: unixNano(value.Expire()), Encoding: encoding}, nil
+}
+
+// acceptEncodingKey is the metadata key listing the compressors a
+// caller accepts.
+const acceptEncodingKey = "groupcache-accept-encoding"
+
+// acceptEncoding returns the compressors accepted by the caller of an
+// inbound RPC.
+func acceptEncoding(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(acceptEncodingKey); len(v) != 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// retrieveOptions returns the GetOptions a RetrieveRequest asks for.
+func retrieveOptions(req *gcgrpc.RetrieveRequest) []GetOption {
+	var opts []GetOption
+	if req.ForceRefresh {
+		opts = append(opts, ForceRefresh())
+	}
+	if req.NoStore {
+		opts = append(opts, NoStore())
+	}
+	if req.HasMinFreshness {
+		opts = append(opts, MinFreshness(time.Duration(req.MinFreshness)))
+	}
+	return opts
+}
+
+// retrieveError converts the error of loading the key of req to a
+// status error.
+func retrieveError(req *gcgrpc.RetrieveRequest, err error) error {
+	if errors.Is(err, ErrNotFound) {
+		st, derr := status.New(codes.NotFound, fmt.Sprintf("Key [%s] not found", req.Key)).
+			WithDetails(&gcgrpc.KeyNotFound{})
+		if derr == nil {
+			return st.Err()
+		}
+	}
+	return status.Errorf(codes.Internal, "Failed to retrieve [%s]: %v", req, err)
+}
+
+// unixNano returns t as Unix nanoseconds, or zero for the zero time.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// retrieveGroup returns the group a RetrieveRequest is for, named or
+// identified by an ID from ResolveGroup.
+func (gp *GRPCPool) retrieveGroup(req *gcgrpc.RetrieveRequest) (*Group, error) {
+	if req.GroupId != 0 {
+		key, ok := gp.groupIDs.name(req.GroupId, req.GroupEpoch)
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "Unknown group id [%d] of epoch [%x]", req.GroupId, req.GroupEpoch)
+		}
+		mu.RLock()
+		group := groups[key]
+		mu.RUnlock()
+		if group == nil {
+			return nil, status.Errorf(codes.NotFound, "Unable to find group of id [%d]", req.GroupId)
+		}
+		return group, nil
+	}
+	group := GetGroupInNamespace(req.Namespace, req.Group)
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "Unable to find group [%s]", req.Group)
+	}
+	return group, nil
+}
+
+// streamChunkSize is the size of the chunks sent by RetrieveStream.
+const streamChunkSize = 1 << 20
+
+// RetrieveStream is like Retrieve but sends the value in chunks.
+func (gp *GRPCPool) RetrieveStream(req *gcgrpc.RetrieveRequest, stream gcgrpc.Peer_RetrieveStreamServer) error {
+	ctx := incomingPriority(extractTrace(stream.Context()))
+	release, err := gp.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	group, err := gp.retrieveGroup(req)
+	if err != nil {
+		return err
+	}
+	group.Stats.ServerRequests.Add(1)
+	if req.Fallback {
+		ctx = withFallbackLoad(ctx)
+	}
+	var value ByteView
+	start := time.Now()
+	err = group.getWithOptions(gp.getterContext(ctx), string(req.Key), ByteViewSink(&value), retrieveOptions(req)...)
+	group.Stats.ServerLatency.Observe(time.Since(start))
+	if err != nil {
+		return retrieveError(req, err)
+	}
+
+	encoding, data := gp.opts.Compression.compress(acceptEncoding(ctx), value.readOnlyBytes())
+	chunk := &gcgrpc.RetrieveChunk{Size: int64(len(data)), Expire: unixNano(value.Expire()), Encoding: encoding}
+	for off := 0; off < len(data) || off == 0; off += streamChunkSize {
+		end := off + streamChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk.Data = data[off:end]
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+		chunk.Size, chunk.Expire, chunk.Encoding = 0, 0, ""
+	}
+	return nil
+}
+
+func (gp *GRPCPool) RetrieveMulti(ctx context.Context, req *gcgrpc.RetrieveMultiRequest) (*gcgrpc.RetrieveMultiResponse, error) {
+	ctx = incomingPriority(ctx)
+	release, err := gp.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	group := GetGroupInNamespace(req.Namespace, req.Group)
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "Unable to find group [%s]", req.Group)
+	}
+	group.Stats.ServerRequests.Add(1)
+
+	ctx = gp.getterContext(ctx)
+	res := &gcgrpc.RetrieveMultiResponse{Values: make([]*gcgrpc.KeyValue, len(req.Keys))}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, gp.opts.ServerBatchParallelism)
+	for i, key := range req.Keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, key []byte) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			kv := &gcgrpc.KeyValue{Key: key}
+			var value ByteView
+			start := time.Now()
+			err := group.get(ctx, string(key), ByteViewSink(&value))
+			group.Stats.ServerLatency.Observe(time.Since(start))
+			if err != nil {
+				kv.Error = err.Error()
+			} else {
+				kv.Value = value.readOnlyBytes()
+				if !value.Expire().IsZero() {
+					kv.Expire = value.Expire().UnixNano()
+				}
+			}
+			res.Values[i] = kv
+		}(i, key)
+	}
+	wg.Wait()
+	return res, nil
+}
+
+func (gp *GRPCPool) Delete(ctx context.Context, req *gcgrpc.DeleteRequest) (*gcgrpc.Ack, error) {
+	release, err := gp.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	group := GetGroupInNamespace(req.Namespace, req.Group)
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "Unable to find group [%s]", req.Group)
+	}
+	group.Stats.ServerRequests.Add(1)
+	group.localRemove(string(req.Key))
+	return &gcgrpc.Ack{}, nil
+}
+
+func (gp *GRPCPool) DeletePrefix(ctx context.Context, req *gcgrpc.DeletePrefixRequest) (*gcgrpc.Ack, error) {
+	group := GetGroupInNamespace(req.Namespace, req.Group)
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "Unable to find group [%s]", req.Group)
+	}
+	group.Stats.ServerRequests.Add(1)
+	group.localRemovePrefix(string(req.Prefix))
+	return &gcgrpc.Ack{}, nil
+}
+
+func (gp *GRPCPool) Flush(ctx context.Context, req *gcgrpc.FlushRequest) (*gcgrpc.Ack, error) {
+	group := GetGroupInNamespace(req.Namespace, req.Group)
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "Unable to find group [%s]", req.Group)
+	}
+	group.Stats.ServerRequests.Add(1)
+	group.localFlush()
+	return &gcgrpc.Ack{}, nil
+}
+
+func (gp *GRPCPool) BumpGeneration(ctx context.Context, req *gcgrpc.BumpGenerationRequest) (*gcgrpc.Ack, error) {
+	group := GetGroupInNamespace(req.Namespace, req.Group)
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "Unable to find group [%s]", req.Group)
+	}
+	group.Stats.ServerRequests.Add(1)
+	group.localBumpGeneration()
+	return &gcgrpc.Ack{}, nil
+}
+
+// Store caches the given value in the group's main cache. The request
+// must be sent to the owner of the key; any other peer rejects it with
+// codes.FailedPrecondition and a NotOwner detail naming the owner.
+func (gp *GRPCPool) Store(ctx context.Context, req *gcgrpc.StoreRequest) (*gcgrpc.Ack, error) {
+	group := GetGroupInNamespace(req.Namespace, req.Group)
+	if group == nil {
+		return nil, status.Errorf(codes.NotFound, "Unable to find group [%s]", req.Group)
+	}
+	group.Stats.ServerRequests.Add(1)
+
+	key := string(req.Key)
+	var expire time.Time
+	if req.Expire != 0 {
+		expire = time.Unix(0, req.Expire)
+	}
+	if req.Replica {
+		group.localSet(key, ByteView{b: req.Value, e: expire}, &group.hotCache)
+		return &gcgrpc.Ack{}, nil
+	}
+
+	gp.mu.Lock()
+	owner := gp.peers.Get(key)
+	gp.mu.Unlock()
+	if owner != "" && owner != gp.self {
+		st, err := status.New(codes.FailedPrecondition, fmt.Sprintf("Key [%s] is owned by [%s]", key, owner)).
+			WithDetails(&gcgrpc.NotOwner{Owner: owner})
+		if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "Key [%s] is owned by [%s]", key, owner)
+		}
+		return nil, st.Err()
+	}
+
+	view := ByteView{b: req.Value, e: expire}
+	group.localSet(key, view, &group.mainCache)
+	group.replicate(key, view)
+	return &gcgrpc.Ack{}, nil
+}
+
+// Replicate implements Replicator. Replicas that could not be stored
+// are reported in the returned PeersError.
+func (gp *GRPCPool) Replicate(ctx context.Context, group, key string, value ByteView) error {
+	if gp.opts.ReplicationFactor <= 1 {
+		return nil
+	}
+	gp.mu.Lock()
+	var replicas []*grpcGetter
+	for _, peer := range gp.replicaPeers(key, gp.opts.ReplicationFactor) {
+		if getter, ok := gp.grpcGetters[peer]; ok && peer != gp.self {
+			replicas = append(replicas, getter)
+		}
+	}
+	gp.mu.Unlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(PeersError)
+	for _, getter := range replicas {
+		wg.Add(1)
+		go func(getter *grpcGetter) {
+			defer wg.Done()
+			if err := getter.store(ctx, group, key, value, true); err != nil {
+				mu.Lock()
+				errs[getter.address] = err
+				mu.Unlock()
+			}
+		}(getter)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// FlushAll empties the named group on this process and on every peer in
+// the pool. Peers that could not be flushed are reported in the
+// returned PeersError.
+func (gp *GRPCPool) FlushAll(ctx context.Context, group string) error {
+	g := GetGroupInNamespace(gp.opts.Namespace, group)
+	if g == nil {
+		return fmt.Errorf("%w: [%s]", ErrGroupNotFound, group)
+	}
+	g.localFlush()
+
+	gp.mu.Lock()
+	getters := make([]*grpcGetter, 0, len(gp.grpcGetters))
+	for addr, getter := range gp.grpcGetters {
+		if addr != gp.self {
+			getters = append(getters, getter)
+		}
+	}
+	gp.mu.Unlock()
+
+	var (
+		wg   sync.WaitGroup
+		emu  sync.Mutex
+		errs = PeersError{}
+	)
+	for _, getter := range getters {
+		wg.Add(1)
+		go func(getter *grpcGetter) {
+			defer wg.Done()
+			if err := getter.Flush(ctx, group); err != nil {
+				emu.Lock()
+				errs[getter.address] = err
+				emu.Unlock()
+			}
+		}(getter)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// AddPeers adds peers to the pool, or changes their weights. With
+// Gossip the peers are added to the membership, which gossips them to
+// the other peers. Like RemovePeers and SetPeers, it is ignored if
+// peers has a version not above that of the last versioned change.
+func (gp *GRPCPool) AddPeers(ctx context.Context, peers *gcgrpc.Peers) (*gcgrpc.Ack, error) {
+	gp.versioned(peers.Version, func() {
+		if gp.gossip != nil {
+			gp.updateMembers(func(s *gossipState) bool { return s.add(peers.PeerAddr) })
+		} else {
+			gp.addPeers(peers.PeerAddr)
+		}
+	})
+	return &gcgrpc.Ack{}, nil
+}
+
+// versioned applies a peer list change of version, unless it is not
+// above the version of the last versioned change, see
+// gcgrpc.Peers.Version. Changes of version zero are always applied.
+func (gp *GRPCPool) versioned(version uint64, apply func()) {
+	if version == 0 {
+		apply()
+		return
+	}
+	gp.versionMu.Lock()
+	defer gp.versionMu.Unlock()
+	if version <= gp.version {
+		gp.Stats.StaleTopologies.Add(1)
+		gp.log().Debug("Ignoring peer list change of an old topology version", "version", version, "current", gp.version)
+		return
+	}
+	gp.version = version
+	apply()
+}
+
+// TopologyVersion returns the version of the last versioned change of
+// the peer list applied by the AddPeers, RemovePeers and SetPeers RPCs,
+// zero if there was none.
+func (gp *GRPCPool) TopologyVersion() uint64 {
+	gp.versionMu.Lock()
+	defer gp.versionMu.Unlock()
+	return gp.version
+}
+
+// GetTopology returns the specs of the peers of the pool, sorted, with
+// its TopologyVersion, so a controller can check which membership a
+// peer has applied.
+func (gp *GRPCPool) GetTopology(ctx context.Context, req *gcgrpc.GetTopologyRequest) (*gcgrpc.Peers, error) {
+	gp.versionMu.Lock()
+	defer gp.versionMu.Unlock()
+	gp.mu.Lock()
+	specs := make([]string, 0, len(gp.weights))
+	for peer, weight := range gp.weights {
+		specs = append(specs, peerSpecString(peer, weight, gp.zones[peer], gp.replicas[peer]))
+	}
+	gp.mu.Unlock()
+	sort.Strings(specs)
+	return &gcgrpc.Peers{PeerAddr: specs, Version: gp.version}, nil
+}
+
+// GetStats returns the DebugReport of this process with the peers of
+// the pool, as DebugHandler serves it. The report includes the keys of
+// recently evicted entries, so the pool should only be served to
+// trusted clients, for example with GRPCPoolOptions.Auth.
+func (gp *GRPCPool) GetStats(ctx context.Context, req *gcgrpc.GetStatsRequest) (*gcgrpc.GetStatsResponse, error) {
+	report, ok := debugReport(gp, req.Group)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Unable to find group [%s]", req.Group)
+	}
+	b, err := json.Marshal(report)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to encode stats: %v", err)
+	}
+	return &gcgrpc.GetStatsResponse{Report: b}, nil
+}
+
+func (gp *GRPCPool) addPeers(specs []string) {
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+	var changed bool
+	for _, spec := range specs {
+		s, err := ParsePeerSpec(spec)
+		if err != nil {
+			gp.log().Warn("Ignoring peer", "peer", spec, "err", err)
+			continue
+		}
+		peer, weight, zone := s.Address(), s.Weight, s.Zone
+		if _, exists := gp.grpcGetters[peer]; exists != true {
+			getter, err := gp.newGetter(peer)
+			if err != nil {
+				gp.log().Warn("Failed to open connection", "peer", peer, "err", err)
+			} else {
+				gp.log().Info("Adding peer", "peer", peer)
+				gp.grpcGetters[peer] = getter
+				gp.weights[peer] = weight
+				gp.setZone(peer, zone)
+				gp.setReplicas(peer, s.Replicas)
+				gp.addToRing(peer)
+				changed = true
+			}
+			continue
+		}
+		if replicas := gp.setReplicas(peer, s.Replicas); weight != gp.weights[peer] || replicas {
+			gp.log().Info("Changing weight of peer", "peer", peer, "weight", weight, "replicas", s.Replicas)
+			gp.weights[peer] = weight
+			if !gp.ejected[peer] {
+				gp.peers.Remove(peer)
+				gp.addToRing(peer)
+			}
+			changed = true
+		}
+		if gp.setZone(peer, zone) {
+			gp.log().Info("Changing zone of peer", "peer", peer, "zone", zone)
+			changed = true
+		}
+	}
+	if changed {
+		gp.peersChanged()
+	}
+}
+
+// RemovePeers removes peers from the pool. With Gossip the peers are
+// marked as removed in the membership, which gossips their removal to
+// the other peers; a running peer refutes its removal, so remove peers
+// once they are stopped or call RemovePeers on the departing peer
+// itself.
+func (gp *GRPCPool) RemovePeers(ctx context.Context, peers *gcgrpc.Peers) (*gcgrpc.Ack, error) {
+	gp.versioned(peers.Version, func() {
+		if gp.gossip != nil {
+			gp.updateMembers(func(s *gossipState) bool { return s.remove(peers.PeerAddr) })
+		} else {
+			gp.removePeers(peers.PeerAddr)
+		}
+	})
+	return &gcgrpc.Ack{}, nil
+}
+
+func (gp *GRPCPool) removePeers(specs []string) {
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+	var changed bool
+	for _, spec := range specs {
+		peer := peerAddr(spec)
+		if p, exists := gp.grpcGetters[peer]; exists == true {
+			gp.log().Info("Removing peer", "peer", peer)
+			p.close()
+			delete(gp.grpcGetters, peer)
+			delete(gp.ejected, peer)
+			delete(gp.weights, peer)
+			delete(gp.zones, peer)
+			delete(gp.replicas, peer)
+			gp.peers.Remove(peer)
+			changed = true
+		}
+	}
+	if changed {
+		gp.peersChanged()
+	}
+}
+
+// RemovePeersGraceful removes the given peers from the pool so no new
+// requests are routed to them, then waits for requests already in
+// flight to those peers to complete before closing their connections.
+// If ctx expires first the connections are closed anyway and ctx.Err()
+// is returned.
+func (gp *GRPCPool) RemovePeersGraceful(ctx context.Context, peers ...string) error {
+	gp.mu.Lock()
+	var removed []*grpcGetter
+	for _, spec := range peers {
+		peer := peerAddr(spec)
+		if p, exists := gp.grpcGetters[peer]; exists {
+			gp.log().Info("Removing peer", "peer", peer)
+			removed = append(removed, p)
+			delete(gp.grpcGetters, peer)
+			delete(gp.ejected, peer)
+			delete(gp.weights, peer)
+			delete(gp.zones, peer)
+			delete(gp.replicas, peer)
+			gp.peers.Remove(peer)
+		}
+	}
+	if len(removed) != 0 {
+		gp.peersChanged()
+	}
+	gp.mu.Unlock()
+	if gp.gossip != nil {
+		gp.updateMembers(func(s *gossipState) bool { return s.remove(peers) })
+	}
+
+	var err error
+	for _, p := range removed {
+		if e := p.drain(ctx); e != nil {
+			err = e
+		}
+		p.close()
+	}
+	return err
+}
+
+// UpdatePeers changes the pool's peers to exactly desired. Unlike Set,
+// only the difference is applied: connections and ring positions of
+// peers present in both the old and new set, with the same weight, are
+// left untouched. With Gossip the membership is changed to desired.
+func (gp *GRPCPool) UpdatePeers(desired []string) {
+	if gp.gossip != nil {
+		gp.updateMembers(func(s *gossipState) bool { return s.set(desired) })
+		return
+	}
+	gp.applyPeers(desired)
+}
+
+// applyPeers changes the peers of the ring to exactly desired, as
+// UpdatePeers does without Gossip.
+func (gp *GRPCPool) applyPeers(desired []string) {
+	want := make(map[string]bool, len(desired))
+	for _, spec := range desired {
+		want[peerAddr(spec)] = true
+	}
+
+	gp.mu.Lock()
+	var add, remove []string
+	for _, spec := range desired {
+		// AddPeers also applies the new weight, zone and virtual nodes
+		// of an existing peer.
+		s, err := ParsePeerSpec(spec)
+		peer := s.Address()
+		if _, exists := gp.grpcGetters[peer]; err != nil || !exists || s.Weight != gp.weights[peer] || s.Zone != gp.zones[peer] || s.Replicas != gp.replicas[peer] {
+			add = append(add, spec)
+		}
+	}
+	for peer := range gp.grpcGetters {
+		if !want[peer] {
+			remove = append(remove, peer)
+		}
+	}
+	gp.mu.Unlock()
+
+	if len(remove) != 0 {
+		gp.removePeers(remove)
+	}
+	if len(add) != 0 {
+		gp.addPeers(add)
+	}
+}
+
+func (gp *GRPCPool) SetPeers(ctx context.Context, peers *gcgrpc.Peers) (*gcgrpc.Ack, error) {
+	gp.versioned(peers.Version, func() { gp.Set(peers.PeerAddr...) })
+	return &gcgrpc.Ack{}, nil
+}
+
+// Ping reports the address this pool was created with as self.
+func (gp *GRPCPool) Ping(ctx context.Context, req *gcgrpc.PingRequest) (*gcgrpc.PingResponse, error) {
+	return &gcgrpc.PingResponse{Self: gp.self}, nil
+}
+
+// Verify checks that self is the address of this pool's server, by
+// dialing self and sending it a Ping. A self that is unreachable or
+// served by a different pool means peers can never route requests
+// back to this process. Call Verify once the grpc.Server passed to
+// NewGRPCPoolOptions is serving.
+func (gp *GRPCPool) Verify(ctx context.Context) error {
+	conn, err := grpc.DialContext(ctx, gp.self, gp.opts.dialOptions(gp.self)...)
+	if err != nil {
+		return fmt.Errorf("groupcache: self [%s] is unreachable: %v", gp.self, err)
+	}
+	defer conn.Close()
+	resp, err := gcgrpc.NewPeerClient(conn).Ping(ctx, &gcgrpc.PingRequest{})
+	if err != nil {
+		return fmt.Errorf("groupcache: self [%s] is unreachable: %w", gp.self, err)
+	}
+	if resp.Self != gp.self {
+		return fmt.Errorf("groupcache: self [%s] is served by the pool of [%s]", gp.self, resp.Self)
+	}
+	return nil
+}
+
+// ResolveGroup returns the ID callers may send in place of the group
+// name in Retrieve requests to this peer.
+func (gp *GRPCPool) ResolveGroup(ctx context.Context, req *gcgrpc.ResolveGroupRequest) (*gcgrpc.ResolveGroupResponse, error) {
+	if GetGroupInNamespace(req.Namespace, req.Group) == nil {
+		return nil, status.Errorf(codes.NotFound, "Unable to find group [%s]", req.Group)
+	}
+	id, epoch := gp.groupIDs.id(groupKey(req.Namespace, req.Group))
+	return &gcgrpc.ResolveGroupResponse{GroupId: id, Epoch: epoch}, nil
+}
+
+// groupTable assigns group IDs for ResolveGroup. IDs start at 1 and
+// are never reused. They are only valid together with the table's
+// epoch, which is random so IDs of another process are told apart.
+type groupTable struct {
+	mu    sync.RWMutex
+	epoch uint64 // chosen on the first id call
+	ids   map[string]uint32
+	names []string // names[id-1] is the group with that id
+}
+
+func (t *groupTable) id(name string) (uint32, uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id, ok := t.ids[name]; ok {
+		return id, t.epoch
+	}
+	if t.ids == nil {
+		t.ids = make(map[string]uint32)
+		t.epoch = newEpoch()
+	}
+	t.names = append(t.names, name)
+	id := uint32(len(t.names))
+	t.ids[name] = id
+	return id, t.epoch
+}
+
+func (t *groupTable) name(id uint32, epoch uint64) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if id == 0 || int(id) > len(t.names) || epoch != t.epoch {
+		return "", false
+	}
+	return t.names[id-1], true
+}
+
+// newEpoch returns a random non-zero epoch for a groupTable.
+func newEpoch() uint64 {
+	var b [8]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			// Fall back to the clock, which differs across restarts too.
+			binary.BigEndian.PutUint64(b[:], uint64(time.Now().UnixNano()))
+		}
+		if epoch := binary.BigEndian.Uint64(b[:]); epoch != 0 {
+			return epoch
+		}
+	}
+}
+
+// InvalidateLookups flushes the lookup cache after another peer saw
+// its peer list change. The peer list of gp is not changed, so keys
+// keep being routed with gp's own ring. It is not broadcast any
+// further.
+func (gp *GRPCPool) InvalidateLookups(ctx context.Context, req *gcgrpc.InvalidateLookupsRequest) (*gcgrpc.Ack, error) {
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+	if gp.lookups != nil {
+		gp.lookups.Clear()
+		gp.Stats.LookupInvalidations.Add(1)
+	}
+	return &gcgrpc.Ack{}, nil
+}
+
+type grpcGetter struct {
+	address     string
+	namespace   string // sent with every request for a group
+	dialOpts    []grpc.DialOption
+	idleTimeout time.Duration
+	maxConns    int
+	maxStreams  int
+	stats       *GRPCPoolStats
+
+	breaker   *circuitBreaker
+	retry     *retryPolicy
+	batchKeys int
+	batchPar  int
+	intern    bool
+	stream    bool
+	accept    string   // compressors sent in acceptEncodingKey, if any
+	forwardMD []string // incoming metadata keys sent with Gets
+
+	mu        sync.Mutex // guards the fields below
+	conns     []*grpc.ClientConn
+	streams   []int // RPCs in flight on each of conns
+	inflight  int
+	idle      chan struct{} // closed when inflight drops to zero
+	lastUsed  time.Time
+	idleTimer *time.Timer
+	closed    bool
+	groupIDs  map[string]groupID // IDs resolved with the peer
+	noResolve bool               // the peer does not support ResolveGroup
+	noStream  bool               // the peer does not support RetrieveStream
+	done      chan struct{}      // closed by close
+
+	rpcs, rpcErrors AtomicInt // RPCs sent to the peer, as in GRPCPoolStats
+	latency         latencyAverage
+}
+
+func newGRPCGetter(address string, opts *GRPCPoolOptions, stats *GRPCPoolStats) (*grpcGetter, error) {
+	g := &grpcGetter{
+		address:     address,
+		namespace:   opts.Namespace,
+		idleTimeout: opts.IdleTimeout,
+		maxConns:    opts.ConnsPerPeer,
+		maxStreams:  opts.MaxStreamsPerConn,
+		stats:       stats,
+		batchKeys:   opts.MaxBatchKeys,
+		batchPar:    opts.BatchParallelism,
+		intern:      opts.InternGroupNames,
+		stream:      opts.StreamValues,
+		accept:      opts.Compression.acceptHeader(),
+		forwardMD:   opts.ForwardMetadata,
+		lastUsed:    time.Now(),
+		done:        make(chan struct{}),
+	}
+	g.dialOpts = append([]grpc.DialOption{grpc.WithStatsHandler(statsHandler{stats, g})}, opts.dialOptions(address)...)
+	if g.maxConns < 1 {
+		g.maxConns = 1
+	}
+	conn, err := grpc.Dial(address, g.dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to [%s]: %v", address, err)
+	}
+	g.conns, g.streams = []*grpc.ClientConn{conn}, []int{0}
+	if opts.CircuitBreaker != nil {
+		g.breaker = newCircuitBreaker(*opts.CircuitBreaker)
+		g.breaker.trips = &stats.CircuitTrips
+	}
+	if opts.Retry != nil {
+		g.retry = newRetryPolicy(*opts.Retry, retryable)
+	}
+	return g, nil
+}
+
+func (g *grpcGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	return g.get(ctx, in, out, false)
+}
+
+// get retrieves in from the peer, as its fallback owner if fallback is
+// set.
+func (g *grpcGetter) get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse, fallback bool) (err error) {
+	ctx, span := startSpan(ctx, "groupcache.grpcGetter.Get", in.GetGroup(), in.GetKey(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("groupcache.peer", g.address)))
+	defer func() { endSpan(span, err) }()
+	ctx = injectTrace(ctx)
+	if g.accept != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, acceptEncodingKey, g.accept)
+	}
+	ctx = outgoingPriority(forwardMetadata(ctx, g.forwardMD))
+	if g.breaker != nil && !g.breaker.allow() {
+		return ErrCircuitOpen
+	}
+	conn, err := g.begin()
+	if err != nil {
+		g.breaker.record(ctx, err)
+		return fmt.Errorf("Failed to GET [%s]: %v", in, err)
+	}
+	defer g.end(conn)
+	client := gcgrpc.NewPeerClient(conn)
+	o := getOptionsFor(ctx, in.GetGroup(), in.GetKey())
+	req := &gcgrpc.RetrieveRequest{
+		Key:             []byte(in.GetKey()),
+		Namespace:       g.namespace,
+		Fallback:        fallback,
+		ForceRefresh:    o.forceRefresh,
+		NoStore:         o.noStore,
+		HasMinFreshness: o.hasMinFresh,
+		MinFreshness:    int64(o.minFresh),
+	}
+	if id := g.groupID(ctx, client, in.GetGroup()); id.id != 0 {
+		req.GroupId, req.GroupEpoch = id.id, id.epoch
+	} else {
+		req.Group = in.GetGroup()
+	}
+	var resp *gcgrpc.RetrieveResponse
+	retrieve := func(ctx context.Context) (err error) {
+		start := time.Now()
+		resp, err = g.retrieve(ctx, client, req)
+		if err == nil || status.Code(err) == codes.DeadlineExceeded {
+			// A timed out attempt took at least this long.
+			g.latency.observe(time.Since(start))
+		}
+		return err
+	}
+	err = g.retry.do(ctx, retrieve)
+	if req.GroupId != 0 && status.Code(err) == codes.NotFound && !keyNotFound(err) {
+		// The peer may have restarted and forgotten the ID.
+		g.forgetGroupID(in.GetGroup())
+		req.GroupId, req.GroupEpoch, req.Group = 0, 0, in.GetGroup()
+		err = g.retry.do(ctx, retrieve)
+	}
+	if status.Code(err) == codes.NotFound {
+		// The peer is healthy, it just doesn't know the group.
+		g.breaker.record(ctx, nil)
+	} else {
+		g.breaker.record(ctx, err)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to GET [%s]: %w", in, errFromStatus(err))
+	}
+
+	if out.Value, err = decompress(resp.Encoding, resp.Value); err != nil {
+		return fmt.Errorf("Failed to GET [%s]: %w", in, err)
+	}
+	if resp.Expire != 0 {
+		out.Expire = &resp.Expire
+	}
+	return nil
+}
+
+// retrieve sends req with RetrieveStream if StreamValues is set and the
+// peer supports it, or with Retrieve otherwise.
+func (g *grpcGetter) retrieve(ctx context.Context, client gcgrpc.PeerClient, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	g.mu.Lock()
+	stream := g.stream && !g.noStream
+	g.mu.Unlock()
+	if stream {
+		resp, err := retrieveStream(ctx, client, req)
+		if status.Code(err) != codes.Unimplemented {
+			return resp, err
+		}
+		g.mu.Lock()
+		g.noStream = true
+		g.mu.Unlock()
+	}
+	return client.Retrieve(ctx, req)
+}
+
+// maxStreamPrealloc bounds the buffer retrieveStream allocates up front
+// from the size announced by the peer. Larger values grow the buffer
+// as chunks arrive.
+const maxStreamPrealloc = 4 << 20
+
+// retrieveStream reassembles a value sent by RetrieveStream. The whole
+// value is buffered, as it is cached once received.
+func retrieveStream(ctx context.Context, client gcgrpc.PeerClient, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.RetrieveStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp := &gcgrpc.RetrieveResponse{}
+	for first := true; ; first = false {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return resp, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			resp.Expire, resp.Encoding = chunk.Expire, chunk.Encoding
+			if size := chunk.Size; size > 0 {
+				if size > maxStreamPrealloc {
+					size = maxStreamPrealloc
+				}
+				resp.Value = make([]byte, 0, size)
+			}
+		}
+		resp.Value = append(resp.Value, chunk.Data...)
+	}
+}
+
+// groupID is a group ID resolved with a peer.
+type groupID struct {
+	id    uint32
+	epoch uint64
+}
+
+// groupID returns the peer's ID for group, resolving it on first use,
+// or a zero ID if group names are not interned with this peer.
+func (g *grpcGetter) groupID(ctx context.Context, client gcgrpc.PeerClient, group string) groupID {
+	if !g.intern {
+		return groupID{}
+	}
+	g.mu.Lock()
+	id, noResolve := g.groupIDs[group], g.noResolve
+	g.mu.Unlock()
+	if id.id != 0 || noResolve {
+		return id
+	}
+
+	resp, err := client.ResolveGroup(ctx, &gcgrpc.ResolveGroupRequest{Group: group, Namespace: g.namespace})
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch {
+	case status.Code(err) == codes.Unimplemented:
+		g.noResolve = true
+	case err == nil:
+		if g.groupIDs == nil {
+			g.groupIDs = make(map[string]groupID)
+		}
+		id = groupID{id: resp.GroupId, epoch: resp.Epoch}
+		g.groupIDs[group] = id
+		return id
+	}
+	return groupID{}
+}
+
+func (g *grpcGetter) forgetGroupID(group string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.groupIDs, group)
+}
+
+// GetMulti implements MultiGetter. Keys are split into batches of at
+// most MaxBatchKeys with up to BatchParallelism batches in flight.
+func (g *grpcGetter) GetMulti(ctx context.Context, group string, keys []string) (map[string]ByteView, error) {
+	batches := splitBatches(keys, g.batchKeys)
+	par := g.batchPar
+	if par <= 0 {
+		par = 1
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		res  = make(map[string]ByteView, len(keys))
+		errR error
+		sem  = make(chan struct{}, par)
+	)
+	for _, batch := range batches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(batch []string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			values, err := g.retrieveMulti(ctx, group, batch)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errR = err
+				return
+			}
+			for key, value := range values {
+				res[key] = value
+			}
+		}(batch)
+	}
+	wg.Wait()
+	return res, errR
+}
+
+func (g *grpcGetter) retrieveMulti(ctx context.Context, group string, keys []string) (map[string]ByteView, error) {
+	conn, err := g.begin()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to GET [%d keys]: %v", len(keys), err)
+	}
+	defer g.end(conn)
+	req := &gcgrpc.RetrieveMultiRequest{Group: group, Namespace: g.namespace, Keys: make([][]byte, len(keys))}
+	for i, key := range keys {
+		req.Keys[i] = []byte(key)
+	}
+	client := gcgrpc.NewPeerClient(conn)
+	resp, err := client.RetrieveMulti(outgoingPriority(forwardMetadata(ctx, g.forwardMD)), req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to GET [%d keys]: %w", len(keys), errFromStatus(err))
+	}
+
+	now := time.Now()
+	res := make(map[string]ByteView, len(resp.Values))
+	for _, kv := range resp.Values {
+		if kv.Error != "" {
+			continue
+		}
+		var expire time.Time
+		if kv.Expire != 0 {
+			expire = time.Unix(0, kv.Expire)
+			if now.After(expire) {
+				continue
+			}
+		}
+		res[string(kv.Key)] = ByteView{b: kv.Value, e: expire}
+	}
+	return res, nil
+}
+
+// splitBatches splits keys into consecutive batches of at most n keys.
+func splitBatches(keys []string, n int) [][]string {
+	if n <= 0 {
+		n = len(keys)
+	}
+	var batches [][]string
+	for len(keys) > n {
+		batches = append(batches, keys[:n:n])
+		keys = keys[n:]
+	}
+	if len(keys) != 0 {
+		batches = append(batches, keys)
+	}
+	return batches
+}
+
+func (g *grpcGetter) Remove(ctx context.Context, in *pb.GetRequest) error {
+	conn, err := g.begin()
+	if err != nil {
+		return fmt.Errorf("Failed to REMOVE [%s]: %v", in, err)
+	}
+	defer g.end(conn)
+	client := gcgrpc.NewPeerClient(conn)
+	_, err = client.Delete(ctx, &gcgrpc.DeleteRequest{Group: in.GetGroup(), Namespace: g.namespace, Key: []byte(in.GetKey())})
+	if err != nil {
+		return fmt.Errorf("Failed to REMOVE [%s]: %w", in, errFromStatus(err))
+	}
+	return nil
+}
+
+// RemovePrefix implements PeerPrefixRemover with the DeletePrefix RPC.
+func (g *grpcGetter) RemovePrefix(ctx context.Context, group, prefix string) error {
+	conn, err := g.begin()
+	if err != nil {
+		return fmt.Errorf("Failed to DELETE prefix [%s]: %v", prefix, err)
+	}
+	defer g.end(conn)
+	client := gcgrpc.NewPeerClient(conn)
+	_, err = client.DeletePrefix(ctx, &gcgrpc.DeletePrefixRequest{Group: group, Namespace: g.namespace, Prefix: []byte(prefix)})
+	if err != nil {
+		return fmt.Errorf("Failed to DELETE prefix [%s]: %w", prefix, errFromStatus(err))
+	}
+	return nil
+}
+
+// Flush implements PeerFlusher with the Flush RPC.
+func (g *grpcGetter) Flush(ctx context.Context, group string) error {
+	conn, err := g.begin()
+	if err != nil {
+		return fmt.Errorf("Failed to FLUSH [%s]: %v", group, err)
+	}
+	defer g.end(conn)
+	client := gcgrpc.NewPeerClient(conn)
+	_, err = client.Flush(ctx, &gcgrpc.FlushRequest{Group: group, Namespace: g.namespace})
+	if err != nil {
+		return fmt.Errorf("Failed to FLUSH [%s]: %w", group, errFromStatus(err))
+	}
+	return nil
+}
+
+// BumpGeneration implements PeerGenerationBumper with the
+// BumpGeneration RPC.
+func (g *grpcGetter) BumpGeneration(ctx context.Context, group string) error {
+	conn, err := g.begin()
+	if err != nil {
+		return fmt.Errorf("Failed to BUMP [%s]: %v", group, err)
+	}
+	defer g.end(conn)
+	client := gcgrpc.NewPeerClient(conn)
+	_, err = client.BumpGeneration(ctx, &gcgrpc.BumpGenerationRequest{Group: group, Namespace: g.namespace})
+	if err != nil {
+		return fmt.Errorf("Failed to BUMP [%s]: %w", group, errFromStatus(err))
+	}
+	return nil
+}
+
+// Set implements PeerSetter with the Store RPC. A peer that does not
+// own key answers with a *NotOwnerError.
+func (g *grpcGetter) Set(ctx context.Context, group, key string, value ByteView) error {
+	return g.store(ctx, group, key, value, false)
+}
+
+func (g *grpcGetter) store(ctx context.Context, group, key string, value ByteView, replica bool) error {
+	conn, err := g.begin()
+	if err != nil {
+		return fmt.Errorf("Failed to STORE [%s]: %v", key, err)
+	}
+	defer g.end(conn)
+	req := &gcgrpc.StoreRequest{Group: group, Namespace: g.namespace, Key: []byte(key), Value: value.ByteSlice(), Replica: replica}
+	if !value.Expire().IsZero() {
+		req.Expire = value.Expire().UnixNano()
+	}
+	client := gcgrpc.NewPeerClient(conn)
+	_, err = client.Store(ctx, req)
+	if err != nil {
+		return fmt.Errorf("Failed to STORE [%s]: %w", key, errFromStatus(err))
+	}
+	return nil
+}
+
+func (g *grpcGetter) invalidateLookups(ctx context.Context) error {
+	conn, err := g.begin()
+	if err != nil {
+		return fmt.Errorf("Failed to INVALIDATE lookups: %v", err)
+	}
+	defer g.end(conn)
+	client := gcgrpc.NewPeerClient(conn)
+	_, err = client.InvalidateLookups(ctx, &gcgrpc.InvalidateLookupsRequest{})
+	if err != nil {
+		return fmt.Errorf("Failed to INVALIDATE lookups: %w", errFromStatus(err))
+	}
+	return nil
+}
+
+func (g *grpcGetter) setPeers(ctx context.Context, peers *gcgrpc.Peers) error {
+	conn, err := g.begin()
+	if err != nil {
+		return fmt.Errorf("Failed to SET peers: %v", err)
+	}
+	defer g.end(conn)
+	_, err = gcgrpc.NewPeerClient(conn).SetPeers(ctx, peers)
+	if err != nil {
+		return fmt.Errorf("Failed to SET peers: %w", errFromStatus(err))
+	}
+	return nil
+}
+
+// errFromStatus maps the status codes returned by a peer to the
+// matching sentinel error, keeping the peer's message as detail.
+func errFromStatus(err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		if keyNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrNotFound, status.Convert(err).Message())
+		}
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, status.Convert(err).Message())
+	case codes.Internal:
+		return fmt.Errorf("%w: %s", ErrKeyLoadFailed, status.Convert(err).Message())
+	case codes.FailedPrecondition:
+		for _, d := range status.Convert(err).Details() {
+			if no, ok := d.(*gcgrpc.NotOwner); ok {
+				return &NotOwnerError{Owner: no.Owner}
+			}
+		}
+	}
+	return err
+}
+
+// keyNotFound reports whether err is a codes.NotFound status for a key
+// rather than for a group.
+func keyNotFound(err error) bool {
+	for _, d := range status.Convert(err).Details() {
+		if _, ok := d.(*gcgrpc.KeyNotFound); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// begin marks the start of an RPC and returns the connection to send
+// it on, the one with the fewest RPCs in flight, dialing another one if
+// they all have maxStreams or the peer again if its connections were
+// closed while idle. The RPC must be ended with end.
+func (g *grpcGetter) begin() (*grpc.ClientConn, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.conns) == 0 && g.closed {
+		return nil, fmt.Errorf("connection to [%s] is closed", g.address)
+	}
+	i := -1
+	for j, n := range g.streams {
+		if i < 0 || n < g.streams[i] {
+			i = j
+		}
+	}
+	if i < 0 || len(g.conns) < g.maxConns && g.streams[i] >= g.maxStreams {
+		conn, err := grpc.Dial(g.address, g.dialOpts...)
+		if err != nil {
+			if i < 0 {
+				return nil, fmt.Errorf("Failed to connect to [%s]: %v", g.address, err)
+			}
+		} else {
+			if i < 0 {
+				// The connections were closed for being idle.
+				g.stats.Redials.Add(1)
+			}
+			g.conns, g.streams = append(g.conns, conn), append(g.streams, 0)
+			i = len(g.conns) - 1
+		}
+	}
+	g.streams[i]++
+	g.inflight++
+	return g.conns[i], nil
+}
+
+// load returns the number of RPCs in flight to the peer.
+func (g *grpcGetter) load() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.inflight
+}
+
+// end marks the completion of an RPC started with begin on conn.
+func (g *grpcGetter) end(conn *grpc.ClientConn) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, c := range g.conns {
+		if c == conn {
+			g.streams[i]--
+		}
+	}
+	g.inflight--
+	g.lastUsed = time.Now()
+	if g.inflight == 0 && g.idle != nil {
+		close(g.idle)
+		g.idle = nil
+	}
+	if g.idleTimeout > 0 && g.idleTimer == nil && !g.closed {
+		g.idleTimer = time.AfterFunc(g.idleTimeout, g.closeIfIdle)
+	}
+}
+
+// closeIfIdle closes the connection if it has not been used for
+// idleTimeout, otherwise it checks again when it next could be.
+func (g *grpcGetter) closeIfIdle() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed || len(g.conns) == 0 {
+		g.idleTimer = nil
+		return
+	}
+	if wait := g.idleTimeout - time.Since(g.lastUsed); g.inflight > 0 || wait > 0 {
+		if wait <= 0 {
+			wait = g.idleTimeout
+		}
+		g.idleTimer.Reset(wait)
+		return
+	}
+	g.closeConns()
+	g.idleTimer = nil
+}
+
+// drain blocks until no requests are in flight or ctx is done.
+func (g *grpcGetter) drain(ctx context.Context) error {
+	g.mu.Lock()
+	if g.inflight == 0 {
+		g.mu.Unlock()
+		return nil
+	}
+	if g.idle == nil {
+		g.idle = make(chan struct{})
+	}
+	idle := g.idle
+	g.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return nil
 }
 
 // GetURL
@@ -235,7 +2560,27 @@ func (g *grpcGetter) GetURL() string {
 }
 
 func (g *grpcGetter) close() {
-	if g.conn != nil {
-		g.conn.Close()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.closed {
+		close(g.done)
+	}
+	g.closed = true
+	if g.idleTimer != nil {
+		g.idleTimer.Stop()
+		g.idleTimer = nil
+	}
+	g.closeConns()
+}
+
+// closeConns closes the connections to the peer, counting an idle close
+// unless the getter is closed. g.mu must be held.
+func (g *grpcGetter) closeConns() {
+	if len(g.conns) != 0 && !g.closed {
+		g.stats.IdleCloses.Add(1)
+	}
+	for _, conn := range g.conns {
+		conn.Close()
 	}
+	g.conns, g.streams = nil, nil
 }
@@ -6,18 +6,47 @@ package groupcache
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
+	"math/big"
 	"net"
 	"os"
 	"os/exec"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+	pb "github.com/adistroy/groupcache/v3/groupcachepb"
+	"github.com/adistroy/groupcache/v3/hashring"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestGRPCPool(t *testing.T) {
@@ -104,3 +133,2787 @@ func beChildForTestGRPCPool() {
 
 	server.Serve(lis)
 }
+
+// recordingPeer is an in-process gcgrpc.PeerServer that records the
+// groups it was asked to flush.
+type recordingPeer struct {
+	gcgrpc.UnimplementedPeerServer
+	mu      sync.Mutex
+	flushed []string
+}
+
+func (p *recordingPeer) Flush(ctx context.Context, req *gcgrpc.FlushRequest) (*gcgrpc.Ack, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.flushed = append(p.flushed, req.Group)
+	return &gcgrpc.Ack{}, nil
+}
+
+// startTestPeer serves srv on a free local port and returns its address
+// along with a function that stops the server.
+func startTestPeer(t *testing.T, srv gcgrpc.PeerServer, opts ...grpc.ServerOption) (string, func()) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(opts...)
+	gcgrpc.RegisterPeerServer(server, srv)
+	go server.Serve(lis)
+	return lis.Addr().String(), server.Stop
+}
+
+func TestGRPCPoolFlushAll(t *testing.T) {
+	const groupName = "TestGRPCPoolFlushAll-group"
+	g := newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+	for i := 0; i < 10; i++ {
+		var s string
+		if err := g.Get(context.Background(), strconv.Itoa(i), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	peers := []*recordingPeer{{}, {}}
+	var addrs []string
+	for _, p := range peers {
+		addr, stop := startTestPeer(t, p)
+		defer stop()
+		addrs = append(addrs, addr)
+	}
+	unreachable := pickFreeAddr(t)
+
+	pool := newGRPCPool("self", nil)
+	pool.Set(append(addrs, unreachable)...)
+	defer pool.Set()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := pool.FlushAll(ctx, groupName)
+
+	perr, ok := err.(PeersError)
+	if !ok {
+		t.Fatalf("FlushAll returned %v; want PeersError", err)
+	}
+	if _, ok := perr[unreachable]; !ok || len(perr) != 1 {
+		t.Errorf("FlushAll reported %v; want only %s", perr, unreachable)
+	}
+	for i, p := range peers {
+		if len(p.flushed) != 1 || p.flushed[0] != groupName {
+			t.Errorf("peer %d flushed %v; want [%s]", i, p.flushed, groupName)
+		}
+	}
+	if items := g.mainCache.items() + g.hotCache.items(); items != 0 {
+		t.Errorf("local group has %d items after FlushAll; want 0", items)
+	}
+}
+
+func TestGRPCPoolBumpGeneration(t *testing.T) {
+	const groupName = "TestGRPCPoolBumpGeneration-group"
+	g := newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	addr, stop := startTestPeer(t, newGRPCPool("server", nil))
+	defer stop()
+	pool := newGRPCPool("client", nil)
+	pool.Set(addr)
+	defer pool.Set()
+
+	if err := pool.grpcGetters[addr].BumpGeneration(context.Background(), groupName); err != nil {
+		t.Fatal(err)
+	}
+	if g.Generation() != 1 {
+		t.Errorf("Generation = %d; want 1", g.Generation())
+	}
+	err := pool.grpcGetters[addr].BumpGeneration(context.Background(), "TestGRPCPoolBumpGeneration-missing")
+	if !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("BumpGeneration of a missing group = %v; want %v", err, ErrGroupNotFound)
+	}
+}
+
+// blockingPeer is an in-process gcgrpc.PeerServer whose Retrieve blocks
+// until release is closed.
+type blockingPeer struct {
+	gcgrpc.UnimplementedPeerServer
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingPeer) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	close(p.started)
+	<-p.release
+	return &gcgrpc.RetrieveResponse{Value: []byte("got:" + string(req.Key))}, nil
+}
+
+// fallbackPeer is an in-process gcgrpc.PeerServer recording whether it
+// was asked for keys as their fallback owner.
+type fallbackPeer struct {
+	gcgrpc.UnimplementedPeerServer
+	mu       sync.Mutex
+	requests int
+	fallback int
+}
+
+func (p *fallbackPeer) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests++
+	if req.Fallback {
+		p.fallback++
+	}
+	return &gcgrpc.RetrieveResponse{Value: []byte("got:" + string(req.Key))}, nil
+}
+
+func TestGRPCPoolHedge(t *testing.T) {
+	slow := &blockingPeer{started: make(chan struct{}), release: make(chan struct{})}
+	slowAddr, stopSlow := startTestPeer(t, slow)
+	defer stopSlow()
+	defer close(slow.release)
+	next := &fallbackPeer{}
+	nextAddr, stopNext := startTestPeer(t, next)
+	defer stopNext()
+
+	pool := newGRPCPool("self", &GRPCPoolOptions{Hedge: &HedgeOptions{Delay: 10 * time.Millisecond}})
+	pool.Set(slowAddr, nextAddr)
+	defer pool.Set()
+	var key string
+	for i := 0; key == ""; i++ {
+		if peer, _ := pool.PickPeer(strconv.Itoa(i)); peer.GetURL() == slowAddr {
+			key = strconv.Itoa(i)
+		}
+	}
+	const name = "TestGRPCPoolHedge-group"
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local:"+key, time.Time{})
+	}), pool)
+	defer DeregisterGroup(name)
+
+	var s string
+	if err := g.Get(context.Background(), key, StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	next.mu.Lock()
+	defer next.mu.Unlock()
+	if s != "got:"+key || next.fallback != 1 {
+		t.Errorf("hedged Get = %q with %d fallback requests; want %q from the failover peer as fallback", s, next.fallback, "got:"+key)
+	}
+
+	// The delay of a wrapped getter is that of its peer.
+	pool.opts.Hedge.Delay = 0
+	getter := pool.grpcGetters[nextAddr]
+	getter.latency = latencyAverage{value: time.Second}
+	if d := pool.HedgeDelay(fallbackGetter{getter}); d != 2*time.Second {
+		t.Errorf("HedgeDelay of a fallbackGetter = %v; want 2s", d)
+	}
+}
+
+func TestGRPCPoolCoalesceFailover(t *testing.T) {
+	deadAddr, stopDead := startTestPeer(t, &fallbackPeer{})
+	stopDead()
+	next := &fallbackPeer{}
+	nextAddr, stop := startTestPeer(t, next)
+	defer stop()
+
+	const groupName = "TestGRPCPoolCoalesceFailover-group"
+	var local int32
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		atomic.AddInt32(&local, 1)
+		return dest.SetString("local:"+key, time.Time{})
+	})
+	client := newGRPCPool("client", &GRPCPoolOptions{CoalesceFailover: true})
+	if client.FailoverHops() != 1 {
+		t.Errorf("FailoverHops = %d; want 1", client.FailoverHops())
+	}
+	client.Set(deadAddr, nextAddr)
+	defer client.Set()
+	g := newGroup(groupName, cacheSize, getter, client)
+
+	var key string
+	for i := 0; key == ""; i++ {
+		if peer, _ := client.PickPeer(strconv.Itoa(i)); peer.GetURL() == deadAddr {
+			key = strconv.Itoa(i)
+		}
+	}
+	var s string
+	if err := g.Get(context.Background(), key, StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if s != "got:"+key || next.fallback != 1 || local != 0 {
+		t.Errorf("Get = %q with %d fallback requests and %d local loads; want %q from the fallback owner",
+			s, next.fallback, local, "got:"+key)
+	}
+	DeregisterGroup(groupName)
+
+	// The fallback owner loads the key without asking the owner.
+	owner := &fallbackPeer{}
+	ownerAddr, stopOwner := startTestPeer(t, owner)
+	defer stopOwner()
+	server := newGRPCPool("server", nil)
+	server.Set(ownerAddr)
+	defer server.Set()
+	newGroup(groupName, cacheSize, getter, server)
+	defer DeregisterGroup(groupName)
+	res, err := server.Retrieve(context.Background(), &gcgrpc.RetrieveRequest{Group: groupName, Key: []byte(key), Fallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Value) != "local:"+key || owner.requests != 0 || local != 1 {
+		t.Errorf("fallback Retrieve = %q with %d owner requests and %d local loads; want a local load", res.Value, owner.requests, local)
+	}
+}
+
+func TestGRPCPoolPeerStats(t *testing.T) {
+	deadAddr, stopDead := startTestPeer(t, &fallbackPeer{})
+	stopDead()
+	liveAddr, stop := startTestPeer(t, &fallbackPeer{})
+	defer stop()
+
+	pool := newGRPCPool("client", nil)
+	pool.Set(deadAddr, liveAddr)
+	defer pool.Set()
+	group, key := "TestGRPCPoolPeerStats-group", "key"
+	for _, addr := range []string{deadAddr, liveAddr, liveAddr} {
+		pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{})
+	}
+
+	want := map[string]PeerStats{
+		deadAddr: {Peer: deadAddr, RPCs: 1, RPCErrors: 1},
+		liveAddr: {Peer: liveAddr, RPCs: 2},
+	}
+	stats := pool.PeerStats()
+	if len(stats) != 2 {
+		t.Fatalf("PeerStats = %+v; want 2 peers", stats)
+	}
+	for _, got := range stats {
+		if (got.Latency > 0) != (got.Peer == liveAddr) {
+			t.Errorf("latency of %s = %v", got.Peer, got.Latency)
+		}
+		got.Latency = 0
+		if got != want[got.Peer] {
+			t.Errorf("PeerStats of %s = %+v; want %+v", got.Peer, got, want[got.Peer])
+		}
+	}
+}
+
+func TestGRPCPoolRemovePeersGraceful(t *testing.T) {
+	peer := &blockingPeer{started: make(chan struct{}), release: make(chan struct{})}
+	addr, stop := startTestPeer(t, peer)
+	defer stop()
+
+	pool := newGRPCPool("self", nil)
+	pool.Set(addr)
+
+	getter, ok := pool.PickPeer("key")
+	if !ok {
+		t.Fatal("PickPeer did not nominate the remote peer")
+	}
+	getErr := make(chan error, 1)
+	go func() {
+		group, key := "group", "key"
+		getErr <- getter.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{})
+	}()
+	<-peer.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	removed := make(chan error, 1)
+	go func() {
+		removed <- pool.RemovePeersGraceful(ctx, addr)
+	}()
+
+	select {
+	case err := <-removed:
+		t.Fatalf("RemovePeersGraceful returned %v before the in-flight Retrieve finished", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, ok := pool.PickPeer("key"); ok {
+		t.Error("PickPeer still nominates a peer that is being removed")
+	}
+
+	close(peer.release)
+	if err := <-getErr; err != nil {
+		t.Errorf("in-flight Get failed during graceful removal: %v", err)
+	}
+	if err := <-removed; err != nil {
+		t.Errorf("RemovePeersGraceful returned %v", err)
+	}
+}
+
+// echoPeer is an in-process gcgrpc.PeerServer that answers every
+// Retrieve with "got:" + key.
+type echoPeer struct {
+	gcgrpc.UnimplementedPeerServer
+}
+
+func (*echoPeer) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	return &gcgrpc.RetrieveResponse{Value: []byte("got:" + string(req.Key))}, nil
+}
+
+func TestGRPCPoolIdleTimeout(t *testing.T) {
+	addr, stop := startTestPeer(t, &echoPeer{})
+	defer stop()
+
+	pool := newGRPCPool("self", &GRPCPoolOptions{IdleTimeout: 50 * time.Millisecond})
+	pool.Set(addr)
+	defer pool.Set()
+
+	get := func() {
+		t.Helper()
+		getter, ok := pool.PickPeer("key")
+		if !ok {
+			t.Fatal("PickPeer did not nominate the remote peer")
+		}
+		group, key := "group", "key"
+		out := &pb.GetResponse{}
+		if err := getter.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, out); err != nil {
+			t.Fatal(err)
+		}
+		if string(out.Value) != "got:key" {
+			t.Errorf("got %q; want %q", out.Value, "got:key")
+		}
+	}
+
+	get()
+	time.Sleep(200 * time.Millisecond)
+	if n := pool.Stats.IdleCloses.Get(); n != 1 {
+		t.Fatalf("IdleCloses = %d; want 1", n)
+	}
+	pool.grpcGetters[addr].mu.Lock()
+	conns := pool.grpcGetters[addr].conns
+	pool.grpcGetters[addr].mu.Unlock()
+	if len(conns) != 0 {
+		t.Error("idle connection was not closed")
+	}
+
+	get()
+	if n := pool.Stats.Redials.Get(); n != 1 {
+		t.Errorf("Redials = %d; want 1", n)
+	}
+
+	// Closing several idle connections to a peer is one idle close.
+	multi := newGRPCPool("self", &GRPCPoolOptions{IdleTimeout: 50 * time.Millisecond, ConnsPerPeer: 2, MaxStreamsPerConn: 1})
+	multi.Set(addr)
+	defer multi.Set()
+	getter := multi.grpcGetters[addr]
+	first, err := getter.begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := getter.begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Fatal("second RPC was sent on the busy connection")
+	}
+	getter.end(first)
+	getter.end(second)
+	time.Sleep(200 * time.Millisecond)
+	getter.mu.Lock()
+	n := len(getter.conns)
+	getter.mu.Unlock()
+	if closes := multi.Stats.IdleCloses.Get(); n != 0 || closes != 1 {
+		t.Errorf("%d connections left with IdleCloses = %d; want 0 and 1", n, closes)
+	}
+}
+
+func TestGRPCPoolConnsPerPeer(t *testing.T) {
+	addr, stop := startTestPeer(t, &echoPeer{})
+	defer stop()
+	pool := newGRPCPool("self", &GRPCPoolOptions{
+		ConnsPerPeer:      3,
+		MaxStreamsPerConn: 1,
+		Keepalive:         &keepalive.ClientParameters{Time: time.Minute},
+	})
+	pool.Set(addr)
+	defer pool.Set()
+	g := pool.grpcGetters[addr]
+
+	// Each RPC in flight gets a connection of its own, up to three.
+	var conns []*grpc.ClientConn
+	for i := 0; i < 4; i++ {
+		conn, err := g.begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	if conns[0] == conns[1] || conns[1] == conns[2] || conns[0] == conns[2] || len(g.conns) != 3 {
+		t.Errorf("got %d connections for 3 RPCs in flight; want 3", len(g.conns))
+	}
+	g.end(conns[1])
+	g.end(conns[2])
+	if conn, _ := g.begin(); conn != conns[1] && conn != conns[2] {
+		t.Error("RPC was not sent on the least loaded connection")
+	} else {
+		g.end(conn)
+	}
+	g.end(conns[0])
+	g.end(conns[3])
+
+	group, key := "group", "key"
+	var res pb.GetResponse
+	if err := g.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Value) != "got:key" {
+		t.Errorf("Get = %q; want %q", res.Value, "got:key")
+	}
+}
+
+func TestGRPCPoolDeadlineAware(t *testing.T) {
+	addr, stop := startTestPeer(t, &echoPeer{})
+	defer stop()
+	pool := newGRPCPool("self", &GRPCPoolOptions{DeadlineAware: true})
+	pool.Set("self", addr)
+	defer pool.Set()
+	var key string
+	for i := 0; ; i++ {
+		key = strconv.Itoa(i)
+		if _, ok := pool.PickPeer(key); ok {
+			break
+		}
+	}
+
+	getter := pool.grpcGetters[addr]
+	group := "group"
+	var res pb.GetResponse
+	if err := getter.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if getter.latency.get() <= 0 {
+		t.Fatal("latency of a Get was not recorded")
+	}
+
+	getter.latency = latencyAverage{value: time.Second, updated: time.Now()}
+	if _, ok := pool.PickPeerContext(context.Background(), key); !ok {
+		t.Error("Get without a deadline was not sent to the peer")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, ok := pool.PickPeerContext(ctx, key); !ok {
+		t.Error("Get with a loose deadline was not sent to the peer")
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, ok := pool.PickPeerContext(ctx, key); ok {
+		t.Error("Get with a tight deadline was sent to the slow peer")
+	}
+	if n := pool.Stats.DeadlineSkips.Get(); n != 1 {
+		t.Errorf("DeadlineSkips = %d; want 1", n)
+	}
+
+	getter.latency.observe(2 * time.Second)
+	if got, want := getter.latency.get(), 1200*time.Millisecond; got != want {
+		t.Errorf("moving average = %v; want %v", got, want)
+	}
+}
+
+func TestGRPCPoolDeadlineAwareGet(t *testing.T) {
+	addr, stop := startTestPeer(t, &echoPeer{})
+	defer stop()
+	pool := newGRPCPool("self", &GRPCPoolOptions{DeadlineAware: true, DeadlineProbeInterval: 50 * time.Millisecond})
+	pool.Set("self", addr)
+	defer pool.Set()
+	var remote []string
+	for i := 0; len(remote) < 2; i++ {
+		if _, ok := pool.PickPeer(strconv.Itoa(i)); ok {
+			remote = append(remote, strconv.Itoa(i))
+		}
+	}
+	const name = "TestGRPCPoolDeadlineAwareGet-group"
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local:"+key, time.Time{})
+	}), pool)
+	defer DeregisterGroup(name)
+	get := func(key string) string {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		var s string
+		if err := g.Get(ctx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	pool.grpcGetters[addr].latency = latencyAverage{value: time.Second, updated: time.Now()}
+	if got, want := get(remote[0]), "local:"+remote[0]; got != want {
+		t.Errorf("Get with a tight deadline = %q; want %q, loaded locally", got, want)
+	}
+	if n := pool.Stats.DeadlineSkips.Get(); n != 1 {
+		t.Errorf("DeadlineSkips = %d; want 1", n)
+	}
+
+	// Once the average is older than DeadlineProbeInterval, the peer is
+	// tried again.
+	time.Sleep(60 * time.Millisecond)
+	if got, want := get(remote[1]), "got:"+remote[1]; got != want {
+		t.Errorf("Get after DeadlineProbeInterval = %q; want %q, from the peer", got, want)
+	}
+	if l := pool.grpcGetters[addr].latency.get(); l >= time.Second {
+		t.Errorf("latency after a probe = %v; want it below 1s", l)
+	}
+}
+
+func TestGRPCPoolOnTopologyChange(t *testing.T) {
+	changes := make(chan TopologyChange, 10)
+	pool := newGRPCPool("127.0.0.1:1", &GRPCPoolOptions{
+		Replicas:         50,
+		OnTopologyChange: func(c TopologyChange) { changes <- c },
+	})
+	defer pool.Set()
+	next := func() TopologyChange {
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(time.Second):
+			t.Fatal("topology change was not reported")
+			return TopologyChange{}
+		}
+	}
+
+	pool.Set("127.0.0.1:1", "127.0.0.1:2")
+	if c := next(); c.MovedFraction != 1 || strings.Join(c.Peers, ",") != "127.0.0.1:1,127.0.0.1:2" {
+		t.Errorf("first change = %+v; want every key moved to the two peers", c)
+	}
+
+	pool.AddPeers(context.Background(), &gcgrpc.Peers{PeerAddr: []string{"127.0.0.1:3"}})
+	c := next()
+	if c.MovedFraction < 0.2 || c.MovedFraction > 0.5 || len(c.Peers) != 3 {
+		t.Errorf("MovedFraction after adding a third peer = %v with peers %v; want about 1/3", c.MovedFraction, c.Peers)
+	}
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		before, after := c.Owners(key)
+		if before != after && after != "127.0.0.1:3" {
+			t.Errorf("key %s moved from %s to %s; want moves to the new peer only", key, before, after)
+		}
+	}
+
+	// Setting the same peers again moves nothing and is not reported.
+	pool.Set("127.0.0.1:1", "127.0.0.1:2", "127.0.0.1:3")
+	pool.RemovePeers(context.Background(), &gcgrpc.Peers{PeerAddr: []string{"127.0.0.1:3"}})
+	if c := next(); len(c.Peers) != 2 || c.MovedFraction == 0 {
+		t.Errorf("change after removing a peer = %+v", c)
+	}
+}
+
+// flakyPeer is an in-process gcgrpc.PeerServer whose Retrieve fails
+// while fail is set. It counts the requests it receives.
+type flakyPeer struct {
+	gcgrpc.UnimplementedPeerServer
+	fail  int32
+	calls AtomicInt
+}
+
+func (p *flakyPeer) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	p.calls.Add(1)
+	if atomic.LoadInt32(&p.fail) != 0 {
+		return nil, errors.New("simulated error from peer")
+	}
+	return &gcgrpc.RetrieveResponse{Value: []byte("got:" + string(req.Key))}, nil
+}
+
+func TestGRPCPoolCircuitBreaker(t *testing.T) {
+	peer := &flakyPeer{fail: 1}
+	addr, stop := startTestPeer(t, peer)
+	defer stop()
+
+	pool := newGRPCPool("self", &GRPCPoolOptions{
+		CircuitBreaker: &CircuitBreakerOptions{
+			Failures: 3,
+			Window:   time.Minute,
+			Cooldown: 100 * time.Millisecond,
+		},
+	})
+	pool.Set(addr)
+	defer pool.Set()
+
+	get := func() error {
+		getter, ok := pool.PickPeer("key")
+		if !ok {
+			t.Fatal("PickPeer did not nominate the remote peer")
+		}
+		group, key := "group", "key"
+		return getter.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{})
+	}
+
+	// Trip on failures.
+	for i := 0; i < 3; i++ {
+		if err := get(); err == nil || err == ErrCircuitOpen {
+			t.Fatalf("Get #%d returned %v; want peer error", i, err)
+		}
+	}
+
+	// Fast-fail while open, without sending the RPC.
+	if err := get(); err != ErrCircuitOpen {
+		t.Fatalf("Get on open breaker returned %v; want ErrCircuitOpen", err)
+	}
+	if n := peer.calls.Get(); n != 3 {
+		t.Errorf("peer received %d calls; want 3", n)
+	}
+
+	// A failed half-open probe reopens the breaker.
+	time.Sleep(150 * time.Millisecond)
+	if err := get(); err == nil || err == ErrCircuitOpen {
+		t.Fatalf("half-open probe returned %v; want peer error", err)
+	}
+	if err := get(); err != ErrCircuitOpen {
+		t.Fatalf("Get after failed probe returned %v; want ErrCircuitOpen", err)
+	}
+
+	// A successful half-open probe closes the breaker.
+	atomic.StoreInt32(&peer.fail, 0)
+	time.Sleep(150 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("Get #%d after recovery returned %v", i, err)
+		}
+	}
+	if n := peer.calls.Get(); n != 7 {
+		t.Errorf("peer received %d calls; want 7", n)
+	}
+}
+
+func TestGRPCPoolCircuitBreakerBackoff(t *testing.T) {
+	peer := &flakyPeer{fail: 1}
+	addr, stop := startTestPeer(t, peer)
+	defer stop()
+
+	pool := newGRPCPool("self", &GRPCPoolOptions{
+		CircuitBreaker: &CircuitBreakerOptions{
+			Failures:    1,
+			Cooldown:    50 * time.Millisecond,
+			MaxCooldown: 200 * time.Millisecond,
+		},
+	})
+	pool.Set(addr)
+	defer pool.Set()
+
+	get := func() error {
+		getter, _ := pool.PickPeer("key")
+		group, key := "group", "key"
+		return getter.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{})
+	}
+	circuitOpen := func() bool {
+		stats := pool.PeerStats()
+		return len(stats) == 1 && stats[0].CircuitOpen
+	}
+
+	if err := get(); err == nil || err == ErrCircuitOpen {
+		t.Fatalf("first Get returned %v; want peer error", err)
+	}
+	if n := pool.Stats.CircuitTrips.Get(); n != 1 || !circuitOpen() {
+		t.Fatalf("CircuitTrips = %d, CircuitOpen = %v; want 1, true", n, circuitOpen())
+	}
+
+	// The failed probe doubles the cooldown to 100ms.
+	time.Sleep(70 * time.Millisecond)
+	if err := get(); err == nil || err == ErrCircuitOpen {
+		t.Fatalf("half-open probe returned %v; want peer error", err)
+	}
+	time.Sleep(70 * time.Millisecond)
+	if err := get(); err != ErrCircuitOpen {
+		t.Fatalf("Get within the doubled cooldown returned %v; want ErrCircuitOpen", err)
+	}
+
+	atomic.StoreInt32(&peer.fail, 0)
+	time.Sleep(50 * time.Millisecond)
+	if err := get(); err != nil {
+		t.Fatalf("probe after the doubled cooldown returned %v", err)
+	}
+	if circuitOpen() {
+		t.Error("PeerStats reports the breaker open after a successful probe")
+	}
+	if n := peer.calls.Get(); n != 3 {
+		t.Errorf("peer received %d calls; want 3", n)
+	}
+}
+
+// TestGRPCWireCompatibility checks the compatibility contract documented
+// in gcgrpc.proto: unknown fields from newer peers are ignored and
+// absent fields from older peers decode to their zero value.
+func TestGRPCWireCompatibility(t *testing.T) {
+	// A newer peer sends a RetrieveRequest carrying a field we don't know.
+	newer, err := proto.Marshal(&gcgrpc.RetrieveRequest{Group: "group", Key: []byte("key")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer = protowire.AppendTag(newer, 15, protowire.BytesType)
+	newer = protowire.AppendString(newer, "from the future")
+
+	var req gcgrpc.RetrieveRequest
+	if err := proto.Unmarshal(newer, &req); err != nil {
+		t.Fatalf("unknown field caused decode error: %v", err)
+	}
+	if req.Group != "group" || string(req.Key) != "key" {
+		t.Errorf("decoded %v; want group and key preserved", &req)
+	}
+
+	// An older peer doesn't know the field at all and sends nothing.
+	var flush gcgrpc.FlushRequest
+	if err := proto.Unmarshal(nil, &flush); err != nil {
+		t.Fatalf("empty FlushRequest caused decode error: %v", err)
+	}
+	if flush.Group != "" {
+		t.Errorf("absent group decoded as %q; want empty", flush.Group)
+	}
+
+	// A request missing optional fields is answered, not rejected.
+	addr, stop := startTestPeer(t, &echoPeer{})
+	defer stop()
+	pool := newGRPCPool("self", nil)
+	pool.Set(addr)
+	defer pool.Set()
+	getter, _ := pool.PickPeer("")
+	out := &pb.GetResponse{}
+	if err := getter.Get(context.Background(), &pb.GetRequest{}, out); err != nil {
+		t.Fatalf("Get with absent fields failed: %v", err)
+	}
+	if string(out.Value) != "got:" {
+		t.Errorf("got %q; want %q", out.Value, "got:")
+	}
+}
+
+// TestGRPCPoolBufconn wires two pools together over in-memory bufconn
+// listeners and retrieves a key owned by the other pool without opening
+// any real sockets.
+func TestGRPCPoolBufconn(t *testing.T) {
+	const groupName = "TestGRPCPoolBufconn-group"
+	g := newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value:"+key, time.Time{})
+	}), NoPeers{})
+
+	listeners := map[string]*bufconn.Listener{}
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		lis, ok := listeners[addr]
+		if !ok {
+			return nil, fmt.Errorf("no bufconn listener for %q", addr)
+		}
+		return lis.Dial()
+	}
+
+	addrs := []string{"pool-a", "pool-b"}
+	pools := map[string]*GRPCPool{}
+	for _, addr := range addrs {
+		lis := bufconn.Listen(1 << 20)
+		listeners[addr] = lis
+		pools[addr] = newGRPCPool(addr, &GRPCPoolOptions{ContextDialer: dialer})
+		server := grpc.NewServer()
+		gcgrpc.RegisterPeerServer(server, pools[addr])
+		go server.Serve(lis)
+		defer server.Stop()
+	}
+	for _, pool := range pools {
+		pool.Set(addrs...)
+		defer pool.Set()
+	}
+
+	// Find a key pool-a must fetch from pool-b.
+	var key string
+	var peer ProtoGetter
+	for i := 0; ; i++ {
+		key = strconv.Itoa(i)
+		var ok bool
+		if peer, ok = pools["pool-a"].PickPeer(key); ok {
+			break
+		}
+	}
+	if peer.GetURL() != "pool-b" {
+		t.Fatalf("pool-a picked %q; want pool-b", peer.GetURL())
+	}
+
+	name := groupName
+	out := &pb.GetResponse{}
+	if err := peer.Get(context.Background(), &pb.GetRequest{Group: &name, Key: &key}, out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "value:" + key; string(out.Value) != want {
+		t.Errorf("got %q; want %q", out.Value, want)
+	}
+	if n := g.Stats.ServerRequests.Get(); n != 1 {
+		t.Errorf("ServerRequests = %d; want 1", n)
+	}
+}
+
+func TestGRPCPoolGroupNotFound(t *testing.T) {
+	pool := newGRPCPool("self", nil)
+	addr, stop := startTestPeer(t, pool)
+	defer stop()
+
+	_, err := pool.Retrieve(context.Background(), &gcgrpc.RetrieveRequest{Group: "no-such-group", Key: []byte("key")})
+	if code := status.Code(err); code != codes.NotFound {
+		t.Errorf("Retrieve returned code %v; want %v", code, codes.NotFound)
+	}
+
+	pool.Set(addr)
+	defer pool.Set()
+	getter := pool.grpcGetters[addr]
+	group, key := "no-such-group", "key"
+	err = getter.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{})
+	if !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("Get returned %v; want ErrGroupNotFound", err)
+	}
+	if !strings.Contains(err.Error(), "no-such-group") {
+		t.Errorf("Get error %q lost the peer's detail", err)
+	}
+}
+
+func TestGRPCPoolInterceptors(t *testing.T) {
+	const token = "secret"
+	auth := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if v := md.Get("authorization"); len(v) != 1 || v[0] != token {
+			return nil, status.Error(codes.Unauthenticated, "missing token")
+		}
+		return handler(ctx, req)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(auth))
+	gcgrpc.RegisterPeerServer(server, &echoPeer{})
+	go server.Serve(lis)
+	defer server.Stop()
+	addr := lis.Addr().String()
+
+	withToken := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", token)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	group, key := "group", "key"
+	for _, tc := range []struct {
+		name         string
+		interceptors []grpc.UnaryClientInterceptor
+		want         codes.Code
+	}{
+		{"with token", []grpc.UnaryClientInterceptor{withToken}, codes.OK},
+		{"without token", nil, codes.Unauthenticated},
+	} {
+		pool := newGRPCPool("self", &GRPCPoolOptions{UnaryClientInterceptors: tc.interceptors})
+		pool.Set(addr)
+		err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{})
+		pool.Set()
+		if tc.want == codes.OK {
+			if err != nil {
+				t.Errorf("%s: Get failed: %v", tc.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tc.want.String()) {
+			t.Errorf("%s: Get returned %v; want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestGRPCPoolStoreNotOwner(t *testing.T) {
+	const groupName = "TestGRPCPoolStoreNotOwner-group"
+	g := newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("loaded", time.Time{})
+	}), NoPeers{})
+
+	pool := newGRPCPool("peer-a", nil)
+	addr, stop := startTestPeer(t, pool)
+	defer stop()
+	pool.Set("peer-a", "peer-b")
+	defer pool.Set()
+
+	ownedBy := func(owner string) string {
+		for i := 0; ; i++ {
+			if key := strconv.Itoa(i); pool.peers.Get(key) == owner {
+				return key
+			}
+		}
+	}
+
+	client := newGRPCPool("client", nil)
+	client.Set(addr)
+	defer client.Set()
+	getter := client.grpcGetters[addr]
+
+	// Misrouted: peer-a must tell us peer-b owns the key.
+	key := ownedBy("peer-b")
+	err := getter.store(context.Background(), groupName, key, ByteView{s: "stored"}, false)
+	var notOwner *NotOwnerError
+	if !errors.As(err, &notOwner) {
+		t.Fatalf("misrouted Store returned %v; want NotOwnerError", err)
+	}
+	if notOwner.Owner != "peer-b" {
+		t.Errorf("NotOwnerError.Owner = %q; want %q", notOwner.Owner, "peer-b")
+	}
+
+	// Correctly routed: the value lands in peer-a's main cache.
+	key = ownedBy("peer-a")
+	if err := getter.store(context.Background(), groupName, key, ByteView{s: "stored"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := g.mainCache.get(key); !ok || v.String() != "stored" {
+		t.Errorf("main cache has %q, %v; want %q", v.String(), ok, "stored")
+	}
+}
+
+func TestGRPCPoolUpdatePeers(t *testing.T) {
+	pool := newGRPCPool("self", nil)
+	pool.Set("a", "b", "c")
+	defer pool.Set()
+	before := map[string]*grpcGetter{}
+	for addr, getter := range pool.grpcGetters {
+		before[addr] = getter
+	}
+
+	pool.UpdatePeers([]string{"b", "c", "d"})
+
+	if !before["a"].closed {
+		t.Error("connection to a was not closed")
+	}
+	if _, ok := pool.grpcGetters["a"]; ok {
+		t.Error("a is still in the pool")
+	}
+	for _, addr := range []string{"b", "c"} {
+		if pool.grpcGetters[addr] != before[addr] {
+			t.Errorf("getter for %s was replaced", addr)
+		}
+		if before[addr].closed {
+			t.Errorf("connection to %s was closed", addr)
+		}
+	}
+	if pool.grpcGetters["d"] == nil {
+		t.Error("d was not dialed")
+	}
+	if len(pool.grpcGetters) != 3 {
+		t.Errorf("pool has %d getters; want 3", len(pool.grpcGetters))
+	}
+	for i := 0; i < 100; i++ {
+		if owner := pool.peers.Get(strconv.Itoa(i)); owner == "a" {
+			t.Fatalf("key %d still maps to removed peer a", i)
+		}
+	}
+}
+
+func TestGRPCPoolPeerWeights(t *testing.T) {
+	pool := newGRPCPool("self", nil)
+	pool.Set("a?weight=3", "b", "c?weight=0", "d?color=red")
+	defer pool.Set()
+	if len(pool.grpcGetters) != 2 || pool.grpcGetters["a"] == nil || pool.grpcGetters["b"] == nil {
+		t.Fatalf("pool has getters %v; want a and b, without the invalid specs", pool.grpcGetters)
+	}
+	if wa, wb := pool.peers.Weight("a"), pool.peers.Weight("b"); wa != 3 || wb != 1 {
+		t.Errorf("weights of a and b = %d, %d; want 3, 1", wa, wb)
+	}
+
+	a := pool.grpcGetters["a"]
+	pool.UpdatePeers([]string{"a", "b?weight=2"})
+	if pool.grpcGetters["a"] != a || a.closed {
+		t.Error("changing the weight of a replaced its connection")
+	}
+	if wa, wb := pool.peers.Weight("a"), pool.peers.Weight("b"); wa != 1 || wb != 2 {
+		t.Errorf("weights after UpdatePeers = %d, %d; want 1, 2", wa, wb)
+	}
+
+	pool.RemovePeers(context.Background(), &gcgrpc.Peers{PeerAddr: []string{"b?weight=2"}})
+	if _, ok := pool.grpcGetters["b"]; ok || pool.peers.Weight("b") != 0 {
+		t.Error("RemovePeers with a weighted spec left b in the pool")
+	}
+}
+
+func TestGRPCPoolRingTuning(t *testing.T) {
+	// With few virtual nodes some peers own a much larger range of keys.
+	pool := newGRPCPool("self", &GRPCPoolOptions{
+		Replicas:   4,
+		RingTuning: &RingTuningOptions{MinReplicas: 1, MaxReplicas: 64},
+	})
+	pool.Set("self", "a", "b", "c?weight=2")
+	defer pool.Set()
+	opts := pool.ringTuningOptions()
+
+	// spread returns the largest and smallest share of the keys of a peer
+	// relative to its weight, over keys the peers were not tuned for.
+	spread := func(round int) (max, min float64) {
+		owned := map[string]int{}
+		for i := 0; i < 20000; i++ {
+			key := strconv.Itoa(round) + "/" + strconv.Itoa(i)
+			if p, ok := pool.PickPeer(key); ok {
+				owned[p.(*grpcGetter).address]++
+			} else {
+				owned["self"]++
+			}
+		}
+		min = math.Inf(1)
+		for _, peer := range []string{"self", "a", "b", "c"} {
+			share := float64(owned[peer]) / 20000 * 5 / float64(pool.weights[peer])
+			max, min = math.Max(max, share), math.Min(min, share)
+		}
+		return max, min
+	}
+	max, min := spread(0)
+	if max-min < 0.5 {
+		t.Skipf("ring is balanced already: shares from %.2f to %.2f", min, max)
+	}
+	for round := 1; round < 20; round++ {
+		spread(round)
+		pool.tuneRing(opts)
+	}
+	if n := pool.Stats.RingTunings.Get(); n == 0 {
+		t.Fatal("tuneRing never changed the ring")
+	}
+	if tmax, tmin := spread(100); tmax-tmin >= max-min || tmax > 1.5 || tmin < 0.6 {
+		t.Errorf("shares after tuning from %.2f to %.2f; before from %.2f to %.2f", tmin, tmax, min, max)
+	}
+
+	// Too few requests leave the ring alone.
+	pool.tuneRing(opts)
+	n := pool.Stats.RingTunings.Get()
+	pool.PickPeer("key")
+	pool.tuneRing(opts)
+	if pool.Stats.RingTunings.Get() != n {
+		t.Error("tuneRing changed the ring after fewer than MinRequests requests")
+	}
+}
+
+func TestGRPCPoolRingTuningDistribution(t *testing.T) {
+	peer := newGRPCPool("peer", nil)
+	defer peer.Set()
+	addr, stop := startTestPeer(t, peer)
+	defer stop()
+	pool := newGRPCPool("self", &GRPCPoolOptions{
+		Replicas:   4,
+		RingTuning: &RingTuningOptions{MinRequests: 1, MinReplicas: 1, MaxReplicas: 64},
+	})
+	pool.Set("self", addr+"?weight=2")
+	defer pool.Set()
+
+	// Every request went to the remote peer, which gets fewer virtual
+	// nodes.
+	pool.picks[addr] = 1000
+	if !pool.tuneRing(pool.ringTuningOptions()) {
+		t.Fatal("tuneRing did not change the ring")
+	}
+	pool.distributeRing(context.Background())
+
+	if v := peer.TopologyVersion(); v != 1 {
+		t.Errorf("TopologyVersion of the peer = %d; want 1", v)
+	}
+	want, got := pool.Ring(), peer.Ring()
+	if got.Peers[0].Replicas >= 8 || !reflect.DeepEqual(got.Peers, want.Peers) || !reflect.DeepEqual(got.Points, want.Points) {
+		t.Errorf("ring of the peer = %+v; want the tuned %+v", got.Peers, want.Peers)
+	}
+	topology, err := peer.GetTopology(context.Background(), &gcgrpc.GetTopologyRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec, _ := ParsePeerSpec(topology.PeerAddr[0]); spec.Replicas != want.Peers[0].Replicas {
+		t.Errorf("peer spec %s; want %d replicas", topology.PeerAddr[0], want.Peers[0].Replicas)
+	}
+}
+
+func TestGRPCPoolLoadBound(t *testing.T) {
+	pool := newGRPCPool("self", &GRPCPoolOptions{LoadBound: 0.25})
+	pool.Set("a", "b", "c", "d")
+	defer pool.Set()
+
+	const key = "hot"
+	owner, _ := pool.PickPeer(key)
+	if peer, _ := pool.PickPeerForLoad(key); peer != owner {
+		t.Errorf("PickPeerForLoad without load = %v; want the owner %v", peer, owner)
+	}
+
+	// Requests already in flight to the owner push the key elsewhere,
+	// while PickPeer still nominates the owner.
+	ownerGetter := owner.(*grpcGetter)
+	ownerGetter.mu.Lock()
+	ownerGetter.inflight = 10
+	ownerGetter.mu.Unlock()
+	defer func() {
+		ownerGetter.mu.Lock()
+		ownerGetter.inflight = 0
+		ownerGetter.mu.Unlock()
+	}()
+	if peer, ok := pool.PickPeerForLoad(key); ok && peer == owner {
+		t.Error("PickPeerForLoad picked the overloaded owner")
+	}
+	if peer, _ := pool.PickPeer(key); peer != owner {
+		t.Error("PickPeer no longer picks the owner")
+	}
+}
+
+func TestGRPCPoolLoadBoundFallback(t *testing.T) {
+	peers := []*fallbackPeer{{}, {}}
+	var addrs []string
+	for _, p := range peers {
+		addr, stop := startTestPeer(t, p)
+		defer stop()
+		addrs = append(addrs, addr)
+	}
+	pool := newGRPCPool("self", &GRPCPoolOptions{LoadBound: 0.25})
+	pool.Set(addrs...)
+	defer pool.Set()
+
+	key := "hot"
+	owner, _ := pool.PickPeer(key)
+	owner.(*grpcGetter).mu.Lock()
+	owner.(*grpcGetter).inflight = 10
+	owner.(*grpcGetter).mu.Unlock()
+	peer, ok := pool.PickPeerForLoad(key)
+	if !ok || peer.GetURL() == owner.GetURL() {
+		t.Fatalf("PickPeerForLoad = %v, %v; want a peer other than the overloaded owner", peer, ok)
+	}
+
+	// The peer taking the load loads the key itself rather than
+	// forwarding it to the owner.
+	group := "group"
+	var res pb.GetResponse
+	if err := peer.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+		t.Fatal(err)
+	}
+	redirect := peers[0]
+	if addrs[1] == peer.GetURL() {
+		redirect = peers[1]
+	}
+	redirect.mu.Lock()
+	defer redirect.mu.Unlock()
+	if redirect.requests != 1 || redirect.fallback != 1 {
+		t.Errorf("redirected peer got %d requests, %d as fallback; want 1, 1", redirect.requests, redirect.fallback)
+	}
+}
+
+// batchPeer is an in-process gcgrpc.PeerServer that answers
+// RetrieveMulti with "got:" + key and records each batch size.
+type batchPeer struct {
+	gcgrpc.UnimplementedPeerServer
+	mu      sync.Mutex
+	batches []int
+}
+
+func (p *batchPeer) RetrieveMulti(ctx context.Context, req *gcgrpc.RetrieveMultiRequest) (*gcgrpc.RetrieveMultiResponse, error) {
+	p.mu.Lock()
+	p.batches = append(p.batches, len(req.Keys))
+	p.mu.Unlock()
+	res := &gcgrpc.RetrieveMultiResponse{}
+	for _, key := range req.Keys {
+		res.Values = append(res.Values, &gcgrpc.KeyValue{Key: key, Value: []byte("got:" + string(key))})
+	}
+	return res, nil
+}
+
+func TestGRPCPoolGetMultiBatches(t *testing.T) {
+	peer := &batchPeer{}
+	addr, stop := startTestPeer(t, peer)
+	defer stop()
+
+	pool := newGRPCPool("self", &GRPCPoolOptions{MaxBatchKeys: 100, BatchParallelism: 2})
+	pool.Set(addr)
+	defer pool.Set()
+
+	g := newGroup("TestGRPCPoolGetMultiBatches-group", cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return errors.New("local getter called")
+	}), pool)
+
+	keys := testKeys(250)
+	values, err := g.GetMulti(context.Background(), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != len(keys) {
+		t.Fatalf("got %d values; want %d", len(values), len(keys))
+	}
+	for _, key := range keys {
+		if want := "got:" + key; values[key].String() != want {
+			t.Errorf("values[%q] = %q; want %q", key, values[key].String(), want)
+		}
+	}
+
+	sort.Ints(peer.batches)
+	if want := []int{50, 100, 100}; !reflect.DeepEqual(peer.batches, want) {
+		t.Errorf("peer received batches of %v keys; want %v", peer.batches, want)
+	}
+
+	// All values were mirrored in the hot cache, so no more batches.
+	if _, err := g.GetMulti(context.Background(), keys); err != nil {
+		t.Fatal(err)
+	}
+	if len(peer.batches) != 3 {
+		t.Errorf("peer received %d batches after a cached GetMulti; want 3", len(peer.batches))
+	}
+}
+
+func TestGRPCPoolServerBatchParallelism(t *testing.T) {
+	const groupName = "TestGRPCPoolServerBatchParallelism-group"
+	var running, maxRunning int32
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return dest.SetString("got:"+key, time.Time{})
+	}), NoPeers{})
+
+	pool := newGRPCPool("self", &GRPCPoolOptions{ServerBatchParallelism: 3})
+	keys := testKeys(50)
+	req := &gcgrpc.RetrieveMultiRequest{Group: groupName}
+	for _, key := range keys {
+		req.Keys = append(req.Keys, []byte(key))
+	}
+	res, err := pool.RetrieveMulti(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, kv := range res.Values {
+		if want := "got:" + keys[i]; string(kv.Key) != keys[i] || string(kv.Value) != want {
+			t.Errorf("Values[%d] = %q: %q; want %q: %q", i, kv.Key, kv.Value, keys[i], want)
+		}
+	}
+	if max := atomic.LoadInt32(&maxRunning); max > 3 {
+		t.Errorf("%d keys of one RetrieveMulti were loaded at once; want at most 3", max)
+	}
+}
+
+func TestGRPCPoolLookupInvalidation(t *testing.T) {
+	opts := &GRPCPoolOptions{LookupCacheSize: 10}
+	b := newGRPCPool("b", opts)
+	addrB, stop := startTestPeer(t, b)
+	defer stop()
+	b.Set("b")
+	defer b.Set()
+
+	b.PickPeer("key")
+	lookups := func() int {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		return b.lookups.Len()
+	}
+	if n := lookups(); n != 1 {
+		t.Fatalf("b has %d cached lookups; want 1", n)
+	}
+	before := b.Stats.LookupInvalidations.Get()
+
+	a := newGRPCPool("a", opts)
+	a.Set("a", addrB)
+	defer a.Set()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Stats.LookupInvalidations.Get() == before {
+		if time.Now().After(deadline) {
+			t.Fatal("Set on a did not invalidate the lookup cache on b")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := lookups(); n != 0 {
+		t.Errorf("b has %d cached lookups after invalidation; want 0", n)
+	}
+
+	// Without a lookup cache nothing is broadcast.
+	c := newGRPCPool("c", nil)
+	c.Set("c", addrB)
+	defer c.Set()
+	time.Sleep(50 * time.Millisecond)
+	if got, want := b.Stats.LookupInvalidations.Get(), before+1; got != want {
+		t.Errorf("b.Stats.LookupInvalidations = %d; want %d", got, want)
+	}
+}
+
+func TestGRPCPoolMaxServerConcurrency(t *testing.T) {
+	const groupName = "TestGRPCPoolMaxServerConcurrency-group"
+	started := make(chan string)
+	unblock := make(chan bool)
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		started <- key
+		<-unblock
+		return dest.SetString("got:"+key, time.Time{})
+	}), NoPeers{})
+
+	pool := newGRPCPool("self", &GRPCPoolOptions{MaxServerConcurrency: 2})
+	retrieve := func(key string) error {
+		_, err := pool.Retrieve(context.Background(), &gcgrpc.RetrieveRequest{Group: groupName, Key: []byte(key)})
+		return err
+	}
+
+	errs := make(chan error, 2)
+	for _, key := range []string{"a", "b"} {
+		go func(key string) { errs <- retrieve(key) }(key)
+		<-started
+	}
+
+	if err := retrieve("c"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("third concurrent Retrieve error = %v; want code %v", err, codes.ResourceExhausted)
+	}
+	if got := pool.Stats.ServerRejections.Get(); got != 1 {
+		t.Errorf("Stats.ServerRejections = %d; want 1", got)
+	}
+
+	close(unblock)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+
+	// The slots were released, so requests are served again.
+	go func() { <-started }()
+	if err := retrieve("d"); err != nil {
+		t.Errorf("Retrieve after the slots were released: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := pool.Retrieve(ctx, &gcgrpc.RetrieveRequest{Group: groupName, Key: []byte("e")})
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("Retrieve with a canceled context error = %v; want code %v", err, codes.Canceled)
+	}
+}
+
+func TestGRPCPoolMaxServerQueue(t *testing.T) {
+	const groupName = "TestGRPCPoolMaxServerQueue-group"
+	started := make(chan string, 2)
+	unblock := make(chan bool)
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		started <- key
+		<-unblock
+		return dest.SetString("got:"+key, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	pool := newGRPCPool("self", &GRPCPoolOptions{MaxServerConcurrency: 1, MaxServerQueue: 1})
+	retrieve := func(ctx context.Context, key string) error {
+		_, err := pool.Retrieve(ctx, &gcgrpc.RetrieveRequest{Group: groupName, Key: []byte(key)})
+		return err
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- retrieve(context.Background(), "a") }()
+	<-started
+	go func() { errs <- retrieve(context.Background(), "b") }()
+	for pool.Stats.ServerQueued.Get() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := retrieve(context.Background(), "c"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Retrieve with a full queue error = %v; want code %v", err, codes.ResourceExhausted)
+	}
+	if got := pool.Stats.ServerRejections.Get(); got != 1 {
+		t.Errorf("Stats.ServerRejections = %d; want 1", got)
+	}
+
+	close(unblock)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if key := <-started; key != "b" {
+		t.Errorf("second request served = %q; want the queued b", key)
+	}
+}
+
+func TestGRPCPoolServerRateLimit(t *testing.T) {
+	const groupName = "TestGRPCPoolServerRateLimit-group"
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("got:"+key, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	pool := newGRPCPool("self", &GRPCPoolOptions{ServerRateLimit: 0.001, ServerRateBurst: 2})
+	for i, want := range []codes.Code{codes.OK, codes.OK, codes.ResourceExhausted} {
+		_, err := pool.Retrieve(context.Background(), &gcgrpc.RetrieveRequest{Group: groupName, Key: []byte("key")})
+		if status.Code(err) != want {
+			t.Errorf("Retrieve %d error = %v; want code %v", i, err, want)
+		}
+	}
+
+	l := newRateLimiter(10, 0)
+	now := time.Now()
+	var allowed int
+	for i := 0; i < 20; i++ {
+		if l.allow(now) {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("allowed %d requests at once; want a burst of 10", allowed)
+	}
+	if !l.allow(now.Add(100*time.Millisecond)) || l.allow(now.Add(100*time.Millisecond)) {
+		t.Error("100ms at 10/s did not allow exactly one more request")
+	}
+	allowed = 0
+	for i := 0; i < 20; i++ {
+		if l.allow(now.Add(time.Hour)) {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("allowed %d requests after an hour; want the burst of 10", allowed)
+	}
+}
+
+func TestGRPCPoolInternGroupNames(t *testing.T) {
+	const groupName = "TestGRPCPoolInternGroupNames-group"
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("got:"+key, time.Time{})
+	}), NoPeers{})
+	const otherName = "TestGRPCPoolInternGroupNames-other"
+	newGroup(otherName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("other:"+key, time.Time{})
+	}), NoPeers{})
+
+	server := newGRPCPool("server", nil)
+	addr, stop := startTestPeer(t, server)
+	defer stop()
+
+	var mu sync.Mutex
+	var sent []*gcgrpc.RetrieveRequest
+	record := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if r, ok := req.(*gcgrpc.RetrieveRequest); ok {
+			mu.Lock()
+			sent = append(sent, proto.Clone(r).(*gcgrpc.RetrieveRequest))
+			mu.Unlock()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	client := newGRPCPool("client", &GRPCPoolOptions{
+		InternGroupNames:        true,
+		UnaryClientInterceptors: []grpc.UnaryClientInterceptor{record},
+	})
+	client.Set(addr)
+	defer client.Set()
+	getter := client.grpcGetters[addr]
+
+	get := func(key string) {
+		t.Helper()
+		var res pb.GetResponse
+		name := groupName
+		if err := getter.Get(context.Background(), &pb.GetRequest{Group: &name, Key: &key}, &res); err != nil {
+			t.Fatal(err)
+		}
+		if want := "got:" + key; string(res.Value) != want {
+			t.Errorf("Get(%q) = %q; want %q", key, res.Value, want)
+		}
+	}
+
+	get("a")
+	get("b")
+	id, epoch := server.groupIDs.ids[groupName], server.groupIDs.epoch
+	if id == 0 || epoch == 0 {
+		t.Fatal("group was not resolved on the server")
+	}
+	for _, r := range sent {
+		if r.GroupId != id || r.GroupEpoch != epoch || r.Group != "" {
+			t.Errorf("sent group %q id %d epoch %x; want id %d epoch %x only", r.Group, r.GroupId, r.GroupEpoch, id, epoch)
+		}
+	}
+
+	// A restarted server handed the same ID to another group. The new
+	// epoch tells the stale ID apart, so the client falls back to the
+	// name and resolves the ID again instead of reading the other group.
+	server.groupIDs = groupTable{}
+	if otherID, _ := server.groupIDs.id(otherName); otherID != id {
+		t.Fatalf("other group got id %d after restart; want the colliding id %d", otherID, id)
+	}
+	sent = nil
+	get("c")
+	get("d")
+	newEpoch := server.groupIDs.epoch
+	if len(sent) != 3 || sent[1].Group != groupName || sent[2].GroupId != 2 || sent[2].GroupEpoch != newEpoch {
+		t.Errorf("after server restart sent %v; want a stale id, the name, then the new id", sent)
+	}
+	if newEpoch == epoch {
+		t.Errorf("epoch %x was not changed by the restart", epoch)
+	}
+
+	// Unknown groups are reported as such.
+	missing := "TestGRPCPoolInternGroupNames-missing"
+	key := "a"
+	err := getter.Get(context.Background(), &pb.GetRequest{Group: &missing, Key: &key}, &pb.GetResponse{})
+	if !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("Get of an unknown group error = %v; want %v", err, ErrGroupNotFound)
+	}
+}
+
+// wireCounter is a stats.Handler summing the bytes of outgoing messages.
+type wireCounter struct{ n int64 }
+
+func (w *wireCounter) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (w *wireCounter) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if p, ok := s.(*stats.OutPayload); ok {
+		atomic.AddInt64(&w.n, int64(p.WireLength))
+	}
+}
+
+func (w *wireCounter) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (w *wireCounter) HandleConn(context.Context, stats.ConnStats) {}
+
+func BenchmarkGRPCPoolInternGroupNames(b *testing.B) {
+	const groupName = "BenchmarkGRPCPoolInternGroupNames-group-with-a-descriptive-name"
+	if GetGroup(groupName) == nil {
+		newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			return dest.SetString("v", time.Time{})
+		}), NoPeers{})
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	server := grpc.NewServer()
+	gcgrpc.RegisterPeerServer(server, newGRPCPool("server", nil))
+	go server.Serve(lis)
+	defer server.Stop()
+	addr := lis.Addr().String()
+
+	for _, intern := range []bool{false, true} {
+		b.Run(fmt.Sprintf("intern=%v", intern), func(b *testing.B) {
+			var wire wireCounter
+			client := newGRPCPool("client", &GRPCPoolOptions{
+				InternGroupNames: intern,
+				PeerDialOptions:  []grpc.DialOption{grpc.WithInsecure(), grpc.WithStatsHandler(&wire)},
+			})
+			client.Set(addr)
+			defer client.Set()
+			getter := client.grpcGetters[addr]
+
+			name, key := groupName, "k"
+			req := &pb.GetRequest{Group: &name, Key: &key}
+			// Resolve the group ID outside of the measurement.
+			if err := getter.Get(context.Background(), req, &pb.GetResponse{}); err != nil {
+				b.Fatal(err)
+			}
+			atomic.StoreInt64(&wire.n, 0)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := getter.Get(context.Background(), req, &pb.GetResponse{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&wire.n))/float64(b.N), "wire-bytes/op")
+		})
+	}
+}
+
+func TestGRPCPoolVerify(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	pool := newGRPCPool(addr, nil)
+	server := grpc.NewServer()
+	gcgrpc.RegisterPeerServer(server, pool)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pool.Verify(ctx); err != nil {
+		t.Errorf("Verify of a reachable self: %v", err)
+	}
+
+	// A self nobody listens on.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := closed.Addr().String()
+	closed.Close()
+	if err := newGRPCPool(unreachable, nil).Verify(ctx); err == nil || !strings.Contains(err.Error(), "unreachable") {
+		t.Errorf("Verify of unreachable self error = %v; want unreachable", err)
+	}
+
+	// A self served by another pool.
+	otherAddr, stop := startTestPeer(t, newGRPCPool("other:8080", nil))
+	defer stop()
+	if err := newGRPCPool(otherAddr, nil).Verify(ctx); err == nil || !strings.Contains(err.Error(), "other:8080") {
+		t.Errorf("Verify of self served by another pool error = %v; want mention of other:8080", err)
+	}
+}
+
+// replicaPeer is an in-process gcgrpc.PeerServer that keeps replicas
+// sent with Store and serves them to Retrieve.
+type replicaPeer struct {
+	gcgrpc.UnimplementedPeerServer
+	mu     sync.Mutex
+	values map[string][]byte
+	stored chan string
+}
+
+func (p *replicaPeer) Store(ctx context.Context, req *gcgrpc.StoreRequest) (*gcgrpc.Ack, error) {
+	if !req.Replica {
+		return nil, status.Error(codes.FailedPrecondition, "not a replica")
+	}
+	p.mu.Lock()
+	p.values[string(req.Key)] = req.Value
+	p.mu.Unlock()
+	p.stored <- string(req.Key)
+	return &gcgrpc.Ack{}, nil
+}
+
+func (p *replicaPeer) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	value, ok := p.values[string(req.Key)]
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "no replica of [%s]", req.Key)
+	}
+	return &gcgrpc.RetrieveResponse{Value: value}, nil
+}
+
+func TestGRPCPoolReplication(t *testing.T) {
+	const groupName = "TestGRPCPoolReplication-group"
+	replica := &replicaPeer{values: make(map[string][]byte), stored: make(chan string, 1)}
+	replicaAddr, stop := startTestPeer(t, replica)
+	defer stop()
+
+	// The owner is an address nobody will listen on once it is "killed".
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerAddr := lis.Addr().String()
+	lis.Close()
+
+	opts := &GRPCPoolOptions{ReplicationFactor: 2}
+	owner := newGRPCPool(ownerAddr, opts)
+	owner.Set(ownerAddr, replicaAddr)
+	defer owner.Set()
+	var key string
+	for i := 0; ; i++ {
+		if key = strconv.Itoa(i); owner.peers.Get(key) == ownerAddr {
+			break
+		}
+	}
+
+	// The owner loads the key and replicates it.
+	gOwner := newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("owner:"+key, time.Time{})
+	}), owner)
+	var s string
+	if err := gOwner.Get(context.Background(), key, StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-replica.stored:
+		if got != key {
+			t.Errorf("replicated key %q; want %q", got, key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("value was not replicated")
+	}
+
+	// Values Set on the owner are replicated too.
+	if err := gOwner.Set(context.Background(), key, []byte("set:"+key), time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-replica.stored:
+	case <-time.After(5 * time.Second):
+		t.Fatal("value Set on the owner was not replicated")
+	}
+	DeregisterGroup(groupName)
+
+	// Another peer finds the owner gone and is served by the replica
+	// rather than loading the key itself.
+	client := newGRPCPool("client", opts)
+	client.Set(ownerAddr, replicaAddr)
+	defer client.Set()
+	gClient := newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return errors.New("cold reload")
+	}), client)
+	defer DeregisterGroup(groupName)
+	if err := gClient.Get(context.Background(), key, StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "set:" + key; s != want {
+		t.Errorf("Get after the owner was lost = %q; want %q", s, want)
+	}
+}
+
+// prefixPeer is an in-process gcgrpc.PeerServer answering Retrieve with
+// prefix + key.
+type prefixPeer struct {
+	gcgrpc.UnimplementedPeerServer
+	prefix string
+}
+
+func (p *prefixPeer) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	return &gcgrpc.RetrieveResponse{Value: []byte(p.prefix + string(req.Key))}, nil
+}
+
+func TestScopedGRPCPools(t *testing.T) {
+	clusters := []struct {
+		group, prefix string
+	}{
+		{"TestScopedGRPCPools-a", "a:"},
+		{"TestScopedGRPCPools-b", "b:"},
+	}
+	for _, c := range clusters {
+		addr, stop := startTestPeer(t, &prefixPeer{prefix: c.prefix})
+		defer stop()
+
+		// Each cluster has its own pool; creating several must not panic.
+		pool := NewScopedGRPCPool("self-"+c.prefix, grpc.NewServer(), nil)
+		pool.Set(addr)
+		defer pool.Set()
+
+		g := NewGroupOpts(c.group, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			return errors.New("local getter called")
+		}), &GroupOptions{Peers: pool})
+		defer DeregisterGroup(c.group)
+
+		var s string
+		if err := g.Get(context.Background(), "key", StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+		if want := c.prefix + "key"; s != want {
+			t.Errorf("%s: Get = %q; want %q from its own pool", c.group, s, want)
+		}
+	}
+}
+
+// newTestCertificates returns a CA pool and a certificate it signed
+// for 127.0.0.1, usable by both servers and clients.
+func newTestCertificates(t *testing.T) (*x509.CertPool, tls.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "groupcache test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err = x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "groupcache test peer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return pool, tls.Certificate{Certificate: [][]byte{leafDER}, PrivateKey: key}
+}
+
+func TestGRPCPoolTLS(t *testing.T) {
+	roots, cert := newTestCertificates(t)
+	peerTLS := &PeerTLS{
+		ServerCertificates: []tls.Certificate{cert},
+		ClientCertificates: []tls.Certificate{cert},
+		RootCAs:            roots,
+		RequireClientCert:  true,
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(peerTLS.ServerOption())
+	gcgrpc.RegisterPeerServer(server, &echoPeer{})
+	go server.Serve(lis)
+	defer server.Stop()
+	addr := lis.Addr().String()
+
+	get := func(tlsOpts *PeerTLS) error {
+		pool := newGRPCPool("self", &GRPCPoolOptions{TLS: tlsOpts})
+		pool.Set(addr)
+		defer pool.Set()
+		group, key := "group", "key"
+		var res pb.GetResponse
+		if err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+			return err
+		}
+		if string(res.Value) != "got:key" {
+			t.Errorf("Get = %q; want %q", res.Value, "got:key")
+		}
+		return nil
+	}
+
+	if err := get(peerTLS); err != nil {
+		t.Errorf("Get over mutual TLS: %v", err)
+	}
+	if err := get(&PeerTLS{RootCAs: roots}); err == nil {
+		t.Error("Get without a client certificate succeeded; want it rejected")
+	}
+	if err := get(nil); err == nil {
+		t.Error("Get over an insecure connection succeeded; want it rejected")
+	}
+}
+
+func TestGRPCPoolAuth(t *testing.T) {
+	auth := &PeerAuth{Token: "secret", AcceptTokens: []string{"next"}}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(auth.ServerOptions()...)
+	gcgrpc.RegisterPeerServer(server, &echoPeer{})
+	go server.Serve(lis)
+	defer server.Stop()
+	addr := lis.Addr().String()
+
+	get := func(auth *PeerAuth, stream bool) error {
+		pool := newGRPCPool("self", &GRPCPoolOptions{Auth: auth, StreamValues: stream})
+		pool.Set(addr)
+		defer pool.Set()
+		group, key := "group", "key"
+		return pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{})
+	}
+
+	for _, token := range []string{"secret", "next"} {
+		if err := get(&PeerAuth{Token: token}, false); err != nil {
+			t.Errorf("Get with token %q: %v", token, err)
+		}
+	}
+	for _, tt := range []struct {
+		auth   *PeerAuth
+		stream bool
+	}{{nil, false}, {&PeerAuth{Token: "wrong"}, false}, {&PeerAuth{Token: "wrong"}, true}} {
+		if err := get(tt.auth, tt.stream); err == nil || !strings.Contains(err.Error(), "peer token") {
+			t.Errorf("Get with %+v, stream %v = %v; want it rejected", tt.auth, tt.stream, err)
+		}
+	}
+}
+
+func TestGRPCPoolStreamValues(t *testing.T) {
+	const groupName = "TestGRPCPoolStreamValues-group"
+	large := strings.Repeat("x", 5<<20) // above gRPC's 4MB default limit
+	newGroup(groupName, 16<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(large, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	addr, stop := startTestPeer(t, newGRPCPool("server", nil))
+	defer stop()
+
+	get := func(stream bool) ([]byte, error) {
+		pool := newGRPCPool("client", &GRPCPoolOptions{StreamValues: stream})
+		pool.Set(addr)
+		defer pool.Set()
+		group, key := groupName, "key"
+		var res pb.GetResponse
+		err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res)
+		return res.Value, err
+	}
+
+	if _, err := get(false); status.Code(errors.Unwrap(err)) != codes.ResourceExhausted {
+		t.Errorf("Retrieve of a 5MB value error = %v; want code %v", err, codes.ResourceExhausted)
+	}
+	value, err := get(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != large {
+		t.Errorf("streamed value has %d bytes; want %d", len(value), len(large))
+	}
+
+	// Peers without RetrieveStream are sent Retrieve instead.
+	echoAddr, stop := startTestPeer(t, &echoPeer{})
+	defer stop()
+	pool := newGRPCPool("client", &GRPCPoolOptions{StreamValues: true})
+	pool.Set(echoAddr)
+	defer pool.Set()
+	group, key := groupName, "key"
+	var res pb.GetResponse
+	if err := pool.grpcGetters[echoAddr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Value) != "got:key" {
+		t.Errorf("Get from a peer without RetrieveStream = %q; want %q", res.Value, "got:key")
+	}
+}
+
+// oversizedStreamPeer announces a value far larger than it sends.
+type oversizedStreamPeer struct {
+	gcgrpc.UnimplementedPeerServer
+}
+
+func (p *oversizedStreamPeer) RetrieveStream(req *gcgrpc.RetrieveRequest, stream gcgrpc.Peer_RetrieveStreamServer) error {
+	return stream.Send(&gcgrpc.RetrieveChunk{Data: []byte("got:" + string(req.Key)), Size: 1 << 50})
+}
+
+func TestGRPCPoolStreamSizeHint(t *testing.T) {
+	addr, stop := startTestPeer(t, &oversizedStreamPeer{})
+	defer stop()
+
+	pool := newGRPCPool("client", &GRPCPoolOptions{StreamValues: true})
+	pool.Set(addr)
+	defer pool.Set()
+	group, key := "TestGRPCPoolStreamSizeHint-group", "key"
+	var res pb.GetResponse
+	if err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Value) != "got:key" {
+		t.Errorf("streamed value = %q; want %q", res.Value, "got:key")
+	}
+	if cap(res.Value) > maxStreamPrealloc {
+		t.Errorf("buffer of %d bytes allocated for a 7 byte value", cap(res.Value))
+	}
+}
+
+func TestGRPCPoolBinaryKeys(t *testing.T) {
+	const groupName = "TestGRPCPoolBinaryKeys-group"
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("got:"+key, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+	addr, stop := startTestPeer(t, newGRPCPool("server", nil))
+	defer stop()
+
+	pool := newGRPCPool("client", nil)
+	pool.Set(addr)
+	defer pool.Set()
+	getter := pool.grpcGetters[addr]
+
+	// Not valid UTF-8, which a protobuf string field rejects.
+	group, key := groupName, "\xff\x00\xfe"
+	var res pb.GetResponse
+	if err := getter.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if want := "got:" + key; string(res.Value) != want {
+		t.Errorf("Get = %q; want %q", res.Value, want)
+	}
+	values, err := getter.retrieveMulti(context.Background(), groupName, []string{key, "\x80"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 2 || values[key].String() != "got:"+key || values["\x80"].String() != "got:\x80" {
+		t.Errorf("retrieveMulti = %v", values)
+	}
+	if err := getter.Remove(context.Background(), &pb.GetRequest{Group: &group, Key: &key}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGRPCPoolCompression(t *testing.T) {
+	const groupName = "TestGRPCPoolCompression-group"
+	large := strings.Repeat("compressible ", 1000)
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		if key == "small" {
+			return dest.SetString("small", time.Time{})
+		}
+		return dest.SetString(large, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	addr, stop := startTestPeer(t, newGRPCPool("server", &GRPCPoolOptions{Compression: &CompressionOptions{}}))
+	defer stop()
+
+	get := func(opts *GRPCPoolOptions, key string) (string, int64) {
+		t.Helper()
+		pool := newGRPCPool("client", opts)
+		pool.Set(addr)
+		defer pool.Set()
+		group := groupName
+		var res pb.GetResponse
+		if err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+			t.Fatal(err)
+		}
+		return string(res.Value), pool.Stats.BytesReceived.Get()
+	}
+
+	compress := &CompressionOptions{Accept: []string{"unknown", "gzip"}}
+	for _, opts := range []*GRPCPoolOptions{
+		{Compression: compress},
+		{Compression: compress, StreamValues: true},
+	} {
+		value, received := get(opts, "large")
+		if value != large {
+			t.Errorf("StreamValues=%v: got %d bytes; want %d", opts.StreamValues, len(value), len(large))
+		}
+		if received >= int64(len(large))/10 {
+			t.Errorf("StreamValues=%v: received %d bytes for a %d byte value; want it compressed", opts.StreamValues, received, len(large))
+		}
+	}
+
+	// Callers that do not accept compression get the value as it is.
+	if value, received := get(nil, "large"); value != large || received < int64(len(large)) {
+		t.Errorf("without compression got %d bytes in %d on the wire; want %d uncompressed", len(value), received, len(large))
+	}
+	if value, _ := get(&GRPCPoolOptions{Compression: compress}, "small"); value != "small" {
+		t.Errorf("small value = %q; want %q", value, "small")
+	}
+}
+
+func TestGRPCPoolExpire(t *testing.T) {
+	const groupName = "TestGRPCPoolExpire-group"
+	expire := time.Now().Add(time.Hour)
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", expire)
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	addr, stop := startTestPeer(t, newGRPCPool("server", nil))
+	defer stop()
+
+	for _, stream := range []bool{false, true} {
+		pool := newGRPCPool("client", &GRPCPoolOptions{StreamValues: stream})
+		pool.Set(addr)
+		group, key := groupName, "key"
+		var res pb.GetResponse
+		if err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.GetExpire() != expire.UnixNano() {
+			t.Errorf("stream=%v: Get expire = %d; want %d", stream, res.GetExpire(), expire.UnixNano())
+		}
+		pool.Set()
+	}
+}
+
+func TestGRPCPoolRPCStats(t *testing.T) {
+	addr, stop := startTestPeer(t, &echoPeer{})
+	defer stop()
+
+	pool := newGRPCPool("self", nil)
+	pool.Set(addr)
+	defer pool.Set()
+	getter := pool.grpcGetters[addr]
+
+	group, key := "group", "key"
+	if err := getter.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	// echoPeer does not implement Delete.
+	if err := getter.Remove(context.Background(), &pb.GetRequest{Group: &group, Key: &key}); err == nil {
+		t.Fatal("Remove succeeded on a peer without Delete")
+	}
+
+	s := &pool.Stats
+	if s.RPCs.Get() != 2 || s.RPCErrors.Get() != 1 {
+		t.Errorf("Stats counted %d RPCs and %d errors; want 2 and 1", s.RPCs.Get(), s.RPCErrors.Get())
+	}
+	if s.RPCNanos.Get() <= 0 || s.BytesSent.Get() <= 0 || s.BytesReceived.Get() <= 0 {
+		t.Errorf("Stats RPCNanos = %d, BytesSent = %d, BytesReceived = %d; want all positive",
+			s.RPCNanos.Get(), s.BytesSent.Get(), s.BytesReceived.Get())
+	}
+}
+
+func TestGRPCPoolTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	}()
+
+	const groupName = "TestGRPCPoolTracing-group"
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	addr, stop := startTestPeer(t, newGRPCPool("server", nil))
+	defer stop()
+	pool := newGRPCPool("client", nil)
+	pool.Set(addr)
+	defer pool.Set()
+
+	ctx, root := provider.Tracer("test").Start(context.Background(), "root")
+	group, key := groupName, "key"
+	if err := pool.grpcGetters[addr].Get(ctx, &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+
+	names := map[string]bool{}
+	for _, span := range exporter.GetSpans() {
+		if span.SpanContext.TraceID() != root.SpanContext().TraceID() {
+			t.Errorf("span %q has trace ID %s; want %s", span.Name, span.SpanContext.TraceID(), root.SpanContext().TraceID())
+		}
+		names[span.Name] = true
+	}
+	for _, name := range []string{"groupcache.grpcGetter.Get", "groupcache.Get", "groupcache.load", "groupcache.getLocally"} {
+		if !names[name] {
+			t.Errorf("no %q span recorded; got %v", name, names)
+		}
+	}
+}
+
+// chanDiscovery reports the peer lists sent on its channel.
+type chanDiscovery chan []string
+
+func (d chanDiscovery) Watch(ctx context.Context, update func(peers []string)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case peers, ok := <-d:
+			if !ok {
+				return errors.New("discovery closed")
+			}
+			update(peers)
+		}
+	}
+}
+
+func TestGRPCPoolDiscover(t *testing.T) {
+	pool := newGRPCPool("self", nil)
+	defer pool.Set()
+	d := make(chanDiscovery)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Discover(ctx, d, &DiscoveryOptions{IncludeSelf: true})
+		close(done)
+	}()
+
+	peers := func() []string {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		var res []string
+		for peer := range pool.grpcGetters {
+			res = append(res, peer)
+		}
+		sort.Strings(res)
+		return res
+	}
+
+	// Each send on the unbuffered channel returns once the previous
+	// update was handled.
+	d <- []string{"b:1", "a:1", "b:1"}
+	d <- nil // ignored
+	if got, want := peers(), []string{"a:1", "b:1", "self"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("peers after first update = %v; want %v", got, want)
+	}
+	d <- []string{"c:1"}
+	d <- []string{"c:1"}
+	if got, want := peers(), []string{"c:1", "self"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("peers after second update = %v; want %v", got, want)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Discover did not return after ctx was canceled")
+	}
+}
+
+// pingPeer fails Pings while down is set.
+type pingPeer struct {
+	gcgrpc.UnimplementedPeerServer
+	down int32
+}
+
+func (p *pingPeer) Ping(context.Context, *gcgrpc.PingRequest) (*gcgrpc.PingResponse, error) {
+	if atomic.LoadInt32(&p.down) != 0 {
+		return nil, status.Error(codes.Unavailable, "down")
+	}
+	return &gcgrpc.PingResponse{}, nil
+}
+
+func TestGRPCPoolHealthCheck(t *testing.T) {
+	peer := &pingPeer{}
+	addr, stop := startTestPeer(t, peer)
+	defer stop()
+
+	pool := newGRPCPool("self", &GRPCPoolOptions{
+		HealthCheck: &HealthCheckOptions{Interval: 5 * time.Millisecond, Failures: 2},
+	})
+	pool.Set("self", addr)
+	defer pool.Set()
+
+	owned := func() bool {
+		for i := 0; i < 100; i++ {
+			if _, ok := pool.PickPeer(strconv.Itoa(i)); ok {
+				return true
+			}
+		}
+		return false
+	}
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if !owned() {
+		t.Fatal("healthy peer owns no keys")
+	}
+	atomic.StoreInt32(&peer.down, 1)
+	waitFor("ejection", func() bool { return len(pool.EjectedPeers()) == 1 })
+	if owned() {
+		t.Error("ejected peer still owns keys")
+	}
+	if n := pool.Stats.PeerEjections.Get(); n != 1 {
+		t.Errorf("PeerEjections = %d; want 1", n)
+	}
+
+	atomic.StoreInt32(&peer.down, 0)
+	waitFor("restoration", func() bool { return len(pool.EjectedPeers()) == 0 })
+	if !owned() {
+		t.Error("restored peer owns no keys")
+	}
+	if n := pool.Stats.PeerRestorations.Get(); n != 1 {
+		t.Errorf("PeerRestorations = %d; want 1", n)
+	}
+}
+
+// unavailablePeer answers the first failures Retrieves with
+// codes.Unavailable.
+type unavailablePeer struct {
+	gcgrpc.UnimplementedPeerServer
+	failures int64
+	code     codes.Code // of the failures, codes.Unavailable if zero
+	calls    AtomicInt
+}
+
+func (p *unavailablePeer) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	if p.calls.Add(1); p.calls.Get() <= p.failures {
+		if p.code != codes.OK {
+			return nil, status.Error(p.code, "overloaded")
+		}
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+	return &gcgrpc.RetrieveResponse{Value: []byte("got:" + string(req.Key))}, nil
+}
+
+func TestGRPCPoolRetry(t *testing.T) {
+	for _, tc := range []struct {
+		failures, attempts int
+		ok                 bool
+	}{
+		{failures: 2, attempts: 3, ok: true},
+		{failures: 3, attempts: 3, ok: false},
+	} {
+		peer := &unavailablePeer{failures: int64(tc.failures)}
+		addr, stop := startTestPeer(t, peer)
+		pool := newGRPCPool("self", &GRPCPoolOptions{
+			Retry: &RetryOptions{Attempts: tc.attempts, Backoff: time.Millisecond},
+		})
+		pool.Set(addr)
+
+		group, key := "group", "key"
+		err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{})
+		if (err == nil) != tc.ok {
+			t.Errorf("%d failures, %d attempts: Get error = %v; want ok = %v", tc.failures, tc.attempts, err, tc.ok)
+		}
+		if n := peer.calls.Get(); n != int64(tc.attempts) {
+			t.Errorf("%d failures, %d attempts: peer got %d requests; want %d", tc.failures, tc.attempts, n, tc.attempts)
+		}
+		pool.Set()
+		stop()
+	}
+
+	// Errors from the peer's getter are not retried.
+	peer := &flakyPeer{fail: 1}
+	addr, stop := startTestPeer(t, peer)
+	defer stop()
+	pool := newGRPCPool("self", &GRPCPoolOptions{Retry: &RetryOptions{Backoff: time.Millisecond}})
+	pool.Set(addr)
+	defer pool.Set()
+	group, key := "group", "key"
+	if err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{}); err == nil {
+		t.Fatal("Get succeeded on a failing peer")
+	}
+	if n := peer.calls.Get(); n != 1 {
+		t.Errorf("failing peer got %d requests; want 1", n)
+	}
+
+	// Nor are the requests a loaded peer sheds.
+	shedding := &unavailablePeer{failures: 1, code: codes.ResourceExhausted}
+	sheddingAddr, stopShedding := startTestPeer(t, shedding)
+	defer stopShedding()
+	pool.Set(addr, sheddingAddr)
+	if err := pool.grpcGetters[sheddingAddr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{}); status.Code(errors.Unwrap(err)) != codes.ResourceExhausted {
+		t.Errorf("Get from a shedding peer = %v; want code %v", err, codes.ResourceExhausted)
+	}
+	if n := shedding.calls.Get(); n != 1 {
+		t.Errorf("shedding peer got %d requests; want 1", n)
+	}
+}
+
+func TestGRPCPoolKeyNotFound(t *testing.T) {
+	const groupName = "TestGRPCPoolKeyNotFound-group"
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return ErrNotFound
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	addr, stop := startTestPeer(t, newGRPCPool("server", nil))
+	defer stop()
+
+	for _, stream := range []bool{false, true} {
+		pool := newGRPCPool("client", &GRPCPoolOptions{StreamValues: stream, InternGroupNames: true})
+		pool.Set(addr)
+		group, key := groupName, "key"
+		err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{})
+		if !errors.Is(err, ErrNotFound) || errors.Is(err, ErrGroupNotFound) {
+			t.Errorf("stream=%v: Get error = %v; want ErrNotFound", stream, err)
+		}
+		pool.Set()
+	}
+}
+
+// transferPeer is an in-process gcgrpc.PeerServer recording the
+// entries handed off to it with TransferKeys.
+type transferPeer struct {
+	gcgrpc.UnimplementedPeerServer
+	mu      sync.Mutex
+	entries []*gcgrpc.TransferEntry
+}
+
+func (p *transferPeer) TransferKeys(stream gcgrpc.Peer_TransferKeysServer) error {
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&gcgrpc.Ack{})
+		}
+		if err != nil {
+			return err
+		}
+		p.mu.Lock()
+		p.entries = append(p.entries, entry)
+		p.mu.Unlock()
+	}
+}
+
+func TestGRPCPoolDrain(t *testing.T) {
+	const groupName = "TestGRPCPoolDrain-group"
+	peer := &transferPeer{}
+	addr, stop := startTestPeer(t, peer)
+	defer stop()
+
+	pool := newGRPCPool("self", nil)
+	pool.Set("self", addr)
+	defer pool.Set()
+	g := newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value of "+key, time.Now().Add(time.Hour))
+	}), pool)
+
+	var owned []string
+	for i := 0; len(owned) < 3; i++ {
+		if key := strconv.Itoa(i); pool.peers.Get(key) == "self" {
+			var s string
+			if err := g.Get(context.Background(), key, StringSink(&s)); err != nil {
+				t.Fatal(err)
+			}
+			owned = append(owned, key)
+		}
+	}
+	// The first key is hit again, so it is handed off first.
+	var s string
+	g.Get(context.Background(), owned[0], StringSink(&s))
+
+	if err := pool.Drain(context.Background(), &DrainOptions{MaxKeys: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if len(peer.entries) != 2 || string(peer.entries[0].Key) != owned[0] || peer.entries[0].Group != groupName {
+		t.Fatalf("peer was handed %v; want 2 entries starting with %s", peer.entries, owned[0])
+	}
+	if e := peer.entries[0]; string(e.Value) != "value of "+owned[0] || e.Expire == 0 {
+		t.Errorf("entry = %q expiring at %d; want the cached value and expiry", e.Value, e.Expire)
+	}
+	if n := pool.Stats.TransferredKeys.Get(); n != 2 {
+		t.Errorf("TransferredKeys = %d; want 2", n)
+	}
+	DeregisterGroup(groupName)
+
+	// The new owner keeps the entries, but not over values it has.
+	receiver := newGRPCPool("receiver", nil)
+	receiverAddr, stop := startTestPeer(t, receiver)
+	defer stop()
+	g = newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return errors.New("getter called for a handed off key")
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+	second := string(peer.entries[1].Key)
+	g.localSet(second, ByteView{s: "fresh"}, &g.mainCache)
+
+	sender := newGRPCPool("sender", nil)
+	sender.Set(receiverAddr)
+	defer sender.Set()
+	if err := sender.grpcGetters[receiverAddr].transferKeys(context.Background(), peer.entries); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{owned[0]: "value of " + owned[0], second: "fresh"} {
+		if err := g.Get(context.Background(), key, StringSink(&s)); err != nil || s != want {
+			t.Errorf("Get(%s) after the hand-off = %q, %v; want %q", key, s, err, want)
+		}
+	}
+	if n := receiver.Stats.ReceivedKeys.Get(); n != 1 {
+		t.Errorf("ReceivedKeys = %d; want 1", n)
+	}
+}
+
+func TestGRPCPoolNamespace(t *testing.T) {
+	const name = "TestGRPCPoolNamespace-group"
+	getter := func(prefix string) Getter {
+		return GetterFunc(func(_ context.Context, key string, dest Sink) error {
+			return dest.SetString(prefix+key, time.Time{})
+		})
+	}
+	newGroup(name, cacheSize, getter("default:"), NoPeers{})
+	defer DeregisterGroup(name)
+	a := newGroupOpts(name, cacheSize, getter("a:"), NoPeers{}, &GroupOptions{Namespace: "a"})
+	defer DeregisterGroupInNamespace(context.Background(), "a", name)
+	if GetGroup(name) == a || GetGroupInNamespace("a", name) != a || GetGroupInNamespace("b", name) != nil {
+		t.Fatal("groups of the same name in different namespaces are not separate")
+	}
+
+	server := newGRPCPool("server", nil)
+	addr, stop := startTestPeer(t, server)
+	defer stop()
+	for _, ns := range []string{"", "a"} {
+		client := newGRPCPool("client", &GRPCPoolOptions{Namespace: ns})
+		client.Set(addr)
+		want := "default:key"
+		if ns != "" {
+			want = ns + ":key"
+		}
+		// The second Get uses the group ID the first one resolved.
+		for i := 0; i < 2; i++ {
+			group, key := name, "key"
+			out := &pb.GetResponse{}
+			if err := client.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, out); err != nil {
+				t.Fatal(err)
+			}
+			if string(out.Value) != want {
+				t.Errorf("Get %d in namespace %q = %q; want %q", i, ns, out.Value, want)
+			}
+		}
+		client.Set()
+	}
+
+	if got := NamespaceStats("a").Gets; got != a.Stats.Gets.Get() || got == 0 {
+		t.Errorf("NamespaceStats(a).Gets = %d; want %d", got, a.Stats.Gets.Get())
+	}
+}
+
+func TestGRPCPoolForwardMetadata(t *testing.T) {
+	const name = "TestGRPCPoolForwardMetadata-group"
+	seen := make(chan metadata.MD, 1)
+	newGroup(name, cacheSize, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		md, _ := metadata.FromIncomingContext(ctx)
+		seen <- md
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(name)
+
+	opts := &GRPCPoolOptions{ForwardMetadata: []string{"X-Tenant"}}
+	server := newGRPCPool("server", opts)
+	addr, stop := startTestPeer(t, server)
+	defer stop()
+	client := newGRPCPool("client", opts)
+	client.Set(addr)
+	defer client.Set()
+
+	// A Get made while serving an RPC of the client process.
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant", "t1", "x-secret", "s"))
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-other", "o")
+	group, key := name, "key"
+	if err := client.grpcGetters[addr].Get(ctx, &pb.GetRequest{Group: &group, Key: &key}, &pb.GetResponse{}); err != nil {
+		t.Fatal(err)
+	}
+	md := <-seen
+	if got := md.Get("x-tenant"); len(got) != 1 || got[0] != "t1" {
+		t.Errorf("x-tenant = %q; want t1", got)
+	}
+	if len(md) != 1 {
+		t.Errorf("getter metadata = %v; want only x-tenant", md)
+	}
+}
+
+func TestGRPCPoolDeletePrefix(t *testing.T) {
+	const name = "TestGRPCPoolDeletePrefix-group"
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(name)
+	var s string
+	for _, key := range []string{"a:1", "a:2", "b:1"} {
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	addr, stop := startTestPeer(t, newGRPCPool("server", nil))
+	defer stop()
+	client := newGRPCPool("client", nil)
+	client.Set(addr)
+	defer client.Set()
+	if err := client.grpcGetters[addr].RemovePrefix(context.Background(), name, "a:"); err != nil {
+		t.Fatal(err)
+	}
+	if items := g.mainCache.items(); items != 1 {
+		t.Errorf("main cache has %d items after DeletePrefix; want 1", items)
+	}
+	if _, ok := g.Peek("b:1"); !ok {
+		t.Error("key outside the prefix was removed")
+	}
+}
+
+// largePeer is an in-process gcgrpc.PeerServer returning values of 5MB,
+// above gRPC's default limit of 4MB.
+type largePeer struct {
+	gcgrpc.UnimplementedPeerServer
+}
+
+func (*largePeer) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	return &gcgrpc.RetrieveResponse{Value: make([]byte, 5<<20)}, nil
+}
+
+func TestGRPCPoolMaxMsgSize(t *testing.T) {
+	addr, stop := startTestPeer(t, &largePeer{})
+	defer stop()
+	group, key := "TestGRPCPoolMaxMsgSize-group", "key"
+
+	pool := newGRPCPool("client", nil)
+	pool.Set(addr)
+	var res pb.GetResponse
+	err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res)
+	if err == nil || !strings.Contains(err.Error(), "ResourceExhausted") {
+		t.Errorf("Get of a 5MB value with default limits = %v; want code %v", err, codes.ResourceExhausted)
+	}
+	pool.Set()
+
+	pool = newGRPCPool("client", &GRPCPoolOptions{MaxRecvMsgSize: 8 << 20})
+	pool.Set(addr)
+	defer pool.Set()
+	if err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Value) != 5<<20 {
+		t.Errorf("value of %d bytes; want %d", len(res.Value), 5<<20)
+	}
+
+	// The limits of ServerOptions apply to the server.
+	limited := &GRPCPoolOptions{MaxSendMsgSize: 1 << 20}
+	limitedAddr, stopLimited := startTestPeer(t, &largePeer{}, limited.ServerOptions()...)
+	defer stopLimited()
+	pool.Set(addr, limitedAddr)
+	err = pool.grpcGetters[limitedAddr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res)
+	if err == nil || !strings.Contains(err.Error(), "ResourceExhausted") {
+		t.Errorf("Get of a 5MB value from a server sending at most 1MB = %v; want code %v", err, codes.ResourceExhausted)
+	}
+}
+
+func TestGRPCPoolRPCTimeout(t *testing.T) {
+	peer := &blockingPeer{started: make(chan struct{}), release: make(chan struct{})}
+	addr, stop := startTestPeer(t, peer)
+	defer stop()
+	defer close(peer.release)
+
+	pool := newGRPCPool("client", &GRPCPoolOptions{RPCTimeout: 50 * time.Millisecond})
+	pool.Set(addr)
+	defer pool.Set()
+	group, key := "TestGRPCPoolRPCTimeout-group", "key"
+	var res pb.GetResponse
+	start := time.Now()
+	err := pool.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res)
+	if err == nil || !strings.Contains(err.Error(), "DeadlineExceeded") {
+		t.Errorf("Get of a stuck peer = %v; want code %v", err, codes.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Get of a stuck peer took %v; want about the RPCTimeout", d)
+	}
+
+	// Client streams, like that of TransferKeys, are not bounded.
+	intercept := streamTimeout(50 * time.Millisecond)
+	for _, desc := range []*grpc.StreamDesc{{ClientStreams: true}, {ServerStreams: true}} {
+		var bounded bool
+		intercept(context.Background(), desc, nil, "", func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+			_, bounded = ctx.Deadline()
+			return nil, errors.New("not dialed")
+		})
+		if want := !desc.ClientStreams; bounded != want {
+			t.Errorf("stream %+v bounded = %v; want %v", desc, bounded, want)
+		}
+	}
+}
+
+func TestGRPCPoolZones(t *testing.T) {
+	zones := map[string]string{"a:1": "z1", "b:1": "z1", "c:1": "z2", "d:1": "z2"}
+	var specs []string
+	for peer, zone := range zones {
+		specs = append(specs, peer+"?zone="+zone)
+	}
+	pool := newGRPCPool("a:1", &GRPCPoolOptions{ReplicationFactor: 2, Zone: "z1"})
+	pool.Set(specs...)
+	defer pool.Set()
+
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		pool.mu.Lock()
+		replicas := pool.replicaPeers(key, 2)
+		pool.mu.Unlock()
+		if len(replicas) != 2 || zones[replicas[0]] == zones[replicas[1]] {
+			t.Fatalf("replicas of %q = %v; want one in each zone", key, replicas)
+		}
+		owner, replica := replicas[0], replicas[1]
+		want := owner
+		if owner != "a:1" && replica != "a:1" && zones[owner] != "z1" {
+			want = replica
+		}
+		getter, ok := pool.PickPeer(key)
+		if got := "a:1"; ok {
+			got = getter.(*grpcGetter).address
+			if got != want {
+				t.Errorf("PickPeer(%q) = %s; want %s of replicas %v", key, got, want, replicas)
+			}
+		} else if want != "a:1" {
+			t.Errorf("PickPeer(%q) = self; want %s of replicas %v", key, want, replicas)
+		}
+	}
+	if pool.Stats.ZonePicks.Get() == 0 {
+		t.Error("no Get was sent to a replica in the zone of the pool")
+	}
+
+	// Without Zone, Gets go to the owners.
+	plain := newGRPCPool("a:1", &GRPCPoolOptions{ReplicationFactor: 2})
+	plain.Set(specs...)
+	defer plain.Set()
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		if getter, ok := plain.PickPeer(key); ok && getter.(*grpcGetter).address != plain.peers.Get(key) {
+			t.Errorf("PickPeer(%q) without Zone = %s; want the owner", key, getter.(*grpcGetter).address)
+		}
+	}
+}
+
+func TestGRPCPoolTopologyVersion(t *testing.T) {
+	pool := newGRPCPool("a:1", nil)
+	defer pool.Set()
+	ctx := context.Background()
+	topology := func() string {
+		t.Helper()
+		res, err := pool.GetTopology(ctx, &gcgrpc.GetTopologyRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprintf("%d %s", res.Version, strings.Join(res.PeerAddr, ","))
+	}
+
+	pool.SetPeers(ctx, &gcgrpc.Peers{PeerAddr: []string{"a:1", "b:1"}, Version: 2})
+	pool.SetPeers(ctx, &gcgrpc.Peers{PeerAddr: []string{"a:1"}, Version: 1})
+	pool.RemovePeers(ctx, &gcgrpc.Peers{PeerAddr: []string{"b:1"}, Version: 2})
+	if got, want := topology(), "2 a:1,b:1"; got != want {
+		t.Errorf("topology after older and duplicate changes = %q; want %q", got, want)
+	}
+	if n := pool.Stats.StaleTopologies.Get(); n != 2 {
+		t.Errorf("StaleTopologies = %d; want 2", n)
+	}
+
+	pool.AddPeers(ctx, &gcgrpc.Peers{PeerAddr: []string{"c:1?weight=2&zone=z1"}, Version: 3})
+	if got, want := topology(), "3 a:1,b:1,c:1?weight=2&zone=z1"; got != want {
+		t.Errorf("topology after a newer change = %q; want %q", got, want)
+	}
+
+	// Unversioned changes are applied as before.
+	pool.RemovePeers(ctx, &gcgrpc.Peers{PeerAddr: []string{"b:1"}})
+	if got, want := topology(), "3 a:1,c:1?weight=2&zone=z1"; got != want {
+		t.Errorf("topology after an unversioned change = %q; want %q", got, want)
+	}
+	if v := pool.TopologyVersion(); v != 3 {
+		t.Errorf("TopologyVersion = %d; want 3", v)
+	}
+}
+
+func TestGRPCPoolRing(t *testing.T) {
+	pool := newGRPCPool("a:1", nil)
+	defer pool.Set()
+	ctx := context.Background()
+	pool.SetPeers(ctx, &gcgrpc.Peers{PeerAddr: []string{"a:1", "b:1?weight=2", "c:1?zone=z1"}, Version: 4})
+
+	r := pool.Ring()
+	if r.Version != 4 || r.Hash != hashring.HashCRC32 || r.Replicas != defaultReplicas {
+		t.Errorf("ring version, hash, replicas = %d, %s, %d; want 4, %s, %d", r.Version, r.Hash, r.Replicas, hashring.HashCRC32, defaultReplicas)
+	}
+	want := []hashring.Peer{
+		{Addr: "a:1", Weight: 1, Replicas: defaultReplicas},
+		{Addr: "b:1", Weight: 2, Replicas: 2 * defaultReplicas},
+		{Addr: "c:1", Weight: 1, Zone: "z1", Replicas: defaultReplicas},
+	}
+	if !reflect.DeepEqual(r.Peers, want) {
+		t.Errorf("ring peers = %+v; want %+v", r.Peers, want)
+	}
+
+	router, err := hashring.NewRouter(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		key := "key" + strconv.Itoa(i)
+		pool.mu.Lock()
+		owner := pool.peers.Get(key)
+		pool.mu.Unlock()
+		if got := router.Owner(key); got != owner {
+			t.Fatalf("router owner of %s = %s; want %s", key, got, owner)
+		}
+	}
+}
+
+func TestGRPCPoolGetStats(t *testing.T) {
+	const name = "TestGRPCPoolGetStats-group"
+	pool := newGRPCPool("a:1", nil)
+	defer pool.Set()
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), pool)
+	defer DeregisterGroup(name)
+	var s string
+	if err := g.Get(context.Background(), "key", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	res, err := pool.GetStats(ctx, &gcgrpc.GetStatsRequest{Group: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report DebugReport
+	if err := json.Unmarshal(res.Report, &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Groups) != 1 || report.Groups[0].Name != name || report.Groups[0].Stats.Loads != 1 {
+		t.Errorf("stats of %s = %+v; want the group with 1 load", name, report.Groups)
+	}
+	if _, err := pool.GetStats(ctx, &gcgrpc.GetStatsRequest{Group: "no-such-group"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetStats of an unknown group = %v; want NotFound", err)
+	}
+}
@@ -0,0 +1,105 @@
+package groupcache
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// PeerAuth authenticates the RPCs between the peers of a GRPCPool, or
+// the peer updates of an HTTPPool, with a shared token, so clients that
+// can reach the port of a peer cannot read or purge its cache without
+// it. The token is sent in the authorization metadata of every RPC; it
+// travels in the clear unless TLS is set too.
+type PeerAuth struct {
+	// Token is sent by this peer and accepted from other peers.
+	Token string
+
+	// AcceptTokens are accepted from other peers in addition to Token,
+	// so the token can be rotated one peer at a time: first accept the
+	// new token everywhere, then send it, then stop accepting the old
+	// one.
+	AcceptTokens []string
+}
+
+// authMetadataKey is the metadata key the token is sent in.
+const authMetadataKey = "authorization"
+
+// peerServicePrefix starts the full method names of the groupcache
+// peer service, the only RPCs the server interceptors authenticate.
+const peerServicePrefix = "/gcgrpc.Peer/"
+
+// ServerOptions returns the options to pass to grpc.NewServer so the
+// server of the pool rejects groupcache RPCs that do not carry an
+// accepted token with codes.Unauthenticated. Other services registered
+// on the server are not affected.
+func (a *PeerAuth) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if strings.HasPrefix(info.FullMethod, peerServicePrefix) {
+				if err := a.Check(ctx); err != nil {
+					return nil, err
+				}
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if strings.HasPrefix(info.FullMethod, peerServicePrefix) {
+				if err := a.Check(ss.Context()); err != nil {
+					return err
+				}
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// Check returns a codes.Unauthenticated error unless the incoming
+// metadata of ctx carries an accepted token. It is for servers that
+// chain their own interceptors rather than use ServerOptions.
+func (a *PeerAuth) Check(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get(authMetadataKey) {
+		if a.accepts(v) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "groupcache: missing or invalid peer token")
+}
+
+// accepts reports whether the authorization value v carries an
+// accepted token.
+func (a *PeerAuth) accepts(v string) bool {
+	token := strings.TrimPrefix(v, "Bearer ")
+	for _, accepted := range append([]string{a.Token}, a.AcceptTokens...) {
+		if accepted != "" && subtle.ConstantTimeCompare([]byte(token), []byte(accepted)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// dialOption returns the per-RPC credentials sending the token.
+func (a *PeerAuth) dialOption() grpc.DialOption {
+	return grpc.WithPerRPCCredentials(tokenCredentials(a.Token))
+}
+
+// tokenCredentials implements credentials.PerRPCCredentials with a
+// bearer token.
+type tokenCredentials string
+
+var _ credentials.PerRPCCredentials = tokenCredentials("")
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{authMetadataKey: "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity allows the token on insecure connections,
+// which peers on a trusted network may use.
+func (tokenCredentials) RequireTransportSecurity() bool { return false }
@@ -0,0 +1,62 @@
+package groupcache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// PeerTLS is the TLS configuration of the connections between the
+// peers of a GRPCPool.
+type PeerTLS struct {
+	// ServerCertificates are presented by this peer's server.
+	ServerCertificates []tls.Certificate
+
+	// ClientCertificates are presented by this peer when connecting to
+	// other peers, for mutual TLS.
+	// If empty, no client certificate is sent.
+	ClientCertificates []tls.Certificate
+
+	// RootCAs verifies the certificates presented by other peers, both
+	// server certificates and, when RequireClientCert is set, client
+	// certificates.
+	// If nil, the host's root CA set is used.
+	RootCAs *x509.CertPool
+
+	// RequireClientCert makes this peer's server reject connections
+	// that do not present a client certificate signed by RootCAs.
+	RequireClientCert bool
+
+	// ServerName optionally returns the name a peer's certificate is
+	// verified against, for peers addressed by an IP or a name that is
+	// not in their certificate.
+	// If nil, the host part of the peer address is used.
+	ServerName func(peer string) string
+}
+
+// ServerOption returns the option to pass to grpc.NewServer so the
+// server of the pool accepts TLS connections from its peers.
+func (t *PeerTLS) ServerOption() grpc.ServerOption {
+	cfg := &tls.Config{Certificates: t.ServerCertificates}
+	if t.RequireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		cfg.ClientCAs = t.RootCAs
+	}
+	return grpc.Creds(credentials.NewTLS(cfg))
+}
+
+// dialOption returns the transport credentials used to dial peer.
+func (t *PeerTLS) dialOption(peer string) grpc.DialOption {
+	cfg := &tls.Config{
+		Certificates: t.ClientCertificates,
+		RootCAs:      t.RootCAs,
+	}
+	if t.ServerName != nil {
+		cfg.ServerName = t.ServerName(peer)
+	} else if s, err := ParsePeerSpec(peer); err == nil {
+		cfg.ServerName = s.Host()
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(cfg))
+}
@@ -0,0 +1,165 @@
+// Package hashring describes the consistent hash ring of a GRPCPool, as
+// returned by its Ring method, so that L7 routers and clients outside
+// the cluster, possibly written in other languages, can send each
+// request straight to the peer owning its key:
+//
+//	r := pool.Ring()
+//	b, _ := json.Marshal(r) // published to the routers
+//
+//	// In a router:
+//	var r hashring.Ring
+//	json.Unmarshal(b, &r)
+//	router, err := hashring.NewRouter(r, nil)
+//	owner := router.Owner(key)
+//
+// A Ring lists its virtual nodes, so routers need not rebuild it: the
+// owner of a key is the peer of the first point whose Hash is at least
+// the hash of the key, or of the first point if there is none. With
+// HashCRC32 the hash of a key is the CRC-32 (IEEE) checksum of its
+// bytes. Keys are those given to the pool, encoded by the KeyEncoder of
+// their group if it has one; the group name is not part of the hash.
+package hashring
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+)
+
+// Hash function identifiers of a Ring.
+const (
+	// HashCRC32 is the default hash of GRPCPool, crc32.ChecksumIEEE.
+	HashCRC32 = "crc32-ieee"
+
+	// HashCustom is a GRPCPoolOptions.HashFn, which routers must be
+	// given as well.
+	HashCustom = "custom"
+)
+
+// Ring is a serializable description of a consistent hash ring.
+type Ring struct {
+	// Version is the topology version of the pool when the ring was
+	// taken, see GRPCPool.TopologyVersion, so routers can tell an older
+	// ring from a newer one.
+	Version uint64 `json:"version"`
+
+	// Hash identifies the hash function of keys, HashCRC32 or
+	// HashCustom.
+	Hash string `json:"hash"`
+
+	// Replicas is the number of virtual nodes of a peer of weight one,
+	// before any ring tuning.
+	Replicas int `json:"replicas"`
+
+	// Peers are the peers on the ring, sorted by address.
+	Peers []Peer `json:"peers"`
+
+	// Points are the virtual nodes of the ring, sorted by Hash.
+	Points []Point `json:"points"`
+}
+
+// Peer is a peer on a Ring.
+type Peer struct {
+	Addr     string `json:"addr"`
+	Weight   int    `json:"weight"`
+	Zone     string `json:"zone,omitempty"`
+	Replicas int    `json:"replicas"` // virtual nodes of the peer
+}
+
+// Point is a virtual node of a Ring. It owns the hashes of keys above
+// that of the previous point, and the first point also owns those
+// above the last one.
+type Point struct {
+	Hash uint32 `json:"hash"`
+	Peer int    `json:"peer"` // index in Ring.Peers
+}
+
+// Validate returns an error if r is not a well-formed ring.
+func (r *Ring) Validate() error {
+	if r.Hash != HashCRC32 && r.Hash != HashCustom {
+		return fmt.Errorf("hashring: unknown hash function %q", r.Hash)
+	}
+	for i, p := range r.Points {
+		if p.Peer < 0 || p.Peer >= len(r.Peers) {
+			return fmt.Errorf("hashring: point %d refers to peer %d of %d", i, p.Peer, len(r.Peers))
+		}
+		if i > 0 && p.Hash < r.Points[i-1].Hash {
+			return fmt.Errorf("hashring: point %d is out of order", i)
+		}
+	}
+	return nil
+}
+
+// A Router looks up the owners of keys on a Ring. It is safe for
+// concurrent use.
+type Router struct {
+	ring Ring
+	hash func(data []byte) uint32
+}
+
+// NewRouter returns a Router for r. hash is the hash function of a ring
+// using HashCustom and must be nil for HashCRC32. r must not be
+// modified afterwards.
+func NewRouter(r Ring, hash func(data []byte) uint32) (*Router, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	switch {
+	case r.Hash == HashCRC32 && hash == nil:
+		hash = crc32.ChecksumIEEE
+	case r.Hash == HashCustom && hash == nil:
+		return nil, errors.New("hashring: the ring uses a custom hash function, which was not given")
+	case r.Hash == HashCRC32:
+		return nil, errors.New("hashring: a hash function was given for a CRC-32 ring")
+	}
+	return &Router{ring: r, hash: hash}, nil
+}
+
+// Version returns the Version of the ring.
+func (rt *Router) Version() uint64 {
+	return rt.ring.Version
+}
+
+// Owner returns the address of the peer owning key, or "" if the ring
+// is empty.
+func (rt *Router) Owner(key string) string {
+	points := rt.ring.Points
+	if len(points) == 0 {
+		return ""
+	}
+	return rt.ring.Peers[points[rt.search(key)].Peer].Addr
+}
+
+// OwnerN returns up to n distinct peers in the order they follow key on
+// the ring. The first is the one Owner returns, the next ones those a
+// GRPCPool fails over to and replicates to, unless the pool spreads
+// replicas over zones.
+func (rt *Router) OwnerN(key string, n int) []string {
+	points := rt.ring.Points
+	if len(points) == 0 || n <= 0 {
+		return nil
+	}
+	var res []string
+	seen := make(map[int]bool)
+	idx := rt.search(key)
+	for i := 0; i < len(points) && len(res) < n; i++ {
+		peer := points[(idx+i)%len(points)].Peer
+		if !seen[peer] {
+			seen[peer] = true
+			res = append(res, rt.ring.Peers[peer].Addr)
+		}
+	}
+	return res
+}
+
+// search returns the index of the point owning key.
+func (rt *Router) search(key string) int {
+	points := rt.ring.Points
+	hash := rt.hash([]byte(key))
+	idx := sort.Search(len(points), func(i int) bool { return points[i].Hash >= hash })
+	if idx == len(points) {
+		idx = 0
+	}
+	return idx
+}
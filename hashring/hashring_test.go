@@ -0,0 +1,89 @@
+package hashring
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func testRing() Ring {
+	return Ring{
+		Version:  3,
+		Hash:     HashCustom,
+		Replicas: 1,
+		Peers:    []Peer{{Addr: "a", Weight: 1, Replicas: 1}, {Addr: "b", Weight: 2, Replicas: 2}},
+		Points:   []Point{{Hash: 10, Peer: 0}, {Hash: 20, Peer: 1}, {Hash: 30, Peer: 1}},
+	}
+}
+
+// byteHash hashes a key to its first byte.
+func byteHash(data []byte) uint32 {
+	return uint32(data[0])
+}
+
+func TestRouter(t *testing.T) {
+	rt, err := NewRouter(testRing(), byteHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		key  byte
+		want string
+	}{{5, "a"}, {10, "a"}, {11, "b"}, {30, "b"}, {31, "a"}} {
+		if got := rt.Owner(string([]byte{tc.key})); got != tc.want {
+			t.Errorf("Owner(%d) = %q; want %q", tc.key, got, tc.want)
+		}
+	}
+	if got, want := rt.OwnerN(string([]byte{15}), 3), []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OwnerN = %v; want %v", got, want)
+	}
+	if rt.Version() != 3 {
+		t.Errorf("Version = %d; want 3", rt.Version())
+	}
+
+	empty, err := NewRouter(Ring{Hash: HashCRC32}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := empty.Owner("key"); got != "" {
+		t.Errorf("Owner on an empty ring = %q; want none", got)
+	}
+}
+
+func TestRouterErrors(t *testing.T) {
+	if _, err := NewRouter(testRing(), nil); err == nil {
+		t.Error("NewRouter accepted a custom ring without its hash function")
+	}
+	if _, err := NewRouter(Ring{Hash: HashCRC32}, byteHash); err == nil {
+		t.Error("NewRouter accepted a hash function for a CRC-32 ring")
+	}
+	r := testRing()
+	r.Hash = "md5"
+	if err := r.Validate(); err == nil {
+		t.Error("Validate accepted an unknown hash function")
+	}
+	r = testRing()
+	r.Points[0].Peer = 2
+	if err := r.Validate(); err == nil {
+		t.Error("Validate accepted a point of an unknown peer")
+	}
+	r = testRing()
+	r.Points[0].Hash = 40
+	if err := r.Validate(); err == nil {
+		t.Error("Validate accepted unsorted points")
+	}
+}
+
+func TestRingJSON(t *testing.T) {
+	b, err := json.Marshal(testRing())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r Ring
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, testRing()) {
+		t.Errorf("ring after a JSON round trip = %+v; want %+v", r, testRing())
+	}
+}
@@ -0,0 +1,117 @@
+package groupcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// HealthCheckOptions are the configurations of the health checks a
+// GRPCPool runs against its peers.
+type HealthCheckOptions struct {
+	// Interval is the time between two health checks of a peer.
+	// If blank, it defaults to 5 seconds.
+	Interval time.Duration
+
+	// Timeout bounds a single health check.
+	// If blank, it defaults to 1 second.
+	Timeout time.Duration
+
+	// Failures is the number of consecutive failed health checks after
+	// which a peer is ejected from the hash ring.
+	// If blank, it defaults to 3.
+	Failures int
+}
+
+// checkHealth pings the peer of getter every Interval until the getter
+// is closed, ejecting the peer from the ring after Failures failed
+// pings in a row and restoring it on the first ping that succeeds.
+func (gp *GRPCPool) checkHealth(getter *grpcGetter) {
+	opts := *gp.opts.HealthCheck
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	if opts.Failures <= 0 {
+		opts.Failures = 3
+	}
+
+	t := time.NewTicker(opts.Interval)
+	defer t.Stop()
+	var failures int
+	for {
+		select {
+		case <-getter.done:
+			return
+		case <-t.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+		err := getter.ping(ctx)
+		cancel()
+		if err != nil {
+			if failures++; failures == opts.Failures {
+				gp.log().Warn("Ejecting unhealthy peer", "peer", getter.address, "err", err)
+				gp.setEjected(getter, true)
+			}
+			continue
+		}
+		if failures >= opts.Failures {
+			gp.log().Info("Restoring recovered peer", "peer", getter.address)
+			gp.setEjected(getter, false)
+		}
+		failures = 0
+	}
+}
+
+// setEjected removes the peer of getter from the hash ring or adds it
+// back, unless the peer has since been removed from the pool.
+func (gp *GRPCPool) setEjected(getter *grpcGetter, ejected bool) {
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+	peer := getter.address
+	if gp.grpcGetters[peer] != getter || gp.ejected[peer] == ejected {
+		return
+	}
+	if ejected {
+		gp.ejected[peer] = true
+		gp.peers.Remove(peer)
+		gp.Stats.PeerEjections.Add(1)
+	} else {
+		delete(gp.ejected, peer)
+		gp.addToRing(peer)
+		gp.Stats.PeerRestorations.Add(1)
+	}
+	gp.peersChanged()
+}
+
+// EjectedPeers returns the peers currently ejected from the hash ring
+// by failed health checks.
+func (gp *GRPCPool) EjectedPeers() []string {
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+	res := make([]string, 0, len(gp.ejected))
+	for peer := range gp.ejected {
+		res = append(res, peer)
+	}
+	return res
+}
+
+// ping sends a Ping to the peer. Peers that do not support Ping are
+// healthy as long as they answer.
+func (g *grpcGetter) ping(ctx context.Context) error {
+	conn, err := g.begin()
+	if err != nil {
+		return err
+	}
+	defer g.end(conn)
+	_, err = gcgrpc.NewPeerClient(conn).Ping(ctx, &gcgrpc.PingRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	return err
+}
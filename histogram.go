@@ -0,0 +1,122 @@
+package groupcache
+
+import (
+	"math/bits"
+	"time"
+)
+
+// Histograms have exponential buckets from 100µs doubling to about
+// 6.5s, and one more for longer durations.
+const (
+	histogramMin     = 100 * time.Microsecond
+	histogramBuckets = 18
+)
+
+// HistogramBounds returns the upper bounds of the buckets of a
+// Histogram except the last, which counts longer durations.
+func HistogramBounds() []time.Duration {
+	bounds := make([]time.Duration, histogramBuckets-1)
+	for i := range bounds {
+		bounds[i] = histogramMin << i
+	}
+	return bounds
+}
+
+// Histogram counts durations in buckets. It is safe for concurrent use,
+// and its zero value is ready to use.
+type Histogram struct {
+	buckets [histogramBuckets]AtomicInt
+	sum     AtomicInt // nanoseconds
+}
+
+// Observe adds d to the histogram.
+func (h *Histogram) Observe(d time.Duration) {
+	i := 0
+	if d > histogramMin {
+		// The first bound at or above d is histogramMin << i.
+		i = bits.Len64(uint64((d - 1) / histogramMin))
+		if i >= histogramBuckets {
+			i = histogramBuckets - 1
+		}
+	}
+	h.buckets[i].Add(1)
+	h.sum.Add(int64(d))
+}
+
+// Snapshot returns a copy of the counts of h.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	var s HistogramSnapshot
+	for i := range h.buckets {
+		s.Buckets[i] = h.buckets[i].Get()
+	}
+	s.Sum = time.Duration(h.sum.Get())
+	return s
+}
+
+// HistogramSnapshot is a point-in-time copy of a Histogram.
+type HistogramSnapshot struct {
+	// Buckets are the numbers of durations up to each of
+	// HistogramBounds and above the last of them.
+	Buckets [histogramBuckets]int64
+	Sum     time.Duration
+}
+
+// Count returns the number of durations observed.
+func (s HistogramSnapshot) Count() int64 {
+	var n int64
+	for _, c := range s.Buckets {
+		n += c
+	}
+	return n
+}
+
+// Mean returns the average duration, or zero if there is none.
+func (s HistogramSnapshot) Mean() time.Duration {
+	n := s.Count()
+	if n == 0 {
+		return 0
+	}
+	return s.Sum / time.Duration(n)
+}
+
+// Quantile returns an upper bound of the q-quantile of the durations,
+// such as 0.99 for the p99: the bound of the bucket holding it. Beyond
+// the last bound it returns the last bound. It returns zero if there
+// are no durations.
+func (s HistogramSnapshot) Quantile(q float64) time.Duration {
+	n := s.Count()
+	if n == 0 {
+		return 0
+	}
+	rank := int64(q*float64(n) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, c := range s.Buckets[:histogramBuckets-1] {
+		if seen += c; seen >= rank {
+			return histogramMin << i
+		}
+	}
+	return histogramMin << (histogramBuckets - 2)
+}
+
+// Sub returns the durations observed since prev, an earlier snapshot of
+// the same histogram.
+func (s HistogramSnapshot) Sub(prev HistogramSnapshot) HistogramSnapshot {
+	for i := range s.Buckets {
+		s.Buckets[i] -= prev.Buckets[i]
+	}
+	s.Sum -= prev.Sum
+	return s
+}
+
+// Add returns the observations of s and other together, such as those
+// of several groups.
+func (s HistogramSnapshot) Add(other HistogramSnapshot) HistogramSnapshot {
+	for i := range s.Buckets {
+		s.Buckets[i] += other.Buckets[i]
+	}
+	s.Sum += other.Sum
+	return s
+}
@@ -0,0 +1,83 @@
+package groupcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHistogram(t *testing.T) {
+	var h Histogram
+	for _, d := range []time.Duration{
+		0,
+		100 * time.Microsecond,
+		150 * time.Microsecond,
+		time.Millisecond,
+		time.Hour,
+	} {
+		h.Observe(d)
+	}
+	s := h.Snapshot()
+	want := [histogramBuckets]int64{0: 2, 1: 1, 4: 1, histogramBuckets - 1: 1}
+	if s.Buckets != want {
+		t.Errorf("buckets = %v; want %v", s.Buckets, want)
+	}
+	if s.Count() != 5 || s.Sum != time.Hour+1250*time.Microsecond {
+		t.Errorf("count = %d, sum = %v", s.Count(), s.Sum)
+	}
+	bounds := HistogramBounds()
+	if len(bounds) != histogramBuckets-1 || bounds[4] != 1600*time.Microsecond {
+		t.Errorf("bounds = %v", bounds)
+	}
+	for _, tt := range []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0, 100 * time.Microsecond},
+		{0.5, 200 * time.Microsecond},
+		{0.7, 1600 * time.Microsecond},
+		{1, bounds[len(bounds)-1]},
+	} {
+		if got := s.Quantile(tt.q); got != tt.want {
+			t.Errorf("Quantile(%v) = %v; want %v", tt.q, got, tt.want)
+		}
+	}
+
+	h.Observe(time.Millisecond)
+	if d := h.Snapshot().Sub(s); d.Count() != 1 || d.Sum != time.Millisecond || d.Buckets[4] != 1 {
+		t.Errorf("delta = %+v; want one duration of 1ms", d)
+	}
+	if (HistogramSnapshot{}).Quantile(0.99) != 0 || (HistogramSnapshot{}).Mean() != 0 {
+		t.Error("empty histogram has a quantile or mean")
+	}
+}
+
+func TestStatsSnapshotSub(t *testing.T) {
+	const name = "TestStatsSnapshotSub-group"
+	g := newGroup(name, 1<<10, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(name)
+	get := func(key string) {
+		var s string
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	get("a")
+	before := g.Snapshot()
+	get("a")
+	get("b")
+	get("a")
+	d := g.Snapshot().Sub(before)
+	if d.Gets != 3 || d.CacheHits != 2 || d.LocalLoads != 1 || d.LocalLoadLatency.Count() != 1 {
+		t.Errorf("delta = %+v", d)
+	}
+	if r := d.HitRatio(); r < 0.66 || r > 0.67 {
+		t.Errorf("HitRatio = %v; want 2/3", r)
+	}
+	if d.MainCacheItems != 2 {
+		t.Errorf("MainCacheItems of the delta = %d; want the current 2", d.MainCacheItems)
+	}
+}
@@ -0,0 +1,111 @@
+package groupcache
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/policy"
+)
+
+// A HotCachePolicy decides whether a value fetched from a peer is
+// mirrored into the local hot cache.
+type HotCachePolicy interface {
+	// Admit is called each time key has been fetched from its owner
+	// and reports whether the value should be added to the hot cache.
+	Admit(key string) bool
+}
+
+// A HotCachePolicyFunc implements HotCachePolicy with a function.
+type HotCachePolicyFunc func(key string) bool
+
+func (f HotCachePolicyFunc) Admit(key string) bool {
+	return f(key)
+}
+
+// AlwaysAdmission returns a HotCachePolicy that promotes every peer
+// fetch, which is what a group without a HotCachePolicy does.
+func AlwaysAdmission() HotCachePolicy {
+	return HotCachePolicyFunc(func(string) bool { return true })
+}
+
+// NeverAdmission returns a HotCachePolicy that promotes no peer fetch.
+// Values can still be put in the hot cache with Group.Set or by
+// replication; use GroupOptions.DisableHotCache to prevent that too.
+func NeverAdmission() HotCachePolicy {
+	return HotCachePolicyFunc(func(string) bool { return false })
+}
+
+// ProbabilityAdmission returns a HotCachePolicy that promotes each
+// peer fetch with probability p.
+func ProbabilityAdmission(p float64) HotCachePolicy {
+	return HotCachePolicyFunc(func(string) bool {
+		return rand.Float64() < p
+	})
+}
+
+// FrequencyAdmission returns a HotCachePolicy that only promotes a key
+// once it has been fetched from its owner k times within window. This
+// keeps one-off reads of cold keys from evicting genuinely hot ones.
+//
+// Counts are kept for the keys seen during the current window only, so
+// memory use is bounded by the number of distinct keys fetched per
+// window.
+func FrequencyAdmission(k int, window time.Duration) HotCachePolicy {
+	return &frequencyAdmission{k: k, window: window}
+}
+
+type frequencyAdmission struct {
+	k      int
+	window time.Duration
+
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+func (f *frequencyAdmission) Admit(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	if f.counts == nil || now.Sub(f.start) > f.window {
+		f.counts = make(map[string]int)
+		f.start = now
+	}
+	f.counts[key]++
+	if f.counts[key] < f.k {
+		return false
+	}
+	delete(f.counts, key)
+	return true
+}
+
+// SketchAdmission returns a HotCachePolicy that, like
+// FrequencyAdmission, promotes a key once it has been fetched from its
+// owner k times recently, but counts fetches with a policy.Sketch of
+// about counters counters, so memory use does not grow with the number
+// of distinct keys fetched. Counts fade out over time rather than per
+// window, and k is capped at 15. A k below 1 is taken as 1.
+func SketchAdmission(k, counters int) HotCachePolicy {
+	if k > 15 {
+		k = 15
+	}
+	if k < 1 {
+		k = 1
+	}
+	return &sketchAdmission{k: uint8(k), sketch: policy.NewSketch(counters)}
+}
+
+type sketchAdmission struct {
+	k uint8
+
+	mu     sync.Mutex
+	sketch *policy.Sketch
+}
+
+func (s *sketchAdmission) Admit(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sketch.Increment(key)
+	return s.sketch.Estimate(key) >= s.k
+}
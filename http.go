@@ -22,12 +22,15 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/adistroy/groupcache/v3/consistenthash"
+	"github.com/adistroy/groupcache/v3/gcgrpc"
 	pb "github.com/adistroy/groupcache/v3/groupcachepb"
 	"github.com/golang/protobuf/proto"
 )
@@ -36,6 +39,11 @@ const defaultBasePath = "/_groupcache/"
 
 const defaultReplicas = 50
 
+// peersPath is the path below BasePath of the endpoints that change the
+// peers of an HTTPPool with HTTPPoolOptions.PeerUpdates, so its groups
+// must not be named like it.
+const peersPath = "_peers"
+
 // HTTPPool implements PeerPicker for a pool of HTTP peers.
 type HTTPPool struct {
 	// this peer's base URL, e.g. "https://example.net:8000"
@@ -64,7 +72,8 @@ type HTTPPoolOptions struct {
 	HashFn consistenthash.Hash
 
 	// Transport optionally specifies an http.RoundTripper for the client
-	// to use when it makes a request.
+	// to use when it makes a request. Peers on unix sockets are always
+	// sent requests with a transport dialing their socket.
 	// If nil, the client uses http.DefaultTransport.
 	Transport func(context.Context) http.RoundTripper
 
@@ -72,6 +81,26 @@ type HTTPPoolOptions struct {
 	// receives a request.
 	// If nil, uses the http.Request.Context()
 	Context func(*http.Request) context.Context
+
+	// Compression compresses the responses sent to and received from
+	// peers, negotiated per request with the Accept-Encoding and
+	// Content-Encoding headers.
+	// If nil, responses are neither compressed nor asked for compressed.
+	Compression *CompressionOptions
+
+	// PeerUpdates serves endpoints changing the peers of the pool like
+	// the SetPeers, AddPeers and RemovePeers RPCs of GRPCPool: a
+	// gcgrpc.Peers message POSTed to BasePath + "_peers/set",
+	// "_peers/add" or "_peers/remove" with a token of PeerUpdates in an
+	// "Authorization: Bearer" header. The name "_peers" is then reserved
+	// and cannot be used by a group.
+	// If nil, the peers can only be changed with Set, AddPeers and
+	// RemovePeers.
+	PeerUpdates *PeerAuth
+
+	// Logger receives the log messages of the pool.
+	// If nil, the Logger set with SetLogger is used.
+	Logger Logger
 }
 
 // NewHTTPPool initializes an HTTP pool of peers, and registers itself as a PeerPicker.
@@ -95,6 +124,10 @@ func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 	}
 	httpPoolMade = true
 
+	if s, err := ParsePeerSpec(self); err == nil {
+		// Compare against the peer addresses as Set writes them.
+		self = s.Address()
+	}
 	p := &HTTPPool{
 		self:        self,
 		httpGetters: make(map[string]*httpGetter),
@@ -108,6 +141,11 @@ func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 	if p.opts.Replicas == 0 {
 		p.opts.Replicas = defaultReplicas
 	}
+	if p.opts.HashFn != nil {
+		if err := consistenthash.ValidateHash(p.opts.HashFn); err != nil {
+			p.log().Warn("HTTPPoolOptions.HashFn looks degenerate", "err", err)
+		}
+	}
 	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
 
 	RegisterPeerPicker(func() PeerPicker { return p })
@@ -116,19 +154,104 @@ func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 
 // Set updates the pool's list of peers.
 // Each peer value should be a valid base URL,
-// for example "http://example.net:8000".
+// for example "http://example.net:8000", or the address of a unix
+// socket such as "unix:///run/groupcache.sock", optionally followed by
+// a weight as in "http://example.net:8000?weight=3", see PeerSpec.
 func (p *HTTPPool) Set(peers ...string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
-	p.peers.Add(peers...)
 	p.httpGetters = make(map[string]*httpGetter, len(peers))
-	for _, peer := range peers {
-		p.httpGetters[peer] = &httpGetter{
-			getTransport: p.opts.Transport,
-			baseURL:      peer + p.opts.BasePath,
+	for _, spec := range peers {
+		s, err := parseHTTPPeer(spec)
+		if err != nil {
+			p.log().Warn("Ignoring peer", "peer", spec, "err", err)
+			continue
+		}
+		peer := s.Address()
+		if _, dup := p.httpGetters[peer]; !dup {
+			p.httpGetters[peer] = p.newGetter(peer)
+			p.peers.AddWeighted(s.Weight, peer)
+		}
+	}
+}
+
+// AddPeers adds peers to the pool, leaving the others untouched.
+func (p *HTTPPool) AddPeers(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, spec := range peers {
+		s, err := parseHTTPPeer(spec)
+		if err != nil {
+			p.log().Warn("Ignoring peer", "peer", spec, "err", err)
+			continue
+		}
+		peer := s.Address()
+		if _, exists := p.httpGetters[peer]; !exists {
+			p.httpGetters[peer] = p.newGetter(peer)
+			p.peers.AddWeighted(s.Weight, peer)
+		}
+	}
+}
+
+// RemovePeers removes peers from the pool, leaving the others
+// untouched.
+func (p *HTTPPool) RemovePeers(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, spec := range peers {
+		s, err := parseHTTPPeer(spec)
+		if err != nil {
+			continue
+		}
+		peer := s.Address()
+		if _, exists := p.httpGetters[peer]; exists {
+			delete(p.httpGetters, peer)
+			p.peers.Remove(peer)
+		}
+	}
+}
+
+func (p *HTTPPool) log() Logger {
+	return pickLogger(p.opts.Logger)
+}
+
+// parseHTTPPeer parses the spec of an HTTPPool peer, which must be an
+// http or https URL or a unix socket.
+func parseHTTPPeer(spec string) (PeerSpec, error) {
+	s, err := ParsePeerSpec(spec)
+	if err != nil {
+		return PeerSpec{}, err
+	}
+	switch s.Scheme {
+	case "http", "https", "unix", "unix-abstract":
+		return s, nil
+	}
+	return PeerSpec{}, fmt.Errorf("groupcache: invalid peer spec %q: HTTPPool peers must be http, https or unix URLs", spec)
+}
+
+func (p *HTTPPool) newGetter(peer string) *httpGetter {
+	h := &httpGetter{
+		getTransport: p.opts.Transport,
+		baseURL:      peer + p.opts.BasePath,
+		accept:       p.opts.Compression.acceptHeader(),
+	}
+	if s, err := ParsePeerSpec(peer); err == nil && (s.Scheme == "unix" || s.Scheme == "unix-abstract") {
+		// Requests are sent over the socket, whatever their host.
+		socket := s.Endpoint
+		if s.Scheme == "unix-abstract" {
+			socket = "@" + socket
 		}
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		h.name = h.baseURL
+		h.baseURL = "http://localhost" + p.opts.BasePath
+		h.getTransport = func(context.Context) http.RoundTripper { return tr }
 	}
+	return h
 }
 
 // GetAll returns all the peers in the pool
@@ -169,6 +292,10 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	groupName := parts[0]
 	key := parts[1]
+	if groupName == peersPath && p.opts.PeerUpdates != nil {
+		p.servePeers(w, r, key)
+		return
+	}
 
 	// Fetch the value for this group/key.
 	group := GetGroup(groupName)
@@ -191,16 +318,10 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var b []byte
-
-	value := AllocatingByteSliceSink(&b)
-	err := group.Get(ctx, key, value)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	view, err := value.view()
+	var view ByteView
+	start := time.Now()
+	err := group.get(ctx, key, ByteViewSink(&view))
+	group.Stats.ServerLatency.Observe(time.Since(start))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -211,22 +332,69 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Write the value to the response body as a proto message.
-	body, err := proto.Marshal(&pb.GetResponse{Value: b, Expire: &expireNano})
+	// The cached bytes are marshaled without another copy.
+	body, err := proto.Marshal(&pb.GetResponse{Value: view.readOnlyBytes(), Expire: &expireNano})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	encoding, body := p.opts.Compression.compress(r.Header.Get("Accept-Encoding"), body)
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
 	w.Header().Set("Content-Type", "application/x-protobuf")
 	w.Write(body)
 }
 
+// servePeers changes the pool's peers to, or by, the gcgrpc.Peers
+// message posted to an endpoint of HTTPPoolOptions.PeerUpdates.
+func (p *HTTPPool) servePeers(w http.ResponseWriter, r *http.Request, op string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !p.opts.PeerUpdates.accepts(r.Header.Get("Authorization")) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "missing or invalid peer token", http.StatusUnauthorized)
+		return
+	}
+	var update func(peers ...string)
+	switch op {
+	case "set":
+		update = p.Set
+	case "add":
+		update = p.AddPeers
+	case "remove":
+		update = p.RemovePeers
+	default:
+		http.Error(w, "no such peers operation: "+op, http.StatusNotFound)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var peers gcgrpc.Peers
+	if err := proto.Unmarshal(body, &peers); err != nil {
+		http.Error(w, "decoding peers: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	update(peers.PeerAddr...)
+}
+
 type httpGetter struct {
 	getTransport func(context.Context) http.RoundTripper
 	baseURL      string
+	accept       string // Accept-Encoding of requests, if any
+	name         string // GetURL of peers on unix sockets, whose baseURL does not name them
 }
 
 // GetURL
 func (p *httpGetter) GetURL() string {
+	if p.name != "" {
+		return p.name
+	}
 	return p.baseURL
 }
 
@@ -245,6 +413,9 @@ func (h *httpGetter) makeRequest(ctx context.Context, method string, in *pb.GetR
 	if err != nil {
 		return err
 	}
+	if h.accept != "" {
+		req.Header.Set("Accept-Encoding", h.accept)
+	}
 
 	tr := http.DefaultTransport
 	if h.getTransport != nil {
@@ -275,7 +446,11 @@ func (h *httpGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResp
 	if err != nil {
 		return fmt.Errorf("reading response body: %v", err)
 	}
-	err = proto.Unmarshal(b.Bytes(), out)
+	body, err := decompress(res.Header.Get("Content-Encoding"), b.Bytes())
+	if err != nil {
+		return err
+	}
+	err = proto.Unmarshal(body, out)
 	if err != nil {
 		return fmt.Errorf("decoding response body: %v", err)
 	}
@@ -17,6 +17,7 @@ limitations under the License.
 package groupcache
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"flag"
@@ -27,11 +28,18 @@ import (
 	"net/http/httptest"
 	"os"
 	"os/exec"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/adistroy/groupcache/v3/consistenthash"
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+	pb "github.com/adistroy/groupcache/v3/groupcachepb"
+	"github.com/golang/protobuf/proto"
 )
 
 var (
@@ -219,3 +227,161 @@ func awaitAddrReady(t *testing.T, addr string, wg *sync.WaitGroup) {
 		time.Sleep(delay)
 	}
 }
+
+func TestHTTPPoolCompression(t *testing.T) {
+	const groupName = "TestHTTPPoolCompression-group"
+	large := strings.Repeat("compressible ", 1000)
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(large, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	// The pool is built by hand, as NewHTTPPoolOpts may only be called
+	// once per process.
+	p := &HTTPPool{opts: HTTPPoolOptions{BasePath: defaultBasePath, Compression: &CompressionOptions{}}}
+	var encoding string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.ServeHTTP(w, r)
+		encoding = w.Header().Get("Content-Encoding")
+	}))
+	defer ts.Close()
+
+	for _, accept := range []string{"", "gzip"} {
+		// Keep the transport from asking for gzip on its own.
+		tr := &http.Transport{DisableCompression: true}
+		defer tr.CloseIdleConnections()
+		h := &httpGetter{
+			getTransport: func(context.Context) http.RoundTripper { return tr },
+			baseURL:      ts.URL + defaultBasePath,
+			accept:       accept,
+		}
+		group, key := groupName, "key"
+		var res pb.GetResponse
+		if err := h.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+			t.Fatal(err)
+		}
+		if string(res.Value) != large {
+			t.Errorf("accept %q: got %d bytes; want %d", accept, len(res.Value), len(large))
+		}
+		if encoding != accept {
+			t.Errorf("accept %q: response encoding = %q", accept, encoding)
+		}
+	}
+}
+
+func TestHTTPPoolBinaryKeys(t *testing.T) {
+	const groupName = "TestHTTPPoolBinaryKeys-group"
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("got:"+key, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	p := &HTTPPool{opts: HTTPPoolOptions{BasePath: defaultBasePath}}
+	ts := httptest.NewServer(p)
+	defer ts.Close()
+	h := &httpGetter{
+		getTransport: func(context.Context) http.RoundTripper { return http.DefaultTransport },
+		baseURL:      ts.URL + defaultBasePath,
+	}
+
+	group, key := groupName, "\xff\x00/\xfe"
+	var res pb.GetResponse
+	if err := h.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if want := "got:" + key; string(res.Value) != want {
+		t.Errorf("Get = %q; want %q", res.Value, want)
+	}
+}
+
+func TestHTTPPoolPeers(t *testing.T) {
+	const groupName = "TestHTTPPoolPeers-group"
+	g := newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	p := &HTTPPool{
+		self:        "http://self",
+		opts:        HTTPPoolOptions{BasePath: defaultBasePath, Replicas: defaultReplicas, PeerUpdates: &PeerAuth{Token: "secret"}},
+		httpGetters: make(map[string]*httpGetter),
+	}
+	p.peers = consistenthash.New(p.opts.Replicas, nil)
+	ts := httptest.NewServer(p)
+	defer ts.Close()
+
+	send := func(op, token string, peers ...string) int {
+		t.Helper()
+		body, err := proto.Marshal(&gcgrpc.Peers{PeerAddr: peers})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest(http.MethodPost, ts.URL+defaultBasePath+peersPath+"/"+op, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	post := func(op string, peers ...string) {
+		t.Helper()
+		if code := send(op, "secret", peers...); code != http.StatusOK {
+			t.Fatalf("%s: status %d", op, code)
+		}
+	}
+	urls := func() []string {
+		var res []string
+		for _, peer := range p.GetAll() {
+			res = append(res, peer.GetURL())
+		}
+		sort.Strings(res)
+		return res
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if code := send("set", token, "http://evil"); code != http.StatusUnauthorized {
+			t.Errorf("set with token %q: status %d; want %d", token, code, http.StatusUnauthorized)
+		}
+	}
+	post("set", "http://self", "http://a")
+	post("add", "http://b", "http://a")
+	want := []string{"http://a" + defaultBasePath, "http://b" + defaultBasePath, "http://self" + defaultBasePath}
+	if got := urls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("peers after set and add = %v; want %v", got, want)
+	}
+	post("remove", "http://a", "http://b")
+	if got := urls(); len(got) != 1 {
+		t.Errorf("peers after remove = %v; want only self", got)
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := p.PickPeer(strconv.Itoa(i)); ok {
+			t.Fatalf("key %d maps to a removed peer", i)
+		}
+	}
+
+	// Without PeerUpdates, "_peers" is just a group name.
+	p.opts.PeerUpdates = nil
+	if code := send("set", "secret", "http://evil"); code != http.StatusNotFound {
+		t.Errorf("set without PeerUpdates: status %d; want %d", code, http.StatusNotFound)
+	}
+
+	// DELETE clears the key from the group.
+	var s string
+	g.Get(context.Background(), "key", StringSink(&s))
+	h := p.newGetter(ts.URL)
+	group, key := groupName, "key"
+	if err := h.Remove(context.Background(), &pb.GetRequest{Group: &group, Key: &key}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.lookupCache("key"); ok {
+		t.Error("key is still cached after DELETE")
+	}
+}
@@ -0,0 +1,106 @@
+package groupcache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// A KeyEncoder hides the keys of a group, see GroupOptions.KeyEncoder.
+type KeyEncoder interface {
+	// EncodeKey returns the key stored and sent to peers in place of
+	// key. It must always return the same encoding of a key, on every
+	// peer, so the encoded key can be hashed to its owner and cached.
+	EncodeKey(key string) string
+
+	// DecodeKey returns the key encoded as encoded, or an error if
+	// encoded is not a key returned by EncodeKey. The getter of a key
+	// receives it decoded, on whichever peer owns it, so DecodeKey must
+	// invert EncodeKey.
+	DecodeKey(encoded string) (string, error)
+}
+
+// keyCipher is the KeyEncoder returned by NewKeyCipher.
+type keyCipher struct {
+	iv   []byte // HMAC key deriving the nonce of a key
+	aead cipher.AEAD
+}
+
+// NewKeyCipher returns a KeyEncoder encrypting keys with AES-GCM under a
+// key derived from secret, which must be at least 16 bytes long and the
+// same on every peer. The nonce of a key is its HMAC-SHA256 under
+// another key derived from secret, so a key always encrypts to the
+// same URL-safe base64 string, and only processes knowing secret can
+// produce or read encoded keys.
+func NewKeyCipher(secret []byte) (KeyEncoder, error) {
+	if len(secret) < 16 {
+		return nil, errors.New("groupcache: key cipher secret must be at least 16 bytes")
+	}
+	block, err := aes.NewCipher(deriveKey(secret, "groupcache key encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &keyCipher{iv: deriveKey(secret, "groupcache key nonce"), aead: aead}, nil
+}
+
+// deriveKey returns the 32 byte key of secret for purpose.
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func (c *keyCipher) nonce(key []byte) []byte {
+	mac := hmac.New(sha256.New, c.iv)
+	mac.Write(key)
+	return mac.Sum(nil)[:c.aead.NonceSize()]
+}
+
+func (c *keyCipher) EncodeKey(key string) string {
+	nonce := c.nonce([]byte(key))
+	return base64.RawURLEncoding.EncodeToString(c.aead.Seal(nonce, nonce, []byte(key), nil))
+}
+
+func (c *keyCipher) DecodeKey(encoded string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	n := c.aead.NonceSize()
+	if err != nil || len(b) < n+c.aead.Overhead() {
+		return "", errors.New("groupcache: not an encoded key")
+	}
+	key, err := c.aead.Open(nil, b[:n], b[n:], nil)
+	if err != nil || !hmac.Equal(c.nonce(key), b[:n]) {
+		return "", errors.New("groupcache: not an encoded key")
+	}
+	return string(key), nil
+}
+
+// encodeKey returns the key of key in the caches and on the wire. The
+// keys received from peers are encoded already, and are given to the
+// unexported entry points, such as get, instead.
+func (g *Group) encodeKey(key string) string {
+	if g.opts.KeyEncoder == nil {
+		return key
+	}
+	return g.opts.KeyEncoder.EncodeKey(key)
+}
+
+// decodeKey returns the key given to the group for key, the key in the
+// caches.
+func (g *Group) decodeKey(key string) (string, error) {
+	if g.opts.KeyEncoder == nil {
+		return key, nil
+	}
+	decoded, err := g.opts.KeyEncoder.DecodeKey(key)
+	if err != nil {
+		return "", fmt.Errorf("groupcache: decoding key of %s: %w", g.name, err)
+	}
+	return decoded, nil
+}
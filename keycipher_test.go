@@ -0,0 +1,80 @@
+package groupcache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyCipher(t *testing.T) {
+	if _, err := NewKeyCipher([]byte("short")); err == nil {
+		t.Error("NewKeyCipher accepted a 5 byte secret")
+	}
+	c, err := NewKeyCipher([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const key = "alice@example.com"
+	enc := c.EncodeKey(key)
+	if enc == key || strings.Contains(enc, "alice") || enc != c.EncodeKey(key) {
+		t.Errorf("EncodeKey(%q) = %q, then %q; want the same hidden key", key, enc, c.EncodeKey(key))
+	}
+	if got, err := c.DecodeKey(enc); err != nil || got != key {
+		t.Errorf("DecodeKey = %q, %v; want %q", got, err, key)
+	}
+	other, _ := NewKeyCipher([]byte("fedcba9876543210"))
+	for _, s := range []string{key, "", enc[:len(enc)-2], other.EncodeKey(key)} {
+		if _, err := c.DecodeKey(s); err == nil {
+			t.Errorf("DecodeKey(%q) succeeded", s)
+		}
+	}
+}
+
+func TestGroupKeyEncoder(t *testing.T) {
+	const name = "TestGroupKeyEncoder-group"
+	const key = "alice@example.com"
+	c, _ := NewKeyCipher([]byte("0123456789abcdef"))
+	peer := &fakePeer{}
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("local:"+key, time.Time{})
+	}), fakePeers{peer}, &GroupOptions{KeyEncoder: c})
+	defer DeregisterGroup(name)
+
+	// Peers are sent the encoded key.
+	var s string
+	if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil || s != "got:"+c.EncodeKey(key) {
+		t.Errorf("Get from a peer = %q, %v; want got: and the encoded key", s, err)
+	}
+
+	// The getter is given the key, which is cached encoded.
+	g.peers = NoPeers{}
+	if err := g.Get(dummyCtx, "bob@example.com", StringSink(&s)); err != nil || s != "local:bob@example.com" {
+		t.Errorf("local Get = %q, %v; want local:bob@example.com", s, err)
+	}
+	if _, ok := g.Peek("bob@example.com"); !ok {
+		t.Error("Peek of a loaded key found nothing")
+	}
+	for _, e := range g.mainCache.hottest(10) {
+		if strings.Contains(e.key, "example.com") {
+			t.Errorf("key %q cached in clear text", e.key)
+		}
+	}
+
+	// A key received encoded from a peer is not encoded again, while the
+	// keys of callers always are, even if they look encoded.
+	if err := g.get(dummyCtx, c.EncodeKey("bob@example.com"), StringSink(&s)); err != nil || s != "local:bob@example.com" {
+		t.Errorf("get of an encoded key = %q, %v; want local:bob@example.com", s, err)
+	}
+	if err := g.Get(dummyCtx, c.EncodeKey("bob@example.com"), StringSink(&s)); err != nil || s != "local:"+c.EncodeKey("bob@example.com") {
+		t.Errorf("Get of a key looking encoded = %q, %v; want it given to the getter as it is", s, err)
+	}
+
+	values, err := g.GetMulti(dummyCtx, []string{"bob@example.com", "carol@example.com"})
+	if err != nil || values["carol@example.com"].String() != "local:carol@example.com" || len(values) != 2 {
+		t.Errorf("GetMulti = %v, %v; want the values of the keys given", values, err)
+	}
+	if err := g.RemovePrefix(dummyCtx, "bob"); err == nil {
+		t.Error("RemovePrefix of encoded keys succeeded")
+	}
+}
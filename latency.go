@@ -0,0 +1,47 @@
+package groupcache
+
+import (
+	"sync"
+	"time"
+)
+
+// latencyWeight is the weight of each new sample in a latencyAverage.
+const latencyWeight = 0.2
+
+// latencyAverage is an exponentially weighted moving average of the
+// durations of RPCs to a peer.
+type latencyAverage struct {
+	mu      sync.Mutex
+	value   time.Duration // zero until the first sample
+	updated time.Time     // of the last sample or probe
+}
+
+func (a *latencyAverage) observe(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.updated = time.Now()
+	if a.value == 0 {
+		a.value = d
+		return
+	}
+	a.value += time.Duration(latencyWeight * float64(d-a.value))
+}
+
+func (a *latencyAverage) get() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.value
+}
+
+// probe reports whether the average has not been updated for every, in
+// which case a request is due to refresh it. Only one caller is told so
+// per interval.
+func (a *latencyAverage) probe(every time.Duration) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if time.Since(a.updated) < every {
+		return false
+	}
+	a.updated = time.Now()
+	return true
+}
@@ -0,0 +1,117 @@
+package groupcache
+
+import (
+	"time"
+
+	"github.com/adistroy/groupcache/v3/consistenthash"
+)
+
+// ownershipLease is a change of the ring, after which the current peer
+// holds no lease on the keys the change gave it until the time until.
+type ownershipLease struct {
+	prev  *consistenthash.Map // ring before the change
+	until time.Time
+}
+
+// HoldsLease implements LeasePicker. The current peer holds no lease on
+// the keys given it by the changes of the ring of the last
+// OwnershipLease.
+func (gp *GRPCPool) HoldsLease(key string) bool {
+	if gp.opts.OwnershipLease <= 0 {
+		return true
+	}
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+	if len(gp.leases) == 0 || gp.peers.Get(key) != gp.self {
+		return true
+	}
+	now := time.Now()
+	for _, l := range gp.leases {
+		if now.Before(l.until) && l.prev.Get(key) != gp.self {
+			return false
+		}
+	}
+	return true
+}
+
+// changeLeases starts the lease of the keys the latest change of the
+// ring moved to the current peer, and removes the values cached for
+// the keys it moved to or from the current peer in the background. The
+// first peers given to the pool start no lease, as no other peer owned
+// the keys before, so a cold start caches right away. gp.mu must be
+// held.
+func (gp *GRPCPool) changeLeases() {
+	if gp.opts.OwnershipLease <= 0 {
+		return
+	}
+	now := time.Now()
+	kept := gp.leases[:0]
+	for _, l := range gp.leases {
+		if now.Before(l.until) {
+			kept = append(kept, l)
+		}
+	}
+	prev, next := gp.leaseRing, gp.peers.Clone()
+	if len(consistenthash.Diff(prev, next)) == 0 {
+		gp.leases = kept
+		return
+	}
+	gp.leaseRing = next
+	gp.transferred = nil
+	if prev.IsEmpty() {
+		gp.leases = kept
+		return
+	}
+	gp.leases = append(kept, ownershipLease{prev: prev, until: now.Add(gp.opts.OwnershipLease)})
+	go gp.invalidateMoved(prev, next)
+}
+
+// transferredKey is a key of a group received by TransferKeys.
+type transferredKey struct {
+	group *Group
+	key   string
+}
+
+// keepTransferred records that key of group was received from a
+// draining peer, for invalidateMoved to keep it. gp.mu must be held.
+func (gp *GRPCPool) keepTransferred(group *Group, key string) {
+	if gp.opts.OwnershipLease <= 0 {
+		return
+	}
+	if gp.transferred == nil {
+		gp.transferred = make(map[transferredKey]bool)
+	}
+	gp.transferred[transferredKey{group, key}] = true
+}
+
+// invalidateMoved removes the values cached in the main caches of the
+// groups of the pool for the keys owned by the current peer in either
+// prev or next but not both, except those a draining peer handed to it
+// since the change.
+func (gp *GRPCPool) invalidateMoved(prev, next *consistenthash.Map) {
+	mu.RLock()
+	var pooled []*Group
+	for _, g := range groups {
+		pooled = append(pooled, g)
+	}
+	mu.RUnlock()
+
+	for _, g := range pooled {
+		g.peersOnce.Do(g.initPeers)
+		if g.peers != PeerPicker(gp) {
+			continue
+		}
+		for _, key := range g.mainCache.keysWithPrefix("") {
+			if (prev.Get(key) == gp.self) == (next.Get(key) == gp.self) {
+				continue
+			}
+			gp.mu.Lock()
+			transferred := gp.transferred[transferredKey{g, key}]
+			gp.mu.Unlock()
+			if !transferred {
+				g.mainCache.remove(key)
+				gp.Stats.LeaseInvalidations.Add(1)
+			}
+		}
+	}
+}
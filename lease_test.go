@@ -0,0 +1,140 @@
+package groupcache
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/consistenthash"
+)
+
+func TestGRPCPoolOwnershipLease(t *testing.T) {
+	pool := newGRPCPool("a:1", &GRPCPoolOptions{OwnershipLease: 100 * time.Millisecond})
+	defer pool.Set()
+
+	const name = "TestGRPCPoolOwnershipLease-group"
+	var loads AtomicInt
+	tier := &mapTier{values: map[string]ByteView{}}
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads.Add(1)
+		return dest.SetString("value", time.Time{})
+	}), pool, &GroupOptions{TierTwo: tier})
+	defer DeregisterGroup(name)
+	get := func(key string) {
+		t.Helper()
+		var s string
+		if err := g.Get(context.Background(), key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first peers of a pool start no lease, so a cold start caches
+	// right away.
+	pool.Set("a:1")
+	get("key")
+	get("key")
+	if n, unleased := loads.Get(), g.Stats.UnleasedLoads.Get(); n != 1 || unleased != 0 {
+		t.Errorf("Gets after a cold start made %d loads, %d unleased; want 1, 0", n, unleased)
+	}
+
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+		get(keys[i])
+	}
+	pool.Set("a:1", "b:1")
+	pool.mu.Lock()
+	var kept int64
+	var moved, stayed string
+	for _, key := range append(keys, "key") {
+		if pool.peers.Get(key) == "a:1" {
+			kept++
+			stayed = key
+		} else {
+			moved = key
+		}
+	}
+	pool.mu.Unlock()
+	if moved == "" || stayed == "" {
+		t.Fatal("no key moved to b:1 or stayed on a:1")
+	}
+	for deadline := time.Now().Add(5 * time.Second); g.CacheStats(MainCache).Items != kept; {
+		if time.Now().After(deadline) {
+			t.Fatalf("main cache holds %d items; want the %d kept by a:1", g.CacheStats(MainCache).Items, kept)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := pool.Stats.LeaseInvalidations.Get(); n != 21-kept {
+		t.Errorf("LeaseInvalidations = %d; want %d", n, 21-kept)
+	}
+
+	// Keys given back to a:1 are not leased to it yet, unlike those it
+	// kept, and are loaded without caching them, even if the TierTwo
+	// has the copy a:1 stored when it owned them.
+	pool.Set("a:1")
+	if pool.HoldsLease(moved) {
+		t.Errorf("a:1 holds the lease of %s, which it just got back", moved)
+	}
+	if !pool.HoldsLease(stayed) {
+		t.Errorf("a:1 does not hold the lease of %s, which it kept", stayed)
+	}
+	n := loads.Get()
+	get(moved)
+	get(moved)
+	if n, unleased := loads.Get()-n, g.Stats.UnleasedLoads.Get(); n != 2 || unleased != 2 {
+		t.Errorf("Gets within the lease made %d loads, %d unleased; want 2, 2", n, unleased)
+	}
+	if _, ok := g.Peek(moved); ok {
+		t.Errorf("%s was cached within the lease", moved)
+	}
+	time.Sleep(120 * time.Millisecond)
+	n = loads.Get()
+	get(moved)
+	get(moved)
+	if n := loads.Get() - n; n > 1 {
+		t.Errorf("Gets after the lease made %d loads; want at most 1", n)
+	}
+}
+
+func TestGRPCPoolOwnershipLeaseKeepsTransferred(t *testing.T) {
+	pool := newGRPCPool("a:1", &GRPCPoolOptions{OwnershipLease: time.Minute})
+	defer pool.Set()
+	const name = "TestGRPCPoolOwnershipLeaseKeepsTransferred-group"
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), pool)
+	defer DeregisterGroup(name)
+
+	prev := consistenthash.New(defaultReplicas, nil)
+	prev.Add("a:1", "b:1")
+	next := consistenthash.New(defaultReplicas, nil)
+	next.Add("a:1")
+	var transferred, other string
+	for i := 0; transferred == "" || other == ""; i++ {
+		key := strconv.Itoa(i)
+		if prev.Get(key) != "b:1" {
+			continue
+		}
+		if transferred == "" {
+			transferred = key
+		} else {
+			other = key
+		}
+	}
+
+	// Entries a draining b:1 handed to a:1 after the change stay; a
+	// copy from before it does not.
+	pool.mu.Lock()
+	pool.keepTransferred(g, transferred)
+	pool.mu.Unlock()
+	g.localFill(transferred, ByteView{s: "transferred"}, &g.mainCache)
+	g.localFill(other, ByteView{s: "old"}, &g.mainCache)
+	pool.invalidateMoved(prev, next)
+	if _, ok := g.Peek(transferred); !ok {
+		t.Errorf("transferred %s was removed", transferred)
+	}
+	if _, ok := g.Peek(other); ok {
+		t.Errorf("%s cached before the change was kept", other)
+	}
+}
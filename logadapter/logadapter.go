@@ -0,0 +1,42 @@
+// Package logadapter adapts logging libraries to groupcache.Logger.
+//
+//	groupcache.SetLogger(logadapter.Logrus(logrus.WithField("category", "groupcache")))
+package logadapter
+
+import (
+	"fmt"
+
+	"github.com/adistroy/groupcache/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// Logrus returns a groupcache.Logger logging to e, with the key value
+// pairs of every message as logrus fields.
+func Logrus(e *logrus.Entry) groupcache.Logger {
+	return logrusLogger{e}
+}
+
+type logrusLogger struct {
+	e *logrus.Entry
+}
+
+func (l logrusLogger) with(keyvals []interface{}) *logrus.Entry {
+	if len(keyvals) == 0 {
+		return l.e
+	}
+	fields := make(logrus.Fields, len(keyvals)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		if i+1 < len(keyvals) {
+			fields[key] = keyvals[i+1]
+		} else {
+			fields[key] = nil
+		}
+	}
+	return l.e.WithFields(fields)
+}
+
+func (l logrusLogger) Debug(msg string, keyvals ...interface{}) { l.with(keyvals).Debug(msg) }
+func (l logrusLogger) Info(msg string, keyvals ...interface{})  { l.with(keyvals).Info(msg) }
+func (l logrusLogger) Warn(msg string, keyvals ...interface{})  { l.with(keyvals).Warn(msg) }
+func (l logrusLogger) Error(msg string, keyvals ...interface{}) { l.with(keyvals).Error(msg) }
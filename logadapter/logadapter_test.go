@@ -0,0 +1,25 @@
+package logadapter
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLogrus(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	l := Logrus(logrus.NewEntry(logger))
+	l.Warn("peer failed", "peer", "10.0.0.1:8080", "odd")
+
+	e := hook.LastEntry()
+	if e == nil || e.Level != logrus.WarnLevel || e.Message != "peer failed" {
+		t.Fatalf("logged %v; want a warning", e)
+	}
+	if e.Data["peer"] != "10.0.0.1:8080" {
+		t.Errorf("fields = %v; want the peer", e.Data)
+	}
+	if _, ok := e.Data["odd"]; !ok {
+		t.Errorf("fields = %v; want a trailing key without value kept", e.Data)
+	}
+}
@@ -0,0 +1,39 @@
+package groupcache
+
+// Logger receives the log messages of groups and pools. The arguments
+// after the message are alternating keys and values, such as "group",
+// name, "key", key, "peer", addr and "err", err, so a *slog.Logger is a
+// Logger as is. The logadapter package adapts logrus.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+var logger Logger
+
+// SetLogger sets the Logger of the groups and pools that have none in
+// their options. By default nothing is logged.
+func SetLogger(l Logger) {
+	logger = l
+}
+
+// pickLogger returns l, or else the Logger set with SetLogger, or else
+// one discarding everything.
+func pickLogger(l Logger) Logger {
+	if l != nil {
+		return l
+	}
+	if logger != nil {
+		return logger
+	}
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
@@ -93,6 +93,22 @@ func (c *Cache) Get(key Key) (value interface{}, ok bool) {
 	return
 }
 
+// Peek looks up a key's value like Get, but neither counts it as use of
+// the item nor removes it when it has expired.
+func (c *Cache) Peek(key Key) (value interface{}, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		entry := ele.Value.(*entry)
+		if !entry.expire.IsZero() && entry.expire.Before(time.Now()) {
+			return nil, false
+		}
+		return entry.value, true
+	}
+	return
+}
+
 // Remove removes the provided key from the cache.
 func (c *Cache) Remove(key Key) {
 	if c.cache == nil {
@@ -123,6 +139,38 @@ func (c *Cache) removeElement(e *list.Element) {
 	}
 }
 
+// RemoveExpired removes the items that expired before now and returns
+// how many were removed.
+func (c *Cache) RemoveExpired(now time.Time) int {
+	if c.cache == nil {
+		return 0
+	}
+	var n int
+	for e := c.ll.Back(); e != nil; {
+		prev := e.Prev()
+		if kv := e.Value.(*entry); !kv.expire.IsZero() && kv.expire.Before(now) {
+			c.removeElement(e)
+			n++
+		}
+		e = prev
+	}
+	return n
+}
+
+// Range calls fn for every item in the cache, from the most recently
+// used, until fn returns false. It does not count as use of the items.
+func (c *Cache) Range(fn func(key Key, value interface{}) bool) {
+	if c.cache == nil {
+		return
+	}
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		kv := e.Value.(*entry)
+		if !fn(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
 // Len returns the number of items in the cache.
 func (c *Cache) Len() int {
 	if c.cache == nil {
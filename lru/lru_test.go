@@ -121,3 +121,65 @@ func TestExpire(t *testing.T) {
 		}
 	}
 }
+
+func TestRemoveExpired(t *testing.T) {
+	var evicted []Key
+	lru := New(0)
+	lru.OnEvicted = func(key Key, value interface{}) {
+		evicted = append(evicted, key)
+	}
+	now := time.Now()
+	lru.Add("expired", 1, now.Add(-time.Second))
+	lru.Add("live", 2, now.Add(time.Hour))
+	lru.Add("forever", 3, time.Time{})
+
+	if n := lru.RemoveExpired(now); n != 1 {
+		t.Fatalf("RemoveExpired removed %d items; want 1", n)
+	}
+	if lru.Len() != 2 {
+		t.Errorf("cache has %d items; want 2", lru.Len())
+	}
+	if len(evicted) != 1 || evicted[0] != Key("expired") {
+		t.Errorf("evicted %v; want [expired]", evicted)
+	}
+}
+
+func TestRange(t *testing.T) {
+	lru := New(0)
+	for _, key := range []string{"a", "b", "c"} {
+		lru.Add(key, 1234, time.Time{})
+	}
+	lru.Get("a")
+
+	var keys []Key
+	lru.Range(func(key Key, value interface{}) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "c" {
+		t.Errorf("Range listed %v; want [a c]", keys)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	lru := New(0)
+	for _, key := range []string{"a", "b", "c"} {
+		lru.Add(key, "v"+key, time.Time{})
+	}
+	if v, ok := lru.Peek("a"); !ok || v != "va" {
+		t.Errorf("Peek(a) = %v, %v; want va", v, ok)
+	}
+	// Peeking at a did not make it more recently used.
+	lru.RemoveOldest()
+	if _, ok := lru.Peek("a"); ok {
+		t.Error("RemoveOldest kept a, which was only peeked at")
+	}
+
+	lru.Add("old", "vold", time.Now().Add(-time.Second))
+	if _, ok := lru.Peek("old"); ok {
+		t.Error("Peek returned an expired item")
+	}
+	if lru.Len() != 3 {
+		t.Errorf("Len after peeking at an expired item = %d; want 3", lru.Len())
+	}
+}
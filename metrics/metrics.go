@@ -0,0 +1,216 @@
+// Package metrics exports the statistics of groupcache groups and
+// GRPC pools to Prometheus.
+//
+//	c := metrics.NewCollector("groupcache")
+//	c.AddPool("peers", pool)
+//	prometheus.MustRegister(c)
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/adistroy/groupcache/v3"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector is a prometheus.Collector exporting the Stats and
+// CacheStats of every registered group, labeled by group, as
+// "namespace/name" for groups with a namespace, and the GRPCPoolStats
+// of the pools added with AddPool, labeled by pool.
+type Collector struct {
+	mu    sync.Mutex
+	pools map[string]*groupcache.GRPCPool
+
+	groupCounters   []groupCounter
+	groupHistograms []groupHistogram
+	peerLatency     *prometheus.Desc
+
+	cacheBytes, cacheItems               *prometheus.Desc
+	cacheGets, cacheHits, cacheEvictions *prometheus.Desc
+	poolCounters                         []poolCounter
+	poolRPCSeconds                       *prometheus.Desc
+}
+
+type groupCounter struct {
+	desc *prometheus.Desc
+	get  func(s *groupcache.Stats) int64
+}
+
+type groupHistogram struct {
+	desc *prometheus.Desc
+	get  func(s *groupcache.Stats) *groupcache.Histogram
+}
+
+type poolCounter struct {
+	desc *prometheus.Desc
+	get  func(s *groupcache.GRPCPoolStats) int64
+}
+
+// NewCollector returns a Collector whose metric names are prefixed by
+// namespace.
+func NewCollector(namespace string) *Collector {
+	group := func(name, help string, get func(s *groupcache.Stats) int64) groupCounter {
+		return groupCounter{prometheus.NewDesc(prometheus.BuildFQName(namespace, "group", name), help, []string{"group"}, nil), get}
+	}
+	cache := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", name), help, []string{"group", "cache"}, nil)
+	}
+	histogram := func(name, help string, get func(s *groupcache.Stats) *groupcache.Histogram) groupHistogram {
+		return groupHistogram{prometheus.NewDesc(prometheus.BuildFQName(namespace, "group", name), help, []string{"group"}, nil), get}
+	}
+	pool := func(name, help string, get func(s *groupcache.GRPCPoolStats) int64) poolCounter {
+		return poolCounter{prometheus.NewDesc(prometheus.BuildFQName(namespace, "pool", name), help, []string{"pool"}, nil), get}
+	}
+
+	return &Collector{
+		pools: make(map[string]*groupcache.GRPCPool),
+		groupCounters: []groupCounter{
+			group("gets_total", "Get requests, including from peers.", func(s *groupcache.Stats) int64 { return s.Gets.Get() }),
+			group("cache_hits_total", "Get requests served from the main or hot cache.", func(s *groupcache.Stats) int64 { return s.CacheHits.Get() }),
+			group("peer_loads_total", "Values loaded from peers.", func(s *groupcache.Stats) int64 { return s.PeerLoads.Get() }),
+			group("peer_errors_total", "Failed loads from peers.", func(s *groupcache.Stats) int64 { return s.PeerErrors.Get() }),
+			group("loads_total", "Get requests that missed the cache.", func(s *groupcache.Stats) int64 { return s.Loads.Get() }),
+			group("loads_deduped_total", "Loads left after duplicate suppression.", func(s *groupcache.Stats) int64 { return s.LoadsDeduped.Get() }),
+			group("local_loads_total", "Values loaded by the getter.", func(s *groupcache.Stats) int64 { return s.LocalLoads.Get() }),
+			group("local_load_errors_total", "Failed loads by the getter.", func(s *groupcache.Stats) int64 { return s.LocalLoadErrs.Get() }),
+			group("server_requests_total", "Requests received from peers.", func(s *groupcache.Stats) int64 { return s.ServerRequests.Get() }),
+			group("negative_hits_total", "Gets answered by the negative cache.", func(s *groupcache.Stats) int64 { return s.NegativeHits.Get() }),
+			group("stale_hits_total", "Cache hits served past their expiry.", func(s *groupcache.Stats) int64 { return s.StaleHits.Get() }),
+			group("tier_two_hits_total", "Loads answered by the second-tier cache.", func(s *groupcache.Stats) int64 { return s.TierTwoHits.Get() }),
+			group("oversized_bypasses_total", "Oversized values loaded by the getter instead of from their owner.", func(s *groupcache.Stats) int64 { return s.OversizedBypasses.Get() }),
+			group("hedged_loads_total", "Peer loads also sent to a failover peer because the first peer was slow.", func(s *groupcache.Stats) int64 { return s.HedgedLoads.Get() }),
+			group("hedge_wins_total", "Hedged peer loads answered first by the failover peer.", func(s *groupcache.Stats) int64 { return s.HedgeWins.Get() }),
+			group("getter_retries_total", "Getter calls retried after a temporary error.", func(s *groupcache.Stats) int64 { return s.GetterRetries.Get() }),
+			group("permanent_error_hits_total", "Gets answered with a remembered permanent getter error.", func(s *groupcache.Stats) int64 { return s.PermanentErrorHits.Get() }),
+			group("loads_queued_total", "Getter calls that waited for a MaxConcurrentLoads slot.", func(s *groupcache.Stats) int64 { return s.LoadsQueued.Get() }),
+			group("loads_rejected_total", "Getter calls that failed waiting for a MaxConcurrentLoads slot.", func(s *groupcache.Stats) int64 { return s.LoadsRejected.Get() }),
+			group("write_back_flushed_total", "Values persisted by the write-back Flusher.", func(s *groupcache.Stats) int64 { return s.WriteBackFlushed.Get() }),
+			group("write_back_dropped_total", "Values dropped after the write-back Flusher failed.", func(s *groupcache.Stats) int64 { return s.WriteBackDropped.Get() }),
+			group("faults_injected_total", "Faults injected into loads by the FaultInjector of the group.", func(s *groupcache.Stats) int64 { return s.FaultsInjected.Get() }),
+			group("revalidations_total", "Cached values checked against the origin by the Validator.", func(s *groupcache.Stats) int64 { return s.Revalidations.Get() }),
+			group("revalidation_changes_total", "Cached values the Validator found changed at the origin.", func(s *groupcache.Stats) int64 { return s.RevalidationChanges.Get() }),
+			group("unleased_loads_total", "Values loaded as owner but not cached for lack of an ownership lease.", func(s *groupcache.Stats) int64 { return s.UnleasedLoads.Get() }),
+			group("tombstone_hits_total", "Hot cache copies refused for a recently removed key.", func(s *groupcache.Stats) int64 { return s.TombstoneHits.Get() }),
+		},
+		groupHistograms: []groupHistogram{
+			histogram("local_load_seconds", "Duration of the loads by the getter.", func(s *groupcache.Stats) *groupcache.Histogram { return &s.LocalLoadLatency }),
+			histogram("peer_load_seconds", "Duration of the loads from peers.", func(s *groupcache.Stats) *groupcache.Histogram { return &s.PeerLoadLatency }),
+			histogram("server_seconds", "Duration of the Gets served to peers.", func(s *groupcache.Stats) *groupcache.Histogram { return &s.ServerLatency }),
+		},
+		peerLatency: prometheus.NewDesc(prometheus.BuildFQName(namespace, "group", "peer_latency_max_seconds"),
+			"Slowest load from a peer.", []string{"group"}, nil),
+
+		cacheBytes:     cache("bytes", "Bytes of keys and values in the cache."),
+		cacheItems:     cache("items", "Items in the cache."),
+		cacheGets:      cache("gets_total", "Lookups in the cache."),
+		cacheHits:      cache("hits_total", "Lookups that found a value in the cache."),
+		cacheEvictions: cache("evictions_total", "Values evicted from the cache."),
+
+		poolCounters: []poolCounter{
+			pool("rpcs_total", "RPCs sent to peers.", func(s *groupcache.GRPCPoolStats) int64 { return s.RPCs.Get() }),
+			pool("rpc_errors_total", "RPCs sent to peers that failed.", func(s *groupcache.GRPCPoolStats) int64 { return s.RPCErrors.Get() }),
+			pool("sent_bytes_total", "Message bytes sent to peers.", func(s *groupcache.GRPCPoolStats) int64 { return s.BytesSent.Get() }),
+			pool("received_bytes_total", "Message bytes received from peers.", func(s *groupcache.GRPCPoolStats) int64 { return s.BytesReceived.Get() }),
+			pool("idle_closes_total", "Peer connections closed after IdleTimeout.", func(s *groupcache.GRPCPoolStats) int64 { return s.IdleCloses.Get() }),
+			pool("redials_total", "Peer connections dialed again after an idle close.", func(s *groupcache.GRPCPoolStats) int64 { return s.Redials.Get() }),
+			pool("lookup_invalidations_total", "Flushes of the PickPeer lookup cache.", func(s *groupcache.GRPCPoolStats) int64 { return s.LookupInvalidations.Get() }),
+			pool("server_rejections_total", "Inbound requests rejected by MaxServerConcurrency or ServerRateLimit.", func(s *groupcache.GRPCPoolStats) int64 { return s.ServerRejections.Get() }),
+			pool("server_queued_total", "Inbound requests that waited in the MaxServerQueue queue.", func(s *groupcache.GRPCPoolStats) int64 { return s.ServerQueued.Get() }),
+			pool("server_displaced_total", "Queued bulk requests rejected to make room for interactive ones.", func(s *groupcache.GRPCPoolStats) int64 { return s.ServerDisplaced.Get() }),
+			pool("deadline_skips_total", "Gets loaded locally because the peer was too slow for their deadline.", func(s *groupcache.GRPCPoolStats) int64 { return s.DeadlineSkips.Get() }),
+			pool("gossip_rounds_total", "Gossip RPCs sent to peers.", func(s *groupcache.GRPCPoolStats) int64 { return s.GossipRounds.Get() }),
+			pool("gossip_updates_total", "Membership changes learned from gossip.", func(s *groupcache.GRPCPoolStats) int64 { return s.GossipUpdates.Get() }),
+			pool("stale_topologies_total", "Peer list RPCs ignored for an old topology version.", func(s *groupcache.GRPCPoolStats) int64 { return s.StaleTopologies.Get() }),
+			pool("ring_tunings_total", "Adjustments of the virtual nodes of peers by RunRingTuning.", func(s *groupcache.GRPCPoolStats) int64 { return s.RingTunings.Get() }),
+			pool("zone_picks_total", "Gets sent to a replica in the zone of the pool rather than to the owner.", func(s *groupcache.GRPCPoolStats) int64 { return s.ZonePicks.Get() }),
+			pool("circuit_trips_total", "Times the circuit breaker of a peer opened.", func(s *groupcache.GRPCPoolStats) int64 { return s.CircuitTrips.Get() }),
+			pool("lease_invalidations_total", "Cached values removed as their key changed owner.", func(s *groupcache.GRPCPoolStats) int64 { return s.LeaseInvalidations.Get() }),
+			pool("peer_ejections_total", "Peers ejected from the hash ring by health checks.", func(s *groupcache.GRPCPoolStats) int64 { return s.PeerEjections.Get() }),
+			pool("peer_restorations_total", "Ejected peers added back to the hash ring.", func(s *groupcache.GRPCPoolStats) int64 { return s.PeerRestorations.Get() }),
+			pool("transferred_keys_total", "Entries handed off to other peers by Drain.", func(s *groupcache.GRPCPoolStats) int64 { return s.TransferredKeys.Get() }),
+			pool("received_keys_total", "Entries handed off by other peers and kept.", func(s *groupcache.GRPCPoolStats) int64 { return s.ReceivedKeys.Get() }),
+		},
+		poolRPCSeconds: prometheus.NewDesc(prometheus.BuildFQName(namespace, "pool", "rpc_seconds_total"),
+			"Total duration of RPCs sent to peers.", []string{"pool"}, nil),
+	}
+}
+
+// AddPool adds the stats of pool to the collected metrics, labeled
+// with name.
+func (c *Collector) AddPool(name string, pool *groupcache.GRPCPool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pools[name] = pool
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, gc := range c.groupCounters {
+		ch <- gc.desc
+	}
+	for _, gh := range c.groupHistograms {
+		ch <- gh.desc
+	}
+	ch <- c.peerLatency
+	ch <- c.cacheBytes
+	ch <- c.cacheItems
+	ch <- c.cacheGets
+	ch <- c.cacheHits
+	ch <- c.cacheEvictions
+	for _, pc := range c.poolCounters {
+		ch <- pc.desc
+	}
+	ch <- c.poolRPCSeconds
+}
+
+// bounds are the bucket bounds of the exported histograms.
+var bounds = groupcache.HistogramBounds()
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, g := range groupcache.GetGroups() {
+		name := g.Name()
+		if ns := g.Namespace(); ns != "" {
+			// Groups of different namespaces may share a name.
+			name = ns + "/" + name
+		}
+		for _, gc := range c.groupCounters {
+			ch <- prometheus.MustNewConstMetric(gc.desc, prometheus.CounterValue, float64(gc.get(&g.Stats)), name)
+		}
+		for _, gh := range c.groupHistograms {
+			s := gh.get(&g.Stats).Snapshot()
+			buckets := make(map[float64]uint64, len(bounds))
+			var n uint64
+			for i, bound := range bounds {
+				n += uint64(s.Buckets[i])
+				buckets[bound.Seconds()] = n
+			}
+			ch <- prometheus.MustNewConstHistogram(gh.desc, uint64(s.Count()), s.Sum.Seconds(), buckets, name)
+		}
+		latency := time.Duration(g.Stats.GetFromPeersLatencyLower.Get()) * time.Millisecond
+		ch <- prometheus.MustNewConstMetric(c.peerLatency, prometheus.GaugeValue, latency.Seconds(), name)
+
+		for _, cache := range []struct {
+			label string
+			which groupcache.CacheType
+		}{{"main", groupcache.MainCache}, {"hot", groupcache.HotCache}} {
+			s := g.CacheStats(cache.which)
+			ch <- prometheus.MustNewConstMetric(c.cacheBytes, prometheus.GaugeValue, float64(s.Bytes), name, cache.label)
+			ch <- prometheus.MustNewConstMetric(c.cacheItems, prometheus.GaugeValue, float64(s.Items), name, cache.label)
+			ch <- prometheus.MustNewConstMetric(c.cacheGets, prometheus.CounterValue, float64(s.Gets), name, cache.label)
+			ch <- prometheus.MustNewConstMetric(c.cacheHits, prometheus.CounterValue, float64(s.Hits), name, cache.label)
+			ch <- prometheus.MustNewConstMetric(c.cacheEvictions, prometheus.CounterValue, float64(s.Evictions), name, cache.label)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, pool := range c.pools {
+		for _, pc := range c.poolCounters {
+			ch <- prometheus.MustNewConstMetric(pc.desc, prometheus.CounterValue, float64(pc.get(&pool.Stats)), name)
+		}
+		rpcTime := time.Duration(pool.Stats.RPCNanos.Get())
+		ch <- prometheus.MustNewConstMetric(c.poolRPCSeconds, prometheus.CounterValue, rpcTime.Seconds(), name)
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/adistroy/groupcache/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+)
+
+func TestCollector(t *testing.T) {
+	const groupName = "TestCollector-group"
+	g := groupcache.NewGroup(groupName, 1<<20, groupcache.GetterFunc(func(_ context.Context, key string, dest groupcache.Sink) error {
+		return dest.SetString("value", time.Time{})
+	}))
+	defer groupcache.DeregisterGroup(groupName)
+	for i := 0; i < 2; i++ {
+		var s string
+		if err := g.Get(context.Background(), "key", groupcache.StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := NewCollector("groupcache")
+	c.AddPool("peers", groupcache.NewScopedGRPCPool("self", grpc.NewServer(), nil))
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value := func(name string, labels map[string]string) (float64, bool) {
+		for _, f := range families {
+			if f.GetName() != name {
+				continue
+			}
+			for _, m := range f.GetMetric() {
+				if matchLabels(m, labels) {
+					if m.Counter != nil {
+						return m.Counter.GetValue(), true
+					}
+					if m.Histogram != nil {
+						return float64(m.Histogram.GetSampleCount()), true
+					}
+					return m.Gauge.GetValue(), true
+				}
+			}
+		}
+		return 0, false
+	}
+
+	for _, tt := range []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"groupcache_group_gets_total", map[string]string{"group": groupName}, 2},
+		{"groupcache_group_cache_hits_total", map[string]string{"group": groupName}, 1},
+		{"groupcache_group_local_loads_total", map[string]string{"group": groupName}, 1},
+		{"groupcache_group_local_load_seconds", map[string]string{"group": groupName}, 1},
+		{"groupcache_cache_items", map[string]string{"group": groupName, "cache": "main"}, 1},
+		{"groupcache_cache_items", map[string]string{"group": groupName, "cache": "hot"}, 0},
+		{"groupcache_pool_rpcs_total", map[string]string{"pool": "peers"}, 0},
+	} {
+		got, ok := value(tt.name, tt.labels)
+		if !ok {
+			t.Errorf("%s%v is not exported", tt.name, tt.labels)
+		} else if got != tt.want {
+			t.Errorf("%s%v = %v; want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+}
+
+func matchLabels(m *dto.Metric, want map[string]string) bool {
+	if len(m.GetLabel()) != len(want) {
+		return false
+	}
+	for _, l := range m.GetLabel() {
+		if want[l.GetName()] != l.GetValue() {
+			return false
+		}
+	}
+	return true
+}
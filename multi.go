@@ -0,0 +1,175 @@
+package groupcache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A MultiGetter is a ProtoGetter that can fetch several keys from a peer
+// in a single round trip.
+type MultiGetter interface {
+	// GetMulti returns the values the peer could load for keys. Keys
+	// missing from the result could not be loaded; err reports a
+	// failure of the request as a whole.
+	GetMulti(ctx context.Context, group string, keys []string) (map[string]ByteView, error)
+}
+
+// KeysError is returned by GetMulti and maps each key that could not be
+// loaded to the error it produced.
+type KeysError map[string]error
+
+func (e KeysError) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, len(keys))
+	for i, key := range keys {
+		msgs[i] = fmt.Sprintf("%s: %v", key, e[key])
+	}
+	return fmt.Sprintf("groupcache: %d key(s) failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// GetMulti gets the values of several keys at once. Keys owned by the
+// same peer are fetched from it in batches when the peer implements
+// MultiGetter; all other keys are loaded as with Get.
+//
+// The returned map holds every key that was loaded. If any key failed,
+// a KeysError describing those keys is returned along with the values
+// that were loaded.
+func (g *Group) GetMulti(ctx context.Context, keys []string) (map[string]ByteView, error) {
+	if g.opts.KeyEncoder == nil {
+		return g.getMulti(ctx, keys)
+	}
+	// Map the encoded keys back to those of the caller.
+	encoded := make([]string, len(keys))
+	orig := make(map[string]string, len(keys))
+	for i, key := range keys {
+		encoded[i] = g.encodeKey(key)
+		orig[encoded[i]] = key
+	}
+	values, err := g.getMulti(ctx, encoded)
+	res := make(map[string]ByteView, len(values))
+	for key, value := range values {
+		res[orig[key]] = value
+	}
+	if errs, ok := err.(KeysError); ok {
+		decoded := make(KeysError, len(errs))
+		for key, err := range errs {
+			decoded[orig[key]] = err
+		}
+		err = decoded
+	}
+	return res, err
+}
+
+func (g *Group) getMulti(ctx context.Context, keys []string) (map[string]ByteView, error) {
+	g.peersOnce.Do(g.initPeers)
+
+	res := make(map[string]ByteView, len(keys))
+	seen := make(map[string]bool, len(keys))
+	byPeer := make(map[ProtoGetter][]string)
+	var single []string
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		g.Stats.Gets.Add(1)
+		if value, ok := g.lookupCache(key); ok {
+			g.Stats.CacheHits.Add(1)
+			res[key] = value
+			continue
+		}
+		if peer, ok := g.pickPeerForGet(ctx, key); ok {
+			if _, ok := peer.(MultiGetter); ok {
+				byPeer[peer] = append(byPeer[peer], key)
+				continue
+			}
+		}
+		single = append(single, key)
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = KeysError{}
+	)
+	load := func(key string) {
+		defer wg.Done()
+		value, err := g.load(ctx, key)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs[key] = err
+			return
+		}
+		res[key] = value
+	}
+
+	for peer, keys := range byPeer {
+		wg.Add(1)
+		go func(peer ProtoGetter, keys []string) {
+			defer wg.Done()
+			values, err := peer.(MultiGetter).GetMulti(ctx, g.name, keys)
+			if err != nil {
+				g.Stats.PeerErrors.Add(1)
+				g.log().Error("error retrieving keys from peer", "group", g.name, "keys", len(keys), "peer", peer.GetURL(), "err", err)
+			}
+			for _, key := range keys {
+				value, ok := values[key]
+				if !ok {
+					// Fall back to the single key path, which retries
+					// the owner before loading locally.
+					wg.Add(1)
+					go load(key)
+					continue
+				}
+				g.Stats.PeerLoads.Add(1)
+				g.admitToHotCache(key, value)
+				g.fireOnLoad(key, value, false)
+				mu.Lock()
+				res[key] = value
+				mu.Unlock()
+			}
+		}(peer, keys)
+	}
+	for _, key := range single {
+		wg.Add(1)
+		go load(key)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return res, errs
+	}
+	return res, nil
+}
+
+// GetMultiSinks is like GetMulti but delivers each loaded value to the
+// Sink returned by newSink for its key, which is called once per
+// distinct key that was loaded. Keys that failed to load, or whose Sink
+// rejected the value, are reported in a KeysError.
+func (g *Group) GetMultiSinks(ctx context.Context, keys []string, newSink func(key string) Sink) error {
+	values, err := g.GetMulti(ctx, keys)
+	errs, _ := err.(KeysError)
+	if err != nil && errs == nil {
+		return err
+	}
+	for key, value := range values {
+		if err := setSinkView(newSink(key), value); err != nil {
+			if errs == nil {
+				errs = KeysError{}
+			}
+			errs[key] = err
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
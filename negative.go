@@ -0,0 +1,119 @@
+package groupcache
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/lru"
+)
+
+// ErrNotFound is returned by a Getter, possibly wrapped, for a key that
+// has no value. A group with GroupOptions.NegativeTTL remembers such
+// keys and answers further Gets for them with ErrNotFound without
+// calling the getter. ErrNotFound from the owner of a key is final:
+// the key is not loaded locally instead.
+var ErrNotFound = errors.New("groupcache: not found")
+
+const defaultNegativeCacheSize = 1000
+
+// negativeCache remembers keys the getter could not find. A nil
+// negativeCache remembers nothing.
+type negativeCache struct {
+	ttl time.Duration
+
+	mu  sync.Mutex
+	lru *lru.Cache
+}
+
+func newNegativeCache(ttl time.Duration, size int) *negativeCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if size <= 0 {
+		size = defaultNegativeCacheSize
+	}
+	return &negativeCache{ttl: ttl, lru: lru.New(size)}
+}
+
+func (n *negativeCache) add(key string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.lru.Add(key, nil, time.Now().Add(n.ttl))
+}
+
+func (n *negativeCache) has(key string) bool {
+	if n == nil {
+		return false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, ok := n.lru.Get(key)
+	return ok
+}
+
+// addErr remembers key with the error it failed with.
+func (n *negativeCache) addErr(key string, err error) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.lru.Add(key, err, time.Now().Add(n.ttl))
+}
+
+// err returns the error key was remembered with by addErr.
+func (n *negativeCache) err(key string) (error, bool) {
+	if n == nil {
+		return nil, false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	v, ok := n.lru.Get(key)
+	if !ok {
+		return nil, false
+	}
+	err, _ := v.(error)
+	return err, true
+}
+
+func (n *negativeCache) remove(key string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.lru.Remove(key)
+}
+
+// removePrefix forgets the keys starting with prefix.
+func (n *negativeCache) removePrefix(prefix string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var keys []string
+	n.lru.Range(func(key lru.Key, _ interface{}) bool {
+		if k := key.(string); strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+		return true
+	})
+	for _, key := range keys {
+		n.lru.Remove(key)
+	}
+}
+
+func (n *negativeCache) clear() {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.lru.Clear()
+}
@@ -20,6 +20,10 @@ package groupcache
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	pb "github.com/adistroy/groupcache/v3/groupcachepb"
 )
@@ -32,6 +36,37 @@ type ProtoGetter interface {
 	GetURL() string
 }
 
+// PeerSetter is implemented by a ProtoGetter that can store a value on
+// its peer, as used by Group.Set.
+type PeerSetter interface {
+	// Set stores value for key in group on the peer, which must own
+	// key.
+	Set(ctx context.Context, group, key string, value ByteView) error
+}
+
+// PeerFlusher is implemented by a ProtoGetter that can empty a group on
+// its peer, as used by Group.Clear.
+type PeerFlusher interface {
+	// Flush empties the main and hot cache of group on the peer.
+	Flush(ctx context.Context, group string) error
+}
+
+// PeerPrefixRemover is implemented by a ProtoGetter that can remove
+// keys by prefix on its peer, as used by Group.RemovePrefix.
+type PeerPrefixRemover interface {
+	// RemovePrefix removes every key of group starting with prefix
+	// from the caches of the peer.
+	RemovePrefix(ctx context.Context, group, prefix string) error
+}
+
+// PeerGenerationBumper is implemented by a ProtoGetter that can
+// invalidate a group on its peer, as used by Group.BumpGeneration.
+type PeerGenerationBumper interface {
+	// BumpGeneration invalidates every cached value of group on the
+	// peer.
+	BumpGeneration(ctx context.Context, group string) error
+}
+
 // PeerPicker is the interface that must be implemented to locate
 // the peer that owns a specific key.
 type PeerPicker interface {
@@ -43,6 +78,105 @@ type PeerPicker interface {
 	GetAll() []ProtoGetter
 }
 
+// FailoverPicker is implemented by a PeerPicker that can nominate other
+// peers to try when the owner of a key fails to answer.
+type FailoverPicker interface {
+	// PickFailover returns, in the order they should be tried, up to
+	// hops peers that follow the owner of key. The list stops early if
+	// the current peer is next in line, since the key is then loaded
+	// locally.
+	PickFailover(key string, hops int) []ProtoGetter
+	// FailoverHops returns the number of hops tried by default.
+	FailoverHops() int
+}
+
+// HedgePicker is implemented by a PeerPicker that can send a second,
+// hedged, Get for a key to another peer when the peer a Get was sent to
+// is slow to answer, so a pause of one peer does not hold up its keys.
+// The first answer is used.
+type HedgePicker interface {
+	// HedgeDelay returns how long a Get sent to peer may take before
+	// it is hedged, or zero not to hedge it.
+	HedgeDelay(peer ProtoGetter) time.Duration
+	// PickHedge returns the peer to send the hedged Get for key to,
+	// which must load the key itself rather than forward the Get to its
+	// slow owner, or false not to hedge.
+	PickHedge(key string) (peer ProtoGetter, ok bool)
+}
+
+// LoadPicker is implemented by a PeerPicker that can spread the Gets of
+// a key whose owner is overloaded onto other peers.
+type LoadPicker interface {
+	// PickPeerForLoad is like PickPeer but may nominate a peer other
+	// than the owner of key, or the current peer, to load it.
+	PickPeerForLoad(key string) (peer ProtoGetter, ok bool)
+}
+
+// DeadlinePicker is implemented by a PeerPicker that can decline to
+// send a Get to a peer that is not expected to answer before the
+// deadline of the Get's context, so the key is loaded locally instead.
+type DeadlinePicker interface {
+	// PickPeerContext is like PickPeerForLoad of a LoadPicker, or
+	// PickPeer, but returns nil, false if the nominated peer is too
+	// slow for ctx.
+	PickPeerContext(ctx context.Context, key string) (peer ProtoGetter, ok bool)
+}
+
+// Replicator is implemented by a PeerPicker that can mirror the values
+// loaded by the owner of a key onto other peers, so that they can still
+// be served by those peers if the owner is lost.
+type Replicator interface {
+	// Replicate stores value for key in group on the replica peers of
+	// key.
+	Replicate(ctx context.Context, group, key string, value ByteView) error
+}
+
+// LeasePicker is implemented by a PeerPicker that leases the ownership
+// of keys to the current peer, so that two peers do not cache
+// conflicting values of a key while a change of owner is spreading.
+type LeasePicker interface {
+	// HoldsLease reports whether the current peer may cache the value
+	// it loaded for key as its owner.
+	HoldsLease(key string) bool
+}
+
+type failoverHopsKey struct{}
+
+// WithFailoverHops returns a copy of ctx that overrides the number of
+// failover hops tried by a Get made with it. A value of zero makes the
+// Get load locally as soon as the owner fails.
+//
+// Concurrent Gets for the same key are deduplicated, so only the
+// context of the call that starts the load is consulted.
+func WithFailoverHops(ctx context.Context, hops int) context.Context {
+	return context.WithValue(ctx, failoverHopsKey{}, hops)
+}
+
+type fallbackLoadKey struct{}
+
+// withFallbackLoad returns a copy of ctx for a Get received from a peer
+// that failed to reach the owner of the key, which then skips the owner
+// and loads the key locally.
+func withFallbackLoad(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fallbackLoadKey{}, true)
+}
+
+func isFallbackLoad(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	fallback, _ := ctx.Value(fallbackLoadKey{}).(bool)
+	return fallback
+}
+
+func failoverHopsFromContext(ctx context.Context) (int, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	hops, ok := ctx.Value(failoverHopsKey{}).(int)
+	return hops, ok
+}
+
 // NoPeers is an implementation of PeerPicker that never finds a peer.
 type NoPeers struct{}
 
@@ -86,3 +220,20 @@ func getPeers(groupName string) PeerPicker {
 	}
 	return pk
 }
+
+// PeersError is returned by operations that fan out to every peer and
+// maps the address of each peer that failed to the error it returned.
+type PeersError map[string]error
+
+func (e PeersError) Error() string {
+	addrs := make([]string, 0, len(e))
+	for addr := range e {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	msgs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		msgs[i] = fmt.Sprintf("%s: %v", addr, e[addr])
+	}
+	return fmt.Sprintf("groupcache: %d peer(s) failed: %s", len(e), strings.Join(msgs, "; "))
+}
@@ -0,0 +1,209 @@
+package groupcache
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// A PeerSpec is a parsed peer address, as given to the Set, AddPeers
+// and RemovePeers methods of the pools. ParsePeerSpec accepts:
+//
+//	host:port, [v6]:port           a plain TCP address
+//	dns:///host:port               a DNS name resolved by gRPC
+//	dns://resolver:53/host:port    the same, asking that DNS server
+//	unix:relative/path, unix:///absolute/path, unix-abstract:name
+//	                               a unix socket, as for a sidecar
+//	http://host:port, https://host:port/path
+//	                               the base URL of an HTTPPool peer
+//	scheme://authority/endpoint    a target for a resolver registered
+//	                               with GRPCPoolOptions.Resolvers
+//
+// each optionally followed by "?weight=N", "?zone=NAME", "?replicas=N"
+// or several, as in "?weight=2&zone=us-east-1a". Host names are case
+// insensitive and IPv6 literals are written in their shortest form, so
+// differently written addresses of a peer are the same peer.
+type PeerSpec struct {
+	// Scheme is "dns", "unix", "unix-abstract", "http", "https" or the
+	// scheme of a custom resolver, empty for plain addresses.
+	Scheme string
+
+	// Authority is the DNS server of dns addresses, the host and port
+	// of http and https URLs, and empty for the other schemes.
+	Authority string
+
+	// Endpoint is the host:port of plain and dns addresses, the socket
+	// path or name of unix addresses, the base path of URLs, without
+	// its leading slash, and the endpoint of custom resolvers.
+	Endpoint string
+
+	// Weight is the number of shares of the keys the peer owns, 1
+	// unless set with "?weight=N".
+	Weight int
+
+	// Zone is the zone of the peer, such as its availability zone, set
+	// with "?zone=NAME", see GRPCPoolOptions.Zone.
+	Zone string
+
+	// Replicas is the number of virtual nodes of the peer on the hash
+	// ring, set with "?replicas=N", as the peer lists RunRingTuning
+	// sends do. If zero, the peer has Weight times
+	// GRPCPoolOptions.Replicas.
+	Replicas int
+}
+
+// ParsePeerSpec parses a peer spec, see PeerSpec. A plain address
+// without a port, such as a name that only a custom dialer resolves,
+// is accepted as it is, but an IPv6 literal must be in brackets.
+func ParsePeerSpec(spec string) (PeerSpec, error) {
+	addr, query := spec, ""
+	if i := strings.IndexByte(spec, '?'); i >= 0 {
+		addr, query = spec[:i], spec[i+1:]
+	}
+	s := PeerSpec{Weight: 1}
+	if query != "" {
+		params, err := url.ParseQuery(query)
+		if err != nil {
+			return PeerSpec{}, fmt.Errorf("groupcache: invalid peer spec %q: %w", spec, err)
+		}
+		for name, values := range params {
+			if len(values) != 1 {
+				return PeerSpec{}, fmt.Errorf("groupcache: invalid peer spec %q: parameter %q given twice", spec, name)
+			}
+			switch name {
+			case "weight":
+				if s.Weight, err = strconv.Atoi(values[0]); err != nil || s.Weight < 1 {
+					return PeerSpec{}, fmt.Errorf("groupcache: invalid peer spec %q: weight must be a positive integer", spec)
+				}
+			case "zone":
+				if s.Zone = values[0]; s.Zone == "" {
+					return PeerSpec{}, fmt.Errorf("groupcache: invalid peer spec %q: empty zone", spec)
+				}
+			case "replicas":
+				if s.Replicas, err = strconv.Atoi(values[0]); err != nil || s.Replicas < 1 {
+					return PeerSpec{}, fmt.Errorf("groupcache: invalid peer spec %q: replicas must be a positive integer", spec)
+				}
+			default:
+				return PeerSpec{}, fmt.Errorf("groupcache: invalid peer spec %q: unknown parameter %q", spec, name)
+			}
+		}
+	}
+
+	var err error
+	switch i := strings.Index(addr, "://"); {
+	case i > 0:
+		s.Scheme = strings.ToLower(addr[:i])
+		s.Authority, s.Endpoint, _ = strings.Cut(addr[i+3:], "/")
+	case strings.HasPrefix(addr, "unix:") || strings.HasPrefix(addr, "unix-abstract:"):
+		s.Scheme, s.Endpoint, _ = strings.Cut(addr, ":")
+	default:
+		s.Endpoint, err = normalizeHostPort(addr)
+	}
+	switch s.Scheme {
+	case "unix", "unix-abstract":
+		if s.Authority != "" {
+			err = fmt.Errorf("unix addresses have no authority, use unix:///%s for an absolute path", s.Endpoint)
+		} else if addr[len(s.Scheme)+1:] != s.Endpoint {
+			// unix:///absolute/path
+			s.Endpoint = "/" + s.Endpoint
+		}
+	case "http", "https":
+		s.Authority, err = normalizeHostPort(strings.ToLower(s.Authority))
+		s.Endpoint = strings.TrimSuffix(s.Endpoint, "/")
+	case "dns":
+		s.Endpoint, err = normalizeHostPort(s.Endpoint)
+	}
+	if err == nil && s.Endpoint == "" && s.Authority == "" {
+		err = fmt.Errorf("empty address")
+	}
+	if err != nil {
+		return PeerSpec{}, fmt.Errorf("groupcache: invalid peer spec %q: %w", spec, err)
+	}
+	return s, nil
+}
+
+// normalizeHostPort lower-cases the host of hostport and writes IPv6
+// literals in their shortest form.
+func normalizeHostPort(hostport string) (string, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		if net.ParseIP(hostport) != nil && strings.Contains(hostport, ":") {
+			return "", fmt.Errorf("IPv6 address must be in brackets, as in [%s]:port", hostport)
+		}
+		if strings.ContainsAny(hostport, "[]") {
+			return "", err
+		}
+		// No port: a name only a custom dialer knows.
+		return hostport, nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	} else {
+		host = strings.ToLower(host)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// Address returns the peer address without its parameters, the
+// same for every way of writing it. It is the target GRPCPool dials
+// and the name of the peer on the hash ring.
+func (s PeerSpec) Address() string {
+	switch s.Scheme {
+	case "":
+		return s.Endpoint
+	case "unix", "unix-abstract":
+		if strings.HasPrefix(s.Endpoint, "/") {
+			return s.Scheme + "://" + s.Endpoint
+		}
+		return s.Scheme + ":" + s.Endpoint
+	case "http", "https":
+		if s.Endpoint == "" {
+			return s.Scheme + "://" + s.Authority
+		}
+	}
+	return s.Scheme + "://" + s.Authority + "/" + s.Endpoint
+}
+
+// String returns the spec in the form ParsePeerSpec accepts.
+func (s PeerSpec) String() string {
+	return peerSpecString(s.Address(), s.Weight, s.Zone, s.Replicas)
+}
+
+// peerSpecString returns the spec of the peer at addr with weight, zone
+// and, unless zero, replicas.
+func peerSpecString(addr string, weight int, zone string, replicas int) string {
+	params := make(url.Values)
+	if weight > 1 {
+		params.Set("weight", strconv.Itoa(weight))
+	}
+	if zone != "" {
+		params.Set("zone", zone)
+	}
+	if replicas > 0 {
+		params.Set("replicas", strconv.Itoa(replicas))
+	}
+	if len(params) == 0 {
+		return addr
+	}
+	return addr + "?" + params.Encode()
+}
+
+// Host returns the host name or IP address of the peer, for example to
+// verify its certificate, or an empty string for unix sockets and
+// addresses without a port.
+func (s PeerSpec) Host() string {
+	hostport := s.Endpoint
+	switch s.Scheme {
+	case "unix", "unix-abstract":
+		return ""
+	case "http", "https":
+		hostport = s.Authority
+	}
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return ""
+	}
+	return host
+}
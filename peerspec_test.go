@@ -0,0 +1,122 @@
+package groupcache
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+	pb "github.com/adistroy/groupcache/v3/groupcachepb"
+	"google.golang.org/grpc"
+)
+
+func TestParsePeerSpec(t *testing.T) {
+	for _, tt := range []struct {
+		spec, addr, host string
+		weight           int
+	}{
+		{"10.0.0.1:8080", "10.0.0.1:8080", "10.0.0.1", 1},
+		{"Cache-1.Example.com:8080?weight=2", "cache-1.example.com:8080", "cache-1.example.com", 2},
+		{"[2001:DB8:0::1]:8080", "[2001:db8::1]:8080", "2001:db8::1", 1},
+		{"bufconn", "bufconn", "", 1},
+		{"dns:///Cache.Example.com:443", "dns:///cache.example.com:443", "cache.example.com", 1},
+		{"DNS://8.8.8.8:53/cache:443?weight=3", "dns://8.8.8.8:53/cache:443", "cache", 3},
+		{"unix:///run/gc.sock", "unix:///run/gc.sock", "", 1},
+		{"unix:run/gc.sock", "unix:run/gc.sock", "", 1},
+		{"unix-abstract:groupcache", "unix-abstract:groupcache", "", 1},
+		{"http://[::1]:8000/", "http://[::1]:8000", "::1", 1},
+		{"https://Example.net/cache", "https://example.net/cache", "", 1},
+		{"consul://agent/groupcache", "consul://agent/groupcache", "", 1},
+		{"10.0.0.1:8080?zone=us-east-1a&weight=2", "10.0.0.1:8080", "10.0.0.1", 2},
+		{"10.0.0.1:8080?weight=2&replicas=137", "10.0.0.1:8080", "10.0.0.1", 2},
+	} {
+		s, err := ParsePeerSpec(tt.spec)
+		if err != nil {
+			t.Errorf("ParsePeerSpec(%q): %v", tt.spec, err)
+			continue
+		}
+		if s.Address() != tt.addr || s.Host() != tt.host || s.Weight != tt.weight {
+			t.Errorf("ParsePeerSpec(%q) = %q, host %q, weight %d; want %q, %q, %d", tt.spec, s.Address(), s.Host(), s.Weight, tt.addr, tt.host, tt.weight)
+		}
+		if again, err := ParsePeerSpec(s.String()); err != nil || again != s {
+			t.Errorf("ParsePeerSpec(%q) = %+v, %v; want %+v", s.String(), again, err, s)
+		}
+	}
+
+	for _, spec := range []string{"::1", "2001:db8::1:8080", "[::1:8080", "unix://host/path", "dns:///", "a?weight=0", "a?color=red", "a?zone=", "a?weight=1&weight=2", "a?replicas=0", ""} {
+		if s, err := ParsePeerSpec(spec); err == nil {
+			t.Errorf("ParsePeerSpec(%q) = %+v; want an error", spec, s)
+		}
+	}
+}
+
+func TestGRPCPoolUnixPeer(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "peer.sock")
+	lis, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	gcgrpc.RegisterPeerServer(server, &optionsPeer{})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	pool := newGRPCPool("[::1]:8080", nil)
+	defer pool.Set()
+	pool.Set("unix://"+sock, "[0:0::1]:8080")
+	if len(pool.grpcGetters) != 2 || pool.self != "[::1]:8080" || pool.grpcGetters["[::1]:8080"] == nil {
+		t.Fatalf("peers = %v, self %q; want the socket and self", pool.grpcGetters, pool.self)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	group, key := "group", "key"
+	var res pb.GetResponse
+	if err := pool.grpcGetters["unix://"+sock].Get(ctx, &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Value) != "got:key" {
+		t.Errorf("Get over the socket = %q; want got:key", res.Value)
+	}
+}
+
+func TestHTTPPoolUnixPeer(t *testing.T) {
+	const groupName = "TestHTTPPoolUnixPeer-group"
+	newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("got:"+key, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	sock := filepath.Join(t.TempDir(), "peer.sock")
+	lis, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &HTTPPool{opts: HTTPPoolOptions{BasePath: defaultBasePath}, httpGetters: map[string]*httpGetter{}}
+	ts := httptest.NewUnstartedServer(p)
+	ts.Listener = lis
+	ts.Start()
+	defer ts.Close()
+
+	p.Set("unix://"+sock, "http://[::0001]:8000/", "dns:///ignored:80")
+	if len(p.httpGetters) != 2 || p.httpGetters["http://[::1]:8000"] == nil {
+		t.Fatalf("peers = %v; want the socket and http://[::1]:8000", p.httpGetters)
+	}
+	h := p.httpGetters["unix://"+sock]
+	if want := "unix://" + sock + defaultBasePath; h.GetURL() != want {
+		t.Errorf("GetURL = %q; want %q", h.GetURL(), want)
+	}
+	group, key := groupName, "key"
+	var res pb.GetResponse
+	if err := h.Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &res); err != nil {
+		t.Fatal(err)
+	}
+	if string(res.Value) != "got:key" {
+		t.Errorf("Get over the socket = %q; want got:key", res.Value)
+	}
+	h.getTransport(context.Background()).(*http.Transport).CloseIdleConnections()
+}
@@ -0,0 +1,92 @@
+// Package policy defines how the caches of a group decide which entry
+// to evict, and implements the LRU, SLRU and TinyLFU replacement
+// policies. A group uses the policy set in GroupOptions.CachePolicy:
+//
+//	groupcache.NewGroupOpts("thumbnails", 64<<20, getter, &groupcache.GroupOptions{
+//		CachePolicy: policy.TinyLFU(nil),
+//	})
+package policy
+
+import (
+	"time"
+
+	"github.com/adistroy/groupcache/v3/lru"
+)
+
+// Cache holds the entries of a cache and orders them for eviction. The
+// group tracks the size of the entries and calls RemoveOldest until
+// they fit its budget. A Cache must call its onEvicted function for
+// every entry that leaves it, whether through Remove, RemoveOldest,
+// RemoveExpired, Clear, expiry on Get, or because Add declined to keep
+// it. It is not safe for concurrent use.
+type Cache interface {
+	// Add adds value for key, which is not in the cache, expiring at
+	// expire. A zero expire never expires.
+	Add(key string, value interface{}, expire time.Time)
+
+	// Get returns the value for key if it is present and not expired,
+	// recording the access.
+	Get(key string) (value interface{}, ok bool)
+
+	// Remove removes key if present.
+	Remove(key string)
+
+	// RemoveOldest evicts the entry the policy values least.
+	RemoveOldest()
+
+	// RemoveExpired removes the entries that expired before now and
+	// returns how many were removed.
+	RemoveExpired(now time.Time) int
+
+	// Len returns the number of entries.
+	Len() int
+
+	// Clear removes every entry.
+	Clear()
+}
+
+// Ranger is implemented by a Cache that can list its entries, from the
+// one its policy values most. GRPCPool.Drain only hands off the entries of
+// caches that are Rangers.
+type Ranger interface {
+	// Range calls fn for every entry until fn returns false. It does
+	// not count as an access of the entries.
+	Range(fn func(key string, value interface{}) bool)
+}
+
+// Peeker is implemented by a Cache that can look up an entry without
+// recording an access, which Group.Peek and Group.Contains need.
+type Peeker interface {
+	// Peek returns the value for key if it is present and not expired.
+	// It neither records the access nor removes an expired entry.
+	Peek(key string) (value interface{}, ok bool)
+}
+
+// New creates an empty Cache calling onEvicted for every entry that
+// leaves it.
+type New func(onEvicted func(key string, value interface{})) Cache
+
+// LRU evicts the least recently used entry. It is the default policy
+// of groups.
+func LRU() New {
+	return func(onEvicted func(key string, value interface{})) Cache {
+		c := lruCache{lru.New(0)}
+		c.OnEvicted = func(key lru.Key, value interface{}) { onEvicted(key.(string), value) }
+		return c
+	}
+}
+
+// lruCache adapts an lru.Cache to Cache.
+type lruCache struct {
+	*lru.Cache
+}
+
+func (c lruCache) Get(key string) (interface{}, bool)  { return c.Cache.Get(key) }
+func (c lruCache) Peek(key string) (interface{}, bool) { return c.Cache.Peek(key) }
+func (c lruCache) Remove(key string)                   { c.Cache.Remove(key) }
+func (c lruCache) Range(fn func(key string, value interface{}) bool) {
+	c.Cache.Range(func(key lru.Key, value interface{}) bool { return fn(key.(string), value) })
+}
+func (c lruCache) Add(key string, value interface{}, expire time.Time) {
+	c.Cache.Add(key, value, expire)
+}
@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+var policies = []struct {
+	name string
+	new  New
+}{
+	{"LRU", LRU()},
+	{"SLRU", SLRU(nil)},
+	{"TinyLFU", TinyLFU(&TinyLFUOptions{Counters: 1024})},
+}
+
+func TestCache(t *testing.T) {
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			evicted := map[string]int{}
+			c := p.new(func(key string, value interface{}) {
+				if value != "v"+key {
+					t.Errorf("evicted %q with value %v", key, value)
+				}
+				evicted[key]++
+			})
+
+			c.Add("1", "v1", time.Time{})
+			c.Add("2", "v2", time.Now().Add(-time.Second))
+			c.Add("3", "v3", time.Now().Add(time.Hour))
+			if v, ok := c.Get("1"); !ok || v != "v1" {
+				t.Errorf("Get(1) = %v, %v; want v1", v, ok)
+			}
+			if _, ok := c.Get("2"); ok {
+				t.Error("Get returned an expired entry")
+			}
+			if c.Len() != 2 || evicted["2"] != 1 {
+				t.Errorf("Len = %d, evictions of 2 = %d after expiry; want 2, 1", c.Len(), evicted["2"])
+			}
+
+			if v, ok := c.(Peeker).Peek("1"); !ok || v != "v1" {
+				t.Errorf("Peek(1) = %v, %v; want v1", v, ok)
+			}
+			if _, ok := c.(Peeker).Peek("missing"); ok {
+				t.Error("Peek returned a missing entry")
+			}
+
+			c.Add("4", "v4", time.Now().Add(-time.Second))
+			if n := c.RemoveExpired(time.Now()); n != 1 || evicted["4"] != 1 {
+				t.Errorf("RemoveExpired = %d with %d evictions of 4; want 1, 1", n, evicted["4"])
+			}
+
+			c.Remove("3")
+			if _, ok := c.Get("3"); ok || evicted["3"] != 1 {
+				t.Errorf("Remove(3) left it cached or evicted it %d times", evicted["3"])
+			}
+
+			c.Add("5", "v5", time.Time{})
+			c.RemoveOldest()
+			if c.Len() != 1 {
+				t.Errorf("Len after RemoveOldest = %d; want 1", c.Len())
+			}
+			c.Clear()
+			if c.Len() != 0 || evicted["1"]+evicted["5"] != 2 {
+				t.Errorf("Len after Clear = %d, evictions = %v", c.Len(), evicted)
+			}
+		})
+	}
+}
+
+// TestScan checks which policies keep a working set of hot keys cached
+// through a scan of keys that are read once.
+func TestScan(t *testing.T) {
+	const size, hot = 100, 50
+	for _, p := range policies {
+		t.Run(p.name, func(t *testing.T) {
+			c := p.new(func(string, interface{}) {})
+			add := func(key string) {
+				if _, ok := c.Get(key); ok {
+					return
+				}
+				c.Add(key, "v"+key, time.Time{})
+				for c.Len() > size {
+					c.RemoveOldest()
+				}
+			}
+			for round := 0; round < 5; round++ {
+				for i := 0; i < hot; i++ {
+					add("hot" + strconv.Itoa(i))
+				}
+			}
+			for i := 0; i < 10*size; i++ {
+				add("scan" + strconv.Itoa(i))
+			}
+
+			var kept int
+			for i := 0; i < hot; i++ {
+				if _, ok := c.Get("hot" + strconv.Itoa(i)); ok {
+					kept++
+				}
+			}
+			if p.name == "LRU" {
+				if kept != 0 {
+					t.Errorf("LRU kept %d hot keys through a scan; want 0", kept)
+				}
+			} else if kept != hot {
+				t.Errorf("%s kept %d of %d hot keys through a scan", p.name, kept, hot)
+			}
+		})
+	}
+}
+
+func TestSketch(t *testing.T) {
+	s := NewSketch(64)
+	for i := 0; i < 5; i++ {
+		s.Increment("hot")
+	}
+	s.Increment("cold")
+	if got := s.Estimate("hot"); got < 5 {
+		t.Errorf("Estimate(hot) = %d; want at least 5", got)
+	}
+	if got := s.Estimate("cold"); got < 1 || got >= 5 {
+		t.Errorf("Estimate(cold) = %d; want 1 to 4", got)
+	}
+	for i := 0; i < 20; i++ {
+		s.Increment("hot")
+	}
+	if got := s.Estimate("hot"); got != maxCount {
+		t.Errorf("Estimate after 25 increments = %d; want %d", got, maxCount)
+	}
+
+	// Counters halve after 10 increments per counter, 640 here.
+	for i := 0; i < 640; i++ {
+		s.Increment("other" + strconv.Itoa(i))
+	}
+	if got := s.Estimate("hot"); got > 8 {
+		t.Errorf("Estimate(hot) after aging = %d; want at most 8", got)
+	}
+}
@@ -0,0 +1,269 @@
+package policy
+
+import (
+	"container/list"
+	"time"
+)
+
+type SLRUOptions struct {
+	// ProtectedRatio is the share of the entries kept in the protected
+	// segment, which holds entries that were hit at least once since
+	// they were added.
+	// If blank, it defaults to 0.8.
+	ProtectedRatio float64
+}
+
+// SLRU is a segmented LRU: new entries start in a probation segment
+// and move to a protected segment when they are hit. Entries are
+// evicted from probation first, so a scan of keys that are read once
+// cannot flush entries that are read repeatedly.
+func SLRU(opts *SLRUOptions) New {
+	var o SLRUOptions
+	if opts != nil {
+		o = *opts
+	}
+	return func(onEvicted func(key string, value interface{})) Cache {
+		return newSegmented(onEvicted, o.ProtectedRatio, 0, 0)
+	}
+}
+
+type TinyLFUOptions struct {
+	// WindowRatio is the share of the entries kept in the admission
+	// window, an LRU that new entries enter first.
+	// If blank, it defaults to 0.01.
+	WindowRatio float64
+
+	// ProtectedRatio is the share of the entries outside the window
+	// kept in the protected segment, as for SLRU.
+	// If blank, it defaults to 0.8.
+	ProtectedRatio float64
+
+	// Counters is the number of counters of the frequency sketch. It
+	// should be about the number of entries the cache holds.
+	// If blank, it defaults to 1<<16.
+	Counters int
+}
+
+// TinyLFU is W-TinyLFU: new entries enter a small LRU window, and when
+// an entry must leave the window it only replaces the least valued
+// entry of a main SLRU if it was accessed more often recently, as
+// estimated by a compact frequency sketch of all Gets and Adds. Keys
+// that are seen once, such as those of a scan, never displace
+// frequently used entries.
+func TinyLFU(opts *TinyLFUOptions) New {
+	var o TinyLFUOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.WindowRatio <= 0 {
+		o.WindowRatio = 0.01
+	}
+	if o.Counters <= 0 {
+		o.Counters = 1 << 16
+	}
+	return func(onEvicted func(key string, value interface{})) Cache {
+		return newSegmented(onEvicted, o.ProtectedRatio, o.WindowRatio, o.Counters)
+	}
+}
+
+const (
+	window = iota
+	probation
+	protected
+)
+
+type entry struct {
+	key    string
+	value  interface{}
+	expire time.Time
+	seg    int
+}
+
+// segmented implements SLRU and, with a window and a sketch, TinyLFU.
+// The front of each list is the most recently used entry.
+type segmented struct {
+	onEvicted      func(key string, value interface{})
+	protectedRatio float64
+	windowRatio    float64
+	sketch         *Sketch // nil for SLRU
+
+	segs     [3]list.List
+	items    map[string]*list.Element
+	capacity int // largest number of entries seen when evicting
+}
+
+func newSegmented(onEvicted func(string, interface{}), protectedRatio, windowRatio float64, counters int) *segmented {
+	if protectedRatio <= 0 || protectedRatio >= 1 {
+		protectedRatio = 0.8
+	}
+	s := &segmented{
+		onEvicted:      onEvicted,
+		protectedRatio: protectedRatio,
+		windowRatio:    windowRatio,
+		items:          make(map[string]*list.Element),
+	}
+	if counters > 0 {
+		s.sketch = NewSketch(counters)
+	}
+	return s
+}
+
+func (s *segmented) Add(key string, value interface{}, expire time.Time) {
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+	seg := probation
+	if s.sketch != nil {
+		s.sketch.Increment(key)
+		seg = window
+	}
+	s.items[key] = s.segs[seg].PushFront(&entry{key: key, value: value, expire: expire, seg: seg})
+}
+
+func (s *segmented) Get(key string) (interface{}, bool) {
+	if s.sketch != nil {
+		s.sketch.Increment(key)
+	}
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	ent := e.Value.(*entry)
+	if !ent.expire.IsZero() && ent.expire.Before(time.Now()) {
+		s.remove(e)
+		return nil, false
+	}
+	switch ent.seg {
+	case probation:
+		s.move(e, protected)
+		s.balance()
+	default:
+		s.segs[ent.seg].MoveToFront(e)
+	}
+	return ent.value, true
+}
+
+func (s *segmented) Peek(key string) (interface{}, bool) {
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	ent := e.Value.(*entry)
+	if !ent.expire.IsZero() && ent.expire.Before(time.Now()) {
+		return nil, false
+	}
+	return ent.value, true
+}
+
+// move moves e to the front of seg.
+func (s *segmented) move(e *list.Element, seg int) {
+	ent := e.Value.(*entry)
+	s.segs[ent.seg].Remove(e)
+	ent.seg = seg
+	s.items[ent.key] = s.segs[seg].PushFront(ent)
+}
+
+// balance demotes protected entries to probation while the protected
+// segment is over its share. The share is unlimited until the cache
+// first filled up.
+func (s *segmented) balance() {
+	if s.capacity == 0 {
+		return
+	}
+	limit := s.protectedRatio * float64(s.capacity-s.segs[window].Len())
+	for float64(s.segs[protected].Len()) > limit && s.segs[protected].Len() > 1 {
+		s.move(s.segs[protected].Back(), probation)
+	}
+}
+
+func (s *segmented) Remove(key string) {
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+}
+
+func (s *segmented) RemoveOldest() {
+	// The cache is full whenever an entry is evicted, which tells the
+	// number of entries the segments share.
+	if len(s.items) > s.capacity {
+		s.capacity = len(s.items)
+	}
+	if s.sketch != nil {
+		target := int(s.windowRatio * float64(s.capacity))
+		if target < 1 {
+			target = 1
+		}
+		// Entries added before the cache first filled up leave the
+		// window without competing.
+		for s.segs[window].Len() > target+1 {
+			s.move(s.segs[window].Back(), probation)
+		}
+		// The oldest window entry replaces the main victim only if it
+		// is used more often.
+		if victim := s.victim(); victim != nil && s.segs[window].Len() > target {
+			candidate := s.segs[window].Back()
+			if s.sketch.Estimate(candidate.Value.(*entry).key) > s.sketch.Estimate(victim.Value.(*entry).key) {
+				s.remove(victim)
+				s.move(candidate, probation)
+			} else {
+				s.remove(candidate)
+			}
+			return
+		}
+	}
+	if victim := s.victim(); victim != nil {
+		s.remove(victim)
+	} else if e := s.segs[window].Back(); e != nil {
+		s.remove(e)
+	}
+}
+
+// victim returns the main entry evicted next, from probation first.
+func (s *segmented) victim() *list.Element {
+	if e := s.segs[probation].Back(); e != nil {
+		return e
+	}
+	return s.segs[protected].Back()
+}
+
+func (s *segmented) remove(e *list.Element) {
+	ent := e.Value.(*entry)
+	s.segs[ent.seg].Remove(e)
+	delete(s.items, ent.key)
+	if s.onEvicted != nil {
+		s.onEvicted(ent.key, ent.value)
+	}
+}
+
+func (s *segmented) RemoveExpired(now time.Time) int {
+	var n int
+	for _, e := range s.items {
+		if ent := e.Value.(*entry); !ent.expire.IsZero() && ent.expire.Before(now) {
+			s.remove(e)
+			n++
+		}
+	}
+	return n
+}
+
+// Range lists the protected entries first, then those of the window
+// and of probation, each from the most recently used.
+func (s *segmented) Range(fn func(key string, value interface{}) bool) {
+	for _, seg := range []int{protected, window, probation} {
+		for e := s.segs[seg].Front(); e != nil; e = e.Next() {
+			if ent := e.Value.(*entry); !fn(ent.key, ent.value) {
+				return
+			}
+		}
+	}
+}
+
+func (s *segmented) Len() int {
+	return len(s.items)
+}
+
+func (s *segmented) Clear() {
+	for _, e := range s.items {
+		s.remove(e)
+	}
+}
@@ -0,0 +1,75 @@
+package policy
+
+import "hash/fnv"
+
+const (
+	sketchDepth = 4
+	maxCount    = 15
+)
+
+// Sketch is a count-min sketch estimating how often keys were seen
+// recently, in little memory, as used by TinyLFU. All counters are
+// halved every 10 increments per counter, so frequencies from the past
+// fade out. Estimates saturate at 15. It is not safe for concurrent
+// use.
+type Sketch struct {
+	rows       [sketchDepth][]uint8
+	mask       uint64
+	increments int
+	resetAt    int
+}
+
+// NewSketch returns a Sketch of about counters counters per row, which
+// should be about the number of distinct keys seen recently.
+func NewSketch(counters int) *Sketch {
+	width := 1
+	for width < counters {
+		width <<= 1
+	}
+	s := &Sketch{mask: uint64(width - 1), resetAt: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// indexes returns the counter of key in every row.
+func (s *Sketch) indexes(key string) [sketchDepth]uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum, sum>>32|sum<<32
+	var idx [sketchDepth]uint64
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) & s.mask
+	}
+	return idx
+}
+
+// Increment records that key was seen.
+func (s *Sketch) Increment(key string) {
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < maxCount {
+			s.rows[i][j]++
+		}
+	}
+	if s.increments++; s.increments >= s.resetAt {
+		s.increments = 0
+		for _, row := range s.rows {
+			for j := range row {
+				row[j] /= 2
+			}
+		}
+	}
+}
+
+// Estimate returns about how often key was seen recently.
+func (s *Sketch) Estimate(key string) uint8 {
+	min := uint8(maxCount)
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < min {
+			min = s.rows[i][j]
+		}
+	}
+	return min
+}
@@ -0,0 +1,312 @@
+package groupcache
+
+import (
+	"container/heap"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ProfileOptions configures the cache profile of a group, see
+// Group.SetProfiling.
+type ProfileOptions struct {
+	// TopN is the number of keys listed in each part of the profile.
+	// If blank, it defaults to 20.
+	TopN int
+
+	// TrackedKeys is the number of keys whose Gets are counted. Keys
+	// beyond it replace the least counted one, which makes the counts
+	// of the keys read most often accurate to within the count of the
+	// key they replaced. It should be well above TopN.
+	// If blank, it defaults to 10 times TopN.
+	TrackedKeys int
+}
+
+// A CacheProfile shows which keys of a group are read most often and
+// which take the most room in its caches.
+type CacheProfile struct {
+	Group     string
+	Namespace string    // of the group, see GroupOptions.Namespace
+	Since     time.Time // when profiling started
+	Gets      int64     // Gets counted since then, from peers included
+
+	// HotKeys are the keys read most often, by decreasing count.
+	HotKeys []KeyCount
+
+	// LargestKeys are the cached entries of the largest size, from
+	// either cache, by decreasing size. They are only listed for
+	// caches whose policy is a policy.Ranger, as the built-in ones are.
+	LargestKeys []KeySize
+}
+
+// KeyCount is the number of Gets of a key in a CacheProfile.
+type KeyCount struct {
+	Key   string
+	Count int64
+	Error int64 // by which Count may overestimate the Gets of Key
+}
+
+// KeySize is the size of a cached entry in a CacheProfile.
+type KeySize struct {
+	Key   string
+	Bytes int64  // as counted against cacheBytes, see GroupOptions.Cost
+	Cache string // "main" or "hot"
+}
+
+// profiler counts the Gets of the keys of a group by the Space-Saving
+// algorithm, keeping the counts of a bounded number of keys.
+type profiler struct {
+	opts  ProfileOptions
+	since time.Time
+
+	mu     sync.Mutex
+	gets   int64
+	counts keyCounts
+	index  map[string]*keyCounter
+}
+
+type keyCounter struct {
+	key          string
+	count, error int64
+	i            int // in profiler.counts
+}
+
+// keyCounts is a min-heap of counters by count.
+type keyCounts []*keyCounter
+
+func (h keyCounts) Len() int            { return len(h) }
+func (h keyCounts) Less(i, j int) bool  { return h[i].count < h[j].count }
+func (h keyCounts) Swap(i, j int)       { h[i], h[j] = h[j], h[i]; h[i].i = i; h[j].i = j }
+func (h *keyCounts) Push(x interface{}) { c := x.(*keyCounter); c.i = len(*h); *h = append(*h, c) }
+func (h *keyCounts) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+func newProfiler(opts ProfileOptions) *profiler {
+	if opts.TopN <= 0 {
+		opts.TopN = 20
+	}
+	if opts.TrackedKeys <= 0 {
+		opts.TrackedKeys = 10 * opts.TopN
+	}
+	return &profiler{opts: opts, since: time.Now(), index: make(map[string]*keyCounter)}
+}
+
+func (p *profiler) record(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gets++
+	if c, ok := p.index[key]; ok {
+		c.count++
+		heap.Fix(&p.counts, c.i)
+		return
+	}
+	if len(p.counts) < p.opts.TrackedKeys {
+		c := &keyCounter{key: key, count: 1}
+		heap.Push(&p.counts, c)
+		p.index[key] = c
+		return
+	}
+	// Replace the least counted key, inheriting its count as error.
+	c := p.counts[0]
+	delete(p.index, c.key)
+	c.key, c.error = key, c.count
+	c.count++
+	p.index[key] = c
+	heap.Fix(&p.counts, 0)
+}
+
+// hotKeys returns the TopN most counted keys.
+func (p *profiler) hotKeys() (int64, []KeyCount) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := make([]KeyCount, len(p.counts))
+	for i, c := range p.counts {
+		res[i] = KeyCount{Key: c.key, Count: c.count, Error: c.error}
+	}
+	return p.gets, res
+}
+
+// SetProfiling starts counting the Gets of the group by key for
+// Profile, restarting the counts if it was profiled already, or stops
+// if opts is nil. Profiling takes a lock on every Get, so it is meant
+// to be turned on while looking into a problem.
+func (g *Group) SetProfiling(opts *ProfileOptions) {
+	var p *profiler
+	if opts != nil {
+		p = newProfiler(*opts)
+	}
+	g.profile.Store(p)
+}
+
+// profiler returns the profiler of the group, nil unless profiling.
+func (g *Group) profiler() *profiler {
+	p, _ := g.profile.Load().(*profiler)
+	return p
+}
+
+// Profile returns the cache profile of the group, or false if it is not
+// being profiled, see SetProfiling. The largest entries are found by
+// walking the caches, which takes their read locks one shard at a time.
+func (g *Group) Profile() (CacheProfile, bool) {
+	p := g.profiler()
+	if p == nil {
+		return CacheProfile{}, false
+	}
+	prof := CacheProfile{Group: g.name, Namespace: g.opts.Namespace, Since: p.since}
+	var hot []KeyCount
+	prof.Gets, hot = p.hotKeys()
+	sortKeyCounts(hot)
+	if len(hot) > p.opts.TopN {
+		hot = hot[:p.opts.TopN]
+	}
+	prof.HotKeys = hot
+
+	var largest keySizes
+	for _, c := range []struct {
+		name  string
+		cache *cache
+	}{{"main", &g.mainCache}, {"hot", &g.hotCache}} {
+		c.cache.rangeEntries(func(key string, value ByteView) {
+			size := value.cost
+			if len(largest) < p.opts.TopN {
+				heap.Push(&largest, KeySize{key, size, c.name})
+			} else if size > largest[0].Bytes {
+				largest[0] = KeySize{key, size, c.name}
+				heap.Fix(&largest, 0)
+			}
+		})
+	}
+	prof.LargestKeys = make([]KeySize, len(largest))
+	for i := len(largest) - 1; i >= 0; i-- {
+		prof.LargestKeys[i] = heap.Pop(&largest).(KeySize)
+	}
+	return prof, true
+}
+
+func sortKeyCounts(counts []KeyCount) {
+	// Few enough keys for an insertion sort, by decreasing count.
+	for i := 1; i < len(counts); i++ {
+		for j := i; j > 0 && counts[j].Count > counts[j-1].Count; j-- {
+			counts[j], counts[j-1] = counts[j-1], counts[j]
+		}
+	}
+}
+
+// keySizes is a min-heap of entries by size.
+type keySizes []KeySize
+
+func (h keySizes) Len() int            { return len(h) }
+func (h keySizes) Less(i, j int) bool  { return h[i].Bytes < h[j].Bytes }
+func (h keySizes) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *keySizes) Push(x interface{}) { *h = append(*h, x.(KeySize)) }
+func (h *keySizes) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// profiles returns the profiles of the groups being profiled.
+func profiles() []CacheProfile {
+	var res []CacheProfile
+	for _, g := range GetGroups() {
+		if prof, ok := g.Profile(); ok {
+			res = append(res, prof)
+		}
+	}
+	return res
+}
+
+// PublishProfiles publishes the cache profiles of the groups being
+// profiled as the expvar of name, such as "groupcache_profiles". Like
+// expvar.Publish, it panics if name is published already.
+func PublishProfiles(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} { return profiles() }))
+}
+
+// ProfileHandler returns an http.Handler serving the cache profiles of
+// the groups, in the manner of the handlers of net/http/pprof. The
+// "group" query parameter limits the report to the group of that name
+// in the namespace given by "namespace", and "namespace" alone to the
+// groups of that namespace. A POST with "start" starts profiling the
+// group, with the TopN given by "top", and one with "stop" stops it, so
+// a process can be profiled without changing its code. Profiles are
+// written as text, or as JSON if "format" is "json".
+// Like DebugHandler, it shows keys, so it should only be served to
+// trusted clients.
+func ProfileHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		name, namespace := q.Get("group"), q.Get("namespace")
+		if q.Has("start") || q.Has("stop") {
+			if r.Method != http.MethodPost {
+				w.Header().Set("Allow", http.MethodPost)
+				http.Error(w, "start and stop require POST", http.StatusMethodNotAllowed)
+				return
+			}
+			g := GetGroupInNamespace(namespace, name)
+			if g == nil {
+				http.Error(w, "no such group: "+name, http.StatusNotFound)
+				return
+			}
+			if q.Has("stop") {
+				g.SetProfiling(nil)
+				return
+			}
+			top, _ := strconv.Atoi(q.Get("top"))
+			g.SetProfiling(&ProfileOptions{TopN: top})
+			return
+		}
+
+		var res []CacheProfile
+		for _, prof := range profiles() {
+			if (name != "" || q.Has("namespace")) && prof.Namespace != namespace {
+				continue
+			}
+			if name == "" || prof.Group == name {
+				res = append(res, prof)
+			}
+		}
+		if q.Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(res); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(res) == 0 {
+			fmt.Fprintln(w, "no group is being profiled; POST ?group=NAME&start to start")
+			return
+		}
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, prof := range res {
+			group := prof.Group
+			if prof.Namespace != "" {
+				group = prof.Namespace + "/" + group
+			}
+			fmt.Fprintf(tw, "group %s: %d gets since %s\n\n", group, prof.Gets, prof.Since.Format(time.RFC3339))
+			fmt.Fprintln(tw, "gets\terror\tkey")
+			for _, k := range prof.HotKeys {
+				fmt.Fprintf(tw, "%d\t%d\t%q\n", k.Count, k.Error, k.Key)
+			}
+			fmt.Fprintln(tw, "\nbytes\tcache\tkey")
+			for _, k := range prof.LargestKeys {
+				fmt.Fprintf(tw, "%d\t%s\t%q\n", k.Bytes, k.Cache, k.Key)
+			}
+			fmt.Fprintln(tw)
+		}
+		tw.Flush()
+	})
+}
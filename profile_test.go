@@ -0,0 +1,126 @@
+package groupcache
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfile(t *testing.T) {
+	const name = "TestProfile-group"
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString(strings.Repeat("x", len(key)*10), time.Time{})
+	}), NoPeers{}, &GroupOptions{Profile: &ProfileOptions{TopN: 2, TrackedKeys: 4}})
+	defer DeregisterGroup(name)
+
+	var s string
+	get := func(key string, n int) {
+		for i := 0; i < n; i++ {
+			g.Get(dummyCtx, key, StringSink(&s))
+		}
+	}
+	get("hot", 10)
+	get("warm", 5)
+	for i := 0; i < 5; i++ {
+		get("cold"+strconv.Itoa(i), 1)
+	}
+	get("a-rather-long-key", 1)
+
+	prof, ok := g.Profile()
+	if !ok {
+		t.Fatal("Profile of a profiled group = false")
+	}
+	if prof.Gets != 21 {
+		t.Errorf("Gets = %d; want 21", prof.Gets)
+	}
+	if len(prof.HotKeys) != 2 || prof.HotKeys[0] != (KeyCount{"hot", 10, 0}) || prof.HotKeys[1] != (KeyCount{"warm", 5, 0}) {
+		t.Errorf("HotKeys = %+v; want hot and warm counted exactly", prof.HotKeys)
+	}
+	if len(prof.LargestKeys) != 2 || prof.LargestKeys[0].Key != "a-rather-long-key" || prof.LargestKeys[0].Bytes != 17*11 || prof.LargestKeys[0].Cache != "main" {
+		t.Errorf("LargestKeys = %+v; want a-rather-long-key of 187 bytes first", prof.LargestKeys)
+	}
+
+	g.SetProfiling(nil)
+	if _, ok := g.Profile(); ok {
+		t.Error("Profile after SetProfiling(nil) = true")
+	}
+}
+
+func TestProfileHandler(t *testing.T) {
+	const name = "TestProfileHandler-group"
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(name)
+	ts := httptest.NewServer(ProfileHandler())
+	defer ts.Close()
+
+	read := func(res *http.Response, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	get := func(query string) string {
+		t.Helper()
+		return read(http.Get(ts.URL + "?" + query))
+	}
+	post := func(query string) string {
+		t.Helper()
+		return read(http.Post(ts.URL+"?"+query, "", nil))
+	}
+
+	// Starting takes a POST.
+	if res, err := http.Get(ts.URL + "?group=" + name + "&start"); err != nil || res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET start = %v, %v; want status %d", res, err, http.StatusMethodNotAllowed)
+	} else {
+		res.Body.Close()
+	}
+	if _, ok := g.Profile(); ok {
+		t.Error("group profiled after a GET of start")
+	}
+	post("group=" + name + "&start&top=5")
+	var s string
+	g.Get(dummyCtx, "profiled-key", StringSink(&s))
+	if body := get("group=" + name); !strings.Contains(body, `"profiled-key"`) || !strings.Contains(body, "1 gets since") {
+		t.Errorf("text profile = %q; want the Get of profiled-key", body)
+	}
+	var profs []CacheProfile
+	if err := json.Unmarshal([]byte(get("group="+name+"&format=json")), &profs); err != nil || len(profs) != 1 || len(profs[0].HotKeys) != 1 {
+		t.Errorf("JSON profile = %+v, %v; want one profile with one hot key", profs, err)
+	}
+
+	// Groups of the same name in a namespace are profiled apart.
+	other := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	}), NoPeers{}, &GroupOptions{Namespace: "ns"})
+	defer DeregisterGroupInNamespace(context.Background(), "ns", name)
+	post("namespace=ns&group=" + name + "&start")
+	other.Get(dummyCtx, "other-key", StringSink(&s))
+	if body := get("namespace=ns&group=" + name); !strings.Contains(body, "group ns/"+name) || strings.Contains(body, "profiled-key") {
+		t.Errorf("text profile of the namespace = %q; want only its group", body)
+	}
+	if body := get("group=" + name); strings.Contains(body, "other-key") {
+		t.Errorf("text profile = %q; want no key of the namespace", body)
+	}
+
+	post("group=" + name + "&stop")
+	if _, ok := g.Profile(); ok {
+		t.Error("group still profiled after stop")
+	}
+	if _, ok := other.Profile(); !ok {
+		t.Error("group of the namespace stopped with the other")
+	}
+}
@@ -0,0 +1,183 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Priority is the class of service of a Get. A GRPCPool whose
+// MaxServerConcurrency slots are all taken serves the requests waiting
+// for one in order of priority, so bulk traffic such as warming caches
+// does not add to the latency of the Gets of users. See WithPriority.
+type Priority int
+
+const (
+	// PriorityInteractive is the priority of Gets serving users, the
+	// default.
+	PriorityInteractive Priority = iota
+
+	// PriorityBulk is the priority of batch jobs, warming and other
+	// background Gets, which peers serve once no interactive request
+	// waits. Warm and the revalidation of a group use it unless their
+	// context has a priority.
+	PriorityBulk
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBulk:
+		return "bulk"
+	default:
+		return "unknown"
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx whose Gets, and the requests they
+// send to peers, have priority p.
+//
+// Concurrent Gets for the same key are deduplicated, so only the
+// context of the call that starts the load is consulted.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFromContext(ctx context.Context) (Priority, bool) {
+	if ctx == nil {
+		return PriorityInteractive, false
+	}
+	p, ok := ctx.Value(priorityKey{}).(Priority)
+	return p, ok
+}
+
+// withDefaultPriority returns ctx with priority p unless it has one.
+func withDefaultPriority(ctx context.Context, p Priority) context.Context {
+	if _, ok := priorityFromContext(ctx); ok {
+		return ctx
+	}
+	return WithPriority(ctx, p)
+}
+
+// priorityMDKey is the metadata key of the priority of a request to a
+// peer, sent unless the request is interactive.
+const priorityMDKey = "groupcache-priority"
+
+// outgoingPriority adds the priority of ctx to the metadata of the RPCs
+// made with it.
+func outgoingPriority(ctx context.Context) context.Context {
+	if p, _ := priorityFromContext(ctx); p != PriorityInteractive {
+		return metadata.AppendToOutgoingContext(ctx, priorityMDKey, p.String())
+	}
+	return ctx
+}
+
+// incomingPriority returns ctx with the priority of the inbound RPC it
+// is the context of, so the priority also applies to the requests the
+// RPC sends in turn. Priorities this peer does not know are
+// interactive.
+func incomingPriority(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(priorityMDKey); len(v) != 0 && v[0] == PriorityBulk.String() {
+		return WithPriority(ctx, PriorityBulk)
+	}
+	return ctx
+}
+
+// errNoSlot is returned by serverSlots.acquire when its queue is full.
+var errNoSlot = errors.New("groupcache: no server slot")
+
+// serverSlots are the MaxServerConcurrency slots of a pool and the
+// MaxServerQueue requests waiting for one. A freed slot goes to the
+// interactive request waiting the longest, if any, before bulk ones,
+// and an interactive request finding the queue full takes the place of
+// the last bulk request queued.
+type serverSlots struct {
+	size, queueSize int
+	stats           *GRPCPoolStats // counts ServerQueued and ServerDisplaced
+
+	mu      sync.Mutex
+	used    int
+	waiting [2][]*slotWaiter // by Priority
+}
+
+type slotWaiter struct {
+	ready    chan struct{} // closed once the waiter has a slot or is rejected
+	rejected bool          // set before ready is closed
+}
+
+func newServerSlots(size, queueSize int, stats *GRPCPoolStats) *serverSlots {
+	return &serverSlots{size: size, queueSize: queueSize, stats: stats}
+}
+
+// acquire takes a slot for a request of priority p, waiting in the
+// queue until ctx is done. The slot must then be released.
+func (s *serverSlots) acquire(ctx context.Context, p Priority) error {
+	if p != PriorityBulk {
+		p = PriorityInteractive
+	}
+	s.mu.Lock()
+	if s.used < s.size {
+		s.used++
+		s.mu.Unlock()
+		return nil
+	}
+	if len(s.waiting[PriorityInteractive])+len(s.waiting[PriorityBulk]) >= s.queueSize {
+		bulk := s.waiting[PriorityBulk]
+		if p == PriorityBulk || len(bulk) == 0 {
+			s.mu.Unlock()
+			return errNoSlot
+		}
+		last := bulk[len(bulk)-1]
+		s.waiting[PriorityBulk] = bulk[:len(bulk)-1]
+		last.rejected = true
+		close(last.ready)
+		s.stats.ServerDisplaced.Add(1)
+	}
+	w := &slotWaiter{ready: make(chan struct{})}
+	s.waiting[p] = append(s.waiting[p], w)
+	s.mu.Unlock()
+	s.stats.ServerQueued.Add(1)
+
+	select {
+	case <-w.ready:
+		if w.rejected {
+			return errNoSlot
+		}
+		return nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	for i, other := range s.waiting[p] {
+		if other == w {
+			s.waiting[p] = append(s.waiting[p][:i], s.waiting[p][i+1:]...)
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	s.mu.Unlock()
+	// The waiter was given a slot or rejected meanwhile.
+	if !w.rejected {
+		s.release()
+	}
+	return ctx.Err()
+}
+
+// release frees a slot, handing it to the next waiter if any.
+func (s *serverSlots) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for p := range s.waiting {
+		if q := s.waiting[p]; len(q) != 0 {
+			s.waiting[p] = q[1:]
+			close(q[0].ready)
+			return
+		}
+	}
+	s.used--
+}
@@ -0,0 +1,158 @@
+package groupcache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestServerSlotsPriority(t *testing.T) {
+	var stats GRPCPoolStats
+	s := newServerSlots(1, 2, &stats)
+	ctx := context.Background()
+	if err := s.acquire(ctx, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan Priority, 2)
+	wait := func(p Priority) {
+		if err := s.acquire(ctx, p); err != nil {
+			t.Error(err)
+			return
+		}
+		served <- p
+		s.release()
+	}
+	go wait(PriorityBulk)
+	for stats.ServerQueued.Get() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	go wait(PriorityInteractive)
+	for stats.ServerQueued.Get() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	s.release()
+	if p := <-served; p != PriorityInteractive {
+		t.Errorf("first request served from the queue is %v; want %v", p, PriorityInteractive)
+	}
+	if p := <-served; p != PriorityBulk {
+		t.Errorf("second request served from the queue is %v; want %v", p, PriorityBulk)
+	}
+}
+
+func TestServerSlotsDisplace(t *testing.T) {
+	var stats GRPCPoolStats
+	s := newServerSlots(1, 1, &stats)
+	ctx := context.Background()
+	if err := s.acquire(ctx, PriorityBulk); err != nil {
+		t.Fatal(err)
+	}
+
+	bulk := make(chan error, 1)
+	go func() { bulk <- s.acquire(ctx, PriorityBulk) }()
+	for stats.ServerQueued.Get() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.acquire(ctx, PriorityBulk); err != errNoSlot {
+		t.Errorf("bulk acquire on a full queue = %v; want %v", err, errNoSlot)
+	}
+
+	interactive := make(chan error, 1)
+	go func() { interactive <- s.acquire(ctx, PriorityInteractive) }()
+	if err := <-bulk; err != errNoSlot {
+		t.Errorf("displaced bulk acquire = %v; want %v", err, errNoSlot)
+	}
+	if n := stats.ServerDisplaced.Get(); n != 1 {
+		t.Errorf("ServerDisplaced = %d; want 1", n)
+	}
+	s.release()
+	if err := <-interactive; err != nil {
+		t.Errorf("interactive acquire = %v", err)
+	}
+
+	// A canceled waiter leaves the queue.
+	cctx, cancel := context.WithCancel(ctx)
+	go func() {
+		for stats.ServerQueued.Get() != 3 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	if err := s.acquire(cctx, PriorityInteractive); err != context.Canceled {
+		t.Errorf("canceled acquire = %v; want %v", err, context.Canceled)
+	}
+	s.release()
+	if err := s.acquire(ctx, PriorityBulk); err != nil {
+		t.Errorf("acquire after the queue emptied = %v", err)
+	}
+}
+
+// priorityPeer is an in-process gcgrpc.PeerServer recording the
+// priority metadata of the last RetrieveRequest it received.
+type priorityPeer struct {
+	gcgrpc.UnimplementedPeerServer
+	mu       sync.Mutex
+	priority []string
+}
+
+func (p *priorityPeer) Retrieve(ctx context.Context, req *gcgrpc.RetrieveRequest) (*gcgrpc.RetrieveResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.priority = md.Get(priorityMDKey)
+	return &gcgrpc.RetrieveResponse{Value: []byte("got:" + string(req.Key))}, nil
+}
+
+func TestGRPCPoolPriority(t *testing.T) {
+	peer := &priorityPeer{}
+	addr, stop := startTestPeer(t, peer)
+	defer stop()
+	pool := newGRPCPool("client", nil)
+	pool.Set(addr)
+	defer pool.Set()
+
+	const name = "TestGRPCPoolPriority-group"
+	g := newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		t.Error("local getter called for a key of the peer")
+		return dest.SetString("local", time.Time{})
+	}), pool)
+	defer DeregisterGroup(name)
+
+	priority := func() []string {
+		peer.mu.Lock()
+		defer peer.mu.Unlock()
+		return peer.priority
+	}
+	var s string
+	if err := g.Get(WithPriority(context.Background(), PriorityBulk), "bulk", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if p := priority(); len(p) != 1 || p[0] != "bulk" {
+		t.Errorf("priority of a bulk Get = %q; want bulk", p)
+	}
+	if err := g.Get(context.Background(), "interactive", StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if p := priority(); len(p) != 0 {
+		t.Errorf("priority of a plain Get = %q; want none", p)
+	}
+	if err := g.Warm(context.Background(), []string{"warm"}, 1, &WarmOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if p := priority(); len(p) != 1 || p[0] != "bulk" {
+		t.Errorf("priority of Warm = %q; want bulk", p)
+	}
+
+	in := metadata.NewIncomingContext(context.Background(), metadata.Pairs(priorityMDKey, "bulk"))
+	if p, _ := priorityFromContext(incomingPriority(in)); p != PriorityBulk {
+		t.Errorf("priority of an inbound bulk RPC = %v; want %v", p, PriorityBulk)
+	}
+	in = metadata.NewIncomingContext(context.Background(), metadata.Pairs(priorityMDKey, "urgent"))
+	if p, _ := priorityFromContext(incomingPriority(in)); p != PriorityInteractive {
+		t.Errorf("priority of an inbound RPC of unknown priority = %v; want %v", p, PriorityInteractive)
+	}
+}
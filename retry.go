@@ -0,0 +1,132 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryOptions are the configurations of the retries of failed
+// Retrieve requests to a peer, or of failed getter calls with
+// GroupOptions.GetterRetry.
+type RetryOptions struct {
+	// Attempts is the total number of times a request is sent,
+	// including the first one.
+	// If blank, it defaults to 3.
+	Attempts int
+
+	// Backoff is the wait before the first retry. It doubles before
+	// every further retry.
+	// If blank, it defaults to 50 milliseconds.
+	Backoff time.Duration
+
+	// Timeout bounds every attempt separately. The context of the
+	// caller still bounds all attempts together.
+	// If zero, attempts are only bounded by the caller's context.
+	Timeout time.Duration
+}
+
+type retryPolicy struct {
+	opts      RetryOptions
+	retryable func(error) bool
+}
+
+func newRetryPolicy(opts RetryOptions, retryable func(error) bool) *retryPolicy {
+	if opts.Attempts <= 0 {
+		opts.Attempts = 3
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 50 * time.Millisecond
+	}
+	return &retryPolicy{opts: opts, retryable: retryable}
+}
+
+// do calls fn until it succeeds, fails with an error that is not worth
+// retrying, the attempts are used up or ctx is done. A nil policy calls
+// fn once.
+func (r *retryPolicy) do(ctx context.Context, fn func(context.Context) error) error {
+	if r == nil {
+		return fn(ctx)
+	}
+	backoff := r.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		err := r.attempt(ctx, fn)
+		if err == nil || attempt == r.opts.Attempts || ctx.Err() != nil || !r.retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (r *retryPolicy) attempt(ctx context.Context, fn func(context.Context) error) error {
+	if r.opts.Timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// retryable reports whether a request that failed with err may succeed
+// when sent again. Errors from the peer's getter and unknown groups are
+// not retried, nor is codes.ResourceExhausted, with which a loaded peer
+// sheds requests and oversized messages fail.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// Temporary marks err, returned by a Getter, as a transient failure
+// that a group with GroupOptions.GetterRetry retries with backoff.
+func Temporary(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &getterError{err: err, temporary: true}
+}
+
+// Permanent marks err, returned by a Getter, as a failure that will
+// not go away by calling the getter again, which a group with
+// GroupOptions.PermanentErrorTTL remembers for the key.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &getterError{err: err}
+}
+
+// getterError is an error marked by Temporary or Permanent.
+type getterError struct {
+	err       error
+	temporary bool
+}
+
+func (e *getterError) Error() string   { return e.err.Error() }
+func (e *getterError) Unwrap() error   { return e.err }
+func (e *getterError) Temporary() bool { return e.temporary }
+
+// IsTemporary reports whether err, or an error it wraps, was marked by
+// Temporary or has a Temporary method returning true, as some net
+// errors do.
+func IsTemporary(err error) bool {
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
+
+// IsPermanent reports whether err, or an error it wraps, was marked by
+// Permanent.
+func IsPermanent(err error) bool {
+	var e *getterError
+	return errors.As(err, &e) && !e.temporary
+}
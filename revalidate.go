@@ -0,0 +1,139 @@
+package groupcache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Validator checks cached values against their origin, for groups of
+// data that changes without the cache being told, see
+// GroupOptions.Revalidate.
+type Validator interface {
+	// Validate reports whether value, cached for key, is still the
+	// current value at the origin, for example by comparing a version
+	// or ETag stored in the value with that of the origin, which is
+	// usually cheaper than loading it again. An error leaves the value
+	// cached.
+	Validate(ctx context.Context, key string, value ByteView) (bool, error)
+}
+
+// ValidatorFunc implements Validator with a function.
+type ValidatorFunc func(ctx context.Context, key string, value ByteView) (bool, error)
+
+func (f ValidatorFunc) Validate(ctx context.Context, key string, value ByteView) (bool, error) {
+	return f(ctx, key, value)
+}
+
+// RevalidateOptions configures the background revalidation of a group.
+type RevalidateOptions struct {
+	// Validator checks the values cached by the group.
+	Validator Validator
+
+	// Interval is how often every value cached by the group is
+	// validated. A value may thus be stale for up to Interval after
+	// the origin changed, plus the time a round takes.
+	// If blank, it defaults to 1 minute.
+	Interval time.Duration
+
+	// Concurrency is the number of Validate calls made at once.
+	// If blank, it defaults to 4.
+	Concurrency int
+
+	// Evict removes the stale values of the main cache, so the next
+	// Get loads them, rather than loading them again right away. Stale
+	// copies in the hot cache are always removed, to be fetched from
+	// their owner again.
+	Evict bool
+}
+
+func (o RevalidateOptions) withDefaults() RevalidateOptions {
+	if o.Interval <= 0 {
+		o.Interval = time.Minute
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 4
+	}
+	return o
+}
+
+// runRevalidate revalidates the cached values every Interval until the
+// group is deregistered.
+func (g *Group) runRevalidate() {
+	t := time.NewTicker(g.opts.Revalidate.withDefaults().Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-g.background.Done():
+			return
+		case <-t.C:
+			g.Revalidate(WithPriority(g.background, PriorityBulk))
+		}
+	}
+}
+
+// Revalidate validates every value cached by the group now with the
+// Validator of GroupOptions.Revalidate, reloading or removing those
+// that are stale, as is done every Interval. It returns ctx.Err() if
+// ctx is done first, and nil if the group has no Validator.
+func (g *Group) Revalidate(ctx context.Context) error {
+	if g.opts.Revalidate == nil || g.opts.Revalidate.Validator == nil {
+		return nil
+	}
+	o := g.opts.Revalidate.withDefaults()
+
+	type entry struct {
+		key   string
+		value ByteView
+		hot   bool
+	}
+	var entries []entry
+	g.mainCache.rangeEntries(func(key string, value ByteView) {
+		entries = append(entries, entry{key, value, false})
+	})
+	g.hotCache.rangeEntries(func(key string, value ByteView) {
+		entries = append(entries, entry{key, value, true})
+	})
+
+	sem := make(chan struct{}, o.Concurrency)
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(e entry) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			key, err := g.decodeKey(e.key)
+			if err != nil {
+				return
+			}
+			g.Stats.Revalidations.Add(1)
+			current, err := o.Validator.Validate(ctx, key, e.value)
+			if err != nil {
+				g.log().Debug("revalidation failed", "group", g.name, "err", err)
+				return
+			}
+			if current {
+				return
+			}
+			g.Stats.RevalidationChanges.Add(1)
+			if e.hot || o.Evict {
+				g.localRemove(e.key)
+				return
+			}
+			g.loadGroup.Forget(e.key)
+			g.loadGroup.DoContext(ctx, e.key, func(ctx context.Context) (interface{}, error) {
+				return g.fetchResult(ctx, e.key)
+			})
+		}(e)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
@@ -0,0 +1,63 @@
+package groupcache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRevalidate(t *testing.T) {
+	var mu sync.Mutex
+	versions := map[string]string{"a": "1", "b": "1"}
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return dest.SetString(key+":"+versions[key], time.Time{})
+	})
+	validator := ValidatorFunc(func(_ context.Context, key string, value ByteView) (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return value.String() == key+":"+versions[key], nil
+	})
+
+	for _, evict := range []bool{false, true} {
+		name := "TestRevalidate-group"
+		if evict {
+			name += "-evict"
+		}
+		mu.Lock()
+		versions["a"], versions["b"] = "1", "1"
+		mu.Unlock()
+		g := newGroupOpts(name, cacheSize, getter, NoPeers{}, &GroupOptions{
+			Revalidate: &RevalidateOptions{Validator: validator, Interval: time.Hour, Evict: evict},
+		})
+		var s string
+		for _, key := range []string{"a", "b"} {
+			if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		mu.Lock()
+		versions["a"] = "2"
+		mu.Unlock()
+		if err := g.Revalidate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if evict {
+			if _, ok := g.Peek("a"); ok {
+				t.Error("stale value of a still cached with Evict")
+			}
+		} else if v, ok := g.Peek("a"); !ok || v.String() != "a:2" {
+			t.Errorf("a after Revalidate = %q, %v; want the reloaded a:2", v.String(), ok)
+		}
+		if v, ok := g.Peek("b"); !ok || v.String() != "b:1" {
+			t.Errorf("b after Revalidate = %q, %v; want the current b:1", v.String(), ok)
+		}
+		if s := g.Snapshot(); s.Revalidations != 2 || s.RevalidationChanges != 1 {
+			t.Errorf("Revalidations, RevalidationChanges = %d, %d; want 2, 1", s.Revalidations, s.RevalidationChanges)
+		}
+		DeregisterGroup(name)
+	}
+}
@@ -0,0 +1,206 @@
+package groupcache
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+)
+
+// RingTuningOptions configures the tuning of the virtual nodes of the
+// hash ring done by RunRingTuning.
+type RingTuningOptions struct {
+	// Interval is the time between two adjustments of the ring.
+	// If blank, it defaults to 1 minute.
+	Interval time.Duration
+
+	// Tolerance is how far the share of a peer in the requests of an
+	// interval may be from its share by weight, as a fraction of the
+	// latter, before its virtual nodes are adjusted.
+	// If blank, it defaults to 0.1.
+	Tolerance float64
+
+	// MinRequests is the number of requests an interval must see for
+	// the ring to be adjusted, so a few requests for unlucky keys do
+	// not move it.
+	// If blank, it defaults to 1000.
+	MinRequests int64
+
+	// MinReplicas and MaxReplicas bound the virtual nodes of a peer of
+	// weight one; peers of a larger weight get as many times more.
+	// If blank, they default to a quarter of and four times
+	// GRPCPoolOptions.Replicas.
+	MinReplicas, MaxReplicas int
+}
+
+// maxTuningStep bounds the factor by which one adjustment changes the
+// virtual nodes of a peer, so the ring converges rather than swings.
+const maxTuningStep = 2
+
+// RunRingTuning adjusts the virtual nodes of the peers on the hash ring
+// every interval until ctx is done, from the share of the requests of
+// the pool each peer owned: a peer owning more than its share by weight
+// gets fewer virtual nodes, one owning less gets more. This evens out
+// rings that happen to give some peers larger ranges of keys; it cannot
+// spread the requests of a single hot key.
+//
+// Every peer must use the same ring, or they disagree on owners, so
+// each adjustment is sent to the other peers with the SetPeers RPC at
+// the next TopologyVersion, the virtual nodes given as "?replicas=N" in
+// the peer specs. Run it on a single peer, whose requests stand for
+// those of the cluster, and have controllers keep these parameters of
+// the specs GetTopology returns: a peer list without them resets the
+// virtual nodes. Nothing is tuned with Gossip, whose membership does
+// not carry virtual nodes. It blocks, so it is usually run in its own
+// goroutine. Nothing is tuned unless GRPCPoolOptions.RingTuning is set.
+func (gp *GRPCPool) RunRingTuning(ctx context.Context) {
+	if gp.opts.RingTuning == nil {
+		gp.log().Warn("RunRingTuning called without GRPCPoolOptions.RingTuning")
+		return
+	}
+	if gp.gossip != nil {
+		gp.log().Warn("RunRingTuning called with GRPCPoolOptions.Gossip")
+		return
+	}
+	opts := gp.ringTuningOptions()
+	t := time.NewTicker(opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		if gp.tuneRing(opts) {
+			gp.distributeRing(ctx)
+		}
+	}
+}
+
+// ringTuningOptions returns GRPCPoolOptions.RingTuning with the blank
+// fields defaulted.
+func (gp *GRPCPool) ringTuningOptions() RingTuningOptions {
+	opts := *gp.opts.RingTuning
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = 0.1
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 1000
+	}
+	if opts.MinReplicas <= 0 {
+		opts.MinReplicas = gp.opts.Replicas / 4
+	}
+	if opts.MinReplicas < 1 {
+		opts.MinReplicas = 1
+	}
+	if opts.MaxReplicas <= 0 {
+		opts.MaxReplicas = gp.opts.Replicas * 4
+	}
+	return opts
+}
+
+// countPick records that peer owns a key a request was routed for.
+// gp.mu must be held.
+func (gp *GRPCPool) countPick(peer string) {
+	if gp.picks != nil {
+		gp.picks[peer]++
+	}
+}
+
+// tuneRing adjusts the virtual nodes of the peers from the requests
+// counted since the last call, and reports whether it changed them.
+func (gp *GRPCPool) tuneRing(opts RingTuningOptions) bool {
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+	picks := gp.picks
+	gp.picks = make(map[string]int64, len(picks))
+
+	var total int64
+	var weights int
+	for peer, weight := range gp.weights {
+		if !gp.ejected[peer] {
+			total += picks[peer]
+			weights += weight
+		}
+	}
+	if total < opts.MinRequests || weights == 0 {
+		return false
+	}
+
+	var changed bool
+	for peer, weight := range gp.weights {
+		if gp.ejected[peer] {
+			continue
+		}
+		share := float64(picks[peer]) / float64(total)
+		ratio := share * float64(weights) / float64(weight)
+		if math.Abs(ratio-1) <= opts.Tolerance {
+			continue
+		}
+		ratio = math.Max(1.0/maxTuningStep, math.Min(maxTuningStep, ratio))
+		points := gp.peers.Replicas(peer)
+		next := int(math.Round(float64(points) / ratio))
+		if min := opts.MinReplicas * weight; next < min {
+			next = min
+		}
+		if max := opts.MaxReplicas * weight; next > max {
+			next = max
+		}
+		if next != points {
+			gp.peers.SetReplicas(peer, next)
+			gp.setReplicas(peer, next)
+			changed = true
+		}
+	}
+	if changed {
+		gp.Stats.RingTunings.Add(1)
+		gp.peersChanged()
+	}
+	return changed
+}
+
+// distributeTimeout bounds the sending of a tuned ring to the peers.
+const distributeTimeout = 5 * time.Second
+
+// distributeRing sends the peer list of the pool, with the virtual
+// nodes of the peers, to every other peer at the next topology version,
+// so they apply the ring tuned by this pool.
+func (gp *GRPCPool) distributeRing(ctx context.Context) {
+	gp.versionMu.Lock()
+	gp.version++
+	version := gp.version
+	gp.mu.Lock()
+	specs := make([]string, 0, len(gp.weights))
+	for peer, weight := range gp.weights {
+		specs = append(specs, peerSpecString(peer, weight, gp.zones[peer], gp.replicas[peer]))
+	}
+	var getters []*grpcGetter
+	for peer, getter := range gp.grpcGetters {
+		if peer != gp.self {
+			getters = append(getters, getter)
+		}
+	}
+	gp.mu.Unlock()
+	gp.versionMu.Unlock()
+	sort.Strings(specs)
+
+	ctx, cancel := context.WithTimeout(ctx, distributeTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, getter := range getters {
+		wg.Add(1)
+		go func(getter *grpcGetter) {
+			defer wg.Done()
+			if err := getter.setPeers(ctx, &gcgrpc.Peers{PeerAddr: specs, Version: version}); err != nil {
+				gp.log().Warn("Failed to send tuned ring", "peer", getter.address, "err", err)
+			}
+		}(getter)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,99 @@
+package groupcache
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/adistroy/groupcache/v3/gcgrpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// ServeOptions configures Serve and ListenAndServe.
+type ServeOptions struct {
+	// ServerOptions are passed to grpc.NewServer after those returned
+	// by GRPCPoolOptions.ServerOptions.
+	ServerOptions []grpc.ServerOption
+
+	// Drain hands the main cache entries of the pool's groups off with
+	// Drain once ctx is done, before the server stops.
+	// If nil, nothing is handed off.
+	Drain *DrainOptions
+
+	// ShutdownTimeout is how long the RPCs in flight when ctx is done
+	// may take to finish before the server is stopped forcibly.
+	// If blank, it defaults to 30s.
+	ShutdownTimeout time.Duration
+}
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// ListenAndServe listens on the TCP address addr and serves the pool
+// with Serve.
+func (gp *GRPCPool) ListenAndServe(ctx context.Context, addr string, opts *ServeOptions) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("groupcache: listening on %s: %w", addr, err)
+	}
+	return gp.Serve(ctx, lis, opts)
+}
+
+// Serve creates a grpc.Server, registers the pool and the standard gRPC
+// health service on it and serves peers on lis until ctx is done, for
+// processes that do not run a gRPC server of their own; create the pool
+// with a nil server then. Once ctx is done the health service reports
+// NOT_SERVING, the entries are drained if opts.Drain is set, and the
+// server stops accepting RPCs and waits for those in flight before Serve
+// returns nil. It returns early with the error of the server if serving
+// fails.
+func (gp *GRPCPool) Serve(ctx context.Context, lis net.Listener, opts *ServeOptions) error {
+	var o ServeOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.ShutdownTimeout <= 0 {
+		o.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	server := grpc.NewServer(append(gp.opts.ServerOptions(), o.ServerOptions...)...)
+	gcgrpc.RegisterPeerServer(server, gp)
+	hs := health.NewServer()
+	hs.SetServingStatus("gcgrpc.Peer", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, hs)
+
+	errc := make(chan error, 1)
+	go func() { errc <- server.Serve(lis) }()
+	select {
+	case err := <-errc:
+		hs.Shutdown()
+		return fmt.Errorf("groupcache: serving peers: %w", err)
+	case <-ctx.Done():
+	}
+
+	hs.Shutdown()
+	if o.Drain != nil {
+		dctx, cancel := context.WithTimeout(context.Background(), o.ShutdownTimeout)
+		if err := gp.Drain(dctx, o.Drain); err != nil {
+			gp.log().Warn("Failed to drain before shutdown", "err", err)
+		}
+		cancel()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	t := time.NewTimer(o.ShutdownTimeout)
+	defer t.Stop()
+	select {
+	case <-stopped:
+	case <-t.C:
+		server.Stop()
+		<-stopped
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package groupcache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/adistroy/groupcache/v3/groupcachepb"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGRPCPoolServe(t *testing.T) {
+	const name = "TestGRPCPoolServe-group"
+	newGroup(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("served:"+key, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(name)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	server := newGRPCPool(addr, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx, lis, &ServeOptions{ShutdownTimeout: time.Second}) }()
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	res, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: "gcgrpc.Peer"}, grpc.WaitForReady(true))
+	if err != nil || res.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("health = %v, %v; want SERVING", res, err)
+	}
+
+	client := newGRPCPool("client", nil)
+	client.Set(addr)
+	defer client.Set()
+	group, key := name, "key"
+	out := &pb.GetResponse{}
+	if err := client.grpcGetters[addr].Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, out); err != nil || string(out.Value) != "served:key" {
+		t.Fatalf("Get = %q, %v; want served:key", out.Value, err)
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve = %v; want nil after ctx is done", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after ctx was done")
+	}
+}
@@ -18,31 +18,126 @@ limitations under the License.
 // mechanism.
 package singleflight
 
-import "sync"
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
 
 // call is an in-flight or completed Do call
 type call struct {
 	wg  sync.WaitGroup
 	val interface{}
 	err error
+
+	// Set by DoContext only.
+	done    chan struct{}      // closed once val and err are set
+	waiters int                // callers waiting for the call, guarded by Group.mu
+	cancel  context.CancelFunc // cancels the context of fn
 }
 
 // Group represents a class of work and forms a namespace in which
 // units of work can be executed with duplicate suppression.
 type Group struct {
-	mu sync.Mutex       // protects m
-	m  map[string]*call // lazily initialized
+	// ErrorCooldown is how long the error of a failed call is returned
+	// to the later callers of its key without calling their function,
+	// so a failing backend is not hit again by every caller.
+	// If zero, errors are not remembered.
+	ErrorCooldown time.Duration
+
+	// CooldownJitter adds up to this fraction of ErrorCooldown, at
+	// random, to the cooldown of each error, so processes that saw
+	// the same failure do not all call again at the same time.
+	// If zero, every error is remembered for ErrorCooldown exactly.
+	CooldownJitter float64
+
+	// RememberError reports whether err is worth remembering for
+	// ErrorCooldown.
+	// If nil, every error is.
+	RememberError func(err error) bool
+
+	mu      sync.Mutex          // protects m, c, errs and sweepAt
+	m       map[string]*call    // lazily initialized
+	c       map[string]*call    // calls made with DoContext, lazily initialized
+	errs    map[string]cooldown // errors in their cooldown, lazily initialized
+	sweepAt int                 // size of errs at which expired errors are dropped
+}
+
+// cooldown is a remembered error of a call.
+type cooldown struct {
+	err   error
+	until time.Time
+}
+
+// cooledErr returns the error of the last call of key if it is still
+// in its cooldown. g.mu must be held.
+func (g *Group) cooledErr(key string) (error, bool) {
+	e, ok := g.errs[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.until) {
+		delete(g.errs, key)
+		return nil, false
+	}
+	return e.err, true
+}
+
+// remember starts the cooldown of err, returned by the call of key.
+// g.mu must be held.
+func (g *Group) remember(key string, err error) {
+	if err == nil || g.ErrorCooldown <= 0 || (g.RememberError != nil && !g.RememberError(err)) {
+		return
+	}
+	if g.errs == nil {
+		g.errs = make(map[string]cooldown)
+	}
+	now := time.Now()
+	if len(g.errs) >= g.sweepAt {
+		for k, e := range g.errs {
+			if now.After(e.until) {
+				delete(g.errs, k)
+			}
+		}
+		g.sweepAt = 2*len(g.errs) + 64
+	}
+	d := g.ErrorCooldown
+	if g.CooldownJitter > 0 {
+		d += time.Duration(rand.Float64() * g.CooldownJitter * float64(d))
+	}
+	g.errs[key] = cooldown{err: err, until: now.Add(d)}
+}
+
+// Forget ends the cooldown of the error of key, so the next call of
+// key runs its function.
+func (g *Group) Forget(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.errs, key)
+}
+
+// ForgetAll ends the cooldown of every error.
+func (g *Group) ForgetAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.errs = nil
 }
 
 // Do executes and returns the results of the given function, making
 // sure that only one execution is in-flight for a given key at a
 // time. If a duplicate comes in, the duplicate caller waits for the
-// original to complete and receives the same results.
+// original to complete and receives the same results. A caller coming
+// within ErrorCooldown of a failed call receives its error.
 func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
 	g.mu.Lock()
 	if g.m == nil {
 		g.m = make(map[string]*call)
 	}
+	if err, ok := g.cooledErr(key); ok {
+		g.mu.Unlock()
+		return nil, err
+	}
 	if c, ok := g.m[key]; ok {
 		g.mu.Unlock()
 		c.wg.Wait()
@@ -58,11 +153,80 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 
 	g.mu.Lock()
 	delete(g.m, key)
+	g.remember(key, c.err)
 	g.mu.Unlock()
 
 	return c.val, c.err
 }
 
+// DoContext is like Do, but each caller stops waiting and returns
+// ctx.Err() as soon as its own ctx is done, while the call carries on
+// for the callers still waiting. fn runs in its own goroutine with a
+// context that has the values of the first caller's ctx but is only
+// canceled once every waiting caller has left, so fn is aborted when
+// nobody wants its result any more. The errors of calls that every
+// caller left are not remembered for ErrorCooldown.
+//
+// Calls made with Do and DoContext for the same key are not
+// deduplicated with each other, but share the cooldown of errors.
+func (g *Group) DoContext(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.c == nil {
+		g.c = make(map[string]*call)
+	}
+	if err, ok := g.cooledErr(key); ok {
+		g.mu.Unlock()
+		return nil, err
+	}
+	c, ok := g.c[key]
+	if !ok {
+		fnCtx, cancel := context.WithCancel(detached{ctx})
+		c = &call{done: make(chan struct{}), cancel: cancel}
+		g.c[key] = c
+		go func() {
+			c.val, c.err = fn(fnCtx)
+			g.mu.Lock()
+			if g.c[key] == c {
+				delete(g.c, key)
+				g.remember(key, c.err)
+			}
+			g.mu.Unlock()
+			close(c.done)
+			cancel()
+		}()
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		if c.waiters--; c.waiters == 0 {
+			// Later callers must start a new call rather than join
+			// this canceled one.
+			if g.c[key] == c {
+				delete(g.c, key)
+			}
+			c.cancel()
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// detached is a context with the values of its parent but without its
+// deadline and cancellation.
+type detached struct {
+	parent context.Context
+}
+
+func (detached) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detached) Done() <-chan struct{}               { return nil }
+func (detached) Err() error                          { return nil }
+func (d detached) Value(key interface{}) interface{} { return d.parent.Value(key) }
+
 // Lock prevents single flights from occurring for the duration
 // of the provided function. This allows users to clear caches
 // or preform some operation in between running flights.
@@ -17,6 +17,7 @@ limitations under the License.
 package singleflight
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -83,3 +84,154 @@ func TestDoDupSuppress(t *testing.T) {
 		t.Errorf("number of calls = %d; want 1", got)
 	}
 }
+
+func TestDoContextCancel(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	started := make(chan struct{})
+	fnDone := make(chan error, 1)
+	fn := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		select {
+		case <-release:
+			return "bar", nil
+		case <-ctx.Done():
+			fnDone <- ctx.Err()
+			return nil, ctx.Err()
+		}
+	}
+
+	// The first caller gives up; the second still gets the result.
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	res1 := make(chan error, 1)
+	go func() {
+		_, err := g.DoContext(ctx1, "key", fn)
+		res1 <- err
+	}()
+	<-started
+	res2 := make(chan interface{}, 1)
+	go func() {
+		v, _ := g.DoContext(context.Background(), "key", fn)
+		res2 <- v
+	}()
+	// Wait for the second caller to join before canceling the first.
+	for {
+		g.mu.Lock()
+		n := g.c["key"].waiters
+		g.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel1()
+	if err := <-res1; err != context.Canceled {
+		t.Errorf("canceled caller got %v; want context.Canceled", err)
+	}
+	close(release)
+	if v := <-res2; v != "bar" {
+		t.Errorf("remaining caller got %v; want bar", v)
+	}
+
+	// fn is canceled once its only caller gives up.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	started = make(chan struct{})
+	release = make(chan struct{})
+	if _, err := g.DoContext(ctx, "key", fn); err != context.DeadlineExceeded {
+		t.Errorf("DoContext error = %v; want context.DeadlineExceeded", err)
+	}
+	select {
+	case err := <-fnDone:
+		if err != context.Canceled {
+			t.Errorf("fn context error = %v; want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fn was not canceled")
+	}
+}
+
+func TestDoContextAfterCancel(t *testing.T) {
+	var g Group
+	var calls int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// Slow to notice the cancellation.
+			close(started)
+			<-release
+			return nil, ctx.Err()
+		}
+		return "fresh", nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res := make(chan error, 1)
+	go func() {
+		_, err := g.DoContext(ctx, "key", fn)
+		res <- err
+	}()
+	<-started
+	cancel()
+	if err := <-res; err != context.Canceled {
+		t.Errorf("canceled caller got %v; want context.Canceled", err)
+	}
+
+	// The canceled call is still running, but a new caller must not
+	// join it.
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	v, err := g.DoContext(ctx, "key", fn)
+	if v != "fresh" || err != nil {
+		t.Errorf("DoContext after the only caller left = %v, %v; want fresh", v, err)
+	}
+	close(release)
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("fn called %d times; want 2", got)
+	}
+}
+
+func TestErrorCooldown(t *testing.T) {
+	transient := errors.New("transient")
+	g := Group{
+		ErrorCooldown:  50 * time.Millisecond,
+		CooldownJitter: 0.5,
+		RememberError:  func(err error) bool { return err != transient },
+	}
+	var calls int32
+	failing := errors.New("down")
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, failing
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := g.DoContext(context.Background(), "key", fn); err != failing {
+			t.Fatalf("DoContext = %v; want %v", err, failing)
+		}
+	}
+	if _, err := g.Do("key", func() (interface{}, error) { return fn(nil) }); err != failing {
+		t.Errorf("Do in the cooldown of DoContext = %v; want %v", err, failing)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("fn called %d times within the cooldown; want once", got)
+	}
+
+	// The cooldown lasts between ErrorCooldown and 1.5 times it.
+	time.Sleep(80 * time.Millisecond)
+	if v, err := g.DoContext(context.Background(), "key", func(context.Context) (interface{}, error) { return "ok", nil }); v != "ok" || err != nil {
+		t.Errorf("DoContext after the cooldown = %v, %v; want ok", v, err)
+	}
+
+	g.DoContext(context.Background(), "key", fn)
+	g.Forget("key")
+	if v, _ := g.DoContext(context.Background(), "key", func(context.Context) (interface{}, error) { return "ok", nil }); v != "ok" {
+		t.Errorf("DoContext after Forget = %v; want ok", v)
+	}
+
+	g.Do("other", func() (interface{}, error) { return nil, transient })
+	if v, _ := g.Do("other", func() (interface{}, error) { return "ok", nil }); v != "ok" {
+		t.Errorf("Do after an error RememberError rejects = %v; want ok", v)
+	}
+}
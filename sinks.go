@@ -228,9 +228,26 @@ func AllocatingByteSliceSink(dst *[]byte) Sink {
 	return &allocBytesSink{dst: dst}
 }
 
+// PooledByteSliceSink is like AllocatingByteSliceSink but takes the
+// byte slice assigned to *dst from pool. Once done with *dst the caller
+// may hand it back with pool.Put; slices that are not returned are
+// simply garbage collected.
+func PooledByteSliceSink(dst *[]byte, pool *BufferPool) Sink {
+	return &allocBytesSink{dst: dst, pool: pool}
+}
+
 type allocBytesSink struct {
-	dst *[]byte
-	v   ByteView
+	dst  *[]byte
+	pool *BufferPool // nil to allocate
+	v    ByteView
+}
+
+// alloc returns a slice of length n for *s.dst.
+func (s *allocBytesSink) alloc(n int) []byte {
+	if s.pool == nil {
+		return make([]byte, n)
+	}
+	return s.pool.Get(n)
 }
 
 func (s *allocBytesSink) view() (ByteView, error) {
@@ -238,11 +255,8 @@ func (s *allocBytesSink) view() (ByteView, error) {
 }
 
 func (s *allocBytesSink) setView(v ByteView) error {
-	if v.b != nil {
-		*s.dst = cloneBytes(v.b)
-	} else {
-		*s.dst = []byte(v.s)
-	}
+	*s.dst = s.alloc(v.Len())
+	v.Copy(*s.dst)
 	s.v = v
 	return nil
 }
@@ -263,7 +277,9 @@ func (s *allocBytesSink) setBytesOwned(b []byte, e time.Time) error {
 	if s.dst == nil {
 		return errors.New("nil AllocatingByteSliceSink *[]byte dst")
 	}
-	*s.dst = cloneBytes(b) // another copy, protecting the read-only s.v.b view
+	// Another copy, protecting the read-only s.v.b view.
+	*s.dst = s.alloc(len(b))
+	copy(*s.dst, b)
 	s.v.b = b
 	s.v.s = ""
 	s.v.e = e
@@ -274,7 +290,8 @@ func (s *allocBytesSink) SetString(v string, e time.Time) error {
 	if s.dst == nil {
 		return errors.New("nil AllocatingByteSliceSink *[]byte dst")
 	}
-	*s.dst = []byte(v)
+	*s.dst = s.alloc(len(v))
+	copy(*s.dst, v)
 	s.v.b = nil
 	s.v.s = v
 	s.v.e = e
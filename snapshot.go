@@ -0,0 +1,119 @@
+package groupcache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"time"
+)
+
+// snapshotMagic starts every snapshot, followed by the group name and
+// the entries. Each entry is the key length and value length as big
+// endian uint32, the expiry as big endian Unix nanoseconds or zero,
+// then the key and value bytes. The snapshot ends at EOF.
+const snapshotMagic = "GCSNAP\x00\x01"
+
+const snapshotEntryHeader = 16
+
+// SaveSnapshot writes the unexpired entries of the main cache to w, so
+// a process shutting down can restore them with LoadSnapshot when it
+// starts again rather than reload them all. Entries are written from
+// the one the cache policy values least, so restoring a snapshot into
+// a smaller cache keeps the most valued ones. Only caches whose policy
+// is a policy.Ranger, which the built-in policies are, are saved.
+func (g *Group) SaveSnapshot(w io.Writer) error {
+	entries := g.mainCache.hottest(math.MaxInt32)
+	bw := bufio.NewWriter(w)
+	var hdr [snapshotEntryHeader]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(g.name)))
+	bw.WriteString(snapshotMagic)
+	bw.Write(hdr[:4])
+	bw.WriteString(g.name)
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		binary.BigEndian.PutUint32(hdr[0:], uint32(len(e.key)))
+		binary.BigEndian.PutUint32(hdr[4:], uint32(e.value.Len()))
+		binary.BigEndian.PutUint64(hdr[8:], uint64(unixNano(e.value.Expire())))
+		bw.Write(hdr[:])
+		bw.WriteString(e.key)
+		if _, err := e.value.WriteTo(bw); err != nil {
+			return fmt.Errorf("groupcache: writing snapshot of %s: %w", g.name, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("groupcache: writing snapshot of %s: %w", g.name, err)
+	}
+	return nil
+}
+
+// LoadSnapshot adds the entries of a snapshot written by SaveSnapshot
+// for a group of the same name to the main cache, and returns how many
+// it added. Entries that expired since, that are too large to cache or
+// whose key already has a value, which may be more recent, are
+// skipped. Entries read before an error are kept.
+func (g *Group) LoadSnapshot(r io.Reader) (int, error) {
+	br := bufio.NewReader(r)
+	var hdr [snapshotEntryHeader]byte
+	if _, err := io.ReadFull(br, hdr[:len(snapshotMagic)+4]); err != nil {
+		return 0, fmt.Errorf("groupcache: reading snapshot of %s: %w", g.name, noEOF(err))
+	}
+	if string(hdr[:len(snapshotMagic)]) != snapshotMagic {
+		return 0, fmt.Errorf("groupcache: reading snapshot of %s: not a snapshot", g.name)
+	}
+	name := make([]byte, binary.BigEndian.Uint32(hdr[len(snapshotMagic):]))
+	if len(name) != len(g.name) {
+		return 0, fmt.Errorf("groupcache: reading snapshot of %s: snapshot is of another group", g.name)
+	}
+	if _, err := io.ReadFull(br, name); err != nil {
+		return 0, fmt.Errorf("groupcache: reading snapshot of %s: %w", g.name, noEOF(err))
+	}
+	if string(name) != g.name {
+		return 0, fmt.Errorf("groupcache: reading snapshot of %s: snapshot is of group %s", g.name, name)
+	}
+
+	var n int
+	for {
+		if _, err := io.ReadFull(br, hdr[:]); err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, fmt.Errorf("groupcache: reading snapshot of %s: %w", g.name, noEOF(err))
+		}
+		klen := int64(binary.BigEndian.Uint32(hdr[0:]))
+		vlen := int64(binary.BigEndian.Uint32(hdr[4:]))
+		if klen+vlen > g.maxBytes() {
+			// Never cached, and not worth allocating.
+			if _, err := io.CopyN(ioutil.Discard, br, klen+vlen); err != nil {
+				return n, fmt.Errorf("groupcache: reading snapshot of %s: %w", g.name, noEOF(err))
+			}
+			continue
+		}
+		data := make([]byte, klen+vlen)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return n, fmt.Errorf("groupcache: reading snapshot of %s: %w", g.name, noEOF(err))
+		}
+		value := ByteView{b: data[klen:]}
+		if ns := int64(binary.BigEndian.Uint64(hdr[8:])); ns != 0 {
+			value.e = time.Unix(0, ns)
+		}
+		key := string(data[:klen])
+		if g.tooLargeToCache(key, value) {
+			continue
+		}
+		if g.localFill(key, value, &g.mainCache) {
+			n++
+		}
+	}
+}
+
+// noEOF turns an io.EOF in the middle of a snapshot into
+// io.ErrUnexpectedEOF.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
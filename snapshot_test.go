@@ -0,0 +1,109 @@
+package groupcache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	const name = "TestSnapshot-group"
+	var loads AtomicInt
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		loads.Add(1)
+		expire := time.Now().Add(time.Hour)
+		if key == "short" {
+			expire = time.Now().Add(20 * time.Millisecond)
+		}
+		return dest.SetString("value-"+key, expire)
+	})
+	g := newGroup(name, cacheSize, getter, NoPeers{})
+	var s string
+	for _, key := range []string{"a", "b", "c", "short"} {
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := g.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.Bytes()
+	DeregisterGroup(name)
+
+	// A restarted process restores the entries that did not expire.
+	time.Sleep(30 * time.Millisecond)
+	g = newGroup(name, cacheSize, getter, NoPeers{})
+	defer DeregisterGroup(name)
+	n, err := g.LoadSnapshot(bytes.NewReader(snapshot))
+	if err != nil || n != 3 {
+		t.Fatalf("LoadSnapshot = %d, %v; want 3 entries", n, err)
+	}
+	loads.Store(0)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := g.Get(dummyCtx, key, StringSink(&s)); err != nil || s != "value-"+key {
+			t.Errorf("Get(%q) = %q, %v; want value-%s", key, s, err, key)
+		}
+		if v, _ := g.Peek(key); time.Until(v.Expire()) < 50*time.Minute {
+			t.Errorf("restored %q expires at %v; want in an hour", key, v.Expire())
+		}
+	}
+	if n := loads.Get(); n != 0 {
+		t.Errorf("getter called %d times for restored keys; want 0", n)
+	}
+
+	// Keys that have a value already keep it.
+	if n, err := g.LoadSnapshot(bytes.NewReader(snapshot)); err != nil || n != 0 {
+		t.Errorf("second LoadSnapshot = %d, %v; want no entries", n, err)
+	}
+
+	other := newGroup("TestSnapshot-other", cacheSize, getter, NoPeers{})
+	defer DeregisterGroup("TestSnapshot-other")
+	if _, err := other.LoadSnapshot(bytes.NewReader(snapshot)); err == nil {
+		t.Error("LoadSnapshot of another group's snapshot succeeded")
+	}
+	if _, err := g.LoadSnapshot(bytes.NewReader([]byte("not a snapshot"))); err == nil {
+		t.Error("LoadSnapshot of garbage succeeded")
+	}
+	g.Clear(dummyCtx)
+	if _, err := g.LoadSnapshot(bytes.NewReader(snapshot[:len(snapshot)-3])); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("LoadSnapshot of a truncated snapshot = %v; want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestSnapshotSmallerCache(t *testing.T) {
+	const name = "TestSnapshotSmallerCache-group"
+	getter := GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value", time.Time{})
+	})
+	g := newGroup(name, cacheSize, getter, NoPeers{})
+	var s string
+	for i := 0; i < 10; i++ {
+		if err := g.Get(dummyCtx, strconv.Itoa(i), StringSink(&s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := g.SaveSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	DeregisterGroup(name)
+
+	// Room for three entries of 6 bytes: the three most recently used
+	// survive.
+	g = newGroup(name, 18, getter, NoPeers{})
+	defer DeregisterGroup(name)
+	if _, err := g.LoadSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		_, ok := g.Peek(strconv.Itoa(i))
+		if want := i >= 7; ok != want {
+			t.Errorf("key %d cached = %v; want %v", i, ok, want)
+		}
+	}
+}
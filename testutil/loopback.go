@@ -0,0 +1,233 @@
+// Package testutil helps test applications using groupcache without a
+// network. A LoopbackPool runs the groups of several simulated peers
+// in one process, with their requests to each other going through
+// memory, so tests can check which peer loads, owns and hot caches a
+// key, and how the application copes with slow, failing or partitioned
+// peers.
+//
+//	lp := testutil.NewLoopbackPool("a", "b", "c")
+//	defer lp.Close()
+//	for _, peer := range lp.Peers() {
+//		lp.NewGroup(peer, "users", 1<<20, getter, nil)
+//	}
+//	lp.Partition("a", "b")
+//	err := lp.Group("a", "users").Get(ctx, key, sink)
+package testutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adistroy/groupcache/v3"
+	pb "github.com/adistroy/groupcache/v3/groupcachepb"
+)
+
+// ErrPartitioned is returned by requests between peers that were
+// partitioned with Partition.
+var ErrPartitioned = errors.New("testutil: peers are partitioned")
+
+// LoopbackPool connects simulated peers in one process. Groups are
+// registered globally by name, so every peer's copy of a group is
+// registered under GroupName(name, peer).
+type LoopbackPool struct {
+	peers []string
+	pools map[string]*groupcache.Pool
+
+	mu         sync.Mutex // guards the fields below
+	groups     []string
+	latency    map[string]time.Duration
+	errs       map[string]error
+	partitions map[[2]string]bool
+	requests   map[string]int
+}
+
+// NewLoopbackPool returns a LoopbackPool of the named peers.
+func NewLoopbackPool(peers ...string) *LoopbackPool {
+	lp := &LoopbackPool{
+		peers:      append([]string(nil), peers...),
+		pools:      make(map[string]*groupcache.Pool, len(peers)),
+		latency:    make(map[string]time.Duration),
+		errs:       make(map[string]error),
+		partitions: make(map[[2]string]bool),
+		requests:   make(map[string]int),
+	}
+	for _, peer := range peers {
+		pool := groupcache.NewPool(peer, transport{lp, peer}, nil)
+		pool.Set(peers...)
+		lp.pools[peer] = pool
+	}
+	return lp
+}
+
+// GroupName returns the name the copy of group name on peer is
+// registered under.
+func GroupName(name, peer string) string {
+	return name + "@" + peer
+}
+
+// Peers returns the names of the peers.
+func (lp *LoopbackPool) Peers() []string {
+	return append([]string(nil), lp.peers...)
+}
+
+// Pool returns the PeerPicker of peer.
+func (lp *LoopbackPool) Pool(peer string) *groupcache.Pool {
+	return lp.pools[peer]
+}
+
+// NewGroup creates the copy of group name on peer. The Peers option is
+// set to the pool of peer.
+func (lp *LoopbackPool) NewGroup(peer, name string, cacheBytes int64, getter groupcache.Getter, opts *groupcache.GroupOptions) *groupcache.Group {
+	pool, ok := lp.pools[peer]
+	if !ok {
+		panic("testutil: unknown peer " + peer)
+	}
+	var o groupcache.GroupOptions
+	if opts != nil {
+		o = *opts
+	}
+	o.Peers = pool
+	g := groupcache.NewGroupOpts(GroupName(name, peer), cacheBytes, getter, &o)
+	lp.mu.Lock()
+	lp.groups = append(lp.groups, g.Name())
+	lp.mu.Unlock()
+	return g
+}
+
+// Group returns the copy of group name on peer, or nil.
+func (lp *LoopbackPool) Group(peer, name string) *groupcache.Group {
+	return groupcache.GetGroup(GroupName(name, peer))
+}
+
+// Close deregisters the groups created with NewGroup.
+func (lp *LoopbackPool) Close() {
+	lp.mu.Lock()
+	groups := lp.groups
+	lp.groups = nil
+	lp.mu.Unlock()
+	for _, name := range groups {
+		groupcache.DeregisterGroup(name)
+	}
+}
+
+// SetLatency delays every request served by peer by d.
+func (lp *LoopbackPool) SetLatency(peer string, d time.Duration) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.latency[peer] = d
+}
+
+// SetError makes every request served by peer fail with err, or
+// succeed again if err is nil.
+func (lp *LoopbackPool) SetError(peer string, err error) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.errs[peer] = err
+}
+
+// Partition makes the requests between peers a and b, in both
+// directions, fail with ErrPartitioned.
+func (lp *LoopbackPool) Partition(a, b string) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.partitions[[2]string{a, b}] = true
+	lp.partitions[[2]string{b, a}] = true
+}
+
+// Heal removes all partitions.
+func (lp *LoopbackPool) Heal() {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.partitions = make(map[[2]string]bool)
+}
+
+// Requests returns the number of requests peer was sent by the other
+// peers, including those that failed.
+func (lp *LoopbackPool) Requests(peer string) int {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	return lp.requests[peer]
+}
+
+// deliver applies the faults of a request from one peer to another and
+// returns the request with the group of the receiver.
+func (lp *LoopbackPool) deliver(ctx context.Context, from, to string, in *pb.GetRequest) (*pb.GetRequest, error) {
+	var (
+		partitioned bool
+		latency     time.Duration
+		err         error
+	)
+	// Group.Remove also sends requests to the current peer, which are
+	// neither counted nor faulted.
+	if from != to {
+		lp.mu.Lock()
+		lp.requests[to]++
+		partitioned, latency, err = lp.partitions[[2]string{from, to}], lp.latency[to], lp.errs[to]
+		lp.mu.Unlock()
+	}
+
+	if partitioned {
+		return nil, ErrPartitioned
+	}
+	if latency > 0 {
+		t := time.NewTimer(latency)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	group := strings.TrimSuffix(in.GetGroup(), "@"+from)
+	if group == in.GetGroup() {
+		return nil, fmt.Errorf("testutil: group %q was not created with NewGroup", group)
+	}
+	group = GroupName(group, to)
+	return &pb.GetRequest{Group: &group, Key: in.Key}, nil
+}
+
+// transport is the groupcache.Transport of a peer of a LoopbackPool.
+type transport struct {
+	lp   *LoopbackPool
+	self string
+}
+
+func (t transport) NewGetter(addr string) (groupcache.ProtoGetter, error) {
+	return getter{t.lp, t.self, addr}, nil
+}
+
+// Serve is not needed, as requests are handed to the groups directly.
+func (t transport) Serve(ctx context.Context, self string, h groupcache.PeerHandler) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type getter struct {
+	lp       *LoopbackPool
+	from, to string
+}
+
+func (g getter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	req, err := g.lp.deliver(ctx, g.from, g.to, in)
+	if err != nil {
+		return err
+	}
+	return groupcache.LocalHandler().Get(ctx, req, out)
+}
+
+func (g getter) Remove(ctx context.Context, in *pb.GetRequest) error {
+	req, err := g.lp.deliver(ctx, g.from, g.to, in)
+	if err != nil {
+		return err
+	}
+	return groupcache.LocalHandler().Remove(ctx, req)
+}
+
+func (g getter) GetURL() string { return g.to }
@@ -0,0 +1,104 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adistroy/groupcache/v3"
+)
+
+// newTestPool returns a LoopbackPool of peers a, b and c with a group
+// named name on each, and the number of loads of each peer's getter.
+func newTestPool(t *testing.T, name string) (*LoopbackPool, func(peer string) int) {
+	lp := NewLoopbackPool("a", "b", "c")
+	t.Cleanup(lp.Close)
+	var mu sync.Mutex
+	loads := map[string]int{}
+	for _, peer := range lp.Peers() {
+		peer := peer
+		lp.NewGroup(peer, name, 1<<20, groupcache.GetterFunc(func(_ context.Context, key string, dest groupcache.Sink) error {
+			mu.Lock()
+			loads[peer]++
+			mu.Unlock()
+			return dest.SetString(peer+":"+key, time.Time{})
+		}), nil)
+	}
+	return lp, func(peer string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return loads[peer]
+	}
+}
+
+// ownedBy returns a key starting with prefix owned by peer.
+func ownedBy(lp *LoopbackPool, peer, prefix string) string {
+	for i := 0; ; i++ {
+		key := prefix + strconv.Itoa(i)
+		if _, remote := lp.Pool(peer).PickPeer(key); !remote {
+			return key
+		}
+	}
+}
+
+func TestLoopbackPool(t *testing.T) {
+	lp, loads := newTestPool(t, "TestLoopbackPool")
+	ctx := context.Background()
+	key := ownedBy(lp, "b", "")
+
+	var s string
+	if err := lp.Group("a", "TestLoopbackPool").Get(ctx, key, groupcache.StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "b:" + key; s != want {
+		t.Errorf("Get from a = %q; want %q loaded by the owner", s, want)
+	}
+	if loads("a") != 0 || loads("b") != 1 || lp.Requests("b") != 1 {
+		t.Errorf("loads a=%d b=%d with %d requests to b; want 0, 1, 1", loads("a"), loads("b"), lp.Requests("b"))
+	}
+
+	if err := lp.Group("a", "TestLoopbackPool").Remove(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := lp.Group("c", "TestLoopbackPool").Get(ctx, key, groupcache.StringSink(&s)); err != nil {
+		t.Fatal(err)
+	}
+	if loads("b") != 2 {
+		t.Errorf("owner loaded %d times after Remove; want 2", loads("b"))
+	}
+}
+
+func TestLoopbackPoolFaults(t *testing.T) {
+	lp, loads := newTestPool(t, "TestLoopbackPoolFaults")
+	ctx := context.Background()
+	g := lp.Group("a", "TestLoopbackPoolFaults")
+	var s string
+
+	lp.Partition("a", "b")
+	key := ownedBy(lp, "b", "partitioned-")
+	if err := g.Get(ctx, key, groupcache.StringSink(&s)); err != nil || s != "a:"+key {
+		t.Errorf("Get across a partition = %q, %v; want a local load", s, err)
+	}
+	lp.Heal()
+
+	lp.SetError("c", errors.New("injected"))
+	key = ownedBy(lp, "c", "failing-")
+	if err := g.Get(ctx, key, groupcache.StringSink(&s)); err != nil || s != "a:"+key {
+		t.Errorf("Get from a failing owner = %q, %v; want a local load", s, err)
+	}
+	lp.SetError("c", nil)
+
+	lp.SetLatency("b", time.Hour)
+	key = ownedBy(lp, "b", "slow-")
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := g.Get(tctx, key, groupcache.StringSink(&s)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get from a slow owner = %v; want the deadline to be exceeded", err)
+	}
+	if n := loads("b"); n != 0 {
+		t.Errorf("slow owner loaded %d times; want 0", n)
+	}
+}
@@ -0,0 +1,112 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// TierTwo is a second, typically persistent, cache that a group
+// consults after its in-memory caches miss and before calling its
+// getter, so a restarted peer does not load every key it owns from the
+// origin again. Values loaded by the getter, or set with Group.Set on
+// their owner, are written to it. The disk package implements TierTwo
+// with local files.
+//
+// The group passed to a TierTwo is the name of the group, or its
+// namespace, a NUL byte and its name for a group with a Namespace, so
+// groups of the same name in different namespaces can share one.
+//
+// Errors of a TierTwo never fail a Get: the getter is called instead.
+type TierTwo interface {
+	// Get returns the value and expiry of key in group, or an error
+	// wrapping ErrNotFound if it has none.
+	Get(ctx context.Context, group, key string) (value []byte, expire time.Time, err error)
+
+	// Set stores value for key in group until expire. A zero expire
+	// never expires.
+	Set(ctx context.Context, group, key string, value []byte, expire time.Time) error
+
+	// Remove removes key from group.
+	Remove(ctx context.Context, group, key string) error
+}
+
+// TierTwoClearer is implemented by a TierTwo that can drop all values
+// of a group. Flushing a group clears its TierTwo if it is one.
+type TierTwoClearer interface {
+	Clear(ctx context.Context, group string) error
+}
+
+// defaultTierTwoTimeout bounds the calls of a TierTwo unless
+// GroupOptions.TierTwoTimeout is set.
+const defaultTierTwoTimeout = time.Second
+
+// tierTwoContext returns ctx bounded by the group's TierTwoTimeout.
+func (g *Group) tierTwoContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := g.opts.TierTwoTimeout
+	if timeout <= 0 {
+		timeout = defaultTierTwoTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// getTierTwo returns the value of key in the group's TierTwo, if any.
+func (g *Group) getTierTwo(ctx context.Context, key string) (ByteView, bool) {
+	t := g.opts.TierTwo
+	if t == nil {
+		return ByteView{}, false
+	}
+	ctx, span := startSpan(ctx, "groupcache.getTierTwo", g.name, key)
+	ctx, cancel := g.tierTwoContext(ctx)
+	value, expire, err := t.Get(ctx, g.qualifiedName(), key)
+	cancel()
+	endSpan(span, err)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			g.logTierTwo("get", key, err)
+		}
+		return ByteView{}, false
+	}
+	if !expire.IsZero() && expire.Before(time.Now()) {
+		return ByteView{}, false
+	}
+	g.Stats.TierTwoHits.Add(1)
+	return ByteView{b: value, e: expire}, true
+}
+
+// setTierTwo writes value to the group's TierTwo, if any.
+func (g *Group) setTierTwo(key string, value ByteView) {
+	if t := g.opts.TierTwo; t != nil {
+		ctx, cancel := g.tierTwoContext(g.background)
+		defer cancel()
+		if err := t.Set(ctx, g.qualifiedName(), key, value.ByteSlice(), value.Expire()); err != nil {
+			g.logTierTwo("set", key, err)
+		}
+	}
+}
+
+// removeTierTwo removes key from the group's TierTwo, if any.
+func (g *Group) removeTierTwo(key string) {
+	if t := g.opts.TierTwo; t != nil {
+		ctx, cancel := g.tierTwoContext(g.background)
+		defer cancel()
+		if err := t.Remove(ctx, g.qualifiedName(), key); err != nil {
+			g.logTierTwo("remove", key, err)
+		}
+	}
+}
+
+// clearTierTwo empties the group's TierTwo if it is a TierTwoClearer.
+func (g *Group) clearTierTwo() {
+	if t, ok := g.opts.TierTwo.(TierTwoClearer); ok {
+		ctx, cancel := g.tierTwoContext(g.background)
+		defer cancel()
+		if err := t.Clear(ctx, g.qualifiedName()); err != nil {
+			g.logTierTwo("clear", "", err)
+		}
+	}
+}
+
+func (g *Group) logTierTwo(op, key string, err error) {
+	g.log().Error("tier two "+op+" failed", "group", g.name, "key", key, "err", err)
+}
@@ -0,0 +1,132 @@
+package groupcache
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/adistroy/groupcache/v3/consistenthash"
+	"github.com/adistroy/groupcache/v3/hashring"
+)
+
+// TopologyChange describes a change of the hash ring of a GRPCPool, as
+// reported to GRPCPoolOptions.OnTopologyChange.
+type TopologyChange struct {
+	// Peers are the peers on the ring after the change, sorted.
+	Peers []string
+
+	// Moved are the ranges of key hashes that changed owner.
+	Moved []consistenthash.Range
+
+	// MovedFraction is about the fraction of the keys that changed
+	// owner, from 0 to 1.
+	MovedFraction float64
+
+	prev, next *consistenthash.Map
+}
+
+// Owners returns the owner of key before and after the change, so keys
+// that moved to the current peer can be warmed, for example with
+// Group.Warm. An owner is empty if the ring was empty.
+func (c TopologyChange) Owners(key string) (before, after string) {
+	return c.prev.Get(key), c.next.Get(key)
+}
+
+// Ring returns a description of the current hash ring of the pool,
+// for routers and clients outside the cluster that send requests to the
+// owners of their keys, see package hashring. Peers ejected by health
+// checks are not on the ring. A ring taken from a pool with a
+// GRPCPoolOptions.HashFn is routed with the same function.
+func (gp *GRPCPool) Ring() hashring.Ring {
+	r := hashring.Ring{
+		Version:  gp.TopologyVersion(),
+		Hash:     hashring.HashCRC32,
+		Replicas: gp.opts.Replicas,
+	}
+	if gp.opts.HashFn != nil {
+		r.Hash = hashring.HashCustom
+	}
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+	points := gp.peers.Points()
+	index := make(map[string]int)
+	for _, p := range points {
+		index[p.Item] = 0
+	}
+	for peer := range index {
+		r.Peers = append(r.Peers, hashring.Peer{
+			Addr:     peer,
+			Weight:   gp.peers.Weight(peer),
+			Zone:     gp.zones[peer],
+			Replicas: gp.peers.Replicas(peer),
+		})
+	}
+	sort.Slice(r.Peers, func(i, j int) bool { return r.Peers[i].Addr < r.Peers[j].Addr })
+	for i, peer := range r.Peers {
+		index[peer.Addr] = i
+	}
+	r.Points = make([]hashring.Point, len(points))
+	for i, p := range points {
+		r.Points[i] = hashring.Point{Hash: p.Hash, Peer: index[p.Item]}
+	}
+	return r
+}
+
+// topologyChanged reports the changes of the ring since it was last
+// reported to OnTopologyChange. gp.mu must be held.
+func (gp *GRPCPool) topologyChanged() {
+	if gp.opts.OnTopologyChange == nil {
+		return
+	}
+	next := gp.peers.Clone()
+	moved := consistenthash.Diff(gp.reported, next)
+	if len(moved) == 0 {
+		return
+	}
+	change := TopologyChange{Moved: moved, prev: gp.reported, next: next}
+	for _, r := range moved {
+		change.MovedFraction += float64(r.Size()) / (1 << 32)
+	}
+	for peer := range gp.weights {
+		if !gp.ejected[peer] {
+			change.Peers = append(change.Peers, peer)
+		}
+	}
+	sort.Strings(change.Peers)
+	gp.reported = next
+	gp.topology.notify(change)
+}
+
+// topologyNotifier calls fn with the changes given to notify, in order,
+// from a goroutine of its own, so fn may call back into the pool.
+type topologyNotifier struct {
+	fn func(TopologyChange)
+
+	mu      sync.Mutex
+	queue   []TopologyChange
+	running bool
+}
+
+func (n *topologyNotifier) notify(c TopologyChange) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.queue = append(n.queue, c)
+	if !n.running {
+		n.running = true
+		go n.run()
+	}
+}
+
+func (n *topologyNotifier) run() {
+	for {
+		n.mu.Lock()
+		if len(n.queue) == 0 {
+			n.running = false
+			n.mu.Unlock()
+			return
+		}
+		c := n.queue[0]
+		n.queue = n.queue[1:]
+		n.mu.Unlock()
+		n.fn(c)
+	}
+}
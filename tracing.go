@@ -0,0 +1,79 @@
+package groupcache
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// Spans are created with the global TracerProvider and trace context is
+// carried between peers with the global TextMapPropagator, both set with
+// otel.SetTracerProvider and otel.SetTextMapPropagator. Tracing costs
+// next to nothing until a provider is installed.
+const tracerName = "github.com/adistroy/groupcache/v3"
+
+// startSpan starts a span named name for an operation on key of group.
+func startSpan(ctx context.Context, name, group, key string, opts ...trace.SpanOption) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	opts = append(opts, trace.WithAttributes(
+		attribute.String("groupcache.group", group),
+		attribute.String("groupcache.key", key),
+	))
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTrace adds the trace context of ctx to its outgoing gRPC
+// metadata.
+func injectTrace(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// extractTrace returns ctx with the trace context found in its incoming
+// gRPC metadata.
+func extractTrace(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+}
+
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) != 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,231 @@
+package groupcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/adistroy/groupcache/v3/consistenthash"
+	pb "github.com/adistroy/groupcache/v3/groupcachepb"
+)
+
+// Transport carries the requests of a Pool between peers, so peers can
+// talk over QUIC, Unix domain sockets or an in-process channel without
+// reimplementing the hash ring and peer bookkeeping of GRPCPool and
+// HTTPPool.
+type Transport interface {
+	// NewGetter returns a ProtoGetter sending requests to the peer at
+	// addr. If it is also an io.Closer, it is closed once the peer is
+	// removed from the pool.
+	NewGetter(addr string) (ProtoGetter, error)
+
+	// Serve answers the requests peers send to self with h until ctx
+	// is done or serving fails.
+	Serve(ctx context.Context, self string, h PeerHandler) error
+}
+
+// PeerHandler answers the requests received from peers. It has the
+// methods of ProtoGetter, so a Transport can decode a request, call the
+// handler and send back its response.
+type PeerHandler interface {
+	Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error
+	Remove(ctx context.Context, in *pb.GetRequest) error
+}
+
+// LocalHandler returns the PeerHandler answering requests with the
+// groups of this process, as GRPCPool and HTTPPool do. Requests for an
+// unknown group fail with ErrGroupNotFound.
+func LocalHandler() PeerHandler {
+	return localHandler{}
+}
+
+type localHandler struct{}
+
+func (localHandler) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	group := GetGroup(in.GetGroup())
+	if group == nil {
+		return fmt.Errorf("%w: [%s]", ErrGroupNotFound, in.GetGroup())
+	}
+	group.Stats.ServerRequests.Add(1)
+	var b []byte
+	sink := AllocatingByteSliceSink(&b)
+	if err := group.get(ctx, in.GetKey(), sink); err != nil {
+		return err
+	}
+	view, err := sink.view()
+	if err != nil {
+		return err
+	}
+	var expire int64
+	if !view.Expire().IsZero() {
+		expire = view.Expire().UnixNano()
+	}
+	out.Value = b
+	out.Expire = &expire
+	return nil
+}
+
+func (localHandler) Remove(ctx context.Context, in *pb.GetRequest) error {
+	group := GetGroup(in.GetGroup())
+	if group == nil {
+		return fmt.Errorf("%w: [%s]", ErrGroupNotFound, in.GetGroup())
+	}
+	group.Stats.ServerRequests.Add(1)
+	group.localRemove(in.GetKey())
+	return nil
+}
+
+// PoolOptions are the configurations of a Pool.
+type PoolOptions struct {
+	// Replicas specifies the number of key replicas on the consistent hash.
+	// If blank, it defaults to 50.
+	Replicas int
+
+	// HashFn specifies the hash function of the consistent hash.
+	// If blank, it defaults to crc32.ChecksumIEEE.
+	HashFn consistenthash.Hash
+
+	// Logger receives the log messages of the pool.
+	// If nil, the Logger set with SetLogger is used.
+	Logger Logger
+}
+
+// Pool is a PeerPicker routing keys to their owners over a Transport.
+// Unlike NewHTTPPool it registers nothing, so a process may have
+// several; pass one to a group with GroupOptions.Peers, or register it
+// with RegisterPeerPicker.
+type Pool struct {
+	self      string
+	transport Transport
+	opts      PoolOptions
+
+	mu      sync.Mutex // guards peers and getters
+	peers   *consistenthash.Map
+	getters map[string]ProtoGetter
+}
+
+// NewPool returns a Pool of peers reached with t. The self argument is
+// the address of the current peer, as given to Set by every peer.
+func NewPool(self string, t Transport, opts *PoolOptions) *Pool {
+	p := &Pool{
+		self:      self,
+		transport: t,
+		getters:   make(map[string]ProtoGetter),
+	}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Replicas == 0 {
+		p.opts.Replicas = defaultReplicas
+	}
+	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	return p
+}
+
+// Serve answers the requests of the other peers with LocalHandler until
+// ctx is done or the transport fails.
+func (p *Pool) Serve(ctx context.Context) error {
+	return p.transport.Serve(ctx, p.self, LocalHandler())
+}
+
+// Set replaces the pool's peers. Getters of peers already in the pool
+// are kept, and peers given more than once are added once.
+func (p *Pool) Set(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old := p.getters
+	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	p.getters = make(map[string]ProtoGetter, len(peers))
+	for _, peer := range peers {
+		if _, dup := p.getters[peer]; dup {
+			continue
+		}
+		if getter, ok := old[peer]; ok {
+			p.getters[peer] = getter
+			p.peers.Add(peer)
+			delete(old, peer)
+		} else {
+			p.addLocked(peer)
+		}
+	}
+	for _, getter := range old {
+		p.closeGetter(getter)
+	}
+}
+
+// AddPeers adds peers to the pool, leaving the others untouched.
+func (p *Pool) AddPeers(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, peer := range peers {
+		if _, ok := p.getters[peer]; !ok {
+			p.addLocked(peer)
+		}
+	}
+}
+
+// RemovePeers removes peers from the pool, leaving the others
+// untouched.
+func (p *Pool) RemovePeers(peers ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, peer := range peers {
+		if getter, ok := p.getters[peer]; ok {
+			delete(p.getters, peer)
+			p.peers.Remove(peer)
+			p.closeGetter(getter)
+		}
+	}
+}
+
+// addLocked creates the getter of peer and adds it to the ring. p.mu
+// must be held.
+func (p *Pool) addLocked(peer string) {
+	getter, err := p.transport.NewGetter(peer)
+	if err != nil {
+		p.log().Warn("Failed to create getter", "peer", peer, "err", err)
+		return
+	}
+	p.getters[peer] = getter
+	p.peers.Add(peer)
+}
+
+// closeGetter closes the getter of a peer removed from the pool if it
+// is an io.Closer.
+func (p *Pool) closeGetter(getter ProtoGetter) {
+	if c, ok := getter.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			p.log().Warn("Failed to close getter", "peer", getter.GetURL(), "err", err)
+		}
+	}
+}
+
+func (p *Pool) log() Logger {
+	return pickLogger(p.opts.Logger)
+}
+
+// PickPeer implements PeerPicker, returning the getter of the peer
+// owning key unless it is this one.
+func (p *Pool) PickPeer(key string) (ProtoGetter, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.peers.IsEmpty() {
+		return nil, false
+	}
+	if peer := p.peers.Get(key); peer != p.self {
+		return p.getters[peer], true
+	}
+	return nil, false
+}
+
+// GetAll returns the getters of all the peers in the pool.
+func (p *Pool) GetAll() []ProtoGetter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := make([]ProtoGetter, 0, len(p.getters))
+	for _, getter := range p.getters {
+		res = append(res, getter)
+	}
+	return res
+}
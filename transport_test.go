@@ -0,0 +1,189 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/adistroy/groupcache/v3/groupcachepb"
+)
+
+// chanTransport is a Transport between pools of the same process. It
+// counts the getters it creates and the requests each peer serves.
+type chanTransport struct {
+	mu       sync.Mutex
+	handlers map[string]PeerHandler
+	served   map[string]int
+	closed   map[string]bool
+	getters  int
+}
+
+func newChanTransport() *chanTransport {
+	return &chanTransport{
+		handlers: make(map[string]PeerHandler),
+		served:   make(map[string]int),
+		closed:   make(map[string]bool),
+	}
+}
+
+func (t *chanTransport) NewGetter(addr string) (ProtoGetter, error) {
+	t.mu.Lock()
+	t.getters++
+	t.mu.Unlock()
+	return &chanGetter{t: t, addr: addr}, nil
+}
+
+func (t *chanTransport) Serve(ctx context.Context, self string, h PeerHandler) error {
+	t.mu.Lock()
+	t.handlers[self] = h
+	t.mu.Unlock()
+	<-ctx.Done()
+	t.mu.Lock()
+	delete(t.handlers, self)
+	t.mu.Unlock()
+	return ctx.Err()
+}
+
+func (t *chanTransport) handler(addr string) (PeerHandler, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.handlers[addr]
+	if !ok {
+		return nil, errors.New("no peer at " + addr)
+	}
+	t.served[addr]++
+	return h, nil
+}
+
+type chanGetter struct {
+	t    *chanTransport
+	addr string
+}
+
+func (g *chanGetter) Get(ctx context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	h, err := g.t.handler(g.addr)
+	if err != nil {
+		return err
+	}
+	return h.Get(ctx, in, out)
+}
+
+func (g *chanGetter) Remove(ctx context.Context, in *pb.GetRequest) error {
+	h, err := g.t.handler(g.addr)
+	if err != nil {
+		return err
+	}
+	return h.Remove(ctx, in)
+}
+
+func (g *chanGetter) GetURL() string { return g.addr }
+
+func (g *chanGetter) Close() error {
+	g.t.mu.Lock()
+	g.t.closed[g.addr] = true
+	g.t.mu.Unlock()
+	return nil
+}
+
+// prefixHandler is a PeerHandler answering Get with its value + key.
+type prefixHandler string
+
+func (h prefixHandler) Get(_ context.Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	out.Value = []byte(string(h) + in.GetKey())
+	return nil
+}
+
+func (h prefixHandler) Remove(context.Context, *pb.GetRequest) error { return nil }
+
+func TestPoolTransport(t *testing.T) {
+	const groupName = "TestPoolTransport-group"
+	transport := newChanTransport()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The groups of this process are served by the current peer, so
+	// the other peer answers with a handler of its own.
+	pool := NewPool("self", transport, nil)
+	go transport.Serve(ctx, "other", prefixHandler("remote:"))
+	pool.Set("self", "other")
+	for {
+		if _, err := transport.handler("other"); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	transport.mu.Lock()
+	transport.served["other"] = 0
+	transport.mu.Unlock()
+
+	g := NewGroupOpts(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value of "+key, time.Time{})
+	}), &GroupOptions{Peers: pool})
+	defer DeregisterGroup(groupName)
+
+	var remote string
+	for i := 0; remote == ""; i++ {
+		if peer, ok := pool.PickPeer(strconv.Itoa(i)); ok && peer.GetURL() == "other" {
+			remote = strconv.Itoa(i)
+		}
+	}
+	var s string
+	if err := g.Get(ctx, remote, StringSink(&s)); err != nil || s != "remote:"+remote {
+		t.Fatalf("Get = %q, %v", s, err)
+	}
+	if n := transport.served["other"]; n != 1 {
+		t.Errorf("other served %d requests; want 1", n)
+	}
+
+	if got := len(pool.GetAll()); got != 2 {
+		t.Errorf("GetAll returned %d peers; want 2", got)
+	}
+	pool.RemovePeers("other")
+	if !transport.closed["other"] {
+		t.Error("getter of a removed peer was not closed")
+	}
+	if peer, ok := pool.PickPeer(remote); ok {
+		t.Errorf("PickPeer after RemovePeers = %s; want the current peer", peer.GetURL())
+	}
+}
+
+func TestPoolSetDuplicates(t *testing.T) {
+	transport := newChanTransport()
+	pool := NewPool("self", transport, nil)
+	pool.Set("self", "other", "other")
+	pool.Set("self", "other")
+	if n, all := transport.getters, len(pool.GetAll()); n != 2 || all != 2 {
+		t.Errorf("created %d getters for %d peers; want 2 and 2", n, all)
+	}
+	if transport.closed["other"] {
+		t.Error("getter of a kept peer was closed")
+	}
+}
+
+func TestLocalHandler(t *testing.T) {
+	const groupName = "TestLocalHandler-group"
+	g := newGroup(groupName, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("value of "+key, time.Time{})
+	}), NoPeers{})
+	defer DeregisterGroup(groupName)
+
+	group, key := groupName, "key"
+	var out pb.GetResponse
+	if err := LocalHandler().Get(context.Background(), &pb.GetRequest{Group: &group, Key: &key}, &out); err != nil || string(out.Value) != "value of key" {
+		t.Errorf("Get = %q, %v; want value of key", out.Value, err)
+	}
+	if err := LocalHandler().Remove(context.Background(), &pb.GetRequest{Group: &group, Key: &key}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.lookupCache(key); ok {
+		t.Error("key is still cached after Remove")
+	}
+
+	missing := "TestLocalHandler-missing"
+	if err := LocalHandler().Get(context.Background(), &pb.GetRequest{Group: &missing, Key: &key}, &out); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("Get from an unknown group = %v; want ErrGroupNotFound", err)
+	}
+}
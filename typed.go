@@ -0,0 +1,131 @@
+package groupcache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// A Codec converts the values of a TypedGroup to and from the bytes
+// stored in the cache.
+type Codec[T any] interface {
+	Encode(v T) ([]byte, error)
+	Decode(data []byte, v *T) error
+}
+
+// JSONCodec returns a Codec using encoding/json.
+func JSONCodec[T any]() Codec[T] { return jsonCodec[T]{} }
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Encode(v T) ([]byte, error)     { return json.Marshal(v) }
+func (jsonCodec[T]) Decode(data []byte, v *T) error { return json.Unmarshal(data, v) }
+
+// GobCodec returns a Codec using encoding/gob.
+func GobCodec[T any]() Codec[T] { return gobCodec[T]{} }
+
+type gobCodec[T any] struct{}
+
+func (gobCodec[T]) Encode(v T) ([]byte, error)     { return gobMarshal(v) }
+func (gobCodec[T]) Decode(data []byte, v *T) error { return gobUnmarshal(data, v) }
+
+// CodecError is returned by TypedGroup, CodecSink and SetValue when a
+// value could not be encoded or decoded, as opposed to failing to load.
+type CodecError struct {
+	Op  string // "encode" or "decode"
+	Key string // empty from CodecSink and SetValue
+	Err error
+}
+
+func (e *CodecError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("groupcache: failed to %s value: %v", e.Op, e.Err)
+	}
+	return fmt.Sprintf("groupcache: failed to %s [%s]: %v", e.Op, e.Key, e.Err)
+}
+
+func (e *CodecError) Unwrap() error { return e.Err }
+
+// A TypedGetter loads the typed value for a key, along with the time
+// it expires. A zero expire means the value never expires.
+type TypedGetter[T any] func(ctx context.Context, key string) (value T, expire time.Time, err error)
+
+// TypedGroup is a Group whose values are of type T.
+// Values are encoded with a Codec when they are loaded and decoded
+// again on every Get.
+type TypedGroup[T any] struct {
+	group *Group
+	codec Codec[T]
+}
+
+// NewTypedGroup creates a typed group as NewGroup does. Values are
+// loaded with getter and stored encoded with codec.
+// If codec is nil, JSONCodec is used.
+func NewTypedGroup[T any](name string, cacheBytes int64, getter TypedGetter[T], codec Codec[T]) *TypedGroup[T] {
+	return newTypedGroup(name, cacheBytes, getter, codec, nil, nil)
+}
+
+// NewTypedGroupOpts is like NewTypedGroup but configures the group
+// with o as NewGroupOpts does.
+func NewTypedGroupOpts[T any](name string, cacheBytes int64, getter TypedGetter[T], codec Codec[T], o *GroupOptions) *TypedGroup[T] {
+	var peers PeerPicker
+	if o != nil {
+		peers = o.Peers
+	}
+	return newTypedGroup(name, cacheBytes, getter, codec, peers, o)
+}
+
+// If peers is nil, the peerPicker is called via a sync.Once to initialize it.
+func newTypedGroup[T any](name string, cacheBytes int64, getter TypedGetter[T], codec Codec[T], peers PeerPicker, o *GroupOptions) *TypedGroup[T] {
+	if codec == nil {
+		codec = JSONCodec[T]()
+	}
+	tg := &TypedGroup[T]{codec: codec}
+	tg.group = newGroupOpts(name, cacheBytes, GetterFunc(func(ctx context.Context, key string, dest Sink) error {
+		v, expire, err := getter(ctx, key)
+		if err != nil {
+			return err
+		}
+		data, err := codec.Encode(v)
+		if err != nil {
+			return &CodecError{Op: "encode", Key: key, Err: err}
+		}
+		return dest.SetBytes(data, expire)
+	}), peers, o)
+	return tg
+}
+
+// Group returns the underlying Group.
+func (tg *TypedGroup[T]) Group() *Group {
+	return tg.group
+}
+
+// Get returns the value for key. Errors from decoding the cached value
+// are returned as a *CodecError; any other error is from the load.
+func (tg *TypedGroup[T]) Get(ctx context.Context, key string) (T, error) {
+	var v T
+	var view ByteView
+	if err := tg.group.Get(ctx, key, ByteViewSink(&view)); err != nil {
+		return v, err
+	}
+	if err := tg.codec.Decode(view.ByteSlice(), &v); err != nil {
+		return v, &CodecError{Op: "decode", Key: key, Err: err}
+	}
+	return v, nil
+}
+
+// Set encodes v and stores it for key as Group.Set does. Errors from
+// encoding v are returned as a *CodecError.
+func (tg *TypedGroup[T]) Set(ctx context.Context, key string, v T, expire time.Time, hotCache bool) error {
+	data, err := tg.codec.Encode(v)
+	if err != nil {
+		return &CodecError{Op: "encode", Key: key, Err: err}
+	}
+	return tg.group.Set(ctx, key, data, expire, hotCache)
+}
+
+// Remove removes key as Group.Remove does.
+func (tg *TypedGroup[T]) Remove(ctx context.Context, key string) error {
+	return tg.group.Remove(ctx, key)
+}
@@ -0,0 +1,120 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type typedUser struct {
+	Name string
+	Age  int
+}
+
+// userCodec encodes a typedUser as "name|age"; the zero value encodes
+// to no bytes at all.
+type userCodec struct{}
+
+func (userCodec) Encode(u typedUser) ([]byte, error) {
+	if u == (typedUser{}) {
+		return nil, nil
+	}
+	return []byte(u.Name + "|" + strconv.Itoa(u.Age)), nil
+}
+
+func (userCodec) Decode(data []byte, u *typedUser) error {
+	if len(data) == 0 {
+		*u = typedUser{}
+		return nil
+	}
+	parts := strings.SplitN(string(data), "|", 2)
+	if len(parts) != 2 {
+		return errors.New("missing separator")
+	}
+	age, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return err
+	}
+	*u = typedUser{Name: parts[0], Age: age}
+	return nil
+}
+
+func typedUserGetter(_ context.Context, key string) (typedUser, time.Time, error) {
+	switch key {
+	case "zero":
+		return typedUser{}, time.Time{}, nil
+	case "missing":
+		return typedUser{}, time.Time{}, errors.New("no such user")
+	}
+	return typedUser{Name: key, Age: len(key)}, time.Time{}, nil
+}
+
+func TestTypedGroup(t *testing.T) {
+	codecs := []struct {
+		name  string
+		codec Codec[typedUser]
+	}{
+		{"json", nil},
+		{"custom", userCodec{}},
+	}
+	for _, tc := range codecs {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTypedGroup("TestTypedGroup-"+tc.name, cacheSize, typedUserGetter, tc.codec, NoPeers{}, nil)
+			defer DeregisterGroup(g.Group().Name())
+			ctx := context.Background()
+
+			for _, key := range []string{"alice", "zero"} {
+				want, _, _ := typedUserGetter(ctx, key)
+				// The second Get decodes the cached value.
+				for i := 0; i < 2; i++ {
+					got, err := g.Get(ctx, key)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got != want {
+						t.Errorf("Get(%q) = %+v; want %+v", key, got, want)
+					}
+				}
+			}
+
+			_, err := g.Get(ctx, "missing")
+			var cerr *CodecError
+			if err == nil || errors.As(err, &cerr) {
+				t.Errorf("Get of a failing load error = %v; want a non-codec error", err)
+			}
+
+			bob := typedUser{Name: "bob", Age: 42}
+			if err := g.Set(ctx, "alice", bob, time.Time{}, false); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := g.Get(ctx, "alice"); err != nil || got != bob {
+				t.Errorf("Get after Set = %+v, %v; want %+v", got, err, bob)
+			}
+
+			if err := g.Remove(ctx, "alice"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestTypedGroupCodecError(t *testing.T) {
+	g := newTypedGroup[string]("TestTypedGroupCodecError", cacheSize, func(_ context.Context, key string) (string, time.Time, error) {
+		return key, time.Time{}, nil
+	}, failingCodec{}, NoPeers{}, nil)
+	defer DeregisterGroup(g.Group().Name())
+
+	_, err := g.Get(context.Background(), "key")
+	var cerr *CodecError
+	if !errors.As(err, &cerr) || cerr.Op != "encode" || cerr.Key != "key" {
+		t.Errorf("Get error = %v; want an encode *CodecError for key", err)
+	}
+}
+
+type failingCodec struct{}
+
+func (failingCodec) Encode(string) ([]byte, error) { return nil, errors.New("boom") }
+func (failingCodec) Decode([]byte, *string) error  { return errors.New("boom") }
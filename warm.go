@@ -0,0 +1,112 @@
+package groupcache
+
+import (
+	"context"
+	"sync"
+)
+
+// WarmOptions are the options of Group.Warm.
+type WarmOptions struct {
+	// Force also loads the keys owned by other peers, from those peers,
+	// which fills their caches and, subject to the HotCachePolicy, the
+	// hot cache of this process.
+	// If false, keys owned by other peers are skipped.
+	Force bool
+
+	// Progress is called with the counts so far each time a key was
+	// loaded, skipped or failed. Calls are serialized.
+	// If nil, progress is not reported.
+	Progress func(WarmProgress)
+}
+
+// WarmProgress reports how far Group.Warm got.
+type WarmProgress struct {
+	Total   int // keys passed to Warm
+	Loaded  int // keys loaded or found cached
+	Skipped int // keys owned by other peers
+	Failed  int // keys whose load failed
+}
+
+// Warm loads keys into the cache before the process takes traffic,
+// loading up to concurrency keys at a time. Keys owned by other peers
+// are skipped unless opts.Force is set, so every peer can warm the keys
+// it owns from the same list. opts may be nil.
+//
+// Warm returns once every key was handled or ctx is done, with
+// ctx.Err() in the latter case and otherwise a KeysError of the keys
+// that failed, if any. Run it in a goroutine to warm in the background.
+func (g *Group) Warm(ctx context.Context, keys []string, concurrency int, opts *WarmOptions) error {
+	g.peersOnce.Do(g.initPeers)
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx = withDefaultPriority(ctx, PriorityBulk)
+	var o WarmOptions
+	if opts != nil {
+		o = *opts
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		progress = WarmProgress{Total: len(keys)}
+		errs     = KeysError{}
+	)
+	done := func(key string, skipped bool, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case skipped:
+			progress.Skipped++
+		case err != nil:
+			progress.Failed++
+			errs[key] = err
+		default:
+			progress.Loaded++
+		}
+		if o.Progress != nil {
+			o.Progress(progress)
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+loop:
+	for _, key := range keys {
+		ekey := g.encodeKey(key)
+		if !o.Force {
+			if _, remote := g.peers.PickPeer(ekey); remote {
+				done(key, true, nil)
+				continue
+			}
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break loop
+		}
+		wg.Add(1)
+		go func(key string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			var err error
+			if _, ok := g.lookupCache(ekey); !ok {
+				_, err = g.load(ctx, ekey)
+			}
+			done(key, false, err)
+		}(key)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
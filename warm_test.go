@@ -0,0 +1,73 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarm(t *testing.T) {
+	const name = "TestWarm-group"
+	var fills int32
+	peer := &fakePeer{}
+	g := newGroup(name, 1<<20, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		atomic.AddInt32(&fills, 1)
+		if key == "key-0" {
+			return errors.New("boom")
+		}
+		return dest.SetString("value", time.Time{})
+	}), fakePeers{nil, peer})
+	defer DeregisterGroup(name)
+
+	var keys []string
+	var local int
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		keys = append(keys, key)
+		if _, remote := g.peers.PickPeer(key); !remote {
+			local++
+		}
+	}
+	if _, remote := g.peers.PickPeer("key-0"); remote || local == len(keys) {
+		t.Fatal("test keys need key-0 owned locally and some keys owned remotely")
+	}
+
+	var last WarmProgress
+	var calls int
+	err := g.Warm(dummyCtx, keys, 4, &WarmOptions{Progress: func(p WarmProgress) {
+		calls++
+		last = p
+	}})
+	var kerr KeysError
+	if !errors.As(err, &kerr) || len(kerr) != 1 || kerr["key-0"] == nil {
+		t.Errorf("Warm error = %v; want key-0 to fail", err)
+	}
+	want := WarmProgress{Total: len(keys), Loaded: local - 1, Skipped: len(keys) - local, Failed: 1}
+	if last != want || calls != len(keys) {
+		t.Errorf("last progress = %+v after %d calls; want %+v after %d", last, calls, want, len(keys))
+	}
+	if int(fills) != local || peer.hits != 0 {
+		t.Errorf("got %d fills and %d peer hits; want %d and 0", fills, peer.hits, local)
+	}
+	if items := g.mainCache.items(); items != int64(local-1) {
+		t.Errorf("main cache has %d items; want %d", items, local-1)
+	}
+
+	// Forced, the remote keys are loaded from their owner too, while
+	// the cached keys are not loaded again.
+	if err := g.Warm(dummyCtx, keys, 4, &WarmOptions{Force: true}); err == nil {
+		t.Error("forced Warm succeeded; want key-0 to fail")
+	}
+	if peer.hits != len(keys)-local || int(fills) != local+1 {
+		t.Errorf("got %d peer hits and %d fills; want %d and %d", peer.hits, fills, len(keys)-local, local+1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := g.Warm(ctx, keys, 1, &WarmOptions{Force: true}); err != context.Canceled {
+		t.Errorf("Warm with a canceled ctx = %v; want %v", err, context.Canceled)
+	}
+}
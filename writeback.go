@@ -0,0 +1,246 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// A Flusher persists the values Set on a group in write-back mode, see
+// GroupOptions.WriteBack.
+type Flusher interface {
+	// Flush persists entries, the latest value Set for each of their
+	// keys since they were last flushed. An error fails the whole
+	// batch, which is retried unless the error is marked by Permanent.
+	Flush(ctx context.Context, group string, entries []FlushEntry) error
+}
+
+// FlushFunc implements Flusher with a function.
+type FlushFunc func(ctx context.Context, group string, entries []FlushEntry) error
+
+func (f FlushFunc) Flush(ctx context.Context, group string, entries []FlushEntry) error {
+	return f(ctx, group, entries)
+}
+
+// A FlushEntry is a value to persist, as given to Group.Set. The
+// expiry given to Set is Value.Expire().
+type FlushEntry struct {
+	Key   string
+	Value ByteView
+}
+
+// WriteBackOptions configures the write-back mode of a group.
+type WriteBackOptions struct {
+	// Flusher persists the values Set on the group.
+	Flusher Flusher
+
+	// BatchSize is the largest number of entries given to one Flush.
+	// A full batch is flushed without waiting for Interval.
+	// If blank, it defaults to 100.
+	BatchSize int
+
+	// Interval is the longest a value waits to be flushed.
+	// If blank, it defaults to 1 second.
+	Interval time.Duration
+
+	// MaxPending is the number of keys that may wait to be flushed.
+	// Set fails with ErrWriteBackFull for other keys while as many
+	// wait, so a Flusher that cannot keep up throttles the writers
+	// instead of growing the queue.
+	// If blank, it defaults to 10000.
+	MaxPending int
+
+	// Retry retries failed Flush calls with backoff. The entries of a
+	// batch that fails every attempt are dropped, logged and recorded
+	// in FailedOperations with Op "flush".
+	// If nil, Flush is attempted 3 times with the RetryOptions defaults.
+	Retry *RetryOptions
+}
+
+// ErrWriteBackFull is returned by Set on a group in write-back mode
+// when WriteBackOptions.MaxPending keys are waiting to be flushed.
+var ErrWriteBackFull = errors.New("groupcache: too many writes waiting to be flushed")
+
+// writeBack queues the values Set on a group until they are flushed.
+type writeBack struct {
+	opts  WriteBackOptions
+	retry *retryPolicy
+	kick  chan struct{} // signaled when a batch is full
+
+	mu      sync.Mutex // guards pending and order
+	pending map[string]ByteView
+	order   []string // keys of pending, oldest first
+
+	flushMu sync.Mutex // serializes flushes
+}
+
+func newWriteBack(opts WriteBackOptions) *writeBack {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = 10000
+	}
+	var retry RetryOptions
+	if opts.Retry != nil {
+		retry = *opts.Retry
+	}
+	return &writeBack{
+		opts: opts,
+		retry: newRetryPolicy(retry, func(err error) bool {
+			return !IsPermanent(err)
+		}),
+		kick:    make(chan struct{}, 1),
+		pending: make(map[string]ByteView),
+	}
+}
+
+// full reports whether a value for key cannot be queued.
+func (w *writeBack) full(key string) bool {
+	if w == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.pending[key]
+	return !ok && len(w.pending) >= w.opts.MaxPending
+}
+
+// add queues value to be flushed for key, replacing a value still
+// waiting.
+func (w *writeBack) add(key string, value ByteView) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.pending[key]; !ok {
+		w.order = append(w.order, key)
+	}
+	w.pending[key] = value
+	if len(w.order) >= w.opts.BatchSize {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// take removes up to BatchSize of the oldest entries from the queue.
+func (w *writeBack) take() []FlushEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := len(w.order)
+	if n > w.opts.BatchSize {
+		n = w.opts.BatchSize
+	}
+	batch := make([]FlushEntry, n)
+	for i, key := range w.order[:n] {
+		batch[i] = FlushEntry{Key: key, Value: w.pending[key]}
+		delete(w.pending, key)
+	}
+	w.order = w.order[n:]
+	return batch
+}
+
+// requeue puts back the entries of a batch that was not flushed, unless
+// a newer value was queued for their key meanwhile.
+func (w *writeBack) requeue(batch []FlushEntry) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, e := range batch {
+		if _, ok := w.pending[e.Key]; !ok {
+			w.order = append(w.order, e.Key)
+			w.pending[e.Key] = e.Value
+		}
+	}
+}
+
+// len returns the number of keys waiting to be flushed.
+func (w *writeBack) len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.order)
+}
+
+// runWriteBack flushes the queue of the group every Interval, or as
+// soon as a batch is full, until the group is deregistered.
+func (g *Group) runWriteBack() {
+	w := g.writeBack
+	t := time.NewTicker(w.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-g.background.Done():
+			return
+		case <-t.C:
+		case <-w.kick:
+		}
+		g.FlushWriteBack(g.background)
+	}
+}
+
+// FlushWriteBack flushes every value Set on the group in write-back
+// mode that is still waiting, such as before the process exits. It
+// returns ctx.Err() if ctx is done first, leaving the remaining values
+// queued, and nil if the group is not in write-back mode. Batches that
+// fail are dropped as configured by WriteBackOptions.Retry, they do
+// not fail FlushWriteBack.
+func (g *Group) FlushWriteBack(ctx context.Context) error {
+	w := g.writeBack
+	if w == nil {
+		return nil
+	}
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch := w.take()
+		if len(batch) == 0 {
+			return nil
+		}
+		entries := batch
+		if g.opts.KeyEncoder != nil {
+			// The Flusher persists the keys given to Set.
+			entries = make([]FlushEntry, len(batch))
+			for i, e := range batch {
+				e.Key, _ = g.opts.KeyEncoder.DecodeKey(e.Key)
+				entries[i] = e
+			}
+		}
+		err := w.retry.do(ctx, func(ctx context.Context) error {
+			return w.opts.Flusher.Flush(ctx, g.name, entries)
+		})
+		if err == nil {
+			g.Stats.WriteBackFlushed.Add(int64(len(batch)))
+			continue
+		}
+		if ctx.Err() != nil {
+			w.requeue(batch)
+			return ctx.Err()
+		}
+		g.Stats.WriteBackDropped.Add(int64(len(batch)))
+		g.log().Error("write back flush failed", "group", g.name, "entries", len(batch), "err", err)
+		if g.opts.DeadLetterSize >= 0 {
+			now := time.Now()
+			for _, e := range batch {
+				g.failedOps.add(FailedOp{Op: "flush", Key: e.Key, Err: err, Time: now}, g.opts.DeadLetterSize)
+			}
+		}
+	}
+}
+
+// WriteBackPending returns the number of keys Set on the group in
+// write-back mode that wait to be flushed.
+func (g *Group) WriteBackPending() int {
+	if g.writeBack == nil {
+		return 0
+	}
+	return g.writeBack.len()
+}
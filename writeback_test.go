@@ -0,0 +1,148 @@
+package groupcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingFlusher is a Flusher recording the batches it was given.
+type recordingFlusher struct {
+	mu      sync.Mutex
+	batches [][]FlushEntry
+	err     error
+}
+
+func (f *recordingFlusher) Flush(_ context.Context, _ string, entries []FlushEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, entries)
+	return f.err
+}
+
+func (f *recordingFlusher) flushed() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	values := map[string]string{}
+	for _, batch := range f.batches {
+		for _, e := range batch {
+			values[e.Key] = e.Value.String()
+		}
+	}
+	return values
+}
+
+func TestWriteBack(t *testing.T) {
+	const name = "TestWriteBack-group"
+	flusher := &recordingFlusher{}
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("stored", time.Time{})
+	}), NoPeers{}, &GroupOptions{WriteBack: &WriteBackOptions{
+		Flusher:    flusher,
+		BatchSize:  3,
+		Interval:   time.Hour,
+		MaxPending: 3,
+	}})
+	defer DeregisterGroup(name)
+
+	set := func(key, value string) error {
+		return g.Set(dummyCtx, key, []byte(value), time.Time{}, false)
+	}
+	for _, kv := range [][2]string{{"a", "1"}, {"b", "1"}, {"a", "2"}} {
+		if err := set(kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var s string
+	if err := g.Get(dummyCtx, "a", StringSink(&s)); err != nil || s != "2" {
+		t.Errorf("Get(a) = %q, %v; want the value Set before it is flushed", s, err)
+	}
+	if n := g.WriteBackPending(); n != 2 {
+		t.Errorf("WriteBackPending = %d; want 2 keys", n)
+	}
+
+	// A full batch is flushed without waiting for the interval.
+	if err := set("c", "1"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for g.Stats.WriteBackFlushed.Get() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := flusher.flushed(); len(got) != 3 || got["a"] != "2" || got["b"] != "1" || got["c"] != "1" {
+		t.Errorf("flushed %v; want the latest values of a, b and c", got)
+	}
+
+	// Keys beyond MaxPending are refused until the queue is flushed.
+	flusher.mu.Lock()
+	flusher.batches = nil
+	flusher.mu.Unlock()
+	g.writeBack.mu.Lock()
+	g.writeBack.opts.BatchSize = 10
+	g.writeBack.mu.Unlock()
+	for _, key := range []string{"d", "e", "f"} {
+		if err := set(key, "1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := set("g", "1"); !errors.Is(err, ErrWriteBackFull) {
+		t.Errorf("Set beyond MaxPending = %v; want %v", err, ErrWriteBackFull)
+	}
+	if _, ok := g.Peek("g"); ok {
+		t.Error("Set refused with ErrWriteBackFull cached its value")
+	}
+	if err := set("d", "2"); err != nil {
+		t.Errorf("Set of a pending key = %v; want nil", err)
+	}
+	if err := g.FlushWriteBack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := flusher.flushed(); len(got) != 3 || got["d"] != "2" {
+		t.Errorf("FlushWriteBack flushed %v; want d, e and f", got)
+	}
+	if err := set("g", "1"); err != nil {
+		t.Errorf("Set after FlushWriteBack = %v; want nil", err)
+	}
+}
+
+func TestWriteBackFailure(t *testing.T) {
+	const name = "TestWriteBackFailure-group"
+	flusher := &recordingFlusher{err: Permanent(errors.New("store is read-only"))}
+	g := newGroupOpts(name, cacheSize, GetterFunc(func(_ context.Context, key string, dest Sink) error {
+		return dest.SetString("stored", time.Time{})
+	}), NoPeers{}, &GroupOptions{
+		Logger:    nopLogger{},
+		WriteBack: &WriteBackOptions{Flusher: flusher, Interval: time.Hour},
+	})
+	defer DeregisterGroup(name)
+
+	if err := g.Set(dummyCtx, "key", []byte("value"), time.Time{}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.FlushWriteBack(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(flusher.batches); n != 1 {
+		t.Errorf("Flush called %d times for a permanent error; want once", n)
+	}
+	if n := g.Stats.WriteBackDropped.Get(); n != 1 || g.WriteBackPending() != 0 {
+		t.Errorf("WriteBackDropped = %d with %d pending; want 1 and none", n, g.WriteBackPending())
+	}
+	ops := g.FailedOperations()
+	if len(ops) != 1 || ops[0].Op != "flush" || ops[0].Key != "key" {
+		t.Errorf("FailedOperations = %+v; want the flush of key", ops)
+	}
+
+	// Values queued when the context is done stay queued.
+	flusher.mu.Lock()
+	flusher.err = nil
+	flusher.mu.Unlock()
+	g.Set(dummyCtx, "other", []byte("value"), time.Time{}, false)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := g.FlushWriteBack(ctx); !errors.Is(err, context.Canceled) || g.WriteBackPending() != 1 {
+		t.Errorf("FlushWriteBack with a done context = %v with %d pending; want %v and 1", err, g.WriteBackPending(), context.Canceled)
+	}
+}